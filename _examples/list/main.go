@@ -0,0 +1,32 @@
+// Command list pages through the grayscale pictures with the Go client:
+//
+//	IMAGENEXUS_URL=http://localhost:8000 go run ./_examples/list
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"imagenexus/client"
+)
+
+func main() {
+	c := client.NewClient(os.Getenv("IMAGENEXUS_URL"), nil)
+
+	grayscale := true
+	for page := 1; ; page++ {
+		pictures, err := c.List(context.Background(), client.ListOptions{Page: page, Grayscale: &grayscale})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if len(pictures) == 0 {
+			return
+		}
+
+		for _, picture := range pictures {
+			fmt.Printf("%d\t%s\t%s\n", picture.Id, picture.Name, picture.ContentType)
+		}
+	}
+}
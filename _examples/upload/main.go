@@ -0,0 +1,56 @@
+// Command upload uploads an image with the Go client, downloads it back and
+// deletes it:
+//
+//	IMAGENEXUS_URL=http://localhost:8000 go run ./_examples/upload cat.png
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"imagenexus/client"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalln("usage: upload <file>")
+	}
+
+	c := client.NewClient(os.Getenv("IMAGENEXUS_URL"), &client.ClientOptions{
+		Token:      os.Getenv("IMAGENEXUS_TOKEN"),
+		MaxRetries: 5,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	file, err := os.Open(os.Args[1])
+	if err != nil {
+		log.Fatalln(err)
+	}
+	defer file.Close()
+
+	picture, err := c.Upload(ctx, file, filepath.Base(os.Args[1]))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("uploaded %s as picture %d, %dx%d\n", picture.Name, picture.Id, picture.Width, picture.Height)
+
+	id := strconv.FormatUint(uint64(picture.Id), 10)
+	data, contentType, err := c.GetImageBytes(ctx, id)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Printf("downloaded %d bytes of %s\n", len(data), contentType)
+
+	if err := c.Delete(ctx, id); err != nil {
+		log.Fatalln(err)
+	}
+	if _, err := c.Get(ctx, id); client.IsNotFound(err) {
+		fmt.Println("deleted")
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"imagenexus/api/restutil"
+	"imagenexus/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const claimsKey = "claims"
+
+type Claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+// Authenticate verifies the HS256 bearer token of the request, if there is
+// one, against server.auth.jwtSecret and stores its claims in the context.
+// Requests without a token continue anonymously.
+func Authenticate() gin.HandlerFunc {
+	secret := []byte(config.GetConfigValue("server.auth.jwtSecret"))
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			restutil.WriteError(c, http.StatusUnauthorized, errors.New("authorization header must be a bearer token"), nil)
+			c.Abort()
+			return
+		}
+
+		claims := &Claims{}
+		_, err := jwt.ParseWithClaims(token, claims, func(*jwt.Token) (any, error) {
+			if len(secret) == 0 {
+				return nil, errors.New("no jwt secret configured")
+			}
+			return secret, nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+		if err != nil {
+			restutil.WriteError(c, http.StatusUnauthorized, err, nil)
+			c.Abort()
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// RequireRole rejects requests that are not authenticated with the given
+// role claim.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := GetClaims(c)
+		if claims == nil {
+			restutil.WriteError(c, http.StatusUnauthorized, errors.New("authentication required"), nil)
+			c.Abort()
+			return
+		}
+
+		if claims.Role != role {
+			restutil.WriteError(c, http.StatusForbidden, errors.New("insufficient role"), gin.H{"required_role": role})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// GetClaims returns the claims of the authenticated request, or nil.
+func GetClaims(c *gin.Context) *Claims {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil
+	}
+	claims, _ := value.(*Claims)
+	return claims
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viper.Set("server.auth.jwtSecret", "test-secret")
+	defer viper.Set("server.auth.jwtSecret", "")
+
+	router := gin.New()
+	router.Use(Authenticate())
+	router.GET("/admin", RequireRole("admin"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	sign := func(role, secret string) string {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Role: role}).SignedString([]byte(secret))
+		return "Bearer " + token
+	}
+
+	cases := []struct {
+		authorization string
+		statusCode    int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{sign("admin", "wrong-secret"), http.StatusUnauthorized},
+		{sign("viewer", "test-secret"), http.StatusForbidden},
+		{sign("admin", "test-secret"), http.StatusOK},
+	}
+
+	for _, each := range cases {
+		request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		if each.authorization != "" {
+			request.Header.Set("Authorization", each.authorization)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, each.statusCode, recorder.Code, each.authorization)
+	}
+}
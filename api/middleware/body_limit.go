@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"imagenexus/api/restutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrBodyTooLarge is the error of the requests rejected by BodyLimit.
+var ErrBodyTooLarge = errors.New("the request body is too large")
+
+// BodyLimit caps the size of every request body at maxBytes, 0 for no limit,
+// before the handlers parse it. Unlike router.MaxMultipartMemory, which only
+// bounds the part of the multipart forms kept in memory, it bounds the whole
+// body. The requests announcing a larger Content-Length are rejected with a
+// 413 right away, the others fail reading past the limit, see
+// IsBodyTooLarge.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > maxBytes {
+			restutil.WriteError(c, http.StatusRequestEntityTooLarge, ErrBodyTooLarge, gin.H{"max_size": maxBytes})
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+		c.Next()
+	}
+}
+
+// IsBodyTooLarge tells whether reading the request body failed on the limit
+// of BodyLimit or LimitBody, returning the limit.
+func IsBodyTooLarge(err error) (int64, bool) {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return maxBytesError.Limit, true
+	}
+	return 0, false
+}
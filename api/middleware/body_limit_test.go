@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(BodyLimit(512))
+	router.POST("/", func(c *gin.Context) {
+		_, err := c.FormFile("image")
+		if limit, tooLarge := IsBodyTooLarge(err); tooLarge {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"max_size": limit})
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	multipartBody := func(size int) (io.Reader, string) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("image", "cat.png")
+		part.Write(bytes.Repeat([]byte("a"), size))
+		writer.Close()
+		return &body, writer.FormDataContentType()
+	}
+
+	t.Run("rejects the announced large bodies", func(t *testing.T) {
+		body, contentType := multipartBody(1024)
+		request := httptest.NewRequest(http.MethodPost, "/", body)
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		var problem map[string]any
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+		assert.Equal(t, float64(512), problem["max_size"])
+		assert.Equal(t, ErrBodyTooLarge.Error(), problem["detail"])
+	})
+
+	t.Run("stops reading the chunked bodies at the limit", func(t *testing.T) {
+		body, contentType := multipartBody(1024)
+		request := httptest.NewRequest(http.MethodPost, "/", body)
+		request.ContentLength = -1
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	})
+
+	t.Run("accepts the small bodies", func(t *testing.T) {
+		body, contentType := multipartBody(0)
+		request := httptest.NewRequest(http.MethodPost, "/", body)
+		request.Header.Set("Content-Type", contentType)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+	})
+}
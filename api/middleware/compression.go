@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+// The content codings of Compression, by order of preference.
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// COMPRESSIBLE_CONTENT_TYPES are the media types of the responses worth
+// compressing, on top of the +json and +xml ones. The images and the videos
+// are compressed already.
+var COMPRESSIBLE_CONTENT_TYPES = map[string]bool{
+	"application/json": true,
+	"application/xml":  true,
+	"text/xml":         true,
+	"text/plain":       true,
+	"text/html":        true,
+	"text/css":         true,
+	"text/javascript":  true,
+}
+
+// Compression compresses the JSON and XML responses of at least minBytes
+// with Brotli or gzip, whichever the Accept-Encoding header prefers, Brotli
+// on a tie. The smaller responses are sent as such, the compression costing
+// more than it saves.
+func Compression(minBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minBytes: minBytes}
+		c.Writer = writer
+		defer func() {
+			writer.finish()
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns the content coding of Compression with the
+// highest q value of the header, none when it accepts neither.
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQuality := "", 0.0
+	for _, eachCoding := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(eachCoding, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != EncodingBrotli && coding != EncodingGzip {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > bestQuality || (quality == bestQuality && coding == EncodingBrotli) {
+			best, bestQuality = coding, quality
+		}
+	}
+	return best
+}
+
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return COMPRESSIBLE_CONTENT_TYPES[mediaType] || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// compressWriter holds the start of the compressible responses back until
+// they reach minBytes, then compresses them. The other responses, and the
+// ones already encoded, e.g. the images, go through untouched.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minBytes int
+
+	decided    bool
+	skipped    bool
+	buffer     bytes.Buffer
+	compressor io.WriteCloser
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decide()
+	}
+	if w.compressor != nil {
+		return w.compressor.Write(data)
+	}
+	if !w.buffering() {
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buffer.Write(data)
+	if w.buffer.Len() >= w.minBytes {
+		if err := w.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is called for the responses without a body, which are sent
+// as such.
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		w.decided, w.skipped = true, true
+	}
+	if w.skipped {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Written() bool {
+	return w.ResponseWriter.Written() || w.buffer.Len() > 0
+}
+
+func (w *compressWriter) Flush() {
+	if w.buffering() {
+		if err := w.startCompression(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.compressor.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide whether to compress the response once its headers are set, on its
+// first write.
+func (w *compressWriter) decide() {
+	w.decided = true
+	header := w.Header()
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || status == http.StatusNoContent || status == http.StatusNotModified ||
+		!isCompressible(header.Get("Content-Type")) {
+		w.skipped = true
+	}
+}
+
+func (w *compressWriter) buffering() bool {
+	return w.decided && !w.skipped && w.compressor == nil
+}
+
+func (w *compressWriter) startCompression() error {
+	header := w.Header()
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+
+	switch w.encoding {
+	case EncodingBrotli:
+		w.compressor = brotli.NewWriter(w.ResponseWriter)
+	default:
+		w.compressor = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	_, err := w.compressor.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// finish sends the response held back, compressed or not.
+func (w *compressWriter) finish() {
+	if w.compressor != nil {
+		w.compressor.Close()
+		return
+	}
+	if w.buffer.Len() > 0 {
+		w.ResponseWriter.Write(w.buffer.Bytes())
+		w.buffer.Reset()
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "", negotiateEncoding(""))
+	assert.Equal(t, "", negotiateEncoding("deflate, identity"))
+	assert.Equal(t, EncodingGzip, negotiateEncoding("gzip"))
+	assert.Equal(t, EncodingBrotli, negotiateEncoding("gzip, deflate, br"))
+	assert.Equal(t, EncodingGzip, negotiateEncoding("br;q=0.5, gzip"))
+	assert.Equal(t, EncodingGzip, negotiateEncoding("br;q=0, gzip;q=0.1"))
+	assert.Equal(t, "", negotiateEncoding("gzip;q=0"))
+}
+
+func TestCompression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	large := strings.Repeat("cat ", 512)
+	router := gin.New()
+	router.Use(Compression(1024))
+	router.GET("/large", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"name": large}) })
+	router.GET("/small", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"name": "cat"}) })
+	router.GET("/image", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	router.GET("/empty", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"":             func(r io.Reader) (io.Reader, error) { return r, nil },
+		EncodingGzip:   func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		EncodingBrotli: func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+	}
+
+	cases := []struct {
+		path           string
+		acceptEncoding string
+		statusCode     int
+		encoding       string
+	}{
+		{"/large", "gzip", http.StatusOK, EncodingGzip},
+		{"/large", "gzip, br", http.StatusOK, EncodingBrotli},
+		{"/large", "", http.StatusOK, ""},
+		{"/small", "gzip", http.StatusOK, ""},
+		{"/image", "gzip", http.StatusOK, ""},
+		{"/empty", "gzip", http.StatusNoContent, ""},
+	}
+
+	for _, each := range cases {
+		request := httptest.NewRequest(http.MethodGet, each.path, nil)
+		if each.acceptEncoding != "" {
+			request.Header.Set("Accept-Encoding", each.acceptEncoding)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		name := each.path + " " + each.acceptEncoding
+		assert.Equal(t, each.statusCode, recorder.Code, name)
+		assert.Equal(t, each.encoding, recorder.Header().Get("Content-Encoding"), name)
+		assert.Contains(t, recorder.Header().Values("Vary"), "Accept-Encoding", name)
+		if each.statusCode == http.StatusNoContent {
+			continue
+		}
+
+		reader, err := decoders[each.encoding](recorder.Body)
+		if assert.Nil(t, err, name) {
+			body, err := io.ReadAll(reader)
+			assert.Nil(t, err, name)
+			assert.True(t, bytes.Contains(body, []byte("cat")), name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"slices"
+
+	"imagenexus/api/restutil"
+	"imagenexus/encoding"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentNegotiation picks the format of the responses and problems from
+// the Accept header: JSON, XML or MessagePack, see encoding.Negotiate. The
+// requests accepting none of them are answered in JSON. The images and the
+// documents with a format of their own, e.g. IIIF info.json, are unchanged.
+func ContentNegotiation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		varyAccept(c)
+		c.Set(restutil.EncoderKey, encoding.Negotiate(c.GetHeader("Accept")))
+		c.Next()
+	}
+}
+
+// varyAccept adds the Accept header to Vary, once for the middlewares which
+// depend on it.
+func varyAccept(c *gin.Context) {
+	if !slices.Contains(c.Writer.Header().Values("Vary"), "Accept") {
+		c.Writer.Header().Add("Vary", "Accept")
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"imagenexus/api/restutil"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(ContentNegotiation(), APIVersion())
+	router.GET("/", func(c *gin.Context) { restutil.WriteSuccess(c, http.StatusOK, gin.H{"id": 1}, nil) })
+	router.GET("/error", func(c *gin.Context) {
+		restutil.WriteError(c, http.StatusNotFound, errors.New("picture not found"), nil)
+	})
+
+	cases := []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/", "", "application/json; charset=utf-8", `"id":1`},
+		{"/", "application/xml", "application/xml; charset=utf-8", `<id>1</id>`},
+		{"/", "application/msgpack", "application/msgpack", ""},
+		{"/error", "application/xml", "application/problem+xml", `<problem xmlns="urn:ietf:rfc:7807">`},
+		{"/error", "text/html", restutil.ProblemContentType, `"status":404`},
+	}
+
+	for _, each := range cases {
+		request := httptest.NewRequest(http.MethodGet, each.path, nil)
+		if each.accept != "" {
+			request.Header.Set("Accept", each.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, each.contentType, recorder.Header().Get("Content-Type"), each.accept)
+		assert.True(t, strings.Contains(recorder.Body.String(), each.body), recorder.Body.String())
+		assert.Equal(t, []string{"Accept"}, recorder.Header().Values("Vary"))
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IdempotencyKeyHeader is the header of the key naming the retries of the
+// same request, see Idempotency.
+const IdempotencyKeyHeader = "X-Idempotency-Key"
+
+// IdempotentReplayedHeader is set to true on the replayed responses.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyStore keeps the responses of the requests by idempotency key
+// and user, see service.IdempotencyService.
+type IdempotencyStore interface {
+	Begin(key, userId string) (*dto.IdempotentResponse, bool, error)
+	Complete(key, userId string, response *dto.IdempotentResponse) error
+	Release(key, userId string) error
+}
+
+// Idempotency replays the response of the first request sent with the same
+// X-Idempotency-Key header, by the same user, instead of handling the request
+// again. The retries sent while the first request is handled get a 409, and
+// the requests reusing the key with another method, route or body a 422. The
+// keys of the anonymous requests are shared by every anonymous client. The
+// 5xx responses aren't kept, so the request can be retried. Requests without
+// the header are handled as usual.
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			restutil.WriteErrors(c, http.StatusBadRequest, errors.New("the idempotency key is longer than 255 characters"))
+			c.Abort()
+			return
+		}
+
+		userId := ""
+		if claims := GetClaims(c); claims != nil {
+			userId = claims.Subject
+		}
+
+		replayed, inProgress, err := store.Begin(key, userId)
+		if inProgress {
+			restutil.WriteErrors(c, http.StatusConflict, errors.New("a request with the same idempotency key is in progress"))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			restutil.WriteErrors(c, http.StatusInternalServerError, err)
+			c.Abort()
+			return
+		}
+		if replayed != nil {
+			bodyHash := newHashingBody(c.Request.Body).Sum()
+			if replayed.Method != c.Request.Method || replayed.Route != c.FullPath() || replayed.BodyHash != bodyHash {
+				restutil.WriteErrors(c, http.StatusUnprocessableEntity, errors.New("the idempotency key was used by another request"))
+				c.Abort()
+				return
+			}
+
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(replayed.StatusCode, replayed.ContentType, replayed.Body)
+			c.Abort()
+			return
+		}
+
+		// the body is hashed as the handler reads it, the rest of it once the
+		// handler is done
+		body := newHashingBody(c.Request.Body)
+		c.Request.Body = body
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := store.Release(key, userId); err != nil {
+				log.Printf("Unable to release the idempotency key %q: %v", key, err)
+			}
+			return
+		}
+
+		response := &dto.IdempotentResponse{
+			StatusCode:  writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+			Method:      c.Request.Method,
+			Route:       c.FullPath(),
+			BodyHash:    body.Sum(),
+		}
+		if err := store.Complete(key, userId, response); err != nil {
+			log.Printf("Unable to save the response of the idempotency key %q: %v", key, err)
+		}
+	}
+}
+
+// hashingBody is the body of a request hashed while it's read.
+type hashingBody struct {
+	io.ReadCloser
+	hasher hash.Hash
+}
+
+func newHashingBody(body io.ReadCloser) *hashingBody {
+	return &hashingBody{ReadCloser: body, hasher: sha256.New()}
+}
+
+func (b *hashingBody) Read(data []byte) (int, error) {
+	n, err := b.ReadCloser.Read(data)
+	b.hasher.Write(data[:n])
+	return n, err
+}
+
+// Sum reads the rest of the body, returning the hex SHA-256 of the whole
+// body. The bodies failing to be read, e.g. past the limit of BodyLimit, are
+// hashed up to the failure.
+func (b *hashingBody) Sum() string {
+	io.Copy(io.Discard, b)
+	return hex.EncodeToString(b.hasher.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the body written to the response.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
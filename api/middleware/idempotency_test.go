@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryIdempotencyStore keeps the responses in memory, a nil response while
+// the first request is handled.
+type memoryIdempotencyStore map[string]*dto.IdempotentResponse
+
+func (s memoryIdempotencyStore) Begin(key, userId string) (*dto.IdempotentResponse, bool, error) {
+	response, ok := s[userId+"/"+key]
+	if !ok {
+		s[userId+"/"+key] = nil
+		return nil, false, nil
+	}
+	return response, response == nil, nil
+}
+
+func (s memoryIdempotencyStore) Complete(key, userId string, response *dto.IdempotentResponse) error {
+	s[userId+"/"+key] = response
+	return nil
+}
+
+func (s memoryIdempotencyStore) Release(key, userId string) error {
+	delete(s, userId+"/"+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memoryIdempotencyStore{}
+	calls := 0
+	router := gin.New()
+	router.POST("/", Idempotency(store), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+	router.POST("/fail", Idempotency(store), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusInternalServerError)
+	})
+	router.POST("/batch", Idempotency(store), LimitBody(func() int64 { return 1 }), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusMultiStatus, gin.H{"data": []gin.H{{"status": http.StatusCreated}, {"status": http.StatusInternalServerError}}})
+	})
+	router.PUT("/", Idempotency(store), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/upload", Idempotency(store), func(c *gin.Context) {
+		calls++
+		// the handler reads part of the body only
+		prefix := make([]byte, 2)
+		io.ReadFull(c.Request.Body, prefix)
+		c.JSON(http.StatusCreated, gin.H{"prefix": string(prefix)})
+	})
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			request.Header.Set(IdempotencyKeyHeader, key)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	post := func(path, key string) *httptest.ResponseRecorder {
+		return send(http.MethodPost, path, key, "")
+	}
+
+	t.Run("replays the first response", func(t *testing.T) {
+		first := post("/", "abc")
+		second := post("/", "abc")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+		assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("handles the requests without a key", func(t *testing.T) {
+		calls = 0
+		post("/", "")
+		post("/", "")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("rejects the retries in progress", func(t *testing.T) {
+		store["/pending"] = nil
+		assert.Equal(t, http.StatusConflict, post("/", "pending").Code)
+	})
+
+	t.Run("releases the key of the failed requests", func(t *testing.T) {
+		calls = 0
+		assert.Equal(t, http.StatusInternalServerError, post("/fail", "failed").Code)
+		assert.Equal(t, http.StatusInternalServerError, post("/fail", "failed").Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("replays the partial batches", func(t *testing.T) {
+		calls = 0
+		first := post("/batch", "batch")
+		second := post("/batch", "batch")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusMultiStatus, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("replays the retries with the same body", func(t *testing.T) {
+		calls = 0
+		first := send(http.MethodPost, "/upload", "upload", "picture")
+		second := send(http.MethodPost, "/upload", "upload", "picture")
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("rejects the key reused with another body", func(t *testing.T) {
+		calls = 0
+		assert.Equal(t, http.StatusCreated, send(http.MethodPost, "/upload", "body", "picture").Code)
+		// the same first bytes, which is all the handler read
+		assert.Equal(t, http.StatusUnprocessableEntity, send(http.MethodPost, "/upload", "body", "pixels").Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("rejects the key reused on another route", func(t *testing.T) {
+		calls = 0
+		assert.Equal(t, http.StatusCreated, post("/", "route").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, post("/batch", "route").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, send(http.MethodPut, "/", "route", "").Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("rejects the long keys", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, post("/", strings.Repeat("k", 256)).Code)
+	})
+}
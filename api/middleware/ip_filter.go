@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"imagenexus/api/restutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IPFilter rejects the requests of the clients outside of the allowed
+// ranges, when there are some, with a 403, and of the clients in the blocked
+// ranges with a 429. The client is given by c.ClientIP, from the
+// X-Forwarded-For header of the requests sent by the trusted proxies of the
+// engine, see gin.Engine.SetTrustedProxies.
+func IPFilter(allowed []net.IPNet, blocked []net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(allowed) == 0 && len(blocked) == 0 {
+			c.Next()
+			return
+		}
+
+		ip := net.ParseIP(c.ClientIP())
+		if len(allowed) > 0 && !containsIP(allowed, ip) {
+			restutil.WriteErrors(c, http.StatusForbidden, errors.New("the address of the client is not allowed"))
+			c.Abort()
+			return
+		}
+		if containsIP(blocked, ip) {
+			restutil.WriteErrors(c, http.StatusTooManyRequests, errors.New("the address of the client is blocked"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func containsIP(ranges []net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, each := range ranges {
+		if each.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseIPRanges parses the CIDR ranges of server.ipAllowlist and
+// server.ipBlocklist. A single address stands for a range of its own, e.g.
+// 10.0.0.1 for 10.0.0.1/32.
+func ParseIPRanges(ranges []string) ([]net.IPNet, error) {
+	parsed := make([]net.IPNet, 0, len(ranges))
+	for _, each := range ranges {
+		if !strings.Contains(each, "/") {
+			ip := net.ParseIP(each)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", each)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			parsed = append(parsed, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(each)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP range %q: %w", each, err)
+		}
+		parsed = append(parsed, *network)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIPRanges(t *testing.T) {
+	ranges, err := ParseIPRanges([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	if assert.Nil(t, err) && assert.Len(t, ranges, 3) {
+		assert.Equal(t, "10.0.0.0/8", ranges[0].String())
+		assert.Equal(t, "192.168.1.1/32", ranges[1].String())
+		assert.Equal(t, "::1/128", ranges[2].String())
+	}
+
+	_, err = ParseIPRanges([]string{"10.0.0.0/33"})
+	assert.NotNil(t, err)
+	_, err = ParseIPRanges([]string{"localhost"})
+	assert.NotNil(t, err)
+}
+
+func TestIPFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	allowed, _ := ParseIPRanges([]string{"10.0.0.0/8"})
+	blocked, _ := ParseIPRanges([]string{"10.0.0.66"})
+	router := gin.New()
+	assert.Nil(t, router.SetTrustedProxies([]string{"127.0.0.1"}))
+	router.Use(IPFilter(allowed, blocked))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+	}{
+		{"10.1.2.3:1234", "", http.StatusOK},
+		{"192.168.1.1:1234", "", http.StatusForbidden},
+		{"10.0.0.66:1234", "", http.StatusTooManyRequests},
+		// trusted proxy
+		{"127.0.0.1:1234", "10.1.2.3", http.StatusOK},
+		{"127.0.0.1:1234", "10.0.0.66", http.StatusTooManyRequests},
+		// the header of the untrusted clients is ignored
+		{"192.168.1.1:1234", "10.1.2.3", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			request.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, test.expectedStatus, recorder.Code, test.remoteAddr+" "+test.forwardedFor)
+	}
+}
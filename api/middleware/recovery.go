@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"syscall"
+
+	"imagenexus/api/restutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery logs the panics of the handlers with slog, along with their stack
+// trace and request, answering them with a 500 problem. The stack trace is
+// sent in the stack_trace member of the problem in the debug mode of gin
+// only, never in the release mode.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+
+			stack := string(debug.Stack())
+			slog.Error("Recovered from a panic",
+				"panic_value", value,
+				"stack_trace", stack,
+				"request_id", GetRequestId(c),
+				"path", c.Request.URL.Path,
+				"method", c.Request.Method,
+			)
+
+			// the client is gone, there is no one to answer
+			if err, ok := value.(error); ok && (errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET)) {
+				c.Abort()
+				return
+			}
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+
+			err := errors.New("internal server error")
+			if gin.IsDebugging() {
+				err = restutil.WithMeta(err, gin.H{"stack_trace": stack})
+			}
+			restutil.WriteErrors(c, http.StatusInternalServerError, err)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
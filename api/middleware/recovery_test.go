@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	router := gin.New()
+	router.Use(RequestID(), Recovery())
+	router.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+	serve := func() map[string]any {
+		request := httptest.NewRequest(http.MethodGet, "/panic", nil)
+		request.Header.Set(RequestIdHeader, "abc")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+		var problem map[string]any
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+		return problem
+	}
+
+	t.Run("logs the panic", func(t *testing.T) {
+		gin.SetMode(gin.ReleaseMode)
+		t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+		logs.Reset()
+
+		problem := serve()
+		assert.Equal(t, "internal server error", problem["detail"])
+		assert.Equal(t, "abc", problem["request_id"])
+		assert.NotContains(t, problem, "stack_trace")
+
+		var entry map[string]any
+		assert.Nil(t, json.Unmarshal(logs.Bytes(), &entry))
+		assert.Equal(t, "boom", entry["panic_value"])
+		assert.Equal(t, "abc", entry["request_id"])
+		assert.Equal(t, "/panic", entry["path"])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Contains(t, entry["stack_trace"], "runtime/debug.Stack")
+	})
+
+	t.Run("sends the stack trace in debug mode", func(t *testing.T) {
+		gin.SetMode(gin.DebugMode)
+		t.Cleanup(func() { gin.SetMode(gin.TestMode) })
+
+		assert.Contains(t, serve()["stack_trace"], "runtime/debug.Stack")
+	})
+}
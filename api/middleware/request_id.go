@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"imagenexus/api/restutil"
+	"imagenexus/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// RequestID keeps the X-Request-ID header of the request, or generates one,
+// and echoes it in the response for the clients to quote.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestId := c.GetHeader(RequestIdHeader)
+		if requestId == "" || len(requestId) > 128 {
+			requestId = utils.NewUniqueString()
+		}
+
+		c.Set(restutil.RequestIdKey, requestId)
+		c.Header(RequestIdHeader, requestId)
+		c.Next()
+	}
+}
+
+// GetRequestId returns the id set by RequestID, or an empty string.
+func GetRequestId(c *gin.Context) string {
+	return c.GetString(restutil.RequestIdKey)
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID(), APIVersion())
+	router.GET("/ok", func(c *gin.Context) { restutil.WriteSuccess(c, http.StatusOK, "ok", nil) })
+	router.GET("/fail", func(c *gin.Context) {
+		restutil.WriteErrors(c, http.StatusBadRequest, errors.New("first"), restutil.WithMeta(errors.New("second"), gin.H{"id": 1}))
+	})
+
+	t.Run("keeps the request id", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		request.Header.Set(RequestIdHeader, "abc")
+		request.Header.Set("Accept", "application/vnd.imagenexus.v2+json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		var response dto.Response
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.Equal(t, "abc", recorder.Header().Get(RequestIdHeader))
+		assert.Equal(t, "ok", response.Data)
+		assert.Equal(t, &dto.ResponseMeta{RequestId: "abc", Version: APIVersion2}, response.Meta)
+	})
+
+	t.Run("generates a request id", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+		var problem map[string]any
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+		assert.NotEmpty(t, recorder.Header().Get(RequestIdHeader))
+		assert.Equal(t, recorder.Header().Get(RequestIdHeader), problem["request_id"])
+		assert.Equal(t, "first; second", problem["detail"])
+	})
+}
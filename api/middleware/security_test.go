@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentSecurityPolicy(t *testing.T) {
+	assert.Equal(t, defaultContentSecurityPolicy, contentSecurityPolicy(nil))
+	assert.Equal(t, "default-src 'self'; img-src 'self' data:", contentSecurityPolicy(map[string]string{
+		"img-src":     "'self' data:",
+		"default-src": "'self'",
+	}))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecurityHeaders())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, defaultContentSecurityPolicy, recorder.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "SAMEORIGIN", recorder.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", recorder.Header().Get("Referrer-Policy"))
+}
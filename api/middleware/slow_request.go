@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SlowRequestLogger logs a warning with slog for the requests taking longer
+// than the threshold, 0 to log none. Unlike the access log, it only reports
+// the slow requests, to spot the regressions of the handlers and storages.
+func SlowRequestLogger(threshold time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if threshold <= 0 {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		if latency <= threshold {
+			return
+		}
+
+		slog.Warn("Slow request",
+			"path", c.Request.URL.Path,
+			"method", c.Request.Method,
+			"status", c.Writer.Status(),
+			"latency_ms", latency.Milliseconds(),
+			"request_id", GetRequestId(c),
+		)
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowRequestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	router := gin.New()
+	router.Use(RequestID(), SlowRequestLogger(20*time.Millisecond))
+	router.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/slow", func(c *gin.Context) {
+		time.Sleep(30 * time.Millisecond)
+		c.Status(http.StatusOK)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Empty(t, logs.String())
+
+	request := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	request.Header.Set(RequestIdHeader, "abc")
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	var entry map[string]any
+	if assert.Nil(t, json.Unmarshal(logs.Bytes(), &entry)) {
+		assert.Equal(t, "WARN", entry["level"])
+		assert.Equal(t, "/slow", entry["path"])
+		assert.Equal(t, http.MethodGet, entry["method"])
+		assert.Equal(t, "abc", entry["request_id"])
+		assert.GreaterOrEqual(t, entry["latency_ms"], float64(30))
+	}
+}
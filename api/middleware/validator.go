@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+const requestKey = "request"
+
+var validate = newValidate()
+
+// newValidate reports the fields of the failing validations by their JSON
+// names.
+func newValidate() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// Validator binds the JSON body of the request into a new T and checks it
+// against the validate tags of T, storing it for GetRequest. Requests failing
+// the checks are rejected with a 422 validation problem listing the failing fields.
+func Validator[T any]() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := new(T)
+		if err := c.ShouldBindJSON(request); err != nil {
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				restutil.WriteError(c, http.StatusRequestEntityTooLarge, errors.New("the request body is too large"), gin.H{"max_size": maxBytesError.Limit})
+			} else {
+				restutil.WriteError(c, http.StatusBadRequest, err, nil)
+			}
+			c.Abort()
+			return
+		}
+
+		if err := validate.Struct(request); err != nil {
+			var validationErrors validator.ValidationErrors
+			if !errors.As(err, &validationErrors) {
+				restutil.WriteError(c, http.StatusInternalServerError, err, nil)
+				c.Abort()
+				return
+			}
+
+			restutil.WriteProblem(c, restutil.NewValidationProblem(fieldErrors(validationErrors)))
+			c.Abort()
+			return
+		}
+
+		c.Set(requestKey, request)
+		c.Next()
+	}
+}
+
+func fieldErrors(validationErrors validator.ValidationErrors) []*dto.ResponseError {
+	fields := make([]*dto.ResponseError, 0, len(validationErrors))
+	for _, each := range validationErrors {
+		// the namespace starts with the name of the request type
+		_, field, _ := strings.Cut(each.Namespace(), ".")
+		fields = append(fields, &dto.ResponseError{
+			Message: fmt.Sprintf("%s failed on the %s rule", field, each.Tag()),
+			Field:   field,
+			Rule:    each.Tag(),
+			Param:   each.Param(),
+		})
+	}
+	return fields
+}
+
+// GetRequest returns the request body bound by Validator, or nil.
+func GetRequest[T any](c *gin.Context) *T {
+	value, ok := c.Get(requestKey)
+	if !ok {
+		return nil
+	}
+	request, _ := value.(*T)
+	return request
+}
+
+// LimitBody caps the size of the request bodies at the number of bytes
+// returned by maxBytes, 0 for no limit.
+func LimitBody(maxBytes func() int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit := maxBytes(); limit > 0 {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/import", LimitBody(func() int64 { return 256 }), Validator[dto.DataURIImportRequest](), func(c *gin.Context) {
+		request := GetRequest[dto.DataURIImportRequest](c)
+		c.String(http.StatusOK, request.Images[0].URI)
+	})
+
+	cases := []struct {
+		body       string
+		statusCode int
+		fields     []*dto.ResponseError
+	}{
+		{`{"images": [{"uri": "data:image/png;base64,AA=="}]}`, http.StatusOK, nil},
+		{`{"images": [`, http.StatusBadRequest, nil},
+		{`{"images": []}`, http.StatusUnprocessableEntity, []*dto.ResponseError{{Message: "images failed on the min rule", Field: "images", Rule: "min", Param: "1"}}},
+		{`{"images": [{"uri": "data:,"}, {"name": "a.png"}]}`, http.StatusUnprocessableEntity, []*dto.ResponseError{{Message: "images[1].uri failed on the required rule", Field: "images[1].uri", Rule: "required"}}},
+		{`{"images": [{"uri": "` + strings.Repeat("A", 300) + `"}]}`, http.StatusRequestEntityTooLarge, nil},
+	}
+
+	for _, each := range cases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/import", strings.NewReader(each.body)))
+		assert.Equal(t, each.statusCode, recorder.Code, each.body)
+
+		if each.fields != nil {
+			var problem struct {
+				Type   string               `json:"type"`
+				Errors []*dto.ResponseError `json:"errors"`
+			}
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+			assert.Equal(t, restutil.ValidationProblemType, problem.Type, each.body)
+			assert.Equal(t, each.fields, problem.Errors, each.body)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"imagenexus/api/restutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The response shapes clients can ask for with the Accept header.
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+var supportedAPIVersions = []string{APIVersion1, APIVersion2}
+
+var vendorMediaType = regexp.MustCompile(`^application/vnd\.imagenexus\.(v[0-9]+)\+json$`)
+
+// APIVersion stores the version of a vendor media type of the Accept header,
+// e.g. application/vnd.imagenexus.v2+json, for GetAPIVersion. Requests asking
+// for an unknown version are rejected with 406.
+func APIVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// the shape of the responses depends on the header
+		varyAccept(c)
+
+		for _, eachType := range strings.Split(c.GetHeader("Accept"), ",") {
+			mediaType, _, _ := strings.Cut(eachType, ";")
+			match := vendorMediaType.FindStringSubmatch(strings.ToLower(strings.TrimSpace(mediaType)))
+			if match == nil {
+				continue
+			}
+
+			if !slices.Contains(supportedAPIVersions, match[1]) {
+				restutil.WriteError(c, http.StatusNotAcceptable, errors.New("unsupported api version"), gin.H{"supported_versions": supportedAPIVersions})
+				c.Abort()
+				return
+			}
+
+			c.Set(restutil.APIVersionKey, match[1])
+			break
+		}
+
+		c.Next()
+	}
+}
+
+// GetAPIVersion returns the version requested with the Accept header, v1
+// when there's none.
+func GetAPIVersion(c *gin.Context) string {
+	version, ok := c.Get(restutil.APIVersionKey)
+	if !ok {
+		return APIVersion1
+	}
+	return version.(string)
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(APIVersion())
+	router.GET("/version", func(c *gin.Context) { c.String(http.StatusOK, GetAPIVersion(c)) })
+
+	cases := []struct {
+		accept     string
+		statusCode int
+		version    string
+	}{
+		{"", http.StatusOK, APIVersion1},
+		{"application/json", http.StatusOK, APIVersion1},
+		{"application/vnd.imagenexus.v1+json", http.StatusOK, APIVersion1},
+		{"application/vnd.imagenexus.v2+json", http.StatusOK, APIVersion2},
+		{"text/html, application/vnd.imagenexus.v2+json; q=0.9", http.StatusOK, APIVersion2},
+		{"application/vnd.imagenexus.v9+json", http.StatusNotAcceptable, ""},
+	}
+
+	for _, each := range cases {
+		request := httptest.NewRequest(http.MethodGet, "/version", nil)
+		if each.accept != "" {
+			request.Header.Set("Accept", each.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, each.statusCode, recorder.Code, each.accept)
+		assert.Equal(t, "Accept", recorder.Header().Get("Vary"), each.accept)
+		if each.statusCode == http.StatusOK {
+			assert.Equal(t, each.version, recorder.Body.String(), each.accept)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package resthandlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/restutil"
+	"imagenexus/config"
+	"imagenexus/dto"
+	"imagenexus/service"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler interface {
+	ListLifecycleRules(*gin.Context)
+	PutLifecycleRule(*gin.Context)
+	DeleteLifecycleRule(*gin.Context)
+	ReprocessPicture(*gin.Context)
+	ReprocessAllPictures(*gin.Context)
+	GetProcessingJob(*gin.Context)
+	ReloadConfig(*gin.Context)
+	ListAuditLog(*gin.Context)
+	EstimateStorageCost(*gin.Context)
+}
+
+type adminHandler struct {
+	storageSvc    service.StorageAdminService
+	processingSvc service.ProcessingService
+	auditSvc      service.AuditService
+	costSvc       service.StorageCostService
+}
+
+func NewAdminHandler(storageAdminService service.StorageAdminService, processingService service.ProcessingService, auditService service.AuditService, costService service.StorageCostService) AdminHandler {
+	return &adminHandler{storageSvc: storageAdminService, processingSvc: processingService, auditSvc: auditService, costSvc: costService}
+}
+
+func lifecycleProblem(err error) *dto.Problem {
+	var invalidRule *service.InvalidLifecycleRuleError
+	var notFound *storage.LifecycleRuleNotFoundError
+
+	switch {
+	case errors.Is(err, service.ErrLifecycleNotSupported):
+		return restutil.NewProblem(http.StatusNotImplemented, err)
+	case errors.As(err, &invalidRule):
+		return restutil.NewProblem(http.StatusBadRequest, err)
+	case errors.As(err, &notFound):
+		return restutil.NewNotFoundProblem(err)
+	}
+	return restutil.NewStorageErrorProblem(err)
+}
+
+// List storage lifecycle rules
+// @Summary list storage lifecycle rules
+// @Description List the lifecycle rules of the configured S3 bucket
+// @Security BearerAuth
+// @Success 200 {object} dto.Response{data=[]dto.LifecycleRule}
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/admin/storage/lifecycle [get]
+func (h *adminHandler) ListLifecycleRules(c *gin.Context) {
+	rules, err := h.storageSvc.ListLifecycleRules()
+	if err != nil {
+		JSONProblem(c, lifecycleProblem(err))
+		return
+	}
+
+	JSONSuccess(c, rules, newListMeta(len(rules)))
+}
+
+// Create or update a storage lifecycle rule
+// @Summary create or update a storage lifecycle rule
+// @Description Create a lifecycle rule on the configured S3 bucket, or replace the rule with the same id
+// @Security BearerAuth
+// @Accept json
+// @Param rule body dto.LifecycleRule true "lifecycle rule"
+// @Success 200 {object} dto.Response{data=dto.LifecycleRule}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/admin/storage/lifecycle [post]
+func (h *adminHandler) PutLifecycleRule(c *gin.Context) {
+	rule := middleware.GetRequest[dto.LifecycleRule](c)
+
+	savedRule, err := h.storageSvc.PutLifecycleRule(rule)
+	if err != nil {
+		JSONProblem(c, lifecycleProblem(err))
+		return
+	}
+
+	JSONSuccess(c, savedRule, nil)
+}
+
+// Delete a storage lifecycle rule
+// @Summary delete a storage lifecycle rule
+// @Description Delete a lifecycle rule of the configured S3 bucket by its ID
+// @Security BearerAuth
+// @Param id path string true "Rule Id"
+// @Success 200 {object} dto.Response{data=dto.StringResponse}
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/admin/storage/lifecycle/{id} [delete]
+func (h *adminHandler) DeleteLifecycleRule(c *gin.Context) {
+	if err := h.storageSvc.DeleteLifecycleRule(c.Param("id")); err != nil {
+		JSONProblem(c, lifecycleProblem(err))
+		return
+	}
+
+	JSONSuccess(c, dto.StringResponse{Message: "Successfully deleted"}, nil)
+}
+
+// Reprocess a picture
+// @Summary reprocess a picture
+// @Description Re-run the full processing pipeline on an existing picture and return the result of each step
+// @Security BearerAuth
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.ProcessingResult}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/pictures/{id}/reprocess [post]
+func (h *adminHandler) ReprocessPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.processingSvc.Process(id)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, service.ErrPictureNotFound) {
+			statusCode = http.StatusNotFound
+		}
+		JSONError(c, statusCode, err)
+		return
+	}
+
+	JSONSuccess(c, result, nil)
+}
+
+// Reprocess all pictures
+// @Summary reprocess all pictures
+// @Description Start a background job queueing every picture for processing
+// @Security BearerAuth
+// @Param batch query number false "number of pictures queued at a time, 50 by default" Format(number)
+// @Success 202 {object} dto.Response{data=dto.ProcessingJob}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Router /v1/admin/pictures/reprocess-all [post]
+func (h *adminHandler) ReprocessAllPictures(c *gin.Context) {
+	batchSize, err := strconv.Atoi(c.DefaultQuery("batch", "50"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if batchSize < 1 {
+		JSONError(c, http.StatusBadRequest, errors.New("batch can't be less than 1"))
+		return
+	}
+
+	job := h.processingSvc.ReprocessAll(batchSize)
+	c.Status(http.StatusAccepted)
+	JSONSuccess(c, job, nil)
+}
+
+// Get a processing job
+// @Summary get a processing job
+// @Description Get the progress of a reprocessing job by its ID
+// @Security BearerAuth
+// @Param job_id path string true "Job Id"
+// @Success 200 {object} dto.Response{data=dto.ProcessingJob}
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/admin/jobs/{job_id} [get]
+func (h *adminHandler) GetProcessingJob(c *gin.Context) {
+	job, err := h.processingSvc.GetJob(c.Param("job_id"))
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, job, nil)
+}
+
+// Reload the config
+// @Summary reload the config
+// @Description Re-read the config file, listing the changed settings that need a restart to take effect
+// @Security BearerAuth
+// @Success 200 {object} dto.Response{data=dto.ConfigReloadResponse}
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/config/reload [post]
+func (h *adminHandler) ReloadConfig(c *gin.Context) {
+	restartRequired, err := config.Reload()
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSONSuccess(c, dto.ConfigReloadResponse{RestartRequired: restartRequired}, nil)
+}
+
+// List the audit log
+// @Summary list the audit log
+// @Description List the creations, updates and deletions of a picture, or the ones made by a user, the latest first
+// @Security BearerAuth
+// @Param entity_id query number false "Image Id"
+// @Param actor_id query string false "subject of the token of the user"
+// @Param page query number false "page number starting from 1" Format(number)
+// @Success 200 {object} dto.Response{data=[]dto.AuditLogEntry}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/audit-log [get]
+func (h *adminHandler) ListAuditLog(c *gin.Context) {
+	pageNumber, err := parsePageNumber(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var entries []*dto.AuditLogEntry
+	var totalCount int64
+	switch entityId, actorId := c.Query("entity_id"), c.Query("actor_id"); {
+	case entityId != "":
+		id, parseErr := strconv.ParseUint(entityId, 10, 0)
+		if parseErr != nil || id == 0 {
+			JSONError(c, http.StatusBadRequest, errors.New("entity_id must be a picture id"))
+			return
+		}
+		entries, totalCount, err = h.auditSvc.ListByPicture(uint(id), pageSize, pageNumber)
+	case actorId != "":
+		entries, totalCount, err = h.auditSvc.ListByActor(actorId, pageSize, pageNumber)
+	default:
+		JSONError(c, http.StatusBadRequest, errors.New("either entity_id or actor_id is required"))
+		return
+	}
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSONSuccess(c, entries, newPageMeta(pageNumber, int(totalCount)))
+}
+
+// Estimate the storage cost
+// @Summary estimate the storage cost
+// @Description Estimate the monthly cost of the stored files by storage tier, at the prices of storage.costPerGBMonth
+// @Security BearerAuth
+// @Success 200 {object} dto.Response{data=dto.StorageCostEstimate}
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/storage/cost-estimate [get]
+func (h *adminHandler) EstimateStorageCost(c *gin.Context) {
+	estimate, err := h.costSvc.Estimate()
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	JSONSuccess(c, estimate, nil)
+}
@@ -0,0 +1,338 @@
+package resthandlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+	"imagenexus/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CollectionsHandler interface {
+	CreateCollection(*gin.Context)
+	GetCollection(*gin.Context)
+	DeleteCollection(*gin.Context)
+	AddCollectionPicture(*gin.Context)
+	RemoveCollectionPicture(*gin.Context)
+	PinCollectionPicture(*gin.Context)
+	UnpinCollectionPicture(*gin.Context)
+	ReorderCollectionPins(*gin.Context)
+	CreateSprite(*gin.Context)
+	CreateAnimation(*gin.Context)
+}
+
+type collectionsHandler struct {
+	svc service.CollectionsService
+}
+
+func NewCollectionsHandler(collectionsService service.CollectionsService) CollectionsHandler {
+	return &collectionsHandler{svc: collectionsService}
+}
+
+func parseCollectionPictureParams(c *gin.Context) (int, int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pictureId, err := strconv.Atoi(c.Param("pic_id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, pictureId, nil
+}
+
+// Create a collection
+// @Summary create a collection
+// @Description Create an empty collection of pictures
+// @Accept json
+// @Param collection body dto.CollectionRequest true "collection"
+// @Success 201 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/collections [post]
+func (h *collectionsHandler) CreateCollection(c *gin.Context) {
+	request := middleware.GetRequest[dto.CollectionRequest](c)
+
+	collection, err := h.svc.Create(request)
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Status(http.StatusCreated)
+	JSONSuccess(c, collection, nil)
+}
+
+// Get a collection
+// @Summary get a collection
+// @Description Get a collection along with its pictures, the pinned ones first by pin order, then the others in the order they were added
+// @Param id path number true "Collection Id"
+// @Success 200 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id} [get]
+func (h *collectionsHandler) GetCollection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := h.svc.Get(id)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, collection, nil)
+}
+
+// Delete a collection
+// @Summary delete a collection
+// @Description Delete a collection by its ID, keeping its pictures
+// @Param id path number true "Collection Id"
+// @Success 200 {object} dto.Response{data=dto.StringResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id} [delete]
+func (h *collectionsHandler) DeleteCollection(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.svc.Delete(id); err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, dto.StringResponse{Message: "Successfully deleted"}, nil)
+}
+
+// Add a picture to a collection
+// @Summary add a picture to a collection
+// @Description Add a picture at the end of a collection
+// @Param id path number true "Collection Id"
+// @Param pic_id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id}/pictures/{pic_id} [post]
+func (h *collectionsHandler) AddCollectionPicture(c *gin.Context) {
+	id, pictureId, err := parseCollectionPictureParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := h.svc.AddPicture(id, pictureId)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, collection, nil)
+}
+
+// Remove a picture from a collection
+// @Summary remove a picture from a collection
+// @Description Remove a picture from a collection, keeping the picture itself
+// @Param id path number true "Collection Id"
+// @Param pic_id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.StringResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id}/pictures/{pic_id} [delete]
+func (h *collectionsHandler) RemoveCollectionPicture(c *gin.Context) {
+	id, pictureId, err := parseCollectionPictureParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.svc.RemovePicture(id, pictureId); err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, dto.StringResponse{Message: "Successfully removed"}, nil)
+}
+
+// Pin a picture of a collection
+// @Summary pin a picture of a collection
+// @Description Move a picture of a collection ahead of the others, after the pictures pinned already
+// @Param id path number true "Collection Id"
+// @Param pic_id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id}/pictures/{pic_id}/pin [post]
+func (h *collectionsHandler) PinCollectionPicture(c *gin.Context) {
+	id, pictureId, err := parseCollectionPictureParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := h.svc.Pin(id, pictureId)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, collection, nil)
+}
+
+// Unpin a picture of a collection
+// @Summary unpin a picture of a collection
+// @Description Move a pinned picture of a collection back to the position it was added at
+// @Param id path number true "Collection Id"
+// @Param pic_id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/collections/{id}/pictures/{pic_id}/pin [delete]
+func (h *collectionsHandler) UnpinCollectionPicture(c *gin.Context) {
+	id, pictureId, err := parseCollectionPictureParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := h.svc.Unpin(id, pictureId)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, collection, nil)
+}
+
+// Reorder the pinned pictures of a collection
+// @Summary reorder the pinned pictures of a collection
+// @Description Order the pinned pictures of a collection as the given ids, which have to list each of them once
+// @Accept json
+// @Param id path number true "Collection Id"
+// @Param order body []uint true "ids of the pinned pictures, in their new order"
+// @Success 200 {object} dto.Response{data=dto.CollectionResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Router /v1/collections/{id}/pin-order [patch]
+func (h *collectionsHandler) ReorderCollectionPins(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var pictureIds []uint
+	if err := c.ShouldBindJSON(&pictureIds); err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	collection, err := h.svc.ReorderPins(id, pictureIds)
+	if errors.Is(err, service.ErrPinOrderMismatch) {
+		JSONError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, collection, nil)
+}
+
+// Create a sprite sheet from a collection
+// @Summary create a sprite sheet from a collection
+// @Description Tile the pictures of a collection into a PNG sprite sheet, save it as a new picture and get the position of each picture in it
+// @Param id path number true "Collection Id"
+// @Param columns query number false "number of columns, a square grid by default" Format(number)
+// @Success 201 {object} dto.Response{data=dto.SpriteResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/collections/{id}/sprite [post]
+func (h *collectionsHandler) CreateSprite(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// 0 lets the service pick a square grid
+	columns, err := strconv.Atoi(c.DefaultQuery("columns", "0"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if columns < 0 {
+		JSONError(c, http.StatusBadRequest, errors.New("columns can't be negative"))
+		return
+	}
+
+	sprite, spriteError := h.svc.Sprite(id, columns)
+	if spriteError != nil {
+		JSONError(c, spriteError.StatusCode, restutil.WithMeta(spriteError.Error, spriteError.Data))
+		return
+	}
+
+	c.Status(http.StatusCreated)
+	JSONSuccess(c, sprite, nil)
+}
+
+// Create a GIF animation from a collection
+// @Summary create a GIF animation from a collection
+// @Description Encode the pictures of a collection as the frames of a looping GIF, scaled to the size of the first one, and save it as a new picture
+// @Param id path number true "Collection Id"
+// @Param delay_cs query number false "delay between frames in hundredths of a second, 20 by default" Format(number)
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/collections/{id}/animate [post]
+func (h *collectionsHandler) CreateAnimation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	delay, err := strconv.Atoi(c.DefaultQuery("delay_cs", "20"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// GIF stores the delay as an unsigned 16 bit number
+	if delay < 0 || delay > math.MaxUint16 {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("delay_cs must be between 0 and %d", math.MaxUint16))
+		return
+	}
+
+	animation, animateError := h.svc.Animate(id, delay)
+	if animateError != nil {
+		JSONError(c, animateError.StatusCode, restutil.WithMeta(animateError.Error, animateError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, animation)
+}
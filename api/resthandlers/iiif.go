@@ -0,0 +1,90 @@
+package resthandlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"imagenexus/api/restutil"
+	"imagenexus/iiif"
+	"imagenexus/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IIIFHandler interface {
+	GetInfo(*gin.Context)
+	GetImage(*gin.Context)
+}
+
+type iiifHandler struct {
+	svc service.IIIFService
+}
+
+func NewIIIFHandler(iiifService service.IIIFService) IIIFHandler {
+	return &iiifHandler{svc: iiifService}
+}
+
+// Get IIIF image information
+// @Summary get IIIF image information
+// @Description Get the IIIF Image API 3.0 info.json document of an image, advertising the size limits of the returned images
+// @Param identifier path number true "Image Id"
+// @Success 200 {object} dto.IIIFInfoResponse
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/iiif/{identifier}/info.json [get]
+func (h *iiifHandler) GetInfo(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("identifier"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	info, err := h.svc.Info(id)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+
+	c.Header("Content-Type", iiif.InfoContentType)
+	c.Header("Access-Control-Allow-Origin", "*")
+	restutil.WriteAsJson(c, http.StatusOK, info)
+}
+
+// Get IIIF image
+// @Summary get IIIF image
+// @Description Get an image transformed per the IIIF Image API 3.0 region, size, rotation, quality and format parameters. The max size is scaled down to iiif.maxWidth, iiif.maxHeight and iiif.maxArea, the larger sizes are refused with a 400
+// @Param identifier path number true "Image Id"
+// @Param region path string true "full, square, x,y,w,h or pct:x,y,w,h"
+// @Param size path string true "max, w,, ,h, pct:n, w,h or !w,h with an optional ^ prefix"
+// @Param rotation path string true "degrees between 0 and 360 with an optional ! prefix to mirror"
+// @Param quality_format path string true "{quality}.{format}, e.g. default.jpg"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/iiif/{identifier}/{region}/{size}/{rotation}/{quality_format} [get]
+func (h *iiifHandler) GetImage(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("identifier"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request, err := iiif.ParseRequest(c.Param("region"), c.Param("size"), c.Param("rotation"), c.Param("quality_format"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	data, contentType, renderError := h.svc.Render(id, request)
+	if renderError != nil {
+		JSONError(c, renderError.StatusCode, restutil.WithMeta(renderError.Error, renderError.Data))
+		return
+	}
+
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,148 @@
+package resthandlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+	"imagenexus/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler interface {
+	FlagPicture(*gin.Context)
+	ApprovePicture(*gin.Context)
+	RejectPicture(*gin.Context)
+	ListModerationQueue(*gin.Context)
+}
+
+type moderationHandler struct {
+	svc service.ModerationService
+}
+
+func NewModerationHandler(moderationService service.ModerationService) ModerationHandler {
+	return &moderationHandler{svc: moderationService}
+}
+
+func moderationProblem(err error) *dto.Problem {
+	if errors.Is(err, service.ErrPictureNotFound) {
+		return restutil.NewNotFoundProblem(err)
+	}
+	return restutil.NewProblem(http.StatusInternalServerError, err)
+}
+
+// Flag a picture
+// @Summary flag a picture
+// @Description Report a picture to the moderators, putting it back in the moderation queue
+// @Accept json
+// @Param id path number true "Image Id"
+// @Param request body dto.ModerationFlagRequest true "reason of the report"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/flag [post]
+func (h *moderationHandler) FlagPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request := middleware.GetRequest[dto.ModerationFlagRequest](c)
+	picture, err := h.svc.FlagPicture(id, request.Reason)
+	if err != nil {
+		JSONProblem(c, moderationProblem(err))
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
+
+// Approve a picture
+// @Summary approve a picture
+// @Description Take a picture out of the moderation queue
+// @Security BearerAuth
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/pictures/{id}/approve [post]
+func (h *moderationHandler) ApprovePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	picture, err := h.svc.ApprovePicture(id)
+	if err != nil {
+		JSONProblem(c, moderationProblem(err))
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
+
+// Reject a picture
+// @Summary reject a picture
+// @Description Take a picture out of the moderation queue, its files are then unavailable for legal reasons
+// @Security BearerAuth
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/pictures/{id}/reject [post]
+func (h *moderationHandler) RejectPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	picture, err := h.svc.RejectPicture(id)
+	if err != nil {
+		JSONProblem(c, moderationProblem(err))
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
+
+// List the moderation queue
+// @Summary list the moderation queue
+// @Description List the pictures pending moderation, the longest waiting first
+// @Security BearerAuth
+// @Param page query number false "page number starting from 1" Format(number)
+// @Success 200 {object} dto.Response{data=[]dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/admin/moderation/queue [get]
+func (h *moderationHandler) ListModerationQueue(c *gin.Context) {
+	pageNumber, err := parsePageNumber(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	pictures, totalCount, err := h.svc.ListQueue(pageSize, pageNumber)
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	writePictures(c, pictures, pageNumber, totalCount)
+}
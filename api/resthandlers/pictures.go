@@ -1,26 +1,85 @@
 package resthandlers
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"strconv"
+	"strings"
+	"time"
 
+	"imagenexus/api/middleware"
 	"imagenexus/api/restutil"
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/deepzoom"
 	"imagenexus/dto"
+	"imagenexus/imaging"
 	"imagenexus/service"
+	"imagenexus/storage"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PicturesHandler interface {
 	CreatePicture(*gin.Context)
+	CreatePictureFromBase64(*gin.Context)
+	ImportDataURIPictures(*gin.Context)
 	UpdatePicture(*gin.Context)
 	ListPictures(*gin.Context)
+	SearchPictures(*gin.Context)
+	SearchNearbyPictures(*gin.Context)
+	GetPictureLocation(*gin.Context)
 	GetPicture(*gin.Context)
 	GetPictureFile(*gin.Context)
+	GetPictureOriginal(*gin.Context)
+	GetPictureThumbnail(*gin.Context)
+	GetPictureAnimatedThumbnail(*gin.Context)
+	GetPictureICCProfile(*gin.Context)
+	GetPictureXMP(*gin.Context)
+	ListPictureFrames(*gin.Context)
+	GetPictureFrame(*gin.Context)
+	SavePictureFrame(*gin.Context)
+	ChangePictureAlpha(*gin.Context)
+	ChangePictureTone(*gin.Context)
+	AdjustPicture(*gin.Context)
+	EqualizePicture(*gin.Context)
+	AutoLevelPicture(*gin.Context)
+	BlurPicture(*gin.Context)
+	DenoisePicture(*gin.Context)
+	SharpenPicture(*gin.Context)
+	AddPictureBorder(*gin.Context)
+	DitherPicture(*gin.Context)
+	CompositePictures(*gin.Context)
+	CreatePictureTileset(*gin.Context)
+	GetPictureTileset(*gin.Context)
+	GetPictureTile(*gin.Context)
+	CheckPictureSteganography(*gin.Context)
+	GetPictureQuality(*gin.Context)
+	GetPictureSrcset(*gin.Context)
+	GetPicturePlaceholder(*gin.Context)
+	AddPictureTag(*gin.Context)
+	SetPictureRetention(*gin.Context)
+	GetPictureFilesBatch(*gin.Context)
+	ListPictureVersions(*gin.Context)
+	GetPictureVersion(*gin.Context)
 	DeletePicture(*gin.Context)
 }
 
+// How long the clients and the shared caches may reuse the list of the
+// pictures and their metadata.
+const (
+	listCacheMaxAge               = time.Minute
+	listCacheStaleWhileRevalidate = 5 * time.Minute
+	pictureCacheMaxAge            = time.Hour
+)
+
 type picturesHandler struct {
 	svc service.PicturesService
 }
@@ -35,25 +94,100 @@ func NewPicturesHandler(picturesService service.PicturesService) PicturesHandler
 // @Accept			multipart/form-data
 //
 //	@Param			image	formData	file			true	"upload image file"
+//	@Param			description	formData	string			false	"description of the image, taken from the IPTC caption when empty"
+//	@Param			X-Idempotency-Key	header	string			false	"replays the response of the first upload with the same key, instead of saving the image again"
 //
-// @Success 201 {object} dto.SinglePictureResponse
-// @Failure 400 {object} dto.GeneralErrorResponse
-// @Failure 500 {object} dto.GeneralErrorResponse
-// @Router / [post]
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 409 {object} dto.Problem "an upload with the same idempotency key is in progress"
+// @Failure 413 {object} dto.Problem
+// @Failure 422 {object} dto.Problem "the idempotency key was used by another request"
+// @Failure 500 {object} dto.Problem
+// @Router /v1/ [post]
 func (h *picturesHandler) CreatePicture(c *gin.Context) {
-	file, err := c.FormFile("image")
-	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+	file, ok := formImage(c)
+	if !ok {
+		return
+	}
+
+	createdPicture, createError := h.svc.Create(actorContext(c), file, c.PostForm("description"))
+	if createError != nil {
+		JSONError(c, createError.StatusCode, restutil.WithMeta(createError.Error, createError.Data))
 		return
 	}
 
-	createdPicture, createError := h.svc.Create(file)
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Save a base64 encoded image
+// @Summary save a base64 encoded image
+// @Description Given a base64 string or a data URL, save the image & get its computed metadata
+// @Accept json
+// @Param request body dto.Base64PictureRequest true "base64 data & file name"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 413 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/base64 [post]
+func (h *picturesHandler) CreatePictureFromBase64(c *gin.Context) {
+	request := middleware.GetRequest[dto.Base64PictureRequest](c)
+
+	createdPicture, createError := h.svc.CreateFromBase64(actorContext(c), request)
 	if createError != nil {
-		restutil.WriteError(c, createError.StatusCode, createError.Error, createError.Data)
+		JSONError(c, createError.StatusCode, restutil.WithMeta(createError.Error, createError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Import data URI images
+// @Summary import data URI images
+// @Description Save every base64 data URI image independently, reporting the outcome of each one in the order of the request. The retries with the same idempotency key get the results of the first import, even a partial one, without saving any image again
+// @Accept json
+// @Param request body dto.DataURIImportRequest true "data URIs & file names"
+// @Param X-Idempotency-Key header string false "replays the results of the first import with the same key, instead of saving the images again"
+// @Success 207 {object} dto.Response{data=[]dto.ImportResult}
+// @Failure 400 {object} dto.Problem
+// @Failure 409 {object} dto.Problem "an import with the same idempotency key is in progress"
+// @Failure 413 {object} dto.Problem
+// @Failure 422 {object} dto.Problem "invalid data URIs, or the idempotency key was used by another request"
+// @Router /v1/pictures/import/datauri [post]
+func (h *picturesHandler) ImportDataURIPictures(c *gin.Context) {
+	request := middleware.GetRequest[dto.DataURIImportRequest](c)
+
+	maxBatchSize := config.GetConfigInt("server.maxBatchSize")
+	if len(request.Images) > maxBatchSize {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("can't import more than %d pictures at once", maxBatchSize))
 		return
 	}
 
-	restutil.WriteAsJson(c, http.StatusCreated, dto.SinglePictureResponse{Data: createdPicture})
+	results := h.svc.ImportDataURIs(request.Images)
+	c.Status(http.StatusMultiStatus)
+	JSONSuccess(c, results, newListMeta(len(results)))
+}
+
+// Base64PictureBodyLimit is the largest body of POST /picture/base64, 0 for
+// no limit.
+func Base64PictureBodyLimit() int64 {
+	return base64BodyLimit(1)
+}
+
+// DataURIImportBodyLimit is the largest body of POST /pictures/import/datauri,
+// 0 for no limit.
+func DataURIImportBodyLimit() int64 {
+	return base64BodyLimit(config.GetConfigInt("server.maxBatchSize"))
+}
+
+// base64BodyLimit leaves room for the base64 overhead of count images of the
+// largest upload size and for the rest of the JSON document.
+func base64BodyLimit(count int) int64 {
+	maxSize := service.MaxUploadSize()
+	if maxSize == 0 {
+		return 0
+	}
+	return (int64(base64.StdEncoding.EncodedLen(int(maxSize))) + 4096) * int64(count)
 }
 
 // Update an image
@@ -63,145 +197,1559 @@ func (h *picturesHandler) CreatePicture(c *gin.Context) {
 // @Param id path number true "Image Id"
 //
 //	@Param			image	formData	file			true	"upload image file"
+//	@Param			version	formData	integer			false	"version of the picture the update is based on, refused with a 409 when the picture changed in between"
 //
-// @Success 202 {object} dto.SinglePictureResponse
-// @Failure 400 {object} dto.GeneralErrorResponse
-// @Failure 404 {object} dto.GeneralErrorResponse
-// @Failure 500 {object} dto.GeneralErrorResponse
-// @Router /picture/{id} [put]
+// @Success 202 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem "the picture changed in between, current_version gives its version"
+// @Failure 413 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id} [put]
 func (h *picturesHandler) UpdatePicture(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	file, ok := formImage(c)
+	if !ok {
 		return
 	}
 
-	file, err := c.FormFile("image")
+	version, err := parseVersion(c.PostForm("version"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	pictureResponse, updatedError := h.svc.Update(id, file)
+	pictureResponse, updatedError := h.svc.Update(actorContext(c), id, file, version)
 	if updatedError != nil {
-		restutil.WriteError(c, updatedError.StatusCode, updatedError.Error, nil)
+		JSONError(c, updatedError.StatusCode, restutil.WithMeta(updatedError.Error, updatedError.Data))
 		return
 	}
 
-	restutil.WriteAsJson(c, http.StatusAccepted, dto.SinglePictureResponse{Data: pictureResponse})
+	writePicture(c, http.StatusAccepted, pictureResponse)
 }
 
 // List of pictures
 // @Summary list of pictures
 // @Description List of pictures along with its metadata
 // @Param page query number false "page number starting from 1" Format(number)
-// @Success 200 {object} dto.ListPicturesResponse
-// @Failure 400 {object} dto.GeneralErrorResponse
-// @Failure 500 {object} dto.GeneralErrorResponse
-// @Router / [get]
+// @Param If-None-Match header string false "ETag of a previous response, answered with a 304 when the page didn't change"
+// @Success 200 {object} dto.Response{data=[]dto.PictureResponse}
+// @Success 304 "the page didn't change"
+// @Failure 400 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/ [get]
 func (h *picturesHandler) ListPictures(c *gin.Context) {
-	pageSize := 10
-	page := c.Query("page")
-	if page == "" {
-		page = "1"
+	pageNumber, err := parsePageNumber(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
 	}
 
-	pageNumber, err := strconv.Atoi(page)
+	pictures, totalCount, err := h.svc.List(pageSize, pageNumber)
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	if pageNumber < 1 {
-		restutil.WriteError(c, http.StatusBadRequest, errors.New("page can't be less than 1"), nil)
+	cacheFor(c, listCacheMaxAge, listCacheStaleWhileRevalidate)
+	writePictures(c, pictures, pageNumber, totalCount)
+}
+
+// Search pictures
+// @Summary search pictures
+// @Description List the pictures whose GPS coordinates fall within a bounding box, given all four coordinates, and whether they are grayscale copies. A lon_min greater than lon_max selects a box crossing the antimeridian.
+// @Param lat_min query number false "southern latitude"
+// @Param lat_max query number false "northern latitude"
+// @Param lon_min query number false "western longitude"
+// @Param lon_max query number false "eastern longitude"
+// @Param grayscale query boolean false "grayscale copies only, or none of them"
+// @Param min_quality query number false "lowest quality score from 0 to 100, leaving out the unprocessed pictures"
+// @Param page query number false "page number starting from 1" Format(number)
+// @Success 200 {object} dto.Response{data=[]dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/pictures [get]
+func (h *picturesHandler) SearchPictures(c *gin.Context) {
+	filter, err := parsePictureFilter(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	pictures, totalCount, err := h.svc.List(pageSize, pageNumber)
+	pageNumber, err := parsePageNumber(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	pictures, totalCount, err := h.svc.Search(filter, pageSize, pageNumber)
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	writePictures(c, pictures, pageNumber, totalCount)
+}
+
+// Search nearby pictures
+// @Summary search nearby pictures
+// @Description List the pictures taken within a radius of a point, nearest first, with their distance to it
+// @Param lat query number true "latitude of the point"
+// @Param lon query number true "longitude of the point"
+// @Param radius_km query number true "search radius in kilometers"
+// @Param page query number false "page number starting from 1" Format(number)
+// @Success 200 {object} dto.Response{data=[]dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/pictures/nearby [get]
+func (h *picturesHandler) SearchNearbyPictures(c *gin.Context) {
+	values := map[string]float64{}
+	for _, eachKey := range []string{"lat", "lon", "radius_km"} {
+		value, err := strconv.ParseFloat(c.Query(eachKey), 64)
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", eachKey, err))
+			return
+		}
+		values[eachKey] = value
+	}
+
+	lat, lon, radiusKm := values["lat"], values["lon"], values["radius_km"]
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		JSONError(c, http.StatusBadRequest, errors.New("lat must be between -90 and 90 and lon between -180 and 180"))
+		return
+	}
+	if radiusKm <= 0 {
+		JSONError(c, http.StatusBadRequest, errors.New("radius_km must be positive"))
+		return
+	}
+
+	pageNumber, err := parsePageNumber(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	pictures, totalCount, err := h.svc.SearchNearby(lat, lon, radiusKm, pageSize, pageNumber)
+	if err != nil {
+		JSONError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	writePictures(c, pictures, pageNumber, totalCount)
+}
+
+// Get the location of an image
+// @Summary get the location of an image
+// @Description Get the GPS coordinates where an image was taken, or null when it has none
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.PictureLocation}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/picture/{id}/location [get]
+func (h *picturesHandler) GetPictureLocation(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	location, err := h.svc.GetLocation(id)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, location, nil)
+}
+
+const pageSize = 10
+
+// pictureFileProblem is the problem of a failure to serve a file of a
+// picture, unavailable for legal reasons when the moderators rejected it, a
+// conflict while it's archived and a 503 while the circuit breaker of the
+// storage is open.
+func pictureFileProblem(err error) *dto.Problem {
+	var mismatch *service.ChecksumMismatchError
+	switch {
+	case errors.As(err, &mismatch):
+		return restutil.NewDataCorruptionProblem(err)
+	case errors.Is(err, storage.ErrCircuitOpen):
+		return restutil.NewProblem(http.StatusServiceUnavailable, err)
+	case errors.Is(err, service.ErrPictureRejected):
+		return restutil.NewProblem(http.StatusUnavailableForLegalReasons, err)
+	case errors.Is(err, service.ErrPictureArchived):
+		return restutil.NewProblem(http.StatusConflict, err)
+	}
+	return restutil.NewNotFoundProblem(err)
+}
+
+func parsePageNumber(c *gin.Context) (int, error) {
+	pageNumber, err := strconv.Atoi(c.DefaultQuery("page", "1"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusInternalServerError, err, nil)
+		return 0, err
+	}
+
+	if pageNumber < 1 {
+		return 0, errors.New("page can't be less than 1")
+	}
+	return pageNumber, nil
+}
+
+// writePicture responds with the shape of the picture for the API version
+// of the request.
+func writePicture(c *gin.Context, statusCode int, picture *dto.PictureResponse) {
+	c.Status(statusCode)
+	if middleware.GetAPIVersion(c) == middleware.APIVersion2 {
+		JSONSuccess(c, picture.ToV2(), nil)
+		return
+	}
+	JSONSuccess(c, picture, nil)
+}
+
+// writePictures responds with a page of pictures, pointing the cursor of the
+// meta at the following page, or with a 304 when the page didn't change.
+func writePictures(c *gin.Context, pictures []*dto.PictureResponse, pageNumber int, totalCount int) {
+	meta := newPageMeta(pageNumber, totalCount)
+	if middleware.GetAPIVersion(c) != middleware.APIVersion2 {
+		if !writeETag(c, pictures, meta) {
+			JSONSuccess(c, pictures, meta)
+		}
 		return
 	}
 
+	picturesV2 := make([]*dto.PictureResponseV2, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		picturesV2 = append(picturesV2, eachPicture.ToV2())
+	}
+	if !writeETag(c, picturesV2, meta) {
+		JSONSuccess(c, picturesV2, meta)
+	}
+}
+
+// newPageMeta is the meta of a page of the paged lists, pointing the cursor
+// at the following page.
+func newPageMeta(pageNumber int, totalCount int) *dto.ListMeta {
 	totalPages := totalCount / pageSize
 	if (totalCount % pageSize) > 0 {
 		totalPages += 1
 	}
 
-	restutil.WriteAsJson(c, http.StatusOK, dto.ListPicturesResponse{
-		Pictures:   pictures,
-		Count:      totalCount,
-		TotalPages: totalPages,
-	})
+	meta := &dto.ListMeta{Total: totalCount, TotalPages: totalPages}
+	if pageNumber < totalPages {
+		cursor := strconv.Itoa(pageNumber + 1)
+		meta.Cursor = &cursor
+	}
+	return meta
+}
+
+func parsePictureFilter(c *gin.Context) (*dto.PictureFilter, error) {
+	filter := &dto.PictureFilter{}
+
+	for _, eachKey := range []string{"lat_min", "lat_max", "lon_min", "lon_max"} {
+		if _, ok := c.GetQuery(eachKey); ok {
+			box, err := parseBoundingBox(c)
+			if err != nil {
+				return nil, err
+			}
+			filter.Box = box
+			break
+		}
+	}
+
+	if value, ok := c.GetQuery("grayscale"); ok {
+		grayscale, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grayscale: %w", err)
+		}
+		filter.Grayscale = &grayscale
+	}
+
+	if value, ok := c.GetQuery("min_quality"); ok {
+		minQuality, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_quality: %w", err)
+		}
+		filter.MinQuality = &minQuality
+	}
+	return filter, nil
+}
+
+func parseBoundingBox(c *gin.Context) (*dto.BoundingBox, error) {
+	values := map[string]float64{}
+	for _, eachKey := range []string{"lat_min", "lat_max", "lon_min", "lon_max"} {
+		value, err := strconv.ParseFloat(c.Query(eachKey), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", eachKey, err)
+		}
+		values[eachKey] = value
+	}
+
+	box := &dto.BoundingBox{
+		LatMin: values["lat_min"],
+		LatMax: values["lat_max"],
+		LonMin: values["lon_min"],
+		LonMax: values["lon_max"],
+	}
+
+	if box.LatMin < -90 || box.LatMax > 90 || box.LatMin > box.LatMax {
+		return nil, errors.New("latitudes must be between -90 and 90 with lat_min not above lat_max")
+	}
+	if box.LonMin < -180 || box.LonMin > 180 || box.LonMax < -180 || box.LonMax > 180 {
+		return nil, errors.New("longitudes must be between -180 and 180")
+	}
+	return box, nil
 }
 
 // Get a image
 // @Summary get a image
-// @Description Get a specified image file by its ID
+// @Description Get a specified image file by its ID. PDFs are served as the PNG preview of their first page. Cold pictures are a conflict until restored. Files not matching their checksum are a data corruption problem.
 // @Param id path number true "Image Id"
+// @Param interlace query boolean false "serve PNGs Adam7 interlaced, from a copy written on the first request"
+// @Param Range header string false "byte range, e.g. bytes=0-1023"
 // @Success 200 {file} octet-stream
-// @Failure 400 {object} dto.GeneralErrorResponse
-// @Failure 404 {object} dto.GeneralErrorResponse
-// @Router /picture/{id}/image [get]
+// @Success 204 "served by nginx through X-Accel-Redirect"
+// @Success 206 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/image [get]
 func (h *picturesHandler) GetPictureFile(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	interlace := false
+	if value, ok := c.GetQuery("interlace"); ok {
+		interlace, err = strconv.ParseBool(value)
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid interlace: %w", err))
+			return
+		}
+	}
+
+	if config.GetConfigBool("server.xAccelRedirect.enabled") {
+		getRedirect := h.svc.GetInternalRedirect
+		if interlace {
+			getRedirect = h.svc.GetInterlacedRedirect
+		}
+
+		redirectPath, contentType, err := getRedirect(id)
+		if err != nil {
+			JSONProblem(c, pictureFileProblem(err))
+			return
+		}
+
+		// let nginx serve the file from its internal location
+		c.Header("X-Accel-Redirect", redirectPath)
+		c.Header("Content-Type", contentType)
+		c.Status(http.StatusNoContent)
 		return
 	}
 
-	pictureDestination, err := h.svc.GetFile(id)
+	getReader := h.svc.GetFileReader
+	if interlace {
+		getReader = h.svc.GetInterlacedFileReader
+	}
+
+	reader, contentType, modTime, err := getReader(id)
 	if err != nil {
-		restutil.WriteError(c, http.StatusNotFound, err, nil)
+		JSONProblem(c, pictureFileProblem(err))
 		return
 	}
+	defer reader.Close()
 
-	http.ServeFile(c.Writer, c.Request, pictureDestination)
+	// ServeContent answers Range and If-Range requests with 206 Partial
+	// Content and only reads the requested bytes from storage
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", modTime, reader)
 }
 
-// Get a single image data
-// @Summary get a single image data
-// @Description Get a specified image with its metadata by its ID
+// Get the uploaded file of an image
+// @Summary get the uploaded file of an image
+// @Description Get the file as it was uploaded, e.g. the PDF whose preview is served as its image
 // @Param id path number true "Image Id"
-// @Success 200 {object} dto.SinglePictureResponse
-// @Failure 400 {object} dto.GeneralErrorResponse
-// @Failure 404 {object} dto.GeneralErrorResponse
-// @Router /picture/{id} [get]
-func (h *picturesHandler) GetPicture(c *gin.Context) {
+// @Param Range header string false "byte range, e.g. bytes=0-1023"
+// @Success 200 {file} octet-stream
+// @Success 206 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/file [get]
+func (h *picturesHandler) GetPictureOriginal(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	picture, err := h.svc.Get(id)
+	reader, contentType, modTime, err := h.svc.GetOriginalReader(id)
 	if err != nil {
-		restutil.WriteError(c, http.StatusNotFound, err, nil)
+		JSONProblem(c, pictureFileProblem(err))
 		return
 	}
+	defer reader.Close()
 
-	restutil.WriteAsJson(c, http.StatusOK, dto.SinglePictureResponse{Data: picture})
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", modTime, reader)
 }
 
-// Delete a single image
-// @Summary delete a single image
-// @Description Delete a specified image along with its metadata by its ID
+// Get the thumbnail of an image
+// @Summary get the thumbnail of an image
+// @Description Get the JPEG thumbnail generated after the image was uploaded, or the PNG preview of a PDF
 // @Param id path number true "Image Id"
-// @Success 200 {object} dto.StringResponse
-// @Failure 404 {object} dto.GeneralErrorResponse
-// @Failure 500 {object} dto.GeneralErrorResponse
-// @Router /picture/{id} [delete]
-func (h *picturesHandler) DeletePicture(c *gin.Context) {
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/picture/{id}/thumbnail [get]
+func (h *picturesHandler) GetPictureThumbnail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reader, contentType, modTime, err := h.svc.GetThumbnailReader(id)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", modTime, reader)
+}
+
+// Get the animated thumbnail of an image
+// @Summary get the animated thumbnail of an image
+// @Description Get the animated GIF thumbnail of a GIF with several frames, generated after it was uploaded, fitting in 200x200 and playing at most at 10 fps
+// @Produce gif
+// @Param id path number true "Image Id"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/picture/{id}/thumbnail/animated [get]
+func (h *picturesHandler) GetPictureAnimatedThumbnail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reader, modTime, err := h.svc.GetAnimatedThumbnailReader(id)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "image/gif")
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", modTime, reader)
+}
+
+// Get the ICC profile of an image
+// @Summary get the ICC profile of an image
+// @Description Get the raw ICC colour profile embedded in a JPEG or TIFF image
+// @Produce application/vnd.iccprofile
+// @Param id path number true "Image Id"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/picture/{id}/icc [get]
+func (h *picturesHandler) GetPictureICCProfile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	profile, err := h.svc.GetICCProfile(id)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/vnd.iccprofile", profile)
+}
+
+// Get the XMP metadata of an image
+// @Summary get the XMP metadata of an image
+// @Description Get the raw XMP packet embedded in a JPEG or TIFF image, with its copyright and licensing metadata
+// @Produce application/rdf+xml
+// @Param id path number true "Image Id"
+// @Success 200 {string} string
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/picture/{id}/xmp [get]
+func (h *picturesHandler) GetPictureXMP(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	packet, err := h.svc.GetXMP(id)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	c.Data(http.StatusOK, "application/rdf+xml", []byte(packet))
+}
+
+// List the frames of an animation
+// @Summary list the frames of an animation
+// @Description List the frames of a GIF or animated WebP picture along with their delays
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=[]dto.PictureFrame}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/frames [get]
+func (h *picturesHandler) ListPictureFrames(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	frames, framesError := h.svc.ListFrames(id)
+	if framesError != nil {
+		JSONError(c, framesError.StatusCode, restutil.WithMeta(framesError.Error, framesError.Data))
+		return
+	}
+
+	JSONSuccess(c, frames, newListMeta(len(frames)))
+}
+
+// Get a frame of an animation
+// @Summary get a frame of an animation
+// @Description Get a single frame of a GIF or animated WebP picture as a PNG
+// @Produce png
+// @Param id path number true "Image Id"
+// @Param n path number true "Frame number starting from 0"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/frames/{n} [get]
+func (h *picturesHandler) GetPictureFrame(c *gin.Context) {
+	id, n, err := parseFrameParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	data, frameError := h.svc.GetFrame(id, n)
+	if frameError != nil {
+		JSONError(c, frameError.StatusCode, restutil.WithMeta(frameError.Error, frameError.Data))
+		return
+	}
+
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// Save a frame of an animation
+// @Summary save a frame of an animation
+// @Description Save a single frame of a GIF or animated WebP picture as a new PNG picture
+// @Param id path number true "Image Id"
+// @Param n path number true "Frame number starting from 0"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/frames/{n}/save [post]
+func (h *picturesHandler) SavePictureFrame(c *gin.Context) {
+	id, n, err := parseFrameParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	createdPicture, saveError := h.svc.SaveFrame(id, n)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Add or strip the alpha channel of an image
+// @Summary add or strip the alpha channel of an image
+// @Description Save a copy of an image as a new picture, with its alpha channel stripped into a JPEG flattened on white, or added to a PNG
+// @Param id path number true "Image Id"
+// @Param action query string true "add or strip" Enums(add, strip)
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/alpha [post]
+func (h *picturesHandler) ChangePictureAlpha(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	createdPicture, saveError := h.svc.ChangeAlpha(id, c.Query("action"))
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Convert an image to grayscale
+// @Summary convert an image to grayscale
+// @Description Save a copy of an image as a new picture in the same format, converted to grayscale or toned sepia. The grayscale copies are flagged is_grayscale. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param tone query string false "grayscale by default" Enums(grayscale, sepia)
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/grayscale [post]
+func (h *picturesHandler) ChangePictureTone(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		restutil.WriteError(c, http.StatusBadRequest, err, nil)
+		JSONError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	if err := h.svc.Delete(id); err != nil {
-		restutil.WriteError(c, http.StatusNotFound, err, nil)
+	createdPicture, saveError := h.svc.ChangeTone(id, c.Query("tone"))
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
 		return
 	}
 
-	restutil.WriteAsJson(c, http.StatusOK, dto.StringResponse{Message: "Successfully deleted"})
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Adjust an image
+// @Summary adjust the brightness, contrast and saturation of an image
+// @Description Save a copy of an image as a new picture in the same format, with its brightness, contrast and saturation changed. Each adjustment is between -1 and 1, 0 changes nothing, and at least one must be given. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param brightness query number false "added to the channels"
+// @Param contrast query number false "scales the channels away from mid gray"
+// @Param saturation query number false "scales the channels away from the luminance, -1 for grays"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/adjust [post]
+func (h *picturesHandler) AdjustPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	values := map[string]float64{}
+	for _, eachKey := range []string{"brightness", "contrast", "saturation"} {
+		value, err := strconv.ParseFloat(c.DefaultQuery(eachKey, "0"), 64)
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", eachKey, err))
+			return
+		}
+		values[eachKey] = value
+	}
+
+	createdPicture, saveError := h.svc.Adjust(id, &dto.Adjustments{
+		Brightness: values["brightness"],
+		Contrast:   values["contrast"],
+		Saturation: values["saturation"],
+	})
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Equalize an image
+// @Summary equalize the histogram of an image
+// @Description Save a copy of an image as a new picture in the same format, with the histogram of its luminance equalized to improve its contrast. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/equalize [post]
+func (h *picturesHandler) EqualizePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	createdPicture, saveError := h.svc.Equalize(id)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Auto level an image
+// @Summary auto level an image
+// @Description Save a copy of an image as a new picture in the same format, with its channels stretched so their darkest value becomes black and their brightest one white. Unlike equalization the values keep their spacing. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param per_channel query boolean false "stretch each channel on its own, true by default, false stretches them all by the luminance"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/autolevel [post]
+func (h *picturesHandler) AutoLevelPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	perChannel, err := strconv.ParseBool(c.DefaultQuery("per_channel", "true"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid per_channel: %w", err))
+		return
+	}
+
+	createdPicture, saveError := h.svc.AutoLevel(id, perChannel)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Blur an image
+// @Summary blur an image
+// @Description Save a copy of an image as a new picture in the same format, blurred with a Gaussian whose standard deviation is a third of the radius. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param radius query number true "blur radius in pixels, at most half the smallest side"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/blur [post]
+func (h *picturesHandler) BlurPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	radius, err := strconv.Atoi(c.Query("radius"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid radius: %w", err))
+		return
+	}
+
+	createdPicture, saveError := h.svc.Blur(id, radius)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Denoise an image
+// @Summary reduce the noise of an image
+// @Description Save a copy of an image as a new picture in the same format, with its noise reduced by a median filter, e.g. for low light photographs. Strengths up to 0.5 filter over 3x3 pixels, the stronger ones over 5x5 pixels. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param strength query number true "above 0 and at most 1"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/denoise [post]
+func (h *picturesHandler) DenoisePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	strength, err := strconv.ParseFloat(c.Query("strength"), 64)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid strength: %w", err))
+		return
+	}
+
+	createdPicture, saveError := h.svc.Denoise(id, strength)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Sharpen an image
+// @Summary sharpen an image
+// @Description Save a copy of an image as a new picture in the same format, sharpened with an unsharp mask: amount times the difference with its Gaussian blur is added, where it is at least threshold levels. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param amount query number false "multiple of the difference with the blur, above 0 and at most 5, 1 by default"
+// @Param radius query number true "blur radius in pixels, at most half the smallest side"
+// @Param threshold query number false "smallest difference sharpened, from 0 to 255, 0 by default"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/sharpen [post]
+func (h *picturesHandler) SharpenPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	amount, err := strconv.ParseFloat(c.DefaultQuery("amount", "1"), 64)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid amount: %w", err))
+		return
+	}
+	radius, err := strconv.Atoi(c.Query("radius"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid radius: %w", err))
+		return
+	}
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", "0"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid threshold: %w", err))
+		return
+	}
+
+	createdPicture, saveError := h.svc.Sharpen(id, &dto.UnsharpMask{Amount: amount, Radius: radius, Threshold: threshold})
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Add a border to an image
+// @Summary add a border to an image
+// @Description Save a copy of an image as a new picture in the same format, padded with a solid border. Each side may be as wide as edits.maxBorderPercent of the height or width of the image. WebPs and PDFs can't be encoded.
+// @Param id path number true "Image Id"
+// @Param top query number false "top border in pixels"
+// @Param right query number false "right border in pixels"
+// @Param bottom query number false "bottom border in pixels"
+// @Param left query number false "left border in pixels"
+// @Param color query string false "#RRGGBB, white by default"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/border [post]
+func (h *picturesHandler) AddPictureBorder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	widths := map[string]int{}
+	for _, eachKey := range []string{"top", "right", "bottom", "left"} {
+		width, err := strconv.Atoi(c.DefaultQuery(eachKey, "0"))
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid %s: %w", eachKey, err))
+			return
+		}
+		widths[eachKey] = width
+	}
+
+	borderColor, err := imaging.ParseHexColor(c.DefaultQuery("color", "#ffffff"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	createdPicture, saveError := h.svc.Border(id, &dto.Border{
+		Top:    widths["top"],
+		Right:  widths["right"],
+		Bottom: widths["bottom"],
+		Left:   widths["left"],
+		Color:  borderColor,
+	})
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Dither an image
+// @Summary dither an image
+// @Description Save a copy of an image as a new indexed PNG picture, reduced to a palette of colors chosen by median cut with Floyd-Steinberg dithering, e.g. for the web. Mostly transparent pixels take a transparent palette entry.
+// @Param id path number true "Image Id"
+// @Param colors query number true "palette size, from 2 to 256"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/dither [post]
+func (h *picturesHandler) DitherPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	colors, err := strconv.Atoi(c.Query("colors"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid colors: %w", err))
+		return
+	}
+
+	createdPicture, saveError := h.svc.Dither(id, colors)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Composite two images
+// @Summary composite two images
+// @Description Save a copy of the base image as a new picture in its format, with the overlay image drawn over it at x, y with the opacity. Overlays extending outside the base are refused unless clip is true. WebPs and PDFs can't be encoded.
+// @Accept json
+// @Param request body dto.CompositeRequest true "base & overlay pictures and the position of the overlay"
+// @Param clip query boolean false "clip the overlay to the base"
+// @Success 201 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/composite [post]
+func (h *picturesHandler) CompositePictures(c *gin.Context) {
+	request := middleware.GetRequest[dto.CompositeRequest](c)
+
+	clip := false
+	if value, ok := c.GetQuery("clip"); ok {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid clip: %w", err))
+			return
+		}
+		clip = parsed
+	}
+
+	createdPicture, saveError := h.svc.Composite(request, clip)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	writePicture(c, http.StatusCreated, createdPicture)
+}
+
+// Generate the deep zoom tiles of an image
+// @Summary generate the deep zoom tiles of an image
+// @Description Cut an image into the JPEG tiles of a Deep Zoom Image tile set, e.g. for OpenSeadragon, replacing its previous tile set. Transparent pixels are flattened on white.
+// @Param id path number true "Image Id"
+// @Success 201 {object} dto.Response{data=dto.Tileset}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/tileset [post]
+func (h *picturesHandler) CreatePictureTileset(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	tileset, saveError := h.svc.CreateTileset(id)
+	if saveError != nil {
+		JSONError(c, saveError.StatusCode, restutil.WithMeta(saveError.Error, saveError.Data))
+		return
+	}
+
+	c.Status(http.StatusCreated)
+	JSONSuccess(c, tileset, nil)
+}
+
+// Get the deep zoom descriptor of an image
+// @Summary get the deep zoom descriptor of an image
+// @Description Get the .dzi XML descriptor of the tile set of an image
+// @Produce xml
+// @Param id path number true "Image Id"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/picture/{id}/tileset [get]
+func (h *picturesHandler) GetPictureTileset(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	descriptor, err := h.svc.GetTilesetDescriptor(id)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+
+	c.Data(http.StatusOK, deepzoom.ContentType, descriptor)
+}
+
+// Get a deep zoom tile of an image
+// @Summary get a deep zoom tile of an image
+// @Description Get a JPEG tile of the tile set of an image
+// @Produce jpeg
+// @Param id path number true "Image Id"
+// @Param level path number true "zoom level, 0 is a single pixel"
+// @Param col_row path string true "column and row of the tile, e.g. 2_1.jpg"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/picture/{id}/tileset/{level}/{col_row} [get]
+func (h *picturesHandler) GetPictureTile(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	level, column, row, err := parseTileParams(c)
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	reader, err := h.svc.GetTile(id, level, column, row)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", "image/jpeg")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, reader)
+}
+
+// Check an image for hidden data
+// @Summary check an image for hidden data
+// @Description Run a chi-square attack on the least significant bits of the pixels of an image. It flags the obvious cases of hidden data, e.g. a message spread over a lossless image, and isn't definitive. Suspicious images have an embedding probability above 0.95.
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.StegCheck}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/steg-check [get]
+func (h *picturesHandler) CheckPictureSteganography(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, checkError := h.svc.CheckSteganography(id)
+	if checkError != nil {
+		JSONError(c, checkError.StatusCode, restutil.WithMeta(checkError.Error, checkError.Data))
+		return
+	}
+
+	JSONSuccess(c, result, nil)
+}
+
+// Get the quality of an image
+// @Summary get the quality of an image
+// @Description Estimate the quality of an image without a reference: the sharpness is the variance of the Laplacian of its luminance and the noise estimate the deviation of its noise. The quality score from 0 to 100 is higher for sharp images without noise, and is stored by the processing pipeline for the min_quality searches.
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.PictureQuality}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/quality [get]
+func (h *picturesHandler) GetPictureQuality(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	quality, qualityError := h.svc.GetQuality(id)
+	if qualityError != nil {
+		JSONError(c, qualityError.StatusCode, restutil.WithMeta(qualityError.Error, qualityError.Data))
+		return
+	}
+
+	JSONSuccess(c, quality, nil)
+}
+
+// Get the srcset of an image
+// @Summary get the srcset of an image
+// @Description List the IIIF URLs of an image scaled down to the breakpoints narrower than it (320, 640, 960, 1280 and 1920 px wide) and of the image at its own width, along with the srcset attribute of an <img> tag. Nothing is resized until the URLs are requested.
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.Srcset}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Router /v1/picture/{id}/srcset [get]
+func (h *picturesHandler) GetPictureSrcset(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	srcset, err := h.svc.GetSrcset(id)
+	if err != nil {
+		JSONProblem(c, pictureFileProblem(err))
+		return
+	}
+
+	JSONSuccess(c, srcset, nil)
+}
+
+// Get the placeholder of an image
+// @Summary get the placeholder of an image
+// @Description Get the data URI of a tiny JPEG of an image, fitting in size x size pixels, to use as the src of an <img> while the image loads. Browsers blur it while scaling it up.
+// @Param id path number true "Image Id"
+// @Param size query number false "largest side in pixels, from 1 to 50, 10 by default"
+// @Success 200 {object} dto.Response{data=dto.Placeholder}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/placeholder [get]
+func (h *picturesHandler) GetPicturePlaceholder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("invalid size: %w", err))
+		return
+	}
+
+	placeholder, placeholderError := h.svc.GetPlaceholder(id, size)
+	if placeholderError != nil {
+		JSONError(c, placeholderError.StatusCode, restutil.WithMeta(placeholderError.Error, placeholderError.Data))
+		return
+	}
+
+	JSONSuccess(c, placeholder, nil)
+}
+
+// Tag an image
+// @Summary tag an image
+// @Description Add a tag to an image, along with the IPTC keywords found on upload. Adding a tag it already has changes nothing.
+// @Accept json
+// @Param id path number true "Image Id"
+// @Param request body dto.TagRequest true "tag to add"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/tags [post]
+func (h *picturesHandler) AddPictureTag(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request := middleware.GetRequest[dto.TagRequest](c)
+	picture, tagError := h.svc.AddTag(id, request.Tag)
+	if tagError != nil {
+		JSONError(c, tagError.StatusCode, tagError.Error)
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
+
+// Set the retention of an image
+// @Summary set the retention of an image
+// @Description Keep an image past server.retentionDays, after which the images never viewed are removed, until retain_until. A null retain_until removes the override.
+// @Accept json
+// @Param id path number true "Image Id"
+// @Param request body dto.RetentionRequest true "time until which the image is kept"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Router /v1/picture/{id}/retention [put]
+func (h *picturesHandler) SetPictureRetention(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request := middleware.GetRequest[dto.RetentionRequest](c)
+	picture, retentionError := h.svc.SetRetention(id, request.RetainUntil)
+	if retentionError != nil {
+		JSONError(c, retentionError.StatusCode, retentionError.Error)
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
+
+// parseVersion reads the optional version of the picture an update is based
+// on, 0 when missing.
+func parseVersion(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version %q, expected a positive integer", value)
+	}
+	return version, nil
+}
+
+// parseTileParams reads the level and the column_row.jpg name of a tile.
+func parseTileParams(c *gin.Context) (int, int, int, error) {
+	level, err := strconv.Atoi(c.Param("level"))
+	if err != nil || level < 0 {
+		return 0, 0, 0, fmt.Errorf("invalid level: %q", c.Param("level"))
+	}
+
+	name, ok := strings.CutSuffix(c.Param("col_row"), "."+deepzoom.Format)
+	columnValue, rowValue, found := strings.Cut(name, "_")
+	column, columnErr := strconv.Atoi(columnValue)
+	row, rowErr := strconv.Atoi(rowValue)
+	if !ok || !found || columnErr != nil || rowErr != nil || column < 0 || row < 0 {
+		return 0, 0, 0, fmt.Errorf("invalid tile: %q", c.Param("col_row"))
+	}
+	return level, column, row, nil
+}
+
+func parseFrameParams(c *gin.Context) (int, int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err := strconv.Atoi(c.Param("n"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return id, n, nil
+}
+
+// Get a batch of images
+// @Summary get a batch of images
+// @Description Get the image files of several pictures as the parts of a single multipart/mixed response. Every file is opened before the response is written, the first one that can't be served fails the whole batch with its problem, which gives the id of the picture.
+// @Produce multipart/mixed
+// @Param ids query string true "comma separated image ids"
+// @Success 200 {file} multipart/mixed
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/pictures/batch [get]
+func (h *picturesHandler) GetPictureFilesBatch(c *gin.Context) {
+	rawIds := strings.Split(c.Query("ids"), ",")
+	maxBatchSize := config.GetConfigInt("server.maxBatchSize")
+	if len(rawIds) > maxBatchSize {
+		JSONError(c, http.StatusBadRequest, fmt.Errorf("can't request more than %d pictures at once", maxBatchSize))
+		return
+	}
+
+	// the files are all opened before the status line is sent, so that a
+	// picture that can't be served fails the batch rather than cutting it
+	// short
+	parts := make([]*batchPart, 0, len(rawIds))
+	defer func() {
+		for _, eachPart := range parts {
+			eachPart.reader.Close()
+		}
+	}()
+	for _, rawId := range rawIds {
+		id, err := strconv.Atoi(strings.TrimSpace(rawId))
+		if err != nil {
+			JSONError(c, http.StatusBadRequest, err)
+			return
+		}
+
+		picture, err := h.svc.Get(id)
+		if err != nil {
+			JSONProblem(c, restutil.NewNotFoundProblem(restutil.WithMeta(err, gin.H{"id": id})))
+			return
+		}
+
+		reader, contentType, _, err := h.svc.GetFileReader(id)
+		if err != nil {
+			problem := pictureFileProblem(err)
+			problem.Extensions["id"] = id
+			JSONProblem(c, problem)
+			return
+		}
+		parts = append(parts, &batchPart{picture: picture, reader: reader, contentType: contentType})
+	}
+
+	writer := multipart.NewWriter(c.Writer)
+	c.Header("Content-Type", "multipart/mixed; boundary="+writer.Boundary())
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	for _, eachPart := range parts {
+		if err := writePicturePart(writer, eachPart); err != nil {
+			// the status line is already sent, so the response can only be cut short
+			log.Println("Unable to write batch picture part: ", err)
+			return
+		}
+	}
+
+	writer.Close()
+}
+
+// batchPart is a picture of a batch along with its opened image file.
+type batchPart struct {
+	picture     *dto.PictureResponse
+	reader      io.ReadCloser
+	contentType string
+}
+
+func writePicturePart(writer *multipart.Writer, batchPart *batchPart) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {batchPart.contentType},
+		"Content-Disposition": {mime.FormatMediaType("attachment", map[string]string{"filename": batchPart.picture.Name})},
+		"Content-Id":          {fmt.Sprintf("<picture-%d>", batchPart.picture.Id)},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, batchPart.reader)
+	return err
+}
+
+// List the versions of an image
+// @Summary list the versions of an image
+// @Description List the versions of an image kept by its updates, the current one first. With storage.s3.versioning, the S3 object versions the bucket kept of the files of the image, listed by ListObjectVersions, whose version ids are the S3 ones.
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=[]dto.PictureVersion}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/versions [get]
+func (h *picturesHandler) ListPictureVersions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	versions, err := h.svc.ListVersions(id)
+	if err != nil {
+		JSONProblem(c, versionProblem(err))
+		return
+	}
+
+	JSONSuccess(c, versions, newListMeta(len(versions)))
+}
+
+// Get a version of an image
+// @Summary get a version of an image
+// @Description Download a specific historical version of an image file
+// @Param id path number true "Image Id"
+// @Param version_id path string true "Version Id"
+// @Success 200 {file} octet-stream
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 451 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id}/versions/{version_id} [get]
+func (h *picturesHandler) GetPictureVersion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	stream, contentType, err := h.svc.GetVersion(id, c.Param("version_id"))
+	if err != nil {
+		JSONProblem(c, versionProblem(err))
+		return
+	}
+	defer stream.Close()
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.DataFromReader(http.StatusOK, -1, contentType, stream, nil)
+}
+
+func versionProblem(err error) *dto.Problem {
+	var downloadError *storage.S3DownloadError
+	if errors.As(err, &downloadError) {
+		return restutil.NewStorageErrorProblem(err)
+	}
+	return pictureFileProblem(err)
+}
+
+// Get a single image data
+// @Summary get a single image data
+// @Description Get a specified image with its metadata by its ID
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Router /v1/picture/{id} [get]
+func (h *picturesHandler) GetPicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	picture, err := h.svc.Get(id)
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	if config.GetConfigBool("server.http2Push") {
+		pushPictureFiles(c, picture)
+	}
+
+	cacheFor(c, pictureCacheMaxAge, 0)
+	writePicture(c, http.StatusOK, picture)
+}
+
+// Delete a single image
+// @Summary delete a single image
+// @Description Delete a specified image along with its metadata by its ID
+// @Param id path number true "Image Id"
+// @Param version query integer false "version of the picture the deletion is based on, refused with a 409 when the picture changed in between"
+// @Success 200 {object} dto.Response{data=dto.StringResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem "the picture changed in between, current_version gives its version"
+// @Failure 500 {object} dto.Problem
+// @Router /v1/picture/{id} [delete]
+func (h *picturesHandler) DeletePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	version, err := parseVersion(c.Query("version"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.svc.Delete(actorContext(c), id, version)
+	if conflict := (*db.VersionConflictError)(nil); errors.As(err, &conflict) {
+		JSONError(c, http.StatusConflict, restutil.WithMeta(conflict, gin.H{"current_version": conflict.CurrentVersion}))
+		return
+	}
+	if err != nil {
+		JSONProblem(c, restutil.NewNotFoundProblem(err))
+		return
+	}
+
+	JSONSuccess(c, dto.StringResponse{Message: "Successfully deleted"}, nil)
+}
+
+// pushPictureFiles pushes the image bytes and thumbnail to HTTP/2 clients
+// along with the picture metadata. The Link preload header is the fallback
+// for HTTP/1.1 clients and proxies that drop PUSH_PROMISE frames.
+func pushPictureFiles(c *gin.Context, picture *dto.PictureResponse) {
+	paths := []string{fmt.Sprintf("/%s/picture/%d/image", config.APIVersion, picture.Id)}
+	if picture.ThumbnailUrl != "" {
+		paths = append(paths, fmt.Sprintf("/%s/picture/%d/thumbnail", config.APIVersion, picture.Id))
+	}
+
+	pusher := c.Writer.Pusher()
+	for _, path := range paths {
+		c.Writer.Header().Add("Link", fmt.Sprintf("<%s>; rel=preload; as=image", path))
+
+		if pusher == nil {
+			continue
+		}
+		if err := pusher.Push(path, nil); err != nil {
+			log.Println("Unable to push picture file: ", err)
+		}
+	}
 }
@@ -0,0 +1,114 @@
+package resthandlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/restutil"
+	"imagenexus/db"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSONSuccess responds with the data in the response envelope, with the
+// status set with c.Status or 200. meta is nil for single resources.
+func JSONSuccess(c *gin.Context, data any, meta *dto.ListMeta) {
+	restutil.WriteSuccess(c, c.Writer.Status(), data, meta)
+}
+
+// JSONError responds with an about:blank problem for the errors. Wrap an
+// error with restutil.WithMeta to add extension members to it.
+func JSONError(c *gin.Context, statusCode int, errs ...error) {
+	restutil.WriteErrors(c, statusCode, errs...)
+}
+
+// JSONProblem responds with one of the typed problems, e.g.
+// restutil.NewNotFoundProblem.
+func JSONProblem(c *gin.Context, problem *dto.Problem) {
+	restutil.WriteProblem(c, problem)
+}
+
+// formImage returns the image file of the multipart form, answering with a
+// 413 when the body is past the server.maxRequestBodyBytes limit and with a
+// 400 for the other failures.
+func formImage(c *gin.Context) (*multipart.FileHeader, bool) {
+	file, err := c.FormFile("image")
+	if limit, tooLarge := middleware.IsBodyTooLarge(err); tooLarge {
+		JSONError(c, http.StatusRequestEntityTooLarge, restutil.WithMeta(middleware.ErrBodyTooLarge, gin.H{"max_size": limit}))
+		return nil, false
+	}
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return nil, false
+	}
+	return file, true
+}
+
+// actorContext is the context of the request, recording the changes made
+// with it in the audit log as made by the authenticated user.
+func actorContext(c *gin.Context) context.Context {
+	if claims := middleware.GetClaims(c); claims != nil {
+		return db.WithActor(c.Request.Context(), claims.Subject)
+	}
+	return c.Request.Context()
+}
+
+// newListMeta is the meta of the lists that are not paged.
+func newListMeta(total int) *dto.ListMeta {
+	return &dto.ListMeta{Total: total}
+}
+
+// cacheFor lets the clients and the shared caches, CDNs included with the
+// Surrogate-Control header, reuse the response for maxAge, and serve it stale
+// for staleWhileRevalidate more while revalidating it, 0 for none. The
+// responses of the authenticated requests are only cached by the clients.
+func cacheFor(c *gin.Context, maxAge, staleWhileRevalidate time.Duration) {
+	directives := fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+	if staleWhileRevalidate > 0 {
+		directives += fmt.Sprintf(", stale-while-revalidate=%d", int(staleWhileRevalidate.Seconds()))
+	}
+
+	if middleware.GetClaims(c) != nil {
+		c.Header("Cache-Control", "private, "+directives)
+		return
+	}
+	c.Header("Cache-Control", "public, "+directives)
+	c.Header("Surrogate-Control", directives)
+}
+
+// writeETag sets the ETag of the response to a hash of its contents, for the
+// Accept header of the request, which picks their format and shape. It
+// answers with a 304 and returns true when the If-None-Match header of the
+// request matches it.
+func writeETag(c *gin.Context, contents ...any) bool {
+	hash := sha256.New()
+	hash.Write([]byte(c.GetHeader("Accept")))
+	for _, each := range contents {
+		data, err := json.Marshal(each)
+		if err != nil {
+			return false
+		}
+		hash.Write(data)
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	c.Header("ETag", etag)
+
+	for _, eachTag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		eachTag = strings.TrimPrefix(strings.TrimSpace(eachTag), "W/")
+		if eachTag == etag || eachTag == "*" {
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return true
+		}
+	}
+	return false
+}
@@ -4,31 +4,92 @@ import (
 	"net/http"
 	"time"
 
-	"imagenexus/api/restutil"
+	"imagenexus/dto"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ServerHandler interface {
 	HealthCheck(*gin.Context)
+	Liveness(*gin.Context)
+	Readiness(*gin.Context)
+	StorageCircuit(*gin.Context)
 }
 
+// ReadinessCheck reports whether a dependency of the server can be used.
+type ReadinessCheck func() error
+
 type serverHandler struct {
-	startAt time.Time
+	startAt         time.Time
+	readinessChecks map[string]ReadinessCheck
+	storageCircuit  func() string
 }
 
-func NewServerHandler() ServerHandler {
-	return &serverHandler{startAt: time.Now().UTC()}
+// NewServerHandler reports the readiness checks and the state of the circuit
+// breaker of the image storage given by storageCircuit.
+func NewServerHandler(readinessChecks map[string]ReadinessCheck, storageCircuit func() string) ServerHandler {
+	return &serverHandler{startAt: time.Now().UTC(), readinessChecks: readinessChecks, storageCircuit: storageCircuit}
 }
 
+// Health check
+// @Summary health check
+// @Description Get the start time and uptime of the server along with the IP address of the client
+// @Success 200 {object} dto.Response{data=map[string]string}
+// @Router /healthcheck [get]
 func (h *serverHandler) HealthCheck(c *gin.Context) {
 	now := time.Now().UTC()
 
 	uptime := now.Sub(h.startAt)
 
-	restutil.WriteAsJson(c, http.StatusOK, gin.H{
+	JSONSuccess(c, gin.H{
 		"started_at": h.startAt.String(),
 		"uptime":     uptime.String(),
 		"ip_address": c.ClientIP(),
-	})
+	}, nil)
+}
+
+// Liveness probe
+// @Summary liveness probe
+// @Description Succeeds as long as the server is able to answer requests
+// @Success 200 {object} dto.Response{data=dto.ProbeResponse}
+// @Router /healthz [get]
+func (h *serverHandler) Liveness(c *gin.Context) {
+	JSONSuccess(c, dto.ProbeResponse{Status: "ok"}, nil)
+}
+
+// Readiness probe
+// @Summary readiness probe
+// @Description Succeeds when the dependencies of the server, such as the database, are reachable
+// @Success 200 {object} dto.Response{data=dto.ProbeResponse}
+// @Failure 503 {object} dto.Response{data=dto.ProbeResponse}
+// @Router /readyz [get]
+func (h *serverHandler) Readiness(c *gin.Context) {
+	response := dto.ProbeResponse{Status: "ok", Checks: map[string]string{}}
+	statusCode := http.StatusOK
+
+	for name, check := range h.readinessChecks {
+		response.Checks[name] = "ok"
+		if err := check(); err != nil {
+			response.Checks[name] = err.Error()
+			response.Status = "unavailable"
+			statusCode = http.StatusServiceUnavailable
+		}
+	}
+
+	c.Status(statusCode)
+	JSONSuccess(c, response, nil)
+}
+
+// Storage circuit breaker
+// @Summary storage circuit breaker
+// @Description Get the state of the circuit breaker of the image storage: closed, half-open while a call is tried after a timeout, or open while the storage calls fail right away with a 503
+// @Success 200 {object} dto.Response{data=dto.ProbeResponse}
+// @Failure 503 {object} dto.Response{data=dto.ProbeResponse}
+// @Router /healthz/storage [get]
+func (h *serverHandler) StorageCircuit(c *gin.Context) {
+	state := h.storageCircuit()
+	if state == "open" {
+		c.Status(http.StatusServiceUnavailable)
+	}
+	JSONSuccess(c, dto.ProbeResponse{Status: state}, nil)
 }
@@ -0,0 +1,109 @@
+package resthandlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+	"imagenexus/service"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TierHandler interface {
+	RestorePicture(*gin.Context)
+	SetPictureTier(*gin.Context)
+}
+
+type tierHandler struct {
+	svc service.TierService
+}
+
+func NewTierHandler(tierService service.TierService) TierHandler {
+	return &tierHandler{svc: tierService}
+}
+
+func tierProblem(err error) *dto.Problem {
+	var notFound *storage.S3NotFoundError
+
+	switch {
+	case errors.Is(err, service.ErrPictureNotFound), errors.As(err, &notFound):
+		return restutil.NewNotFoundProblem(err)
+	case errors.Is(err, service.ErrTiersNotSupported):
+		return restutil.NewProblem(http.StatusNotImplemented, err)
+	case errors.Is(err, service.ErrUnknownTier):
+		return restutil.NewProblem(http.StatusBadRequest, err)
+	case errors.Is(err, service.ErrPictureArchived):
+		return restutil.NewProblem(http.StatusConflict, err)
+	}
+	return restutil.NewStorageErrorProblem(err)
+}
+
+// Restore an image
+// @Summary restore an image
+// @Description Request the restore of the archived file of a cold image, answering 202 with a Retry-After header until it can be served
+// @Param id path number true "Image Id"
+// @Success 200 {object} dto.Response{data=dto.RestoreStatus}
+// @Success 202 {object} dto.Response{data=dto.RestoreStatus}
+// @Failure 400 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/picture/{id}/restore [get]
+func (h *tierHandler) RestorePicture(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	status, err := h.svc.Restore(id)
+	if err != nil {
+		JSONProblem(c, tierProblem(err))
+		return
+	}
+
+	if status.Status == storage.ObjectRestoring {
+		c.Header("Retry-After", strconv.Itoa(status.RetryAfterSeconds))
+		c.Status(http.StatusAccepted)
+	}
+	JSONSuccess(c, status, nil)
+}
+
+// Set the storage tier of an image
+// @Summary set the storage tier of an image
+// @Description Move the file of an image to the S3 storage class of the hot, warm or cold tier. Cold images have to be restored first.
+// @Security BearerAuth
+// @Accept json
+// @Param id path number true "Image Id"
+// @Param request body dto.StorageTierRequest true "storage tier"
+// @Success 200 {object} dto.Response{data=dto.PictureResponse}
+// @Failure 400 {object} dto.Problem
+// @Failure 401 {object} dto.Problem
+// @Failure 403 {object} dto.Problem
+// @Failure 404 {object} dto.Problem
+// @Failure 409 {object} dto.Problem
+// @Failure 422 {object} dto.Problem
+// @Failure 500 {object} dto.Problem
+// @Failure 501 {object} dto.Problem
+// @Router /v1/admin/pictures/{id}/tier [put]
+func (h *tierHandler) SetPictureTier(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		JSONError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	request := middleware.GetRequest[dto.StorageTierRequest](c)
+	picture, err := h.svc.SetTier(id, request.Tier)
+	if err != nil {
+		JSONProblem(c, tierProblem(err))
+		return
+	}
+
+	writePicture(c, http.StatusOK, picture)
+}
@@ -0,0 +1,109 @@
+package restutil
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ProblemContentType = "application/problem+json"
+
+// The types of the problems with a meaning of their own. The other problems
+// have the about:blank type and the status text as title.
+const (
+	BlankProblemType          = "about:blank"
+	NotFoundProblemType       = "urn:imagenexus:problem:not-found"
+	ValidationProblemType     = "urn:imagenexus:problem:validation"
+	StorageErrorProblemType   = "urn:imagenexus:problem:storage-error"
+	DataCorruptionProblemType = "urn:imagenexus:problem:data-corruption"
+)
+
+// NewProblem describes the errors as an about:blank problem. The meta
+// attached to a single error with WithMeta becomes extension members, while
+// several errors are listed in the errors member.
+func NewProblem(statusCode int, errs ...error) *dto.Problem {
+	problem := &dto.Problem{
+		Type:       BlankProblemType,
+		Title:      http.StatusText(statusCode),
+		Status:     statusCode,
+		Extensions: map[string]any{},
+	}
+
+	responseErrors := make([]*dto.ResponseError, 0, len(errs))
+	details := make([]string, 0, len(errs))
+	for _, each := range errs {
+		var responseError *dto.ResponseError
+		if !errors.As(each, &responseError) {
+			responseError = &dto.ResponseError{Message: each.Error()}
+		}
+		responseErrors = append(responseErrors, responseError)
+		details = append(details, each.Error())
+	}
+	problem.Detail = strings.Join(details, "; ")
+
+	if len(responseErrors) == 1 {
+		for name, value := range responseErrors[0].Meta {
+			problem.Extensions[name] = value
+		}
+	} else if len(responseErrors) > 1 {
+		problem.Errors = responseErrors
+	}
+	return problem
+}
+
+func NewNotFoundProblem(err error) *dto.Problem {
+	problem := NewProblem(http.StatusNotFound, err)
+	problem.Type = NotFoundProblemType
+	problem.Title = "Resource not found"
+	return problem
+}
+
+// NewValidationProblem lists the failed validations of the request body
+// fields in the errors member.
+func NewValidationProblem(fields []*dto.ResponseError) *dto.Problem {
+	return &dto.Problem{
+		Type:   ValidationProblemType,
+		Title:  "Invalid request body",
+		Status: http.StatusUnprocessableEntity,
+		Detail: "the request body failed the validation of its fields",
+		Errors: fields,
+	}
+}
+
+// NewStorageErrorProblem is the problem of a failure of the image storage,
+// such as an unreachable S3 bucket.
+func NewStorageErrorProblem(err error) *dto.Problem {
+	problem := NewProblem(http.StatusInternalServerError, err)
+	problem.Type = StorageErrorProblemType
+	problem.Title = "Storage error"
+	return problem
+}
+
+// NewDataCorruptionProblem is the problem of a stored file that doesn't match
+// the checksum it was saved with, e.g. after a silent failure of the disk.
+func NewDataCorruptionProblem(err error) *dto.Problem {
+	problem := NewProblem(http.StatusInternalServerError, err)
+	problem.Type = DataCorruptionProblemType
+	problem.Title = "Data corruption"
+	problem.Detail = "the stored file of the picture is corrupted, it doesn't match the checksum of its upload: " + problem.Detail
+	return problem
+}
+
+// WriteProblem writes the problem as application/problem+json, or in the
+// format picked by the ContentNegotiation middleware, with the path of the
+// request as the instance and the request id.
+func WriteProblem(c *gin.Context, problem *dto.Problem) {
+	if problem.Instance == "" {
+		problem.Instance = c.Request.URL.Path
+	}
+	if requestId := c.GetString(RequestIdKey); requestId != "" {
+		problem.RequestId = requestId
+	}
+
+	encoder := getEncoder(c)
+	c.Render(problem.Status, encodedRender{encoder, encoder.ProblemContentType(), problem})
+}
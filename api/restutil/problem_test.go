@@ -0,0 +1,63 @@
+package restutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteProblem(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name    string
+		write   func(*gin.Context)
+		problem map[string]any
+	}{
+		{
+			name: "about:blank",
+			write: func(c *gin.Context) {
+				WriteError(c, http.StatusRequestEntityTooLarge, errors.New("too large"), gin.H{"max_size": 10})
+			},
+			problem: map[string]any{
+				"type":     BlankProblemType,
+				"title":    "Request Entity Too Large",
+				"status":   float64(http.StatusRequestEntityTooLarge),
+				"detail":   "too large",
+				"instance": "/picture/1",
+				"max_size": float64(10),
+			},
+		},
+		{
+			name:  "not found",
+			write: func(c *gin.Context) { WriteProblem(c, NewNotFoundProblem(errors.New("record not found"))) },
+			problem: map[string]any{
+				"type":     NotFoundProblemType,
+				"title":    "Resource not found",
+				"status":   float64(http.StatusNotFound),
+				"detail":   "record not found",
+				"instance": "/picture/1",
+			},
+		},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/picture/1", nil)
+			each.write(c)
+
+			var problem map[string]any
+			assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+			assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, int(each.problem["status"].(float64)), recorder.Code)
+			assert.Equal(t, each.problem, problem)
+		})
+	}
+}
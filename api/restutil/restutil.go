@@ -1,16 +1,85 @@
 package restutil
 
 import (
+	"net/http"
+
+	"imagenexus/config"
 	"imagenexus/dto"
+	"imagenexus/encoding"
 
 	"github.com/gin-gonic/gin"
 )
 
+// The context keys of the request values the middleware stores for the meta
+// of the responses.
+const (
+	RequestIdKey  = "request_id"
+	APIVersionKey = "api_version"
+	EncoderKey    = "encoder"
+)
+
+// WriteAsJson writes the data without the response envelope, for the
+// documents whose shape is set by another spec, e.g. IIIF info.json.
 func WriteAsJson(c *gin.Context, statusCode int, data any) {
 	c.JSON(statusCode, data)
 }
 
+// WriteSuccess writes the data in the response envelope. list is nil for
+// single resources.
+func WriteSuccess(c *gin.Context, statusCode int, data any, list *dto.ListMeta) {
+	encoder := getEncoder(c)
+	c.Render(statusCode, encodedRender{encoder, encoder.ContentType(), dto.Response{Data: data, Meta: newMeta(c, list)}})
+}
+
+// getEncoder returns the encoder picked by the ContentNegotiation
+// middleware, JSON without one.
+func getEncoder(c *gin.Context) encoding.Encoder {
+	if encoder, ok := c.Value(EncoderKey).(encoding.Encoder); ok {
+		return encoder
+	}
+	return encoding.JSON
+}
+
+// encodedRender renders the value with the encoder, under the content type.
+type encodedRender struct {
+	encoder     encoding.Encoder
+	contentType string
+	value       any
+}
+
+func (r encodedRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return r.encoder.Encode(w, r.value)
+}
+
+func (r encodedRender) WriteContentType(w http.ResponseWriter) {
+	if header := w.Header(); len(header["Content-Type"]) == 0 {
+		header["Content-Type"] = []string{r.contentType}
+	}
+}
+
+// WriteErrors writes the errors as an about:blank problem, see NewProblem.
+func WriteErrors(c *gin.Context, statusCode int, errs ...error) {
+	WriteProblem(c, NewProblem(statusCode, errs...))
+}
+
 func WriteError(c *gin.Context, statusCode int, err error, data gin.H) {
-	errorObject := dto.GeneralErrorResponse{Error: err.Error(), Meta: data}
-	WriteAsJson(c, statusCode, errorObject)
+	WriteErrors(c, statusCode, WithMeta(err, data))
+}
+
+// WithMeta attaches the meta to the error, for the extension members of its
+// problem.
+func WithMeta(err error, meta gin.H) error {
+	if len(meta) == 0 {
+		return err
+	}
+	return &dto.ResponseError{Message: err.Error(), Meta: meta}
+}
+
+func newMeta(c *gin.Context, list *dto.ListMeta) *dto.ResponseMeta {
+	version := c.GetString(APIVersionKey)
+	if version == "" {
+		version = config.APIVersion
+	}
+	return &dto.ResponseMeta{RequestId: c.GetString(RequestIdKey), Version: version, ListMeta: list}
 }
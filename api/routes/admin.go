@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"net/http"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/resthandlers"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewAdminRoutes(handlers resthandlers.AdminHandler) []*Route {
+	adminOnly := []gin.HandlerFunc{middleware.RequireRole("admin")}
+
+	return []*Route{
+		{Path: "/admin/storage/lifecycle", Method: http.MethodGet, Handler: handlers.ListLifecycleRules, Middleware: adminOnly},
+		{Path: "/admin/storage/lifecycle", Method: http.MethodPost, Handler: handlers.PutLifecycleRule, Middleware: []gin.HandlerFunc{
+			middleware.RequireRole("admin"),
+			middleware.Validator[dto.LifecycleRule](),
+		}},
+		{Path: "/admin/storage/lifecycle/:id", Method: http.MethodDelete, Handler: handlers.DeleteLifecycleRule, Middleware: adminOnly},
+		{Path: "/admin/storage/cost-estimate", Method: http.MethodGet, Handler: handlers.EstimateStorageCost, Middleware: adminOnly},
+		{Path: "/admin/pictures/:id/reprocess", Method: http.MethodPost, Handler: handlers.ReprocessPicture, Middleware: adminOnly},
+		{Path: "/admin/pictures/reprocess-all", Method: http.MethodPost, Handler: handlers.ReprocessAllPictures, Middleware: adminOnly},
+		{Path: "/admin/jobs/:job_id", Method: http.MethodGet, Handler: handlers.GetProcessingJob, Middleware: adminOnly},
+		{Path: "/admin/config/reload", Method: http.MethodPost, Handler: handlers.ReloadConfig, Middleware: adminOnly},
+		{Path: "/admin/audit-log", Method: http.MethodGet, Handler: handlers.ListAuditLog, Middleware: adminOnly},
+	}
+}
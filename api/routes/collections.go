@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"net/http"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/resthandlers"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewCollectionsRoutes(handlers resthandlers.CollectionsHandler) []*Route {
+	return []*Route{
+		{Path: "/collections", Method: http.MethodPost, Handler: handlers.CreateCollection, Middleware: []gin.HandlerFunc{middleware.Validator[dto.CollectionRequest]()}},
+		{Path: "/collections/:id", Method: http.MethodGet, Handler: handlers.GetCollection},
+		{Path: "/collections/:id", Method: http.MethodDelete, Handler: handlers.DeleteCollection},
+		{Path: "/collections/:id/pictures/:pic_id", Method: http.MethodPost, Handler: handlers.AddCollectionPicture},
+		{Path: "/collections/:id/pictures/:pic_id", Method: http.MethodDelete, Handler: handlers.RemoveCollectionPicture},
+		{Path: "/collections/:id/pictures/:pic_id/pin", Method: http.MethodPost, Handler: handlers.PinCollectionPicture},
+		{Path: "/collections/:id/pictures/:pic_id/pin", Method: http.MethodDelete, Handler: handlers.UnpinCollectionPicture},
+		{Path: "/collections/:id/pin-order", Method: http.MethodPatch, Handler: handlers.ReorderCollectionPins},
+		{Path: "/collections/:id/sprite", Method: http.MethodPost, Handler: handlers.CreateSprite},
+		{Path: "/collections/:id/animate", Method: http.MethodPost, Handler: handlers.CreateAnimation},
+	}
+}
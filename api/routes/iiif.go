@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"net/http"
+
+	"imagenexus/api/resthandlers"
+)
+
+func NewIIIFRoutes(handlers resthandlers.IIIFHandler) []*Route {
+	return []*Route{
+		{Path: "/iiif/:identifier/info.json", Method: http.MethodGet, Handler: handlers.GetInfo},
+		{Path: "/iiif/:identifier/:region/:size/:rotation/:quality_format", Method: http.MethodGet, Handler: handlers.GetImage},
+	}
+}
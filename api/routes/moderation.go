@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"net/http"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/resthandlers"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewModerationRoutes(handlers resthandlers.ModerationHandler) []*Route {
+	adminOnly := []gin.HandlerFunc{middleware.RequireRole("admin")}
+
+	return []*Route{
+		{Path: "/picture/:id/flag", Method: http.MethodPost, Handler: handlers.FlagPicture, Middleware: []gin.HandlerFunc{
+			middleware.Validator[dto.ModerationFlagRequest](),
+		}},
+		{Path: "/admin/moderation/queue", Method: http.MethodGet, Handler: handlers.ListModerationQueue, Middleware: adminOnly},
+		{Path: "/admin/pictures/:id/approve", Method: http.MethodPost, Handler: handlers.ApprovePicture, Middleware: adminOnly},
+		{Path: "/admin/pictures/:id/reject", Method: http.MethodPost, Handler: handlers.RejectPicture, Middleware: adminOnly},
+	}
+}
@@ -3,15 +3,73 @@ package routes
 import (
 	"net/http"
 
+	"imagenexus/api/middleware"
 	"imagenexus/api/resthandlers"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
 )
 
-func NewPicturesRoutes(handlers resthandlers.PicturesHandler) []*Route {
+// NewPicturesRoutes lists the routes of the pictures. idempotency replays the
+// uploads and the batch imports retried with the same X-Idempotency-Key, see
+// middleware.Idempotency. It's ahead of the validation of the imports, so a
+// retry is replayed without being parsed, but behind the body limit, as it
+// hashes the body of the retries.
+func NewPicturesRoutes(handlers resthandlers.PicturesHandler, idempotency gin.HandlerFunc) []*Route {
 	return []*Route{
 		{Path: "/", Method: http.MethodGet, Handler: handlers.ListPictures},
+		{Path: "/pictures", Method: http.MethodGet, Handler: handlers.SearchPictures},
+		{Path: "/pictures/nearby", Method: http.MethodGet, Handler: handlers.SearchNearbyPictures},
 		{Path: "/picture/:id", Method: http.MethodGet, Handler: handlers.GetPicture},
+		{Path: "/picture/:id/location", Method: http.MethodGet, Handler: handlers.GetPictureLocation},
 		{Path: "/picture/:id/image", Method: http.MethodGet, Handler: handlers.GetPictureFile},
-		{Path: "/", Method: http.MethodPost, Handler: handlers.CreatePicture},
+		{Path: "/picture/:id/file", Method: http.MethodGet, Handler: handlers.GetPictureOriginal},
+		{Path: "/picture/:id/versions", Method: http.MethodGet, Handler: handlers.ListPictureVersions},
+		{Path: "/picture/:id/versions/:version_id", Method: http.MethodGet, Handler: handlers.GetPictureVersion},
+		{Path: "/picture/:id/thumbnail", Method: http.MethodGet, Handler: handlers.GetPictureThumbnail},
+		{Path: "/picture/:id/thumbnail/animated", Method: http.MethodGet, Handler: handlers.GetPictureAnimatedThumbnail},
+		{Path: "/picture/:id/icc", Method: http.MethodGet, Handler: handlers.GetPictureICCProfile},
+		{Path: "/picture/:id/xmp", Method: http.MethodGet, Handler: handlers.GetPictureXMP},
+		{Path: "/picture/:id/frames", Method: http.MethodGet, Handler: handlers.ListPictureFrames},
+		{Path: "/picture/:id/frames/:n", Method: http.MethodGet, Handler: handlers.GetPictureFrame},
+		{Path: "/picture/:id/tileset", Method: http.MethodGet, Handler: handlers.GetPictureTileset},
+		{Path: "/picture/:id/tileset/:level/:col_row", Method: http.MethodGet, Handler: handlers.GetPictureTile},
+		{Path: "/picture/:id/steg-check", Method: http.MethodGet, Handler: handlers.CheckPictureSteganography},
+		{Path: "/picture/:id/quality", Method: http.MethodGet, Handler: handlers.GetPictureQuality},
+		{Path: "/picture/:id/srcset", Method: http.MethodGet, Handler: handlers.GetPictureSrcset},
+		{Path: "/picture/:id/placeholder", Method: http.MethodGet, Handler: handlers.GetPicturePlaceholder},
+		{Path: "/pictures/batch", Method: http.MethodGet, Handler: handlers.GetPictureFilesBatch},
+		{Path: "/", Method: http.MethodPost, Handler: handlers.CreatePicture, Middleware: []gin.HandlerFunc{idempotency}},
+		{Path: "/picture/base64", Method: http.MethodPost, Handler: handlers.CreatePictureFromBase64, Middleware: []gin.HandlerFunc{
+			middleware.LimitBody(resthandlers.Base64PictureBodyLimit),
+			middleware.Validator[dto.Base64PictureRequest](),
+		}},
+		{Path: "/pictures/import/datauri", Method: http.MethodPost, Handler: handlers.ImportDataURIPictures, Middleware: []gin.HandlerFunc{
+			middleware.LimitBody(resthandlers.DataURIImportBodyLimit),
+			idempotency,
+			middleware.Validator[dto.DataURIImportRequest](),
+		}},
+		{Path: "/picture/:id/frames/:n/save", Method: http.MethodPost, Handler: handlers.SavePictureFrame},
+		{Path: "/picture/:id/alpha", Method: http.MethodPost, Handler: handlers.ChangePictureAlpha},
+		{Path: "/picture/:id/grayscale", Method: http.MethodPost, Handler: handlers.ChangePictureTone},
+		{Path: "/picture/:id/adjust", Method: http.MethodPost, Handler: handlers.AdjustPicture},
+		{Path: "/picture/:id/equalize", Method: http.MethodPost, Handler: handlers.EqualizePicture},
+		{Path: "/picture/:id/autolevel", Method: http.MethodPost, Handler: handlers.AutoLevelPicture},
+		{Path: "/picture/:id/blur", Method: http.MethodPost, Handler: handlers.BlurPicture},
+		{Path: "/picture/:id/denoise", Method: http.MethodPost, Handler: handlers.DenoisePicture},
+		{Path: "/picture/:id/sharpen", Method: http.MethodPost, Handler: handlers.SharpenPicture},
+		{Path: "/picture/:id/border", Method: http.MethodPost, Handler: handlers.AddPictureBorder},
+		{Path: "/picture/:id/dither", Method: http.MethodPost, Handler: handlers.DitherPicture},
+		{Path: "/picture/composite", Method: http.MethodPost, Handler: handlers.CompositePictures, Middleware: []gin.HandlerFunc{
+			middleware.Validator[dto.CompositeRequest](),
+		}},
+		{Path: "/picture/:id/tileset", Method: http.MethodPost, Handler: handlers.CreatePictureTileset},
+		{Path: "/picture/:id/tags", Method: http.MethodPost, Handler: handlers.AddPictureTag, Middleware: []gin.HandlerFunc{
+			middleware.Validator[dto.TagRequest](),
+		}},
+		{Path: "/picture/:id/retention", Method: http.MethodPut, Handler: handlers.SetPictureRetention, Middleware: []gin.HandlerFunc{
+			middleware.Validator[dto.RetentionRequest](),
+		}},
 		{Path: "/picture/:id", Method: http.MethodDelete, Handler: handlers.DeletePicture},
 		{Path: "/picture/:id", Method: http.MethodPut, Handler: handlers.UpdatePicture},
 	}
@@ -1,17 +1,54 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
 type Route struct {
-	Path    string
-	Method  string
-	Handler gin.HandlerFunc
+	Path       string
+	Method     string
+	Handler    gin.HandlerFunc
+	Middleware []gin.HandlerFunc
 }
 
 func Install(router *gin.Engine, routeList []*Route) {
+	install(router, routeList)
+}
+
+// InstallVersion registers the routes under the version prefix, e.g.
+// /v1/picture/:id for version v1.
+func InstallVersion(router *gin.Engine, version string, routeList []*Route) {
+	install(router.Group("/"+version), routeList)
+}
+
+// RedirectUnversioned permanently redirects the paths of the routes without
+// a version prefix to the same paths of the version, while the unversioned
+// paths are deprecated. Requests other than GET and HEAD get a 308 so the
+// clients keep their method and body.
+func RedirectUnversioned(router *gin.Engine, version string, routeList []*Route) {
+	for _, route := range routeList {
+		router.Handle(route.Method, route.Path, func(c *gin.Context) {
+			location := "/" + version + c.Request.URL.Path
+			if c.Request.URL.RawQuery != "" {
+				location += "?" + c.Request.URL.RawQuery
+			}
+
+			statusCode := http.StatusPermanentRedirect
+			if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+				statusCode = http.StatusMovedPermanently
+			}
+
+			c.Header("Deprecation", "true")
+			c.Redirect(statusCode, location)
+		})
+	}
+}
+
+func install(router gin.IRoutes, routeList []*Route) {
 	for _, route := range routeList {
-		router.Handle(route.Method, route.Path, route.Handler)
+		handlers := append(append([]gin.HandlerFunc{}, route.Middleware...), route.Handler)
+		router.Handle(route.Method, route.Path, handlers...)
 	}
 }
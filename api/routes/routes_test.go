@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	routeList := []*Route{
+		{Path: "/picture/:id", Method: http.MethodGet, Handler: func(c *gin.Context) { c.String(http.StatusOK, c.Param("id")) }},
+		{Path: "/", Method: http.MethodPost, Handler: func(c *gin.Context) { c.Status(http.StatusCreated) }},
+	}
+
+	router := gin.New()
+	InstallVersion(router, "v1", routeList)
+	RedirectUnversioned(router, "v1", routeList)
+
+	cases := []struct {
+		method     string
+		path       string
+		statusCode int
+		location   string
+	}{
+		{http.MethodGet, "/v1/picture/7", http.StatusOK, ""},
+		{http.MethodPost, "/v1/", http.StatusCreated, ""},
+		{http.MethodGet, "/picture/7?download=1", http.StatusMovedPermanently, "/v1/picture/7?download=1"},
+		{http.MethodPost, "/", http.StatusPermanentRedirect, "/v1/"},
+	}
+
+	for _, each := range cases {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(each.method, each.path, nil))
+		assert.Equal(t, each.statusCode, recorder.Code, each.path)
+		assert.Equal(t, each.location, recorder.Header().Get("Location"), each.path)
+	}
+}
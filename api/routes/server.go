@@ -9,5 +9,8 @@ import (
 func NewServerRouteList(handlers resthandlers.ServerHandler) []*Route {
 	return []*Route{
 		{Path: "/healthcheck", Method: http.MethodGet, Handler: handlers.HealthCheck},
+		{Path: "/healthz", Method: http.MethodGet, Handler: handlers.Liveness},
+		{Path: "/healthz/storage", Method: http.MethodGet, Handler: handlers.StorageCircuit},
+		{Path: "/readyz", Method: http.MethodGet, Handler: handlers.Readiness},
 	}
 }
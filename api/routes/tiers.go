@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"net/http"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/resthandlers"
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+func NewTierRoutes(handlers resthandlers.TierHandler) []*Route {
+	return []*Route{
+		{Path: "/picture/:id/restore", Method: http.MethodGet, Handler: handlers.RestorePicture},
+		{Path: "/admin/pictures/:id/tier", Method: http.MethodPut, Handler: handlers.SetPictureTier, Middleware: []gin.HandlerFunc{
+			middleware.RequireRole("admin"),
+			middleware.Validator[dto.StorageTierRequest](),
+		}},
+	}
+}
@@ -0,0 +1,266 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"imagenexus/api/middleware"
+	"imagenexus/api/resthandlers"
+	"imagenexus/api/routes"
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/docs"
+	"imagenexus/service"
+	"imagenexus/storage"
+	"imagenexus/video"
+	"imagenexus/webhook"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"gorm.io/gorm"
+)
+
+// NewRouter wires the repositories, services and handlers of the api on the
+// database and the storages, and starts the processing workers. videoStorage
+// is nil when video uploads are disabled.
+func NewRouter(dbHandler *gorm.DB, dbConfig db.Configuration, imageStorage storage.ImageStorage, videoStorage storage.VideoStorage) *gin.Engine {
+	// gin.New rather than gin.Default, whose own Logger and Recovery would
+	// run next to the ones below
+	router := gin.New()
+	// the X-Forwarded-For header is only trusted from these proxies, none by default
+	if err := router.SetTrustedProxies(config.GetConfigStrings("server.trustedProxies")); err != nil {
+		log.Fatalf("Invalid server.trustedProxies: %v", err)
+	}
+	// RequestID middleware tags each request with the id quoted in the response meta.
+	router.Use(middleware.RequestID())
+	// IPFilter middleware rejects the clients outside of the allowlist or in the blocklist.
+	router.Use(newIPFilter())
+	// Logger middleware will write the logs to gin.DefaultWriter = os.Stdout
+	router.Use(gin.Logger())
+	// SlowRequestLogger middleware warns about the requests slower than server.slowRequestThreshold.
+	router.Use(middleware.SlowRequestLogger(time.Duration(config.GetConfigInt("server.slowRequestThreshold")) * time.Millisecond))
+	// Compression middleware gzips or brotli-compresses the JSON and XML responses.
+	router.Use(middleware.Compression(config.GetConfigInt("server.compressionMinBytes")))
+	// Recovery middleware recovers from any panics and writes a 500 problem if there was one.
+	router.Use(middleware.Recovery())
+	// SecurityHeaders middleware sets the CSP and other browser hardening headers.
+	router.Use(middleware.SecurityHeaders())
+	// Authenticate middleware verifies bearer tokens and stores their claims.
+	router.Use(middleware.Authenticate())
+	// ContentNegotiation middleware picks the response format from the Accept header.
+	router.Use(middleware.ContentNegotiation())
+	// APIVersion middleware picks the response shapes from the Accept header.
+	router.Use(middleware.APIVersion())
+	// BodyLimit middleware caps the whole request bodies, multipart forms included.
+	router.Use(middleware.BodyLimit(int64(config.GetConfigInt("server.maxRequestBodyBytes"))))
+	router.MaxMultipartMemory = 8 << 20 // 8 MiB
+
+	// Set swagger data
+	docs.SwaggerInfo.Title = "Cat Pictures"
+	docs.SwaggerInfo.Description = "RESTful API for uploading and managing cat pictures."
+	docs.SwaggerInfo.Schemes = []string{"http"}
+
+	repository := db.NewPicturesRepository(dbHandler, dbConfig)
+
+	events := webhook.NewDispatcher(config.GetConfigStrings("webhook.urls"), config.GetConfigValue("webhook.secret"))
+
+	processingService := service.NewProcessingService(repository, imageStorage, events, config.GetConfigInt("processing.thumbnailSize"))
+	processingService.Start(config.GetConfigInt("processing.workers"))
+
+	var videoService service.VideoService
+	if videoStorage != nil {
+		extractor := video.NewExtractor(
+			config.GetConfigValue("video.ffprobe"),
+			config.GetConfigValue("video.ffmpeg"),
+			time.Duration(config.GetConfigInt("video.timeout"))*time.Second,
+		)
+		videoService = service.NewVideoService(imageStorage, videoStorage, extractor)
+	}
+
+	picturesService := service.NewPicturesService(repository, imageStorage, processingService, events, videoService)
+	handler := resthandlers.NewPicturesHandler(picturesService)
+	idempotencyService := service.NewIdempotencyService(db.NewIdempotencyRepository(dbHandler))
+	idempotencyService.Start(time.Hour)
+	routesList := routes.NewPicturesRoutes(handler, middleware.Idempotency(idempotencyService))
+
+	collectionsRepository := db.NewCollectionsRepository(dbHandler)
+	collectionsService := service.NewCollectionsService(collectionsRepository, repository, imageStorage, picturesService)
+	collectionsHandler := resthandlers.NewCollectionsHandler(collectionsService)
+	collectionsRoutesList := routes.NewCollectionsRoutes(collectionsHandler)
+
+	iiifService := service.NewIIIFService(repository, imageStorage)
+	iiifHandler := resthandlers.NewIIIFHandler(iiifService)
+	iiifRoutesList := routes.NewIIIFRoutes(iiifHandler)
+
+	storageAdminService := service.NewStorageAdminService(imageStorage)
+	auditService := service.NewAuditService(db.NewAuditRepository(dbHandler))
+	storageCostService := service.NewStorageCostService(repository, imageStorage, events)
+	storageCostService.Start(24 * time.Hour)
+	adminHandler := resthandlers.NewAdminHandler(storageAdminService, processingService, auditService, storageCostService)
+	adminRoutesList := routes.NewAdminRoutes(adminHandler)
+
+	moderationService := service.NewModerationService(repository)
+	moderationHandler := resthandlers.NewModerationHandler(moderationService)
+	moderationRoutesList := routes.NewModerationRoutes(moderationHandler)
+
+	tierService := service.NewTierService(repository, imageStorage)
+	tierService.Start(time.Hour)
+	retentionService := service.NewRetentionService(repository, imageStorage, videoStorage, events)
+	retentionService.Start(24 * time.Hour)
+	tierHandler := resthandlers.NewTierHandler(tierService)
+	tierRoutesList := routes.NewTierRoutes(tierHandler)
+
+	serverHandler := resthandlers.NewServerHandler(map[string]resthandlers.ReadinessCheck{
+		"database": func() error { return db.Ping(dbHandler) },
+	}, storageCircuitState(imageStorage))
+	serverRoutesList := routes.NewServerRouteList(serverHandler)
+
+	apiRoutesList := slices.Concat(routesList, collectionsRoutesList, iiifRoutesList, adminRoutesList, moderationRoutesList, tierRoutesList)
+	routes.InstallVersion(router, config.APIVersion, apiRoutesList)
+	routes.RedirectUnversioned(router, config.APIVersion, apiRoutesList)
+	routes.Install(router, serverRoutesList)
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	return router
+}
+
+// storageCircuitState reports the state of the circuit breaker of the
+// storage, always closed without one.
+func storageCircuitState(imageStorage storage.ImageStorage) func() string {
+	breaker, ok := storage.Capability[storage.CircuitStater](imageStorage)
+	if !ok {
+		return func() string { return "closed" }
+	}
+	return breaker.CircuitState
+}
+
+func newIPFilter() gin.HandlerFunc {
+	allowed, err := middleware.ParseIPRanges(config.GetConfigStrings("server.ipAllowlist"))
+	if err != nil {
+		log.Fatalf("Invalid server.ipAllowlist: %v", err)
+	}
+	blocked, err := middleware.ParseIPRanges(config.GetConfigStrings("server.ipBlocklist"))
+	if err != nil {
+		log.Fatalf("Invalid server.ipBlocklist: %v", err)
+	}
+	return middleware.IPFilter(allowed, blocked)
+}
+
+// NewImageStorage returns the storage backend selected by storage.backend,
+// trialling storage.featureFlag.newBackend when there's one, replicated to
+// storage.backup.backend when backups are enabled and guarded by a circuit
+// breaker unless storage.circuitBreaker.threshold is 0. It fails on unknown
+// storage.namingStrategy settings.
+func NewImageStorage() (storage.ImageStorage, error) {
+	imageStorage, err := newReplicatedStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := config.GetConfigInt("storage.circuitBreaker.threshold")
+	if threshold <= 0 {
+		return imageStorage, nil
+	}
+	timeout := time.Duration(config.GetConfigInt("storage.circuitBreaker.timeout")) * time.Second
+	return storage.NewCircuitBreakerStorage(imageStorage, uint32(threshold), timeout), nil
+}
+
+func newReplicatedStorage() (storage.ImageStorage, error) {
+	if _, err := storage.NewNamingStrategy(config.GetConfigValue("storage.namingStrategy")); err != nil {
+		return nil, err
+	}
+
+	var primary storage.ImageStorage
+	var err error
+	if config.GetConfigBool("storage.routing.enabled") {
+		primary, err = newRoutingStorage()
+	} else {
+		primary, err = NewStorageBackend(config.GetConfigValue("storage.backend"), config.GetConfigValue("server.imagePath"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate := config.GetConfigValue("storage.featureFlag.newBackend"); candidate != "" {
+		newBackend, err := NewStorageBackend(candidate, config.GetConfigValue("storage.featureFlag.imagePath"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to create the new storage backend: %w", err)
+		}
+		primary = storage.NewFeatureFlagStorage(primary, newBackend, config.GetConfigInt("storage.featureFlag.rolloutPercent"))
+	}
+
+	if !config.GetConfigBool("storage.backup.enabled") {
+		return primary, nil
+	}
+
+	backup, err := NewStorageBackend(config.GetConfigValue("storage.backup.backend"), config.GetConfigValue("storage.backup.imagePath"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create backup storage: %w", err)
+	}
+
+	return storage.NewReplicatingStorage(primary, backup), nil
+}
+
+// newRoutingStorage returns the storage saving the files to the backends of
+// storage.routing.backends by the rules of storage.routing.rules. Each
+// backend is local:<directory> or s3:<prefix within storage.s3.bucket>.
+func newRoutingStorage() (storage.ImageStorage, error) {
+	rules, err := storage.ParseRoutingRules(config.GetConfigStrings("storage.routing.rules"))
+	if err != nil {
+		return nil, err
+	}
+
+	backends := map[string]storage.ImageStorage{}
+	for name, location := range config.GetConfigMap("storage.routing.backends") {
+		backend, path, _ := strings.Cut(location, ":")
+		var routed storage.ImageStorage
+		switch backend {
+		case "local":
+			routed = storage.NewStorage(path)
+		case "s3":
+			routed, err = storage.NewS3StorageWithPrefix(path)
+		default:
+			err = fmt.Errorf("unknown storage backend: %s", backend)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to create routing backend %s: %w", name, err)
+		}
+		backends[name] = routed
+	}
+
+	return storage.NewRoutingStorage(rules, backends)
+}
+
+// NewStorageBackend returns the local or s3 storage backend, without any
+// backup. imagePath is the directory of the local backend.
+func NewStorageBackend(backend, imagePath string) (storage.ImageStorage, error) {
+	switch backend {
+	case "", "local":
+		return storage.NewStorage(imagePath), nil
+	case "s3":
+		return storage.NewS3Storage()
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", backend)
+	}
+}
+
+// NewVideoStorage returns the storage backend selected by
+// storage.video.backend, or nil when video uploads are disabled.
+func NewVideoStorage() (storage.VideoStorage, error) {
+	if !config.GetConfigBool("video.enabled") {
+		return nil, nil
+	}
+
+	switch backend := config.GetConfigValue("storage.video.backend"); backend {
+	case "", "local":
+		return storage.NewLocalVideoStorage(config.GetConfigValue("storage.video.path")), nil
+	case "s3":
+		return storage.NewS3VideoStorage(config.GetConfigValue("storage.video.prefix"))
+	default:
+		return nil, fmt.Errorf("unknown video storage backend: %s", backend)
+	}
+}
@@ -0,0 +1,215 @@
+// Package client is the Go client of the Image Nexus REST API.
+//
+//	c := client.NewClient("https://images.example.com", &client.ClientOptions{
+//		Token: os.Getenv("IMAGENEXUS_TOKEN"),
+//	})
+//	picture, err := c.Upload(ctx, file, "cat.png")
+//
+// It only depends on the standard library, so it can be used without the
+// dependencies of the server. See _examples for complete programs.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the API the paths of the requests are
+// prefixed with.
+const apiVersion = "v1"
+
+// ClientOptions configure a Client. The zero value retries the failed requests
+// 3 times, 500ms then 1s then 2s apart.
+type ClientOptions struct {
+	// sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+	// the JWT sent as a bearer token, the requests are anonymous without one
+	Token string
+	// the number of retries of a failed request, -1 for none
+	MaxRetries int
+	// the delay before the first retry, doubled for each of the next ones
+	RetryBackoff time.Duration
+}
+
+// Client calls the REST API of an Image Nexus server. It's safe for
+// concurrent use.
+type Client struct {
+	baseURL      string
+	token        string
+	http         *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+// NewClient returns a client of the server at baseURL, e.g.
+// https://images.example.com. opts may be nil.
+func NewClient(baseURL string, opts *ClientOptions) *Client {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+
+	c := &Client{
+		baseURL:      strings.TrimSuffix(baseURL, "/") + "/" + apiVersion,
+		token:        opts.Token,
+		http:         opts.HTTPClient,
+		maxRetries:   opts.MaxRetries,
+		retryBackoff: opts.RetryBackoff,
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = 3
+	}
+	if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.retryBackoff <= 0 {
+		c.retryBackoff = 500 * time.Millisecond
+	}
+	return c
+}
+
+// APIError is the problem document of a failed request, see RFC 7807.
+type APIError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	// the version of the picture for the 409s of the updates based on
+	// another one
+	CurrentVersion int `json:"current_version,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Title, e.Detail)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Title)
+}
+
+// IsNotFound tells whether the error is a 404 of the API.
+func IsNotFound(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusNotFound
+}
+
+// IsConflict tells whether the error is a 409 of the API, i.e. the picture
+// changed since the version the request was based on.
+func IsConflict(err error) bool {
+	var apiError *APIError
+	return errors.As(err, &apiError) && apiError.StatusCode == http.StatusConflict
+}
+
+// request is a request that can be sent again, its body is kept in memory.
+type request struct {
+	method      string
+	path        string
+	contentType string
+	body        []byte
+}
+
+// send sends the request, retrying it on connection errors and on the
+// statuses telling the server couldn't handle it. The POST requests are
+// only retried when the server says it didn't handle them, 429 and 503, so
+// a picture isn't uploaded twice. The caller is responsible for closing the
+// body of the response.
+func (c *Client) send(ctx context.Context, r *request) (*http.Response, error) {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.sendOnce(ctx, r)
+		if attempt == c.maxRetries || !retryable(r.method, response, err) {
+			if err != nil {
+				return nil, err
+			}
+			if response.StatusCode >= 300 {
+				defer response.Body.Close()
+				return nil, readError(response)
+			}
+			return response, nil
+		}
+
+		delay := backoff
+		if response != nil {
+			if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				delay = time.Duration(seconds) * time.Second
+			}
+			io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, r *request) (*http.Response, error) {
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, r.method, c.baseURL+r.path, body)
+	if err != nil {
+		return nil, err
+	}
+	if r.contentType != "" {
+		httpRequest.Header.Set("Content-Type", r.contentType)
+	}
+	if c.token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.http.Do(httpRequest)
+}
+
+func retryable(method string, response *http.Response, err error) bool {
+	if err != nil {
+		// the context errors are final
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && method != http.MethodPost
+	}
+
+	switch response.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodPost
+	}
+	return false
+}
+
+func readError(response *http.Response) error {
+	apiError := &APIError{StatusCode: response.StatusCode}
+	if err := json.NewDecoder(response.Body).Decode(apiError); err != nil || apiError.Title == "" {
+		apiError.Title = http.StatusText(response.StatusCode)
+	}
+	return apiError
+}
+
+// getJSON sends the request and decodes the data of the response envelope
+// into data.
+func (c *Client) getJSON(ctx context.Context, r *request, data any) error {
+	response, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	envelope := struct {
+		Data any `json:"data"`
+	}{data}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("unable to read the response of %s %s: %w", r.method, r.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, &ClientOptions{Token: "token", RetryBackoff: time.Millisecond})
+}
+
+func TestUpload(t *testing.T) {
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/v1/", r.URL.Path)
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		file, header, err := r.FormFile("image")
+		if assert.Nil(t, err) {
+			data, _ := io.ReadAll(file)
+			assert.Equal(t, "cat.png", header.Filename)
+			assert.Equal(t, "picture", string(data))
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"data":{"id":7,"name":"cat.png","tags":["cat"]},"meta":{"request_id":"1"}}`)
+	})
+
+	picture, err := c.Upload(context.Background(), strings.NewReader("picture"), "cat.png")
+	if assert.Nil(t, err) {
+		assert.Equal(t, uint(7), picture.Id)
+		assert.Equal(t, []string{"cat"}, picture.Tags)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"id":1}}`)
+	})
+
+	picture, err := c.Get(context.Background(), "1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, uint(1), picture.Id)
+	}
+	assert.Equal(t, 3, attempts)
+
+	// the uploads aren't retried when the server may have handled them
+	attempts = 0
+	_, err = c.Upload(context.Background(), strings.NewReader("picture"), "cat.png")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestErrors(t *testing.T) {
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"type":"about:blank","title":"Not Found","status":404,"detail":"record not found"}`)
+	})
+
+	_, err := c.Get(context.Background(), "404")
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, "404 Not Found: record not found", err.Error())
+
+	assert.True(t, IsNotFound(c.Delete(context.Background(), "404")))
+}
+
+func TestListAndImageBytes(t *testing.T) {
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/pictures":
+			assert.Equal(t, "grayscale=true&page=2", r.URL.RawQuery)
+			fmt.Fprint(w, `{"data":[{"id":1},{"id":2}],"meta":{"total":2}}`)
+		case "/v1/picture/1/image":
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "png")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	grayscale := true
+	pictures, err := c.List(context.Background(), ListOptions{Page: 2, Grayscale: &grayscale})
+	if assert.Nil(t, err) && assert.Len(t, pictures, 2) {
+		assert.Equal(t, uint(2), pictures[1].Id)
+	}
+
+	data, contentType, err := c.GetImageBytes(context.Background(), "1")
+	if assert.Nil(t, err) {
+		assert.Equal(t, "png", string(data))
+		assert.Equal(t, "image/png", contentType)
+	}
+}
+
+func TestRetryOnConflict(t *testing.T) {
+	version, updates := 1, 0
+	c := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprintf(w, `{"data":{"id":1,"version":%d}}`, version)
+		case http.MethodPut:
+			updates++
+			// another client updates the picture before the first update
+			if updates == 1 {
+				version++
+			}
+			if r.FormValue("version") != fmt.Sprint(version) {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprintf(w, `{"title":"Conflict","status":409,"current_version":%d}`, version)
+				return
+			}
+			version++
+			fmt.Fprintf(w, `{"data":{"id":1,"version":%d}}`, version)
+		}
+	})
+
+	var updated *Picture
+	err := c.RetryOnConflict(context.Background(), "1", func(picture *Picture) error {
+		var err error
+		updated, err = c.Update(context.Background(), "1", strings.NewReader("picture"), "cat.png", picture.Version)
+		return err
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, 3, updated.Version)
+	}
+	assert.Equal(t, 2, updates)
+
+	_, err = c.Update(context.Background(), "1", strings.NewReader("picture"), "cat.png", 1)
+	var apiError *APIError
+	if assert.True(t, IsConflict(err)) && assert.ErrorAs(t, err, &apiError) {
+		assert.Equal(t, 3, apiError.CurrentVersion)
+	}
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Picture is an uploaded image along with its computed metadata.
+type Picture struct {
+	Id uint `json:"id"`
+	// incremented by every update, see Client.RetryOnConflict
+	Version     int      `json:"version"`
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Height      int32    `json:"height"`
+	Width       int32    `json:"width"`
+	Size        string   `json:"size"`
+	ContentType string   `json:"content_type"`
+	Checksum    string   `json:"checksum"`
+	IsAnimated  bool     `json:"is_animated"`
+	IsGrayscale bool     `json:"is_grayscale"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+
+	ThumbnailUrl string `json:"thumbnail_url,omitempty"`
+	// set for the GIFs with several frames
+	ThumbnailAnimatedUrl string `json:"thumbnail_animated_url,omitempty"`
+	PerceptualHash       string `json:"perceptual_hash,omitempty"`
+	HasICCProfile        bool   `json:"has_icc_profile"`
+	XMPPresent           bool   `json:"xmp_present"`
+	IPTC                 *IPTC  `json:"iptc,omitempty"`
+	// set for the first frames of uploaded videos only
+	Video *Video `json:"video,omitempty"`
+	// from 0 to 100, computed by the processing pipeline
+	QualityScore *float64 `json:"quality_score,omitempty"`
+	Processed    bool     `json:"processed"`
+	// pending, approved or rejected
+	ModerationStatus string `json:"moderation_status"`
+	ModerationReason string `json:"moderation_reason,omitempty"`
+	// hot, warm or cold
+	StorageTier string `json:"storage_tier"`
+
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+type IPTC struct {
+	Keywords  []string `json:"keywords"`
+	Copyright string   `json:"copyright,omitempty"`
+	Credit    string   `json:"credit,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+}
+
+type Video struct {
+	DurationSeconds float64 `json:"duration_seconds"`
+	FrameRate       float64 `json:"frame_rate"`
+	VideoCodec      string  `json:"video_codec"`
+}
+
+// ListOptions select the page of pictures to list. The filters are
+// optional, nil for any picture.
+type ListOptions struct {
+	// starting from 1, the first page when 0
+	Page       int
+	Grayscale  *bool
+	MinQuality *float64
+}
+
+// Upload uploads the image read from r, named filename. The image is read in
+// memory so the upload can be retried.
+func (c *Client) Upload(ctx context.Context, r io.Reader, filename string) (*Picture, error) {
+	return c.sendImage(ctx, http.MethodPost, "/", r, filename, nil)
+}
+
+// Update replaces the image of the picture with the one read from r. Unless
+// version is 0, the update is refused with a 409 when the picture is no
+// longer at the version, see RetryOnConflict.
+func (c *Client) Update(ctx context.Context, id string, r io.Reader, filename string, version int) (*Picture, error) {
+	fields := map[string]string{}
+	if version != 0 {
+		fields["version"] = strconv.Itoa(version)
+	}
+	return c.sendImage(ctx, http.MethodPut, "/picture/"+url.PathEscape(id), r, filename, fields)
+}
+
+// sendImage sends the image as the image field of a multipart form, along
+// with the other fields.
+func (c *Client) sendImage(ctx context.Context, method, path string, r io.Reader, filename string, fields map[string]string) (*Picture, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("image", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	picture := &Picture{}
+	err = c.getJSON(ctx, &request{
+		method:      method,
+		path:        path,
+		contentType: form.FormDataContentType(),
+		body:        body.Bytes(),
+	}, picture)
+	if err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+func (c *Client) Get(ctx context.Context, id string) (*Picture, error) {
+	picture := &Picture{}
+	if err := c.getJSON(ctx, &request{method: http.MethodGet, path: "/picture/" + url.PathEscape(id)}, picture); err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+// List lists a page of the pictures, the most recently updated first, or
+// of the pictures matching the filters of the options.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]Picture, error) {
+	query := url.Values{}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+
+	path := "/"
+	if opts.Grayscale != nil || opts.MinQuality != nil {
+		path = "/pictures"
+		if opts.Grayscale != nil {
+			query.Set("grayscale", strconv.FormatBool(*opts.Grayscale))
+		}
+		if opts.MinQuality != nil {
+			query.Set("min_quality", strconv.FormatFloat(*opts.MinQuality, 'f', -1, 64))
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	pictures := []Picture{}
+	if err := c.getJSON(ctx, &request{method: http.MethodGet, path: path}, &pictures); err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.DeleteVersion(ctx, id, 0)
+}
+
+// DeleteVersion deletes the picture, provided it's still at the version
+// unless version is 0. The deletion is refused with a 409 otherwise.
+func (c *Client) DeleteVersion(ctx context.Context, id string, version int) error {
+	path := "/picture/" + url.PathEscape(id)
+	if version != 0 {
+		path += "?version=" + strconv.Itoa(version)
+	}
+	response, err := c.send(ctx, &request{method: http.MethodDelete, path: path})
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// RetryOnConflict gets the picture and calls fn with it, which updates or
+// deletes it based on its version. When the picture changed in between, the
+// 409 of the update is retried by getting the picture and calling fn again,
+// as many times as the retries of the client. The last error of fn is
+// returned.
+//
+//	err := c.RetryOnConflict(ctx, id, func(picture *client.Picture) error {
+//		_, err := c.Update(ctx, id, bytes.NewReader(image), "cat.png", picture.Version)
+//		return err
+//	})
+func (c *Client) RetryOnConflict(ctx context.Context, id string, fn func(*Picture) error) error {
+	for attempt := 0; ; attempt++ {
+		picture, err := c.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = fn(picture); attempt == c.maxRetries || !IsConflict(err) {
+			return err
+		}
+	}
+}
+
+// GetImageBytes downloads the image file of the picture, returning it along
+// with its content type.
+func (c *Client) GetImageBytes(ctx context.Context, id string) ([]byte, string, error) {
+	response, err := c.send(ctx, &request{method: http.MethodGet, path: "/picture/" + url.PathEscape(id) + "/image"})
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, response.Header.Get("Content-Type"), nil
+}
@@ -0,0 +1,218 @@
+// Command admin runs the maintenance tasks of an Image Nexus deployment on
+// its database and storage directly, with the config.toml of the server:
+//
+//	admin reconcile
+//	admin purge-deleted [--dry-run]
+//	admin reprocess-all [--dry-run] [--workers 4]
+//	admin migrate-storage --from local --to s3 [--dry-run]
+//	admin migrate-prefixes [--dry-run]
+//	admin stats
+//
+// The reports are printed as JSON on stdout, the logs go to stderr. Only
+// migrate-storage runs without the database.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"imagenexus/app"
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/service"
+	"imagenexus/webhook"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "admin",
+		Short:        "Maintain the database and the storage of Image Nexus",
+		SilenceUsage: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			if err := config.Init(config.File()); err != nil {
+				return fmt.Errorf("unable to read the config file: %w", err)
+			}
+			return nil
+		},
+	}
+
+	root.AddCommand(
+		&cobra.Command{
+			Use:   "reconcile",
+			Short: "Compare the pictures of the database with the files of the storage",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				maintenance, err := newMaintenanceService()
+				if err != nil {
+					return err
+				}
+				return printReport(maintenance.Reconcile())
+			},
+		},
+		newPurgeDeletedCommand(),
+		newReprocessAllCommand(),
+		newMigrateStorageCommand(),
+		newMigratePrefixesCommand(),
+		&cobra.Command{
+			Use:   "stats",
+			Short: "Summarize the pictures and the storage usage",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				maintenance, err := newMaintenanceService()
+				if err != nil {
+					return err
+				}
+				return printReport(maintenance.Stats())
+			},
+		},
+	)
+	return root
+}
+
+// newMaintenanceService connects to the database and the storages of the
+// server.
+func newMaintenanceService() (service.MaintenanceService, error) {
+	// the SQL logs go to stderr, leaving stdout to the reports
+	logger.Default = logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{
+		SlowThreshold: time.Second,
+		LogLevel:      logger.Warn,
+	})
+	dbConfig := db.NewConfiguration()
+	dbHandler, err := db.NewConnection(dbConfig)
+	if err != nil {
+		return nil, err
+	}
+	dbHandler.Logger = logger.Default
+	repository := db.NewPicturesRepository(dbHandler, dbConfig)
+
+	imageStorage, err := app.NewImageStorage()
+	if err != nil {
+		return nil, err
+	}
+	videoStorage, err := app.NewVideoStorage()
+	if err != nil {
+		return nil, err
+	}
+
+	events := webhook.NewDispatcher(config.GetConfigStrings("webhook.urls"), config.GetConfigValue("webhook.secret"))
+	processing := service.NewProcessingService(repository, imageStorage, events, config.GetConfigInt("processing.thumbnailSize"))
+	return service.NewMaintenanceService(repository, imageStorage, videoStorage, processing), nil
+}
+
+func newPurgeDeletedCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "purge-deleted",
+		Short: "Remove the soft deleted pictures and their files for good",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			maintenance, err := newMaintenanceService()
+			if err != nil {
+				return err
+			}
+			return printReport(maintenance.PurgeDeleted(dryRun))
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the pictures and files without removing them")
+	return cmd
+}
+
+func newReprocessAllCommand() *cobra.Command {
+	var dryRun bool
+	var workers int
+	cmd := &cobra.Command{
+		Use:   "reprocess-all",
+		Short: "Run every picture through the processing pipeline again",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			maintenance, err := newMaintenanceService()
+			if err != nil {
+				return err
+			}
+			if workers == 0 {
+				workers = config.GetConfigInt("processing.workers")
+			}
+			return printReport(maintenance.ReprocessAll(workers, dryRun))
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "count the pictures without processing them")
+	cmd.Flags().IntVar(&workers, "workers", 0, "pictures processed at once (default processing.workers)")
+	return cmd
+}
+
+func newMigrateStorageCommand() *cobra.Command {
+	var dryRun bool
+	var from, to string
+	cmd := &cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Copy the files of a storage backend to another",
+		Long: "Copy every file of a storage backend to another, at the same destinations. " +
+			"Switch storage.backend to the target once done. The files already copied are skipped.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if from == to {
+				return fmt.Errorf("the source and target backends are both %s", from)
+			}
+
+			// the local backend is the directory of server.imagePath
+			imagePath := config.GetConfigValue("server.imagePath")
+			source, err := app.NewStorageBackend(from, imagePath)
+			if err != nil {
+				return err
+			}
+			target, err := app.NewStorageBackend(to, imagePath)
+			if err != nil {
+				return err
+			}
+			return printReport(service.MigrateStorage(source, target, dryRun))
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "count the files without copying them")
+	cmd.Flags().StringVar(&from, "from", "", "source backend, local or s3")
+	cmd.Flags().StringVar(&to, "to", "", "target backend, local or s3")
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func newMigratePrefixesCommand() *cobra.Command {
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "migrate-prefixes",
+		Short: "Move the files of the S3 storage under the hashed prefixes of their contents",
+		Long: "Move the files of the pictures saved before storage.s3.hashedPrefixes was enabled " +
+			"under the hashed prefixes of their contents, e.g. ab/cd/abcdef...jpg, and update the pictures. " +
+			"Enable storage.s3.hashedPrefixes first. The files already moved are skipped.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			maintenance, err := newMaintenanceService()
+			if err != nil {
+				return err
+			}
+			return printReport(maintenance.MigrateHashedPrefixes(dryRun))
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "count the files without moving them")
+	return cmd
+}
+
+func printReport(report any, err error) error {
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/dto"
+)
+
+// client calls the versioned REST API of a server, authenticating with the
+// API key as a bearer token when there is one.
+type client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+func newClient(serverURL, apiKey string) *client {
+	return &client{
+		baseURL: strings.TrimSuffix(serverURL, "/") + "/" + config.APIVersion,
+		apiKey:  apiKey,
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+// envelope is the body of the successful responses, keeping the data as
+// sent so it's printed without losing the fields of newer servers.
+type envelope struct {
+	Data json.RawMessage   `json:"data"`
+	Meta *dto.ResponseMeta `json:"meta"`
+}
+
+func (c *client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		request.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	return request, nil
+}
+
+// do sends the request and reads the envelope of the response, turning the
+// problem documents of the failures into errors.
+func (c *client) do(request *http.Request) (*envelope, error) {
+	response, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	result := &envelope{}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("unable to read the response of %s: %w", request.URL, err)
+	}
+	return result, nil
+}
+
+// send sends the request, returning the response of the successful ones
+// only. The caller is responsible for closing its body.
+func (c *client) send(request *http.Request) (*http.Response, error) {
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 300 {
+		return response, nil
+	}
+	defer response.Body.Close()
+
+	problem := &dto.Problem{}
+	if err := json.NewDecoder(response.Body).Decode(problem); err != nil || problem.Title == "" {
+		return nil, fmt.Errorf("%s %s: %s", request.Method, request.URL.Path, response.Status)
+	}
+	if problem.Detail != "" {
+		return nil, fmt.Errorf("%s: %s", problem.Title, problem.Detail)
+	}
+	return nil, fmt.Errorf("%s", problem.Title)
+}
+
+func (c *client) get(path string) (*envelope, error) {
+	request, err := c.newRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(request)
+}
+
+func (c *client) postJSON(path string, body any) (*envelope, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := c.newRequest(http.MethodPost, path, bytes.NewReader(encoded))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	return c.do(request)
+}
+
+func (c *client) delete(path string) (*envelope, error) {
+	request, err := c.newRequest(http.MethodDelete, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(request)
+}
+
+// upload sends the file as the image of a multipart form, streaming it
+// instead of reading it in memory.
+func (c *client) upload(filename, description string) (*envelope, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	body, pipe := io.Pipe()
+	form := multipart.NewWriter(pipe)
+	go func() {
+		part, err := form.CreateFormFile("image", filepath.Base(filename))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil && description != "" {
+			err = form.WriteField("description", description)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		pipe.CloseWithError(err)
+	}()
+
+	request, err := c.newRequest(http.MethodPost, "/", body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	return c.do(request)
+}
+
+func (c *client) getPicture(id string) (*dto.PictureResponse, *envelope, error) {
+	result, err := c.get("/picture/" + url.PathEscape(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	picture := &dto.PictureResponse{}
+	if err := json.Unmarshal(result.Data, picture); err != nil {
+		return nil, nil, err
+	}
+	return picture, result, nil
+}
+
+// openOriginal opens the file of the picture as it was uploaded. The caller
+// is responsible for closing the reader.
+func (c *client) openOriginal(id uint) (io.ReadCloser, error) {
+	request, err := c.newRequest(http.MethodGet, fmt.Sprintf("/picture/%d/file", id), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
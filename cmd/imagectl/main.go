@@ -0,0 +1,353 @@
+// Command imagectl manages the pictures of an Image Nexus server from the
+// command line:
+//
+//	imagectl upload <file>
+//	imagectl list
+//	imagectl get <id>
+//	imagectl delete <id>
+//	imagectl export <ids...> --format zip
+//	imagectl tags add <id> <tag>
+//
+// The server URL and the API key, sent as a bearer token, are read from
+// ~/.imagenexus.toml
+//
+//	url = "https://images.example.com"
+//	api_key = "..."
+//
+// or from the IMAGENEXUS_URL and IMAGENEXUS_API_KEY environment variables,
+// which take precedence. The commands print the JSON data of the responses,
+// or a table with --output table.
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"imagenexus/dto"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+)
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// settings are the configuration of the commands, filled in before they run.
+type settings struct {
+	output string
+	client *client
+}
+
+func newRootCommand() *cobra.Command {
+	s := &settings{}
+	var configFile string
+
+	root := &cobra.Command{
+		Use:          "imagectl",
+		Short:        "Manage the pictures of an Image Nexus server",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if s.output != outputJSON && s.output != outputTable {
+				return fmt.Errorf("unknown output %q, expected json or table", s.output)
+			}
+
+			serverURL, apiKey, err := loadConfig(configFile)
+			if err != nil {
+				return err
+			}
+			s.client = newClient(serverURL, apiKey)
+			return nil
+		},
+	}
+	root.PersistentFlags().StringVarP(&s.output, "output", "o", outputJSON, "output format, json or table")
+	root.PersistentFlags().StringVar(&configFile, "config", "", "configuration file (default ~/.imagenexus.toml)")
+
+	root.AddCommand(
+		newUploadCommand(s),
+		newListCommand(s),
+		newGetCommand(s),
+		newDeleteCommand(s),
+		newExportCommand(s),
+		newTagsCommand(s),
+	)
+	return root
+}
+
+// loadConfig reads the server URL and the API key from the configuration
+// file, when it exists, overridden by the environment variables.
+func loadConfig(configFile string) (string, string, error) {
+	v := viper.New()
+	v.SetDefault("url", "http://localhost:8000")
+	v.SetEnvPrefix("IMAGENEXUS")
+	v.AutomaticEnv()
+
+	if configFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", err
+		}
+		configFile = filepath.Join(home, ".imagenexus.toml")
+		if _, err := os.Stat(configFile); errors.Is(err, os.ErrNotExist) {
+			return v.GetString("url"), v.GetString("api_key"), nil
+		}
+	}
+
+	v.SetConfigFile(configFile)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return "", "", fmt.Errorf("unable to read %s: %w", configFile, err)
+	}
+	return v.GetString("url"), v.GetString("api_key"), nil
+}
+
+func newUploadCommand(s *settings) *cobra.Command {
+	var description string
+	cmd := &cobra.Command{
+		Use:   "upload <file>",
+		Short: "Upload an image",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := s.client.upload(args[0], description)
+			if err != nil {
+				return err
+			}
+			return s.printPicture(cmd.OutOrStdout(), result)
+		},
+	}
+	cmd.Flags().StringVar(&description, "description", "", "description of the image, taken from the IPTC caption when empty")
+	return cmd
+}
+
+func newListCommand(s *settings) *cobra.Command {
+	var page int
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the pictures, a page at a time",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			result, err := s.client.get("/?page=" + strconv.Itoa(page))
+			if err != nil {
+				return err
+			}
+			if s.output == outputJSON {
+				return printJSON(cmd.OutOrStdout(), result)
+			}
+
+			var pictures []*dto.PictureResponse
+			if err := json.Unmarshal(result.Data, &pictures); err != nil {
+				return err
+			}
+			printPictureTable(cmd.OutOrStdout(), pictures)
+			if result.Meta != nil && result.Meta.ListMeta != nil {
+				fmt.Fprintf(cmd.OutOrStdout(), "page %d of %d, %d pictures\n", page, result.Meta.TotalPages, result.Meta.Total)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().IntVar(&page, "page", 1, "page number starting from 1")
+	return cmd
+}
+
+func newGetCommand(s *settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <id>",
+		Short: "Show the metadata of a picture",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, result, err := s.client.getPicture(args[0])
+			if err != nil {
+				return err
+			}
+			return s.printPicture(cmd.OutOrStdout(), result)
+		},
+	}
+}
+
+func newDeleteCommand(s *settings) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete <id>",
+		Short: "Delete a picture",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := s.client.delete("/picture/" + url.PathEscape(args[0]))
+			if err != nil {
+				return err
+			}
+			if s.output == outputJSON {
+				return printJSON(cmd.OutOrStdout(), result)
+			}
+
+			message := &dto.StringResponse{}
+			if err := json.Unmarshal(result.Data, message); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), message.Message)
+			return nil
+		},
+	}
+}
+
+// exportResult is printed once the archive is written.
+type exportResult struct {
+	File     string   `json:"file"`
+	Pictures []string `json:"pictures"`
+}
+
+func newExportCommand(s *settings) *cobra.Command {
+	var format, outputFile string
+	cmd := &cobra.Command{
+		Use:   "export <ids...>",
+		Short: "Download the original files of pictures into an archive",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "zip" {
+				return fmt.Errorf("unknown format %q, only zip is supported", format)
+			}
+
+			names, err := s.exportZip(outputFile, args)
+			if err != nil {
+				os.Remove(outputFile)
+				return err
+			}
+
+			result := &exportResult{File: outputFile, Pictures: names}
+			if s.output == outputJSON {
+				return printJSON(cmd.OutOrStdout(), result)
+			}
+			table := tablewriter.NewWriter(cmd.OutOrStdout())
+			table.SetHeader([]string{"File", "Pictures"})
+			table.Append([]string{result.File, strings.Join(result.Pictures, "\n")})
+			table.Render()
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&format, "format", "zip", "archive format, only zip")
+	cmd.Flags().StringVarP(&outputFile, "file", "f", "pictures.zip", "archive to write")
+	return cmd
+}
+
+// exportZip writes the original files of the pictures into the archive,
+// named after their id and name so pictures with the same name don't
+// collide, and returns those names.
+func (s *settings) exportZip(outputFile string, ids []string) ([]string, error) {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		picture, _, err := s.client.getPicture(id)
+		if err != nil {
+			return nil, fmt.Errorf("picture %s: %w", id, err)
+		}
+
+		name := fmt.Sprintf("%d_%s", picture.Id, filepath.Base(picture.Name))
+		if err := s.addToZip(archive, name, picture); err != nil {
+			return nil, fmt.Errorf("picture %s: %w", id, err)
+		}
+		names = append(names, name)
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return names, file.Close()
+}
+
+func (s *settings) addToZip(archive *zip.Writer, name string, picture *dto.PictureResponse) error {
+	reader, err := s.client.openOriginal(picture.Id)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// the images are compressed already
+	entry, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: picture.UpdatedOn})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+func newTagsCommand(s *settings) *cobra.Command {
+	tags := &cobra.Command{
+		Use:   "tags",
+		Short: "Manage the tags of the pictures",
+	}
+	tags.AddCommand(&cobra.Command{
+		Use:   "add <id> <tag>",
+		Short: "Add a tag to a picture",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			result, err := s.client.postJSON("/picture/"+url.PathEscape(args[0])+"/tags", &dto.TagRequest{Tag: args[1]})
+			if err != nil {
+				return err
+			}
+			return s.printPicture(cmd.OutOrStdout(), result)
+		},
+	})
+	return tags
+}
+
+func (s *settings) printPicture(w io.Writer, result *envelope) error {
+	if s.output == outputJSON {
+		return printJSON(w, result)
+	}
+
+	picture := &dto.PictureResponse{}
+	if err := json.Unmarshal(result.Data, picture); err != nil {
+		return err
+	}
+	printPictureTable(w, []*dto.PictureResponse{picture})
+	return nil
+}
+
+// printJSON prints the data of the response, or the value, indented.
+func printJSON(w io.Writer, value any) error {
+	if result, ok := value.(*envelope); ok {
+		value = result.Data
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func printPictureTable(w io.Writer, pictures []*dto.PictureResponse) {
+	table := tablewriter.NewWriter(w)
+	table.SetHeader([]string{"Id", "Name", "Type", "Width", "Height", "Size", "Tags", "Created"})
+	table.SetAutoWrapText(false)
+	for _, picture := range pictures {
+		table.Append([]string{
+			strconv.FormatUint(uint64(picture.Id), 10),
+			picture.Name,
+			picture.ContentType,
+			strconv.Itoa(int(picture.Width)),
+			strconv.Itoa(int(picture.Height)),
+			picture.Size,
+			strings.Join(picture.Tags, ", "),
+			picture.CreatedOn.Format("2006-01-02 15:04"),
+		})
+	}
+	table.Render()
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,7 +15,39 @@ func TestConfigValues(t *testing.T) {
 	assert.Equal(t, "some-name", GetConfigValue("section2.name"))
 }
 
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("IMAGENEXUS_SECTION1_VALUE", "2000")
+	t.Setenv("IMAGENEXUS_SECTION2_LIST", "a b")
+
+	err := Init("test_config_file", "./")
+	assert.Nil(t, err)
+	assert.Equal(t, "2000", GetConfigValue("section1.value"))
+	assert.Equal(t, []string{"a", "b"}, GetConfigStrings("section2.list"))
+	assert.Equal(t, "some-name", GetConfigValue("section2.name"))
+}
+
 func TestInvalidFile(t *testing.T) {
 	err := Init("non-existing-file", "./")
 	assert.NotNil(t, err)
 }
+
+func TestReload(t *testing.T) {
+	path := t.TempDir()
+	writeConfig := func(contents string) {
+		assert.Nil(t, os.WriteFile(filepath.Join(path, "reloaded.toml"), []byte(contents), 0o644))
+	}
+
+	writeConfig("[server]\nport = \"8000\"\nmaxUploadSize = 10\n")
+	assert.Nil(t, Init("reloaded", path))
+	assert.Equal(t, 10, GetConfigInt("server.maxUploadSize"))
+
+	writeConfig("[server]\nport = \"9000\"\nmaxUploadSize = 20\n")
+	restartRequired, err := Reload()
+	assert.Nil(t, err)
+	assert.Equal(t, 20, GetConfigInt("server.maxUploadSize"))
+	assert.Equal(t, []string{"server.port"}, restartRequired)
+
+	restartRequired, err = Reload()
+	assert.Nil(t, err)
+	assert.Empty(t, restartRequired)
+}
@@ -0,0 +1,3 @@
+#!/bin/sh
+# Creates the bucket used by config/config.dev.toml.
+awslocal s3 mb s3://imagenexus-dev
@@ -1,12 +1,61 @@
 package config
 
 import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
 
+// lock guards the reads of the config against reloads.
+var lock sync.RWMutex
+
+// restartKeys are only read on startup, so a reload doesn't apply their
+// changes.
+var restartKeys = []string{
+	"server.port",
+	"server.imagePath",
+	"server.tls",
+	"server.auth",
+	"server.csp",
+	"storage",
+	"processing",
+	"video",
+	"webhook",
+	"postgres",
+}
+
+// APIVersion is the version of the API the generated URLs point to.
+const APIVersion = "v1"
+
+// EnvPrefix prefixes the environment variables overriding the config keys.
+const EnvPrefix = "IMAGENEXUS"
+
+// File returns the name and directory of the config file, the
+// config.toml of the working directory unless IMAGENEXUS_CONFIG points to
+// another one.
+func File() (string, string) {
+	path := os.Getenv("IMAGENEXUS_CONFIG")
+	if path == "" {
+		return "config", "./"
+	}
+
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), filepath.Dir(path)
+}
+
+// Init reads the config file. Every key can be overridden by an environment
+// variable named after the key, upper cased and prefixed with EnvPrefix, with
+// its dots and dashes replaced by underscores: server.maxUploadSize is
+// IMAGENEXUS_SERVER_MAXUPLOADSIZE. Lists are read as space separated values.
 func Init(name, path string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
 	// name of the config file
 	viper.SetConfigName(name)
 
@@ -19,14 +68,96 @@ func Init(name, path string) error {
 	}
 
 	// define replacer
-	replacer := strings.NewReplacer(".", "_")
+	replacer := strings.NewReplacer(".", "_", "-", "_")
 	viper.SetEnvKeyReplacer(replacer)
 
+	viper.SetEnvPrefix(EnvPrefix)
 	viper.AutomaticEnv()
 
 	return nil
 }
 
+// Reload re-reads the config file. It returns the changed settings that
+// only take effect after a restart.
+func Reload() ([]string, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	previous := make(map[string]any, len(restartKeys))
+	for _, eachKey := range restartKeys {
+		previous[eachKey] = viper.Get(eachKey)
+	}
+
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	restartRequired := []string{}
+	for _, eachKey := range restartKeys {
+		if !reflect.DeepEqual(previous[eachKey], viper.Get(eachKey)) {
+			log.Printf("The %s setting changed, restart the server to apply it", eachKey)
+			restartRequired = append(restartRequired, eachKey)
+		}
+	}
+	return restartRequired, nil
+}
+
+// Watch reloads the config whenever the config file changes. The file is
+// watched through its own viper instance since viper re-reads the watched
+// config without any locking.
+func Watch() {
+	lock.RLock()
+	configFile := viper.ConfigFileUsed()
+	lock.RUnlock()
+
+	watcher := viper.New()
+	watcher.SetConfigFile(configFile)
+	watcher.OnConfigChange(func(fsnotify.Event) {
+		if _, err := Reload(); err != nil {
+			log.Printf("Unable to reload the config file: %v", err)
+		}
+	})
+	watcher.WatchConfig()
+}
+
 func GetConfigValue(key string) string {
+	lock.RLock()
+	defer lock.RUnlock()
 	return viper.GetString(key)
 }
+
+func GetConfigBool(key string) bool {
+	lock.RLock()
+	defer lock.RUnlock()
+	return viper.GetBool(key)
+}
+
+func GetConfigInt(key string) int {
+	lock.RLock()
+	defer lock.RUnlock()
+	return viper.GetInt(key)
+}
+
+func GetConfigFloat(key string) float64 {
+	lock.RLock()
+	defer lock.RUnlock()
+	return viper.GetFloat64(key)
+}
+
+func GetConfigStrings(key string) []string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return viper.GetStringSlice(key)
+}
+
+func GetConfigMap(key string) map[string]string {
+	lock.RLock()
+	defer lock.RUnlock()
+	return viper.GetStringMapString(key)
+}
+
+// APIBaseURL returns the public URL of the current API version, e.g.
+// http://localhost:8000/v1.
+func APIBaseURL() string {
+	return GetConfigValue("server.host") + "/" + APIVersion
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// The actions of the audit log.
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// the removal for good of the pictures past server.retentionDays
+	AuditActionExpire = "expire"
+	// a file of the picture found not to match its checksum
+	AuditActionCorrupt = "corrupt"
+)
+
+// AuditEntityPicture is the entity type of the pictures in the audit log.
+const AuditEntityPicture = "picture"
+
+// AuditLog records a change of an entity, along with the user who made it,
+// empty for the anonymous requests. The values are the JSON of the entity
+// before and after the change, null for the created and deleted ones. The
+// corruption records only have a new value, the details of the corruption.
+type AuditLog struct {
+	ID         uint            `gorm:"primary_key"`
+	EntityType string          `gorm:"size:64;index:idx_audit_log_entity"`
+	EntityID   uint            `gorm:"index:idx_audit_log_entity"`
+	Action     string          `gorm:"size:16"`
+	ActorId    string          `gorm:"size:255;index"`
+	OldValue   json.RawMessage `gorm:"serializer:json;type:jsonb"`
+	NewValue   json.RawMessage `gorm:"serializer:json;type:jsonb"`
+	CreatedAt  int64           `gorm:"autoCreateTime:milli;index"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+type actorKey struct{}
+
+// WithActor returns a context recording the changes made with it in the
+// audit log as made by the user.
+func WithActor(ctx context.Context, actorId string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorId)
+}
+
+func actorFrom(ctx context.Context) string {
+	actorId, _ := ctx.Value(actorKey{}).(string)
+	return actorId
+}
+
+// recordAudit inserts the audit record of a change of the picture, within
+// the transaction of the change.
+func recordAudit(tx *gorm.DB, action string, id uint, oldValue, newValue *Picture) error {
+	entry := &AuditLog{EntityType: AuditEntityPicture, EntityID: id, Action: action, ActorId: actorFrom(tx.Statement.Context)}
+	if oldValue != nil {
+		entry.OldValue, _ = json.Marshal(oldValue)
+	}
+	if newValue != nil {
+		entry.NewValue, _ = json.Marshal(newValue)
+	}
+	return tx.Create(entry).Error
+}
+
+// RecordCorruption records that the file of the picture was found
+// corrupted, the picture itself being left as it is.
+func (p *picturesRepository) RecordCorruption(ctx context.Context, id int, details any) error {
+	entry := &AuditLog{EntityType: AuditEntityPicture, EntityID: uint(id), Action: AuditActionCorrupt, ActorId: actorFrom(ctx)}
+	entry.NewValue, _ = json.Marshal(details)
+	return p.db.WithContext(ctx).Create(entry).Error
+}
+
+type AuditRepository interface {
+	// the records of the entity, or of the actor when entityId is 0, the
+	// latest first
+	List(entityType string, entityId uint, actorId string, limit, page int) ([]*AuditLog, int64, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(dbHandler *gorm.DB) AuditRepository {
+	return &auditRepository{db: dbHandler}
+}
+
+func (r *auditRepository) List(entityType string, entityId uint, actorId string, limit, page int) ([]*AuditLog, int64, error) {
+	query := r.db.Model(&AuditLog{})
+	if entityId != 0 {
+		query = query.Where("entity_type = ? AND entity_id = ?", entityType, entityId)
+	}
+	if actorId != "" {
+		query = query.Where("actor_id = ?", actorId)
+	}
+
+	var totalCount int64
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var entries []*AuditLog
+	if err := query.Session(&gorm.Session{}).Order("created_at desc, id desc").Limit(limit).Offset(limit * (page - 1)).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, totalCount, nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type CollectionsRepository interface {
+	Create(string) (*Collection, error)
+	GetById(int) (*Collection, error)
+	Delete(int) error
+	AddPicture(int, int) error
+	RemovePicture(int, int) error
+	GetPictures(int) ([]*Picture, error)
+	Pin(int, int) error
+	Unpin(int, int) error
+	GetPinned(int) ([]uint, error)
+	ReorderPins(int, []uint) error
+}
+
+type collectionsRepository struct {
+	db *gorm.DB
+}
+
+func NewCollectionsRepository(dbHandler *gorm.DB) CollectionsRepository {
+	return &collectionsRepository{db: dbHandler}
+}
+
+func (r *collectionsRepository) Create(name string) (*Collection, error) {
+	collection := Collection{Name: name}
+	if err := r.db.Create(&collection).Error; err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (r *collectionsRepository) GetById(id int) (*Collection, error) {
+	var collection *Collection
+
+	if err := r.db.Where("id = ? AND deleted = ?", id, false).First(&collection).Error; err != nil {
+		return nil, err
+	}
+
+	return collection, nil
+}
+
+func (r *collectionsRepository) Delete(id int) error {
+	result := r.db.Where("id = ? AND deleted = ?", id, false).Updates(Collection{Deleted: true})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("record with id: %d not found", id)
+	}
+
+	return nil
+}
+
+// AddPicture links the picture to the collection. Adding a picture twice
+// keeps its original position.
+func (r *collectionsRepository) AddPicture(collectionId, pictureId int) error {
+	link := CollectionPicture{CollectionID: uint(collectionId), PictureID: uint(pictureId)}
+	return r.db.Where(&link).FirstOrCreate(&link).Error
+}
+
+func (r *collectionsRepository) RemovePicture(collectionId, pictureId int) error {
+	result := r.db.Where("collection_id = ? AND picture_id = ?", collectionId, pictureId).Delete(&CollectionPicture{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return notInCollection(collectionId, pictureId)
+	}
+
+	return nil
+}
+
+// GetPictures returns the pinned pictures of the collection by pin order,
+// then the others in the order they were added, leaving out deleted
+// pictures.
+func (r *collectionsRepository) GetPictures(collectionId int) ([]*Picture, error) {
+	var pictures []*Picture
+
+	err := r.db.Joins("JOIN collection_pictures ON collection_pictures.picture_id = pictures.id").
+		Where("collection_pictures.collection_id = ? AND pictures.deleted = ?", collectionId, false).
+		Order("collection_pictures.pinned DESC, collection_pictures.pin_order, collection_pictures.created_on, pictures.id").
+		Find(&pictures).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pictures, nil
+}
+
+func notInCollection(collectionId, pictureId int) error {
+	return fmt.Errorf("picture %d is not in collection %d", pictureId, collectionId)
+}
+
+// Pin moves the picture ahead of the unpinned pictures of the collection,
+// after the pictures pinned already. Pinning a picture twice keeps its pin
+// order.
+func (r *collectionsRepository) Pin(collectionId, pictureId int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var link CollectionPicture
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("collection_id = ? AND picture_id = ?", collectionId, pictureId).
+			First(&link).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return notInCollection(collectionId, pictureId)
+		}
+		if err != nil || link.Pinned {
+			return err
+		}
+
+		var lastOrder int
+		err = tx.Model(&CollectionPicture{}).
+			Where("collection_id = ? AND pinned = ?", collectionId, true).
+			Select("COALESCE(MAX(pin_order), 0)").
+			Scan(&lastOrder).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&CollectionPicture{}).
+			Where("collection_id = ? AND picture_id = ?", collectionId, pictureId).
+			Updates(map[string]any{"pinned": true, "pin_order": lastOrder + 1}).Error
+	})
+}
+
+func (r *collectionsRepository) Unpin(collectionId, pictureId int) error {
+	result := r.db.Model(&CollectionPicture{}).
+		Where("collection_id = ? AND picture_id = ?", collectionId, pictureId).
+		Updates(map[string]any{"pinned": false, "pin_order": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return notInCollection(collectionId, pictureId)
+	}
+
+	return nil
+}
+
+// GetPinned returns the ids of the pinned pictures of the collection by pin
+// order, leaving out deleted pictures.
+func (r *collectionsRepository) GetPinned(collectionId int) ([]uint, error) {
+	pictureIds := []uint{}
+
+	err := r.db.Model(&CollectionPicture{}).
+		Joins("JOIN pictures ON pictures.id = collection_pictures.picture_id").
+		Where("collection_pictures.collection_id = ? AND collection_pictures.pinned = ? AND pictures.deleted = ?", collectionId, true, false).
+		Order("collection_pictures.pin_order, collection_pictures.created_on").
+		Pluck("collection_pictures.picture_id", &pictureIds).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return pictureIds, nil
+}
+
+// ReorderPins gives the pinned pictures the order of pictureIds.
+func (r *collectionsRepository) ReorderPins(collectionId int, pictureIds []uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for i, pictureId := range pictureIds {
+			err := tx.Model(&CollectionPicture{}).
+				Where("collection_id = ? AND picture_id = ? AND pinned = ?", collectionId, pictureId, true).
+				Update("pin_order", i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -8,6 +8,8 @@ import (
 
 type Configuration interface {
 	Dsn() string
+	// PostGIS tells whether the PostGIS extension is used for geospatial queries
+	PostGIS() bool
 }
 
 type configuration struct {
@@ -16,6 +18,8 @@ type configuration struct {
 	dbHost string
 	dbPort string
 	dbName string
+
+	postgis bool
 }
 
 func NewConfiguration() Configuration {
@@ -25,9 +29,14 @@ func NewConfiguration() Configuration {
 	cfg.dbHost = config.GetConfigValue("postgres.host")
 	cfg.dbPort = config.GetConfigValue("postgres.port")
 	cfg.dbName = config.GetConfigValue("postgres.dbname")
+	cfg.postgis = config.GetConfigBool("postgres.postgis")
 	return cfg
 }
 
 func (c configuration) Dsn() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", c.dbHost, c.dbPort, c.dbUser, c.dbPass, c.dbName)
 }
+
+func (c configuration) PostGIS() bool {
+	return c.postgis
+}
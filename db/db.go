@@ -1,7 +1,9 @@
 package db
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -23,7 +25,51 @@ func NewConnection(cfg Configuration) (*gorm.DB, error) {
 	db.Logger = logger.Default.LogMode(logger.Info)
 
 	log.Println("Running migrations")
-	db.AutoMigrate(&Picture{})
+	if err := createEnum(db, "moderation_status", ModerationPending, ModerationApproved, ModerationRejected); err != nil {
+		return nil, err
+	}
+	if err := createEnum(db, "storage_tier", TierHot, TierWarm, TierCold); err != nil {
+		return nil, err
+	}
+	db.AutoMigrate(&Picture{}, &Collection{}, &CollectionPicture{}, &IdempotencyKey{}, &AuditLog{}, &PictureVersion{})
+
+	if cfg.PostGIS() {
+		if err := migratePostGIS(db); err != nil {
+			return nil, err
+		}
+	}
 
 	return db, nil
 }
+
+// Ping checks that the database is reachable.
+func Ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
+// createEnum creates the enum type of a column before AutoMigrate adds the
+// column. The values of existing types are left as they are.
+func createEnum(db *gorm.DB, name string, values ...string) error {
+	quoted := make([]string, 0, len(values))
+	for _, eachValue := range values {
+		quoted = append(quoted, "'"+eachValue+"'")
+	}
+
+	return db.Exec(fmt.Sprintf(`DO $$ BEGIN
+		CREATE TYPE %s AS ENUM (%s);
+	EXCEPTION WHEN duplicate_object THEN NULL;
+	END $$`, name, strings.Join(quoted, ", "))).Error
+}
+
+// migratePostGIS enables the extension and indexes the picture locations as
+// geographies for ST_DWithin.
+func migratePostGIS(db *gorm.DB) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS postgis").Error; err != nil {
+		return err
+	}
+	return db.Exec("CREATE INDEX IF NOT EXISTS idx_pictures_geography ON pictures USING GIST (" + pictureGeography + ")").Error
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKey is the response of a request sent with an X-Idempotency-Key
+// header, replayed to the retries of the request until it expires. The key
+// is reserved, with a 0 status code, while the first request is handled.
+// The method, route and body hash of the request tell its retries apart
+// from the other requests sent with the same key.
+type IdempotencyKey struct {
+	Key          string `gorm:"primaryKey;size:255"`
+	UserId       string `gorm:"primaryKey;size:255"`
+	StatusCode   int
+	ContentType  string
+	ResponseBody []byte `gorm:"type:bytea"`
+	Method       string `gorm:"size:16"`
+	Route        string `gorm:"size:255"`
+	BodyHash     string `gorm:"size:64"`
+	CreatedOn    int64  `gorm:"autoCreateTime:milli"`
+	ExpiresOn    int64  `gorm:"index"`
+}
+
+type IdempotencyRepository interface {
+	Reserve(key, userId string, now, expiresOn int64) (*IdempotencyKey, bool, error)
+	Complete(*IdempotencyKey) error
+	Release(key, userId string) error
+	DeleteExpired(now int64) (int64, error)
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(dbHandler *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: dbHandler}
+}
+
+// Reserve saves the key of the user unless it's already there, telling
+// whether it was. The existing key is returned otherwise, possibly still
+// reserved by a request in progress. The expired keys not yet deleted are
+// replaced.
+func (r *idempotencyRepository) Reserve(key, userId string, now, expiresOn int64) (*IdempotencyKey, bool, error) {
+	if err := r.db.Where("key = ? AND user_id = ? AND expires_on <= ?", key, userId, now).Delete(&IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	reserved := &IdempotencyKey{Key: key, UserId: userId, ExpiresOn: expiresOn}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reserved)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return reserved, true, nil
+	}
+
+	var existing IdempotencyKey
+	if err := r.db.Where("key = ? AND user_id = ?", key, userId).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// Complete saves the response of the request that reserved the key, along
+// with the fingerprint of the request and its expiry.
+func (r *idempotencyRepository) Complete(key *IdempotencyKey) error {
+	return r.db.Model(&IdempotencyKey{}).Where("key = ? AND user_id = ?", key.Key, key.UserId).
+		Select("status_code", "content_type", "response_body", "method", "route", "body_hash", "expires_on").
+		Updates(key).Error
+}
+
+// Release deletes the key, so the request can be retried.
+func (r *idempotencyRepository) Release(key, userId string) error {
+	return r.db.Where("key = ? AND user_id = ?", key, userId).Delete(&IdempotencyKey{}).Error
+}
+
+// DeleteExpired deletes the expired keys, returning how many there were.
+func (r *idempotencyRepository) DeleteExpired(now int64) (int64, error) {
+	result := r.db.Where("expires_on <= ?", now).Delete(&IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
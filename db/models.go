@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"imagenexus/config"
@@ -13,6 +14,9 @@ type Picture struct {
 	CreatedOn int64 `json:"created_on" gorm:"autoCreateTime:milli"`
 	UpdatedOn int64 `json:"updated_on" gorm:"autoUpdateTime:milli"`
 	Deleted   bool  `json:"deleted" gorm:"default:false"`
+	// incremented by every update, which may require the version it's based
+	// on, see VersionConflictError
+	Version int `json:"version" gorm:"not null;default:1"`
 
 	Name        string `json:"name"`
 	Destination string `json:"destination"`
@@ -20,18 +24,220 @@ type Picture struct {
 	Width       int32  `json:"width"`
 	Size        int32  `json:"size"`
 	ContentType string `json:"content_type"`
+	Checksum    string `json:"checksum"`
+	IsAnimated  bool   `json:"is_animated"`
+	IsGrayscale bool   `json:"is_grayscale" gorm:"default:false;index"`
+	Description string `json:"description"`
+	// user tags along with the IPTC keywords of the picture
+	Tags []string `json:"tags" gorm:"serializer:json;type:jsonb"`
+
+	// computed by the processing pipeline after upload
+	ThumbnailDestination string `json:"thumbnail_destination"`
+	// the resized animated GIF of the GIFs with several frames
+	AnimatedThumbnailDestination string        `json:"animated_thumbnail_destination"`
+	PerceptualHash               string        `json:"perceptual_hash"`
+	ICCProfile                   []byte        `json:"-" gorm:"type:bytea"`
+	XMPData                      string        `json:"-" gorm:"type:text"`
+	IPTCData                     *dto.IPTCData `json:"-" gorm:"serializer:json;type:jsonb"`
+	Latitude                     *float64      `json:"lat" gorm:"column:lat;type:real;index:idx_pictures_location"`
+	Longitude                    *float64      `json:"lon" gorm:"column:lon;type:real;index:idx_pictures_location"`
+	Altitude                     *float64      `json:"altitude" gorm:"type:real"`
+	QualityScore                 *float64      `json:"quality_score" gorm:"index"`
+	ProcessedOn                  int64         `json:"processed_on"`
+
+	// the uploaded video the picture is the first frame of, in the video
+	// storage
+	VideoDestination string  `json:"video_destination"`
+	VideoContentType string  `json:"video_content_type"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	FrameRate        float64 `json:"frame_rate"`
+	VideoCodec       string  `json:"video_codec"`
+
+	// pending pictures wait in the moderation queue, rejected ones aren't
+	// served
+	ModerationStatus string `json:"moderation_status" gorm:"type:moderation_status;default:'approved';index"`
+	ModerationReason string `json:"moderation_reason"`
+
+	// the Adam7 interlaced copy of PNGs, written on their first download
+	// with ?interlace=true
+	InterlacedDestination string `json:"interlaced_destination"`
+
+	// the storage class of the file, cold files are archived until restored
+	StorageTier  string `json:"storage_tier" gorm:"type:storage_tier;default:'hot';index:idx_pictures_tier_views"`
+	LastViewedOn int64  `json:"last_viewed_on" gorm:"index:idx_pictures_tier_views"`
+	// the views recorded, at most one per viewRecordInterval, see RecordView
+	ViewCount int64 `json:"view_count" gorm:"not null;default:0"`
+
+	// keeps the picture past server.retentionDays until then, 0 for no
+	// override
+	RetainUntil int64 `json:"retain_until" gorm:"not null;default:0"`
+
+	// when the file last matched the checksum, see RecordChecksumVerified
+	ChecksumVerifiedOn int64 `json:"checksum_verified_on" gorm:"not null;default:0"`
+}
+
+// PictureFile is a file of a picture, in the image storage or, for the
+// uploaded videos, in the video storage.
+type PictureFile struct {
+	Destination string
+	Video       bool
+}
+
+// Files are the files of the picture, apart from its tile set.
+func (p *Picture) Files() []PictureFile {
+	files := []PictureFile{}
+	for _, destination := range p.ImageFiles() {
+		files = append(files, PictureFile{Destination: destination})
+	}
+	if p.VideoDestination != "" {
+		files = append(files, PictureFile{Destination: p.VideoDestination, Video: true})
+	}
+	return files
+}
+
+// ImageFiles are the files of the picture in the image storage, apart from
+// its tile set.
+func (p *Picture) ImageFiles() []string {
+	files := []string{}
+	for _, destination := range []string{p.Destination, p.ThumbnailDestination, p.AnimatedThumbnailDestination, p.InterlacedDestination} {
+		if destination != "" && !slices.Contains(files, destination) {
+			files = append(files, destination)
+		}
+	}
+	return files
+}
+
+// VersionConflictError is returned by the updates based on another version
+// of the picture than its current one, i.e. the picture was changed in
+// between.
+type VersionConflictError struct {
+	CurrentVersion int
 }
 
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("the picture was changed meanwhile, its current version is %d", e.CurrentVersion)
+}
+
+// The values of the moderation_status enum.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// The values of the storage_tier enum.
+const (
+	TierHot  = "hot"
+	TierWarm = "warm"
+	TierCold = "cold"
+)
+
 func (p *Picture) ToPictureResponse() *dto.PictureResponse {
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	thumbnailUrl := ""
+	if p.ThumbnailDestination != "" {
+		thumbnailUrl = fmt.Sprintf("%s/picture/%d/thumbnail", config.APIBaseURL(), p.ID)
+	}
+
+	animatedThumbnailUrl := ""
+	if p.AnimatedThumbnailDestination != "" {
+		animatedThumbnailUrl = fmt.Sprintf("%s/picture/%d/thumbnail/animated", config.APIBaseURL(), p.ID)
+	}
+
+	var video *dto.VideoMetadata
+	if p.VideoDestination != "" {
+		video = &dto.VideoMetadata{DurationSeconds: p.DurationSeconds, FrameRate: p.FrameRate, VideoCodec: p.VideoCodec}
+	}
+
+	var retainUntil *time.Time
+	if p.RetainUntil > 0 {
+		until := time.UnixMilli(p.RetainUntil)
+		retainUntil = &until
+	}
+
 	return &dto.PictureResponse{
 		Id:          p.ID,
+		Version:     p.Version,
 		Name:        p.Name,
-		Url:         fmt.Sprintf("%s/picture/%d/image", config.GetConfigValue("server.host"), p.ID),
+		Url:         fmt.Sprintf("%s/picture/%d/image", config.APIBaseURL(), p.ID),
 		Height:      p.Height,
 		Width:       p.Width,
 		Size:        fmt.Sprintf("%.2f KB", float64(p.Size)/1024),
 		ContentType: p.ContentType,
-		CreatedOn:   time.UnixMilli(p.CreatedOn),
-		UpdatedOn:   time.UnixMilli(p.UpdatedOn),
+		Checksum:    p.Checksum,
+		IsAnimated:  p.IsAnimated,
+		IsGrayscale: p.IsGrayscale,
+		Description: p.Description,
+		Tags:        tags,
+
+		ThumbnailUrl:         thumbnailUrl,
+		ThumbnailAnimatedUrl: animatedThumbnailUrl,
+		PerceptualHash:       p.PerceptualHash,
+		HasICCProfile:        len(p.ICCProfile) > 0,
+		XMPPresent:           p.XMPData != "",
+		IPTC:                 p.IPTCData,
+		Video:                video,
+		QualityScore:         p.QualityScore,
+		Processed:            p.ProcessedOn > 0,
+
+		ModerationStatus: p.ModerationStatus,
+		ModerationReason: p.ModerationReason,
+		StorageTier:      p.StorageTier,
+		RetainUntil:      retainUntil,
+
+		CreatedOn: time.UnixMilli(p.CreatedOn),
+		UpdatedOn: time.UnixMilli(p.UpdatedOn),
+	}
+}
+
+type Collection struct {
+	ID        uint  `json:"id" gorm:"primary_key"`
+	CreatedOn int64 `json:"created_on" gorm:"autoCreateTime:milli"`
+	UpdatedOn int64 `json:"updated_on" gorm:"autoUpdateTime:milli"`
+	Deleted   bool  `json:"deleted" gorm:"default:false"`
+
+	Name string `json:"name"`
+}
+
+// CollectionPicture links a picture to a collection it was added to. The
+// pinned pictures come first, by pin order.
+type CollectionPicture struct {
+	CollectionID uint  `json:"collection_id" gorm:"primaryKey"`
+	PictureID    uint  `json:"picture_id" gorm:"primaryKey"`
+	CreatedOn    int64 `json:"created_on" gorm:"autoCreateTime:milli"`
+	Pinned       bool  `json:"pinned" gorm:"default:false"`
+	PinOrder     int   `json:"pin_order" gorm:"default:0"`
+}
+
+// ToPictureLocation returns nil for pictures without GPS coordinates.
+func (p *Picture) ToPictureLocation() *dto.PictureLocation {
+	if p.Latitude == nil || p.Longitude == nil {
+		return nil
+	}
+
+	return &dto.PictureLocation{
+		Lat:      *p.Latitude,
+		Lon:      *p.Longitude,
+		Altitude: p.Altitude,
+	}
+}
+
+func (c *Collection) ToCollectionResponse(pictures []*Picture) *dto.CollectionResponse {
+	pictureResponses := make([]*dto.PictureResponse, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		pictureResponses = append(pictureResponses, eachPicture.ToPictureResponse())
+	}
+
+	return &dto.CollectionResponse{
+		Id:        c.ID,
+		Name:      c.Name,
+		Pictures:  pictureResponses,
+		Pinned:    []uint{},
+		CreatedOn: time.UnixMilli(c.CreatedOn),
+		UpdatedOn: time.UnixMilli(c.UpdatedOn),
 	}
 }
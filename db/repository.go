@@ -1,31 +1,90 @@
 package db
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
 
 	"imagenexus/dto"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type PicturesRepository interface {
-	Create(*dto.PictureRequest) (*Picture, error)
-	Update(int, *dto.PictureRequest) (*Picture, error)
-	Delete(id int) error
+	// ctx cancels the queries, e.g. when the client of the upload is gone
+	Create(context.Context, *dto.PictureRequest) (*Picture, error)
+	Update(context.Context, int, *dto.PictureRequest) (*Picture, error)
+	Delete(ctx context.Context, id int, version int) error
 	GetAll(int, int) ([]*Picture, int64, error)
+	GetDeleted(int, int) ([]*Picture, int64, error)
+	GetAfter(deleted bool, afterId uint, limit int) ([]*Picture, error)
+	Purge(id int, remove func(PictureFile) error) error
+	Search(*dto.PictureFilter, int, int) ([]*Picture, int64, error)
+	GetNearby(float64, float64, float64, int, int) ([]*PictureDistance, int64, error)
 	GetById(int) (*Picture, error)
+	UpdateComputed(*Picture) error
+	UpdateModeration(int, string, string) (*Picture, error)
+	GetByModerationStatus(string, int, int) ([]*Picture, int64, error)
+	// the tier of the file of a picture is the one of every picture sharing
+	// it, see UpdateStorageTier
+	UpdateStorageTier(string, string) error
+	RecordChecksumVerified(string, int64) error
+	GetVersions(int) ([]*PictureVersion, error)
+	GetVersionFiles(deleted bool) (map[uint][]string, error)
+	UpdateInterlacedDestination(int, string) error
+	UpdateTags(int, []string) error
+	UpdateDestinations(*Picture) error
+	RecordView(int, int64) error
+	GetUnviewedSince(string, int64, int) ([]*Picture, error)
+	UpdateRetainUntil(int, int64) (*Picture, error)
+	GetSizeByTier() (map[string]int64, error)
+	GetExpired(createdBefore, now int64, limit int) ([]*Picture, error)
+	Expire(ctx context.Context, id int, now int64, remove func(PictureFile) error) error
+	RecordCorruption(ctx context.Context, id int, details any) error
 }
 
+// ErrNotExpired is returned by Expire for the pictures viewed or retained
+// since they were found expired.
+var ErrNotExpired = errors.New("the picture isn't expired")
+
+// expiredCondition selects the pictures never viewed whose retain_until
+// override, if any, is before the now parameter.
+const expiredCondition = "deleted = false AND view_count = 0 AND (retain_until = 0 OR retain_until < ?)"
+
+// computedColumns are the columns filled in by the processing pipeline.
+var computedColumns = []string{"thumbnail_destination", "animated_thumbnail_destination", "perceptual_hash", "icc_profile", "xmp_data", "iptc_data", "description", "tags", "lat", "lon", "altitude", "quality_score", "processed_on"}
+
+// PictureDistance is a picture found by a nearby search.
+type PictureDistance struct {
+	Picture    `gorm:"embedded"`
+	DistanceKm float64
+}
+
+const earthRadiusKm = 6371.0
+
+// pictureGeography is the location of a picture as a PostGIS geography.
+const pictureGeography = "geography(ST_MakePoint(lon, lat))"
+
+// haversineDistance is the great circle distance in kilometers between the
+// picture and the point given as lat, lat and lon parameters.
+var haversineDistance = fmt.Sprintf(
+	"2 * %f * asin(least(1, sqrt(power(sin(radians(lat - ?) / 2), 2) + cos(radians(?)) * cos(radians(lat)) * power(sin(radians(lon - ?) / 2), 2))))",
+	earthRadiusKm)
+
 type picturesRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	postgis bool
 }
 
-func NewPicturesRepository(dbHandler *gorm.DB) PicturesRepository {
-	return &picturesRepository{db: dbHandler}
+func NewPicturesRepository(dbHandler *gorm.DB, cfg Configuration) PicturesRepository {
+	return &picturesRepository{db: dbHandler, postgis: cfg.PostGIS()}
 }
 
-func (p *picturesRepository) Create(request *dto.PictureRequest) (*Picture, error) {
+func (p *picturesRepository) Create(ctx context.Context, request *dto.PictureRequest) (*Picture, error) {
 	picture := Picture{
 		Name:        request.Name,
 		Destination: request.Destination,
@@ -33,28 +92,75 @@ func (p *picturesRepository) Create(request *dto.PictureRequest) (*Picture, erro
 		Width:       request.Width,
 		Size:        request.Size,
 		ContentType: request.ContentType,
+		Checksum:    request.Checksum,
+		IsAnimated:  request.IsAnimated,
+		IsGrayscale: request.IsGrayscale,
+		Description: request.Description,
+
+		ThumbnailDestination: request.ThumbnailDestination,
+		ModerationStatus:     request.ModerationStatus,
+	}
+	if request.Video != nil {
+		picture.VideoDestination = request.Video.Destination
+		picture.VideoContentType = request.Video.ContentType
+		if metadata := request.Video.Metadata; metadata != nil {
+			picture.DurationSeconds = metadata.DurationSeconds
+			picture.FrameRate = metadata.FrameRate
+			picture.VideoCodec = metadata.VideoCodec
+		}
+	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&picture).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, AuditActionCreate, picture.ID, nil, &picture)
+	})
+	if err != nil {
+		return nil, err
 	}
-	p.db.Create(&picture)
 	return &picture, nil
 }
 
-func (p *picturesRepository) Update(id int, request *dto.PictureRequest) (*Picture, error) {
+func (p *picturesRepository) Update(ctx context.Context, id int, request *dto.PictureRequest) (*Picture, error) {
 	var pictureToUpdate *Picture
 
-	if err := p.db.Where("id = ? AND deleted = ?", id, false).First(&pictureToUpdate).Error; err != nil {
-		return nil, err
-	}
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND deleted = ?", id, false).First(&pictureToUpdate).Error; err != nil {
+			return err
+		}
 
-	marshalledBytes, _ := json.Marshal(request)
-	requestMap := make(map[string]interface{})
-	json.Unmarshal(marshalledBytes, &requestMap)
+		if request.Version != 0 && request.Version != pictureToUpdate.Version {
+			return &VersionConflictError{CurrentVersion: pictureToUpdate.Version}
+		}
+		oldPicture := *pictureToUpdate
+		if err := recordVersion(tx, &oldPicture); err != nil {
+			return err
+		}
 
-	result := p.db.Model(&pictureToUpdate).Where("id = ? AND deleted = ?", id, false).Updates(requestMap)
-	if result.Error != nil {
-		return nil, result.Error
-	}
-	if result.RowsAffected == 0 {
-		return nil, fmt.Errorf("record with id: %d not found", id)
+		marshalledBytes, _ := json.Marshal(request)
+		requestMap := make(map[string]interface{})
+		json.Unmarshal(marshalledBytes, &requestMap)
+		requestMap["version"] = gorm.Expr("version + 1")
+		// the new file hasn't been verified yet
+		requestMap["checksum_verified_on"] = 0
+
+		// the version read above is checked again, in case of an update in
+		// between
+		result := tx.Model(&pictureToUpdate).Where("id = ? AND deleted = ? AND version = ?", id, false, pictureToUpdate.Version).Updates(requestMap)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return p.versionConflict(id)
+		}
+
+		if err := tx.Where("id = ?", id).First(&pictureToUpdate).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, AuditActionUpdate, pictureToUpdate.ID, &oldPicture, pictureToUpdate)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	fmt.Println("updating")
@@ -63,17 +169,44 @@ func (p *picturesRepository) Update(id int, request *dto.PictureRequest) (*Pictu
 	return pictureToUpdate, nil
 }
 
-func (p *picturesRepository) Delete(id int) error {
-	result := p.db.Where("id = ? AND deleted = ?", id, false).Updates(Picture{Deleted: true})
-	if result.Error != nil {
-		return result.Error
-	}
+// Delete soft deletes the picture, provided it's still at the version, or
+// whatever its version when 0.
+func (p *picturesRepository) Delete(ctx context.Context, id int, version int) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&Picture{}).Where("id = ? AND deleted = ?", id, false)
+		if version != 0 {
+			query = query.Where("version = ?", version)
+		}
 
-	if result.RowsAffected == 0 {
+		var oldPicture Picture
+		if err := query.Session(&gorm.Session{}).Clauses(clause.Locking{Strength: "UPDATE"}).First(&oldPicture).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return p.versionConflict(id)
+			}
+			return err
+		}
+
+		result := query.Session(&gorm.Session{}).Updates(map[string]any{"deleted": true, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return p.versionConflict(id)
+		}
+
+		return recordAudit(tx, AuditActionDelete, oldPicture.ID, &oldPicture, nil)
+	})
+}
+
+// versionConflict tells the pictures changed in between apart from the
+// missing ones, once an update checking the version changed no row.
+func (p *picturesRepository) versionConflict(id int) error {
+	var current Picture
+	if err := p.db.Select("version").Where("id = ? AND deleted = ?", id, false).First(&current).Error; err != nil {
 		return fmt.Errorf("record with id: %d not found", id)
 	}
-
-	return nil
+	return &VersionConflictError{CurrentVersion: current.Version}
 }
 
 func (p *picturesRepository) GetAll(limit, page int) ([]*Picture, int64, error) {
@@ -84,6 +217,194 @@ func (p *picturesRepository) GetAll(limit, page int) ([]*Picture, int64, error)
 	return pictures, totalCount, nil
 }
 
+// GetDeleted lists the soft deleted pictures, whose files are kept until
+// they are purged.
+func (p *picturesRepository) GetDeleted(limit, page int) ([]*Picture, int64, error) {
+	var pictures []*Picture
+	if err := p.db.Where("deleted = ?", true).Order("id").Limit(limit).Offset(limit * (page - 1)).Find(&pictures).Error; err != nil {
+		return nil, 0, err
+	}
+	var totalCount int64
+	if err := p.db.Model(&Picture{}).Where("deleted = ?", true).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+	return pictures, totalCount, nil
+}
+
+// GetAfter lists the live or soft deleted pictures by id, from the one after
+// afterId, for the scans of every picture. Unlike the pages of GetAll, the
+// batches are left as they are by the pictures changed in between.
+func (p *picturesRepository) GetAfter(deleted bool, afterId uint, limit int) ([]*Picture, error) {
+	var pictures []*Picture
+	if err := p.db.Where("deleted = ? AND id > ?", deleted, afterId).Order("id").Limit(limit).Find(&pictures).Error; err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+// Purge removes the row of a soft deleted picture along with its collection
+// memberships and previous versions, once remove deleted the files of the
+// picture no other picture refers to. The references are checked within the transaction,
+// right before each removal. The row is kept when remove fails, so that the
+// purge can be run again.
+func (p *picturesRepository) Purge(id int, remove func(PictureFile) error) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		var picture Picture
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND deleted = ?", id, true).First(&picture).Error; err != nil {
+			return fmt.Errorf("deleted record with id: %d not found", id)
+		}
+
+		if err := removeUnreferenced(tx, &picture, remove); err != nil {
+			return err
+		}
+		return deletePicture(tx, &picture)
+	})
+}
+
+// deletePicture removes the row of the picture along with its collection
+// memberships and previous versions.
+func deletePicture(tx *gorm.DB, picture *Picture) error {
+	if err := tx.Where("picture_id = ?", picture.ID).Delete(&PictureVersion{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(picture).Error; err != nil {
+		return err
+	}
+	return tx.Where("picture_id = ?", picture.ID).Delete(&CollectionPicture{}).Error
+}
+
+// removeUnreferenced calls remove with each file of the picture, and of its
+// previous versions, no other picture, live or soft deleted, nor version of
+// another picture refers to.
+func removeUnreferenced(tx *gorm.DB, picture *Picture, remove func(PictureFile) error) error {
+	var versionFiles []string
+	if err := tx.Model(&PictureVersion{}).Where("picture_id = ?", picture.ID).Distinct().Pluck("destination", &versionFiles).Error; err != nil {
+		return err
+	}
+	files := picture.Files()
+	for _, destination := range versionFiles {
+		if !slices.Contains(files, PictureFile{Destination: destination}) {
+			files = append(files, PictureFile{Destination: destination})
+		}
+	}
+
+	for _, file := range files {
+		query := tx.Model(&Picture{}).Where("id <> ?", picture.ID)
+		if file.Video {
+			query = query.Where("video_destination = ?", file.Destination)
+		} else {
+			query = query.Where("? IN (destination, thumbnail_destination, animated_thumbnail_destination, interlaced_destination)", file.Destination)
+		}
+
+		var ids []uint
+		if err := query.Limit(1).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			continue
+		}
+		if !file.Video {
+			if err := tx.Model(&PictureVersion{}).Where("picture_id <> ? AND destination = ?", picture.ID, file.Destination).Limit(1).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				continue
+			}
+		}
+		if err := remove(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *picturesRepository) Search(filter *dto.PictureFilter, limit, page int) ([]*Picture, int64, error) {
+	query := p.db.Model(&Picture{}).Where("deleted = ?", false)
+	if box := filter.Box; box != nil {
+		query = query.Where("lat BETWEEN ? AND ?", box.LatMin, box.LatMax)
+		if box.LonMin <= box.LonMax {
+			query = query.Where("lon BETWEEN ? AND ?", box.LonMin, box.LonMax)
+		} else {
+			query = query.Where("(lon >= ? OR lon <= ?)", box.LonMin, box.LonMax)
+		}
+	}
+	if filter.Grayscale != nil {
+		query = query.Where("is_grayscale = ?", *filter.Grayscale)
+	}
+	if filter.MinQuality != nil {
+		query = query.Where("quality_score >= ?", *filter.MinQuality)
+	}
+
+	var totalCount int64
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var pictures []*Picture
+	if err := query.Session(&gorm.Session{}).Order("updated_on desc").Limit(limit).Offset(limit * (page - 1)).Find(&pictures).Error; err != nil {
+		return nil, 0, err
+	}
+	return pictures, totalCount, nil
+}
+
+// GetNearby lists the pictures within radiusKm of the point, nearest first.
+// Without PostGIS a bounding box around the circle narrows the pictures down
+// using the location index before computing their distances.
+func (p *picturesRepository) GetNearby(lat, lon, radiusKm float64, limit, page int) ([]*PictureDistance, int64, error) {
+	var distance clause.Expr
+	query := p.db.Model(&Picture{}).Where("deleted = ?", false)
+
+	if p.postgis {
+		point := "geography(ST_MakePoint(?, ?))"
+		distance = gorm.Expr("ST_Distance("+pictureGeography+", "+point+") / 1000", lon, lat)
+		query = query.Where("ST_DWithin("+pictureGeography+", "+point+", ?)", lon, lat, radiusKm*1000)
+	} else {
+		distance = gorm.Expr(haversineDistance, lat, lat, lon)
+
+		box := boundingBoxAround(lat, lon, radiusKm)
+		query = query.Where("lat BETWEEN ? AND ?", box.LatMin, box.LatMax)
+		if box.LonMin > -180 || box.LonMax < 180 {
+			query = query.Where("lon BETWEEN ? AND ?", box.LonMin, box.LonMax)
+		}
+		query = query.Where("? <= ?", distance, radiusKm)
+	}
+
+	var totalCount int64
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var pictures []*PictureDistance
+	err := query.Session(&gorm.Session{}).Select("pictures.*, (?) AS distance_km", distance).
+		Order("distance_km").Limit(limit).Offset(limit * (page - 1)).
+		Find(&pictures).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return pictures, totalCount, nil
+}
+
+// boundingBoxAround returns a box containing the circle. Boxes reaching a
+// pole or the antimeridian span every longitude, which is wider than needed
+// but keeps the query simple.
+func boundingBoxAround(lat, lon, radiusKm float64) *dto.BoundingBox {
+	latDelta := radiusKm / earthRadiusKm * 180 / math.Pi
+	box := &dto.BoundingBox{
+		LatMin: math.Max(-90, lat-latDelta),
+		LatMax: math.Min(90, lat+latDelta),
+		LonMin: -180,
+		LonMax: 180,
+	}
+
+	if box.LatMin > -90 && box.LatMax < 90 {
+		lonDelta := latDelta / math.Cos(lat*math.Pi/180)
+		if lon-lonDelta >= -180 && lon+lonDelta <= 180 {
+			box.LonMin, box.LonMax = lon-lonDelta, lon+lonDelta
+		}
+	}
+	return box
+}
+
 func (p *picturesRepository) GetById(id int) (*Picture, error) {
 	var picture *Picture
 
@@ -93,3 +414,198 @@ func (p *picturesRepository) GetById(id int) (*Picture, error) {
 
 	return picture, nil
 }
+
+// UpdateComputed saves the fields computed by the processing pipeline without
+// touching updated_on.
+func (p *picturesRepository) UpdateComputed(picture *Picture) error {
+	return p.db.Model(picture).Select(computedColumns).UpdateColumns(picture).Error
+}
+
+// UpdateModeration sets the moderation status of the picture along with the
+// reason it was flagged for.
+func (p *picturesRepository) UpdateModeration(id int, status, reason string) (*Picture, error) {
+	picture, err := p.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	picture.ModerationStatus = status
+	picture.ModerationReason = reason
+	if err := p.db.Model(picture).Select("moderation_status", "moderation_reason", "updated_on").Updates(picture).Error; err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+// GetByModerationStatus lists the pictures with the status, the longest
+// waiting first.
+func (p *picturesRepository) GetByModerationStatus(status string, limit, page int) ([]*Picture, int64, error) {
+	query := p.db.Model(&Picture{}).Where("deleted = ? AND moderation_status = ?", false, status)
+
+	var totalCount int64
+	if err := query.Session(&gorm.Session{}).Count(&totalCount).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var pictures []*Picture
+	if err := query.Session(&gorm.Session{}).Order("updated_on").Limit(limit).Offset(limit * (page - 1)).Find(&pictures).Error; err != nil {
+		return nil, 0, err
+	}
+	return pictures, totalCount, nil
+}
+
+// UpdateStorageTier saves the tier of the file at the destination, which is
+// the tier of every picture with the same contents, soft deleted or not,
+// and of the previous versions with them.
+func (p *picturesRepository) UpdateStorageTier(destination string, tier string) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Picture{}).Where("destination = ?", destination).UpdateColumn("storage_tier", tier).Error; err != nil {
+			return err
+		}
+		return tx.Model(&PictureVersion{}).Where("destination = ?", destination).UpdateColumn("storage_tier", tier).Error
+	})
+}
+
+// RecordChecksumVerified saves when the file at the destination matched its
+// checksum, for every picture with the same contents, without touching
+// updated_on.
+func (p *picturesRepository) RecordChecksumVerified(destination string, verifiedOn int64) error {
+	return p.db.Model(&Picture{}).Where("destination = ?", destination).UpdateColumn("checksum_verified_on", verifiedOn).Error
+}
+
+// UpdateInterlacedDestination saves where the interlaced copy of the picture
+// is without touching updated_on, which dates the picture file.
+func (p *picturesRepository) UpdateInterlacedDestination(id int, destination string) error {
+	result := p.db.Model(&Picture{}).Where("id = ? AND deleted = ?", id, false).UpdateColumn("interlaced_destination", destination)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("record with id: %d not found", id)
+	}
+	return nil
+}
+
+// UpdateTags replaces the tags of the picture without touching updated_on,
+// which dates the picture file.
+func (p *picturesRepository) UpdateTags(id int, tags []string) error {
+	result := p.db.Model(&Picture{}).Where("id = ? AND deleted = ?", id, false).Select("tags").UpdateColumns(&Picture{Tags: tags})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("record with id: %d not found", id)
+	}
+	return nil
+}
+
+// UpdateDestinations saves where the files of the picture are, e.g. after
+// they moved in the storage, soft deleted or not. updated_on is left alone,
+// the files are the same.
+func (p *picturesRepository) UpdateDestinations(picture *Picture) error {
+	result := p.db.Model(&Picture{}).Where("id = ?", picture.ID).
+		Select("destination", "thumbnail_destination", "animated_thumbnail_destination", "interlaced_destination").
+		UpdateColumns(&Picture{
+			Destination:                  picture.Destination,
+			ThumbnailDestination:         picture.ThumbnailDestination,
+			AnimatedThumbnailDestination: picture.AnimatedThumbnailDestination,
+			InterlacedDestination:        picture.InterlacedDestination,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("record with id: %d not found", picture.ID)
+	}
+	return nil
+}
+
+// RecordView saves when the picture was last viewed, and counts the view,
+// without touching updated_on.
+func (p *picturesRepository) RecordView(id int, viewedOn int64) error {
+	return p.db.Model(&Picture{}).Where("id = ?", id).
+		UpdateColumns(map[string]any{"last_viewed_on": viewedOn, "view_count": gorm.Expr("view_count + 1")}).Error
+}
+
+// GetUnviewedSince lists the pictures of the tier neither viewed nor
+// uploaded since the time, the longest unviewed first. The pictures sharing
+// their file with a live picture viewed or uploaded since are left out.
+func (p *picturesRepository) GetUnviewedSince(tier string, viewedOn int64, limit int) ([]*Picture, error) {
+	var pictures []*Picture
+	err := p.db.Where("deleted = ? AND storage_tier = ? AND greatest(last_viewed_on, created_on) < ?", false, tier, viewedOn).
+		Where("NOT EXISTS (SELECT 1 FROM pictures AS others WHERE others.destination = pictures.destination AND others.deleted = false AND greatest(others.last_viewed_on, others.created_on) >= ?)", viewedOn).
+		Order("greatest(last_viewed_on, created_on)").Limit(limit).Find(&pictures).Error
+	if err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+// UpdateRetainUntil saves the retention override of the picture, 0 for none.
+func (p *picturesRepository) UpdateRetainUntil(id int, retainUntil int64) (*Picture, error) {
+	result := p.db.Model(&Picture{}).Where("id = ? AND deleted = ?", id, false).Update("retain_until", retainUntil)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("record with id: %d not found", id)
+	}
+	return p.GetById(id)
+}
+
+// GetExpired lists the live pictures uploaded before createdBefore and never
+// viewed, unless retained past now, the oldest first.
+func (p *picturesRepository) GetExpired(createdBefore, now int64, limit int) ([]*Picture, error) {
+	var pictures []*Picture
+	err := p.db.Where(expiredCondition, now).Where("created_on < ?", createdBefore).
+		Order("created_on").Limit(limit).Find(&pictures).Error
+	if err != nil {
+		return nil, err
+	}
+	return pictures, nil
+}
+
+// Expire removes the row of a picture found by GetExpired, along with its
+// collection memberships and previous versions, provided it's still expired. The files no other
+// picture refers to are given to remove beforehand, see Purge.
+func (p *picturesRepository) Expire(ctx context.Context, id int, now int64, remove func(PictureFile) error) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var picture Picture
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).Where(expiredCondition, now).First(&picture).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotExpired
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := removeUnreferenced(tx, &picture, remove); err != nil {
+			return err
+		}
+		if err := deletePicture(tx, &picture); err != nil {
+			return err
+		}
+		return recordAudit(tx, AuditActionExpire, picture.ID, &picture, nil)
+	})
+}
+
+// GetSizeByTier sums the sizes of the uploaded files by storage tier, those
+// of the soft deleted pictures included until they're purged. The files
+// shared by several pictures are counted once.
+func (p *picturesRepository) GetSizeByTier() (map[string]int64, error) {
+	var rows []struct {
+		StorageTier string
+		Bytes       int64
+	}
+	files := p.db.Model(&Picture{}).Distinct("destination", "storage_tier", "size")
+	err := p.db.Table("(?) AS files", files).Select("storage_tier, sum(size) AS bytes").Group("storage_tier").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := map[string]int64{}
+	for _, eachRow := range rows {
+		sizes[eachRow.StorageTier] = eachRow.Bytes
+	}
+	return sizes, nil
+}
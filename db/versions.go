@@ -0,0 +1,65 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+// PictureVersion is a previous version of the image of a picture, recorded
+// by every update along with the file it had. The content addressed files
+// stay in the storage as long as a picture or a version refers to them, so
+// the previous versions can be downloaded until the picture is purged.
+type PictureVersion struct {
+	ID          uint   `gorm:"primary_key"`
+	PictureID   uint   `gorm:"uniqueIndex:idx_picture_versions_version"`
+	Version     int    `gorm:"uniqueIndex:idx_picture_versions_version"`
+	Destination string `gorm:"index"`
+	ContentType string
+	Size        int32
+	Checksum    string
+	// the tier of the file, moved along with the pictures sharing it, see
+	// UpdateStorageTier
+	StorageTier string `gorm:"type:storage_tier;default:'hot'"`
+	// when the update replaced the version
+	ReplacedOn int64 `gorm:"autoCreateTime:milli"`
+}
+
+// recordVersion keeps the image of the picture about to be replaced, within
+// the transaction of the update.
+func recordVersion(tx *gorm.DB, picture *Picture) error {
+	return tx.Create(&PictureVersion{
+		PictureID:   picture.ID,
+		Version:     picture.Version,
+		Destination: picture.Destination,
+		ContentType: picture.ContentType,
+		Size:        picture.Size,
+		Checksum:    picture.Checksum,
+		StorageTier: picture.StorageTier,
+	}).Error
+}
+
+// GetVersions lists the previous versions of the picture, the oldest first.
+func (p *picturesRepository) GetVersions(id int) ([]*PictureVersion, error) {
+	var versions []*PictureVersion
+	if err := p.db.Where("picture_id = ?", id).Order("version").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// GetVersionFiles lists the files of the previous versions of the live
+// pictures, or of the soft deleted ones, by picture id.
+func (p *picturesRepository) GetVersionFiles(deleted bool) (map[uint][]string, error) {
+	var versions []*PictureVersion
+	err := p.db.Model(&PictureVersion{}).Select("picture_versions.picture_id, picture_versions.destination").
+		Joins("JOIN pictures ON pictures.id = picture_versions.picture_id").
+		Where("pictures.deleted = ?", deleted).Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[uint][]string{}
+	for _, eachVersion := range versions {
+		files[eachVersion.PictureID] = append(files[eachVersion.PictureID], eachVersion.Destination)
+	}
+	return files, nil
+}
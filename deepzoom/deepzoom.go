@@ -0,0 +1,117 @@
+package deepzoom
+
+import (
+	"encoding/xml"
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	Namespace   = "http://schemas.microsoft.com/deepzoom/2008"
+	ContentType = "application/xml"
+
+	// TileSize and Overlap are the defaults of the Deep Zoom tools, the tiles
+	// with their overlap on both sides are 256 pixels wide.
+	TileSize = 254
+	Overlap  = 1
+	Format   = "jpg"
+)
+
+type Size struct {
+	Width  int `xml:"Width,attr"`
+	Height int `xml:"Height,attr"`
+}
+
+// Descriptor is the .dzi document of a tile set, see
+// https://learn.microsoft.com/en-us/previous-versions/windows/silverlight/dotnet-windows-silverlight/cc645077(v=vs.95)
+type Descriptor struct {
+	XMLName  xml.Name `xml:"Image"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Format   string   `xml:"Format,attr"`
+	Overlap  int      `xml:"Overlap,attr"`
+	TileSize int      `xml:"TileSize,attr"`
+	Size     Size     `xml:"Size"`
+}
+
+func NewDescriptor(width, height int) *Descriptor {
+	return &Descriptor{
+		Xmlns:    Namespace,
+		Format:   Format,
+		Overlap:  Overlap,
+		TileSize: TileSize,
+		Size:     Size{Width: width, Height: height},
+	}
+}
+
+func (d *Descriptor) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// MaxLevel is the level of the full size image. Level 0 is a single pixel
+// and each level is twice as large as the previous one.
+func (d *Descriptor) MaxLevel() int {
+	largest := max(d.Size.Width, d.Size.Height, 1)
+	return bits.Len(uint(largest - 1))
+}
+
+// LevelSize returns the size of the image at the level, rounded up.
+func (d *Descriptor) LevelSize(level int) (int, int) {
+	scale := d.MaxLevel() - level
+	return ceilShift(d.Size.Width, scale), ceilShift(d.Size.Height, scale)
+}
+
+// Grid returns the number of columns and rows of tiles at the level.
+func (d *Descriptor) Grid(level int) (int, int) {
+	width, height := d.LevelSize(level)
+	return (width + d.TileSize - 1) / d.TileSize, (height + d.TileSize - 1) / d.TileSize
+}
+
+// TileBounds returns the region of the image at the level covered by the
+// tile, including its overlap with the neighbouring tiles.
+func (d *Descriptor) TileBounds(level, column, row int) image.Rectangle {
+	width, height := d.LevelSize(level)
+	bounds := image.Rect(column*d.TileSize, row*d.TileSize, (column+1)*d.TileSize, (row+1)*d.TileSize)
+	bounds = image.Rect(bounds.Min.X-d.Overlap, bounds.Min.Y-d.Overlap, bounds.Max.X+d.Overlap, bounds.Max.Y+d.Overlap)
+	return bounds.Intersect(image.Rect(0, 0, width, height))
+}
+
+// Generate cuts the image into the tiles of every level, from the full size
+// one down to level 0, calling write with each of them. Each level is scaled
+// down from the previous one.
+func Generate(src image.Image, write func(level, column, row int, tile image.Image) error) error {
+	bounds := src.Bounds()
+	descriptor := NewDescriptor(bounds.Dx(), bounds.Dy())
+
+	levelImage := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(levelImage, levelImage.Bounds(), src, bounds.Min, draw.Src)
+
+	for level := descriptor.MaxLevel(); level >= 0; level-- {
+		width, height := descriptor.LevelSize(level)
+		if levelImage.Bounds().Dx() != width || levelImage.Bounds().Dy() != height {
+			scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+			draw.ApproxBiLinear.Scale(scaled, scaled.Bounds(), levelImage, levelImage.Bounds(), draw.Src, nil)
+			levelImage = scaled
+		}
+
+		columns, rows := descriptor.Grid(level)
+		for column := 0; column < columns; column++ {
+			for row := 0; row < rows; row++ {
+				tile := levelImage.SubImage(descriptor.TileBounds(level, column, row))
+				if err := write(level, column, row, tile); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func ceilShift(value, shift int) int {
+	return max(1, (value+(1<<shift)-1)>>shift)
+}
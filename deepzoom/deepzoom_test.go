@@ -0,0 +1,64 @@
+package deepzoom
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevels(t *testing.T) {
+	descriptor := NewDescriptor(600, 300)
+	assert.Equal(t, 10, descriptor.MaxLevel())
+
+	width, height := descriptor.LevelSize(10)
+	assert.Equal(t, 600, width)
+	assert.Equal(t, 300, height)
+	width, height = descriptor.LevelSize(9)
+	assert.Equal(t, 300, width)
+	assert.Equal(t, 150, height)
+	width, height = descriptor.LevelSize(0)
+	assert.Equal(t, 1, width)
+	assert.Equal(t, 1, height)
+
+	columns, rows := descriptor.Grid(10)
+	assert.Equal(t, 3, columns)
+	assert.Equal(t, 2, rows)
+
+	assert.Equal(t, image.Rect(0, 0, 255, 255), descriptor.TileBounds(10, 0, 0))
+	assert.Equal(t, image.Rect(253, 253, 509, 300), descriptor.TileBounds(10, 1, 1))
+	assert.Equal(t, image.Rect(507, 0, 600, 255), descriptor.TileBounds(10, 2, 0))
+
+	assert.Equal(t, 0, NewDescriptor(1, 1).MaxLevel())
+	assert.Equal(t, 8, NewDescriptor(256, 10).MaxLevel())
+	assert.Equal(t, 9, NewDescriptor(257, 10).MaxLevel())
+}
+
+func TestMarshal(t *testing.T) {
+	body, err := NewDescriptor(600, 300).Marshal()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "<?xml"))
+	assert.Contains(t, string(body), `<Image xmlns="http://schemas.microsoft.com/deepzoom/2008" Format="jpg" Overlap="1" TileSize="254">`)
+	assert.Contains(t, string(body), `<Size Width="600" Height="300"></Size>`)
+}
+
+func TestGenerate(t *testing.T) {
+	descriptor := NewDescriptor(600, 300)
+	tiles := map[[3]int]image.Rectangle{}
+	err := Generate(image.NewRGBA(image.Rect(0, 0, 600, 300)), func(level, column, row int, tile image.Image) error {
+		tiles[[3]int{level, column, row}] = tile.Bounds()
+		return nil
+	})
+	assert.Nil(t, err)
+
+	count := 0
+	for level := 0; level <= descriptor.MaxLevel(); level++ {
+		columns, rows := descriptor.Grid(level)
+		count += columns * rows
+	}
+	assert.Len(t, tiles, count)
+	assert.Equal(t, image.Rect(253, 253, 509, 300), tiles[[3]int{10, 1, 1}])
+	assert.Equal(t, image.Rect(0, 0, 1, 1), tiles[[3]int{0, 0, 0}])
+	assert.Equal(t, image.Rect(0, 0, 150, 75), tiles[[3]int{8, 0, 0}])
+}
@@ -1,6 +1,8 @@
 package dto
 
 import (
+	"encoding/json"
+	"image/color"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,6 +15,84 @@ type PictureRequest struct {
 	Width       int32
 	Size        int32
 	ContentType string
+	Checksum    string
+	IsAnimated  bool
+	IsGrayscale bool
+	// left out when empty so replacing the image keeps the description
+	Description string `json:",omitempty"`
+	// the preview of PDFs, rendered on upload instead of by the processing
+	ThumbnailDestination string `json:",omitempty"`
+	// the uploaded video the picture is the first frame of
+	Video *VideoFile `json:",omitempty"`
+	// left out when empty so replacing the image keeps the moderation status
+	ModerationStatus string `json:",omitempty"`
+	// set when the image is replaced, since the new file is stored hot
+	StorageTier string `json:",omitempty"`
+	// always empty, so replacing the image drops the interlaced copy of the
+	// previous one
+	InterlacedDestination string
+	// the version of the picture the update is based on, 0 to update
+	// whatever the current version
+	Version int `json:"-"`
+}
+
+// VideoFile is an uploaded video, kept in the video storage.
+type VideoFile struct {
+	Destination string
+	ContentType string
+	Metadata    *VideoMetadata
+}
+
+// TagRequest adds a tag to a picture.
+type TagRequest struct {
+	Tag string `json:"tag" validate:"required,max=100"`
+}
+
+// RetentionRequest keeps a picture past server.retentionDays until
+// retain_until, null to remove the override.
+type RetentionRequest struct {
+	RetainUntil *time.Time `json:"retain_until"`
+}
+
+// ModerationFlagRequest reports a picture to the moderators.
+type ModerationFlagRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// CompositeRequest draws the overlay picture over the base one, with its
+// top left corner at x, y.
+type CompositeRequest struct {
+	BaseId    uint `json:"base_id" validate:"required"`
+	OverlayId uint `json:"overlay_id" validate:"required"`
+	X         int  `json:"x"`
+	Y         int  `json:"y"`
+	// between 0 and 1, 1 when left out
+	Opacity *float64 `json:"opacity" validate:"omitempty,gte=0,lte=1"`
+}
+
+type Base64PictureRequest struct {
+	// plain base64 or a data URL
+	Data     string `json:"data" validate:"required"`
+	Filename string `json:"filename" validate:"omitempty,max=255"`
+}
+
+type DataURIImage struct {
+	URI  string `json:"uri" validate:"required"`
+	Name string `json:"name" validate:"omitempty,max=255"`
+}
+
+type DataURIImportRequest struct {
+	Images []*DataURIImage `json:"images" validate:"required,min=1,dive,required"`
+}
+
+// ImportResult is the outcome of importing one image of a bulk import, in the
+// order of the request.
+type ImportResult struct {
+	Index  int              `json:"index"`
+	Status int              `json:"status"`
+	Data   *PictureResponse `json:"data,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Meta   map[string]any   `json:"meta,omitempty"`
 }
 
 type InvalidPictureFileError struct {
@@ -22,32 +102,550 @@ type InvalidPictureFileError struct {
 }
 
 type PictureResponse struct {
-	Id          uint      `json:"id"`
-	Name        string    `json:"name"`
-	Url         string    `json:"url"`
-	Height      int32     `json:"height"`
-	Width       int32     `json:"width"`
-	Size        string    `json:"size"`
-	ContentType string    `json:"content_type"`
-	CreatedOn   time.Time `json:"created_on"`
-	UpdatedOn   time.Time `json:"updated_on"`
+	Id uint `json:"id"`
+	// incremented by every update, sent back with the updates and deletions
+	// to make sure they don't overwrite another change
+	Version     int      `json:"version"`
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Height      int32    `json:"height"`
+	Width       int32    `json:"width"`
+	Size        string   `json:"size"`
+	ContentType string   `json:"content_type"`
+	Checksum    string   `json:"checksum"`
+	IsAnimated  bool     `json:"is_animated"`
+	IsGrayscale bool     `json:"is_grayscale"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+
+	ThumbnailUrl string `json:"thumbnail_url,omitempty"`
+	// set for the GIFs with several frames
+	ThumbnailAnimatedUrl string    `json:"thumbnail_animated_url,omitempty"`
+	PerceptualHash       string    `json:"perceptual_hash,omitempty"`
+	HasICCProfile        bool      `json:"has_icc_profile"`
+	XMPPresent           bool      `json:"xmp_present"`
+	IPTC                 *IPTCData `json:"iptc,omitempty"`
+	// set for the first frames of uploaded videos only
+	Video *VideoMetadata `json:"video,omitempty"`
+	// set by nearby searches only
+	DistanceKm *float64 `json:"distance_km,omitempty"`
+	// from 0 to 100, computed by the processing pipeline
+	QualityScore *float64 `json:"quality_score,omitempty"`
+	Processed    bool     `json:"processed"`
+	// pending, approved or rejected, along with the reason it was flagged for
+	ModerationStatus string `json:"moderation_status"`
+	ModerationReason string `json:"moderation_reason,omitempty"`
+	// hot, warm or cold
+	StorageTier string `json:"storage_tier"`
+	// kept until then past server.retentionDays, see RetentionRequest
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
 }
 
-type ListPicturesResponse struct {
-	Pictures   []*PictureResponse `json:"pictures"`
-	Count      int                `json:"count"`
-	TotalPages int                `json:"total_pages"`
+// PictureResponseV2 is the v2 shape of PictureResponse, with the IPTC
+// metadata flattened into iptc_ fields.
+type PictureResponseV2 struct {
+	Id          uint     `json:"id"`
+	Version     int      `json:"version"`
+	Name        string   `json:"name"`
+	Url         string   `json:"url"`
+	Height      int32    `json:"height"`
+	Width       int32    `json:"width"`
+	Size        string   `json:"size"`
+	ContentType string   `json:"content_type"`
+	Checksum    string   `json:"checksum"`
+	IsAnimated  bool     `json:"is_animated"`
+	IsGrayscale bool     `json:"is_grayscale"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+
+	ThumbnailUrl         string         `json:"thumbnail_url,omitempty"`
+	ThumbnailAnimatedUrl string         `json:"thumbnail_animated_url,omitempty"`
+	PerceptualHash       string         `json:"perceptual_hash,omitempty"`
+	HasICCProfile        bool           `json:"has_icc_profile"`
+	XMPPresent           bool           `json:"xmp_present"`
+	IPTCKeywords         []string       `json:"iptc_keywords,omitempty"`
+	IPTCCopyright        string         `json:"iptc_copyright,omitempty"`
+	IPTCCredit           string         `json:"iptc_credit,omitempty"`
+	IPTCCaption          string         `json:"iptc_caption,omitempty"`
+	Video                *VideoMetadata `json:"video,omitempty"`
+	DistanceKm           *float64       `json:"distance_km,omitempty"`
+	QualityScore         *float64       `json:"quality_score,omitempty"`
+	Processed            bool           `json:"processed"`
+
+	ModerationStatus string     `json:"moderation_status"`
+	ModerationReason string     `json:"moderation_reason,omitempty"`
+	StorageTier      string     `json:"storage_tier"`
+	RetainUntil      *time.Time `json:"retain_until,omitempty"`
+
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
 }
 
-type SinglePictureResponse struct {
-	Data *PictureResponse `json:"data"`
+func (p *PictureResponse) ToV2() *PictureResponseV2 {
+	response := &PictureResponseV2{
+		Id:                   p.Id,
+		Version:              p.Version,
+		Name:                 p.Name,
+		Url:                  p.Url,
+		Height:               p.Height,
+		Width:                p.Width,
+		Size:                 p.Size,
+		ContentType:          p.ContentType,
+		Checksum:             p.Checksum,
+		IsAnimated:           p.IsAnimated,
+		IsGrayscale:          p.IsGrayscale,
+		Description:          p.Description,
+		Tags:                 p.Tags,
+		ThumbnailUrl:         p.ThumbnailUrl,
+		ThumbnailAnimatedUrl: p.ThumbnailAnimatedUrl,
+		PerceptualHash:       p.PerceptualHash,
+		HasICCProfile:        p.HasICCProfile,
+		XMPPresent:           p.XMPPresent,
+		Video:                p.Video,
+		DistanceKm:           p.DistanceKm,
+		QualityScore:         p.QualityScore,
+		Processed:            p.Processed,
+		CreatedOn:            p.CreatedOn,
+		UpdatedOn:            p.UpdatedOn,
+
+		ModerationStatus: p.ModerationStatus,
+		ModerationReason: p.ModerationReason,
+		StorageTier:      p.StorageTier,
+		RetainUntil:      p.RetainUntil,
+	}
+	if p.IPTC != nil {
+		response.IPTCKeywords = p.IPTC.Keywords
+		response.IPTCCopyright = p.IPTC.Copyright
+		response.IPTCCredit = p.IPTC.Credit
+		response.IPTCCaption = p.IPTC.Caption
+	}
+	return response
 }
 
 type StringResponse struct {
 	Message string `json:"message"`
 }
 
-type GeneralErrorResponse struct {
-	Error string         `json:"error"`
-	Meta  map[string]any `json:"meta,omitempty"`
+// Response is the envelope of the successful JSON responses. The errors are
+// Problem documents.
+type Response struct {
+	Data any           `json:"data"`
+	Meta *ResponseMeta `json:"meta"`
+}
+
+type ResponseMeta struct {
+	RequestId string `json:"request_id"`
+	Version   string `json:"version"`
+	// set for lists only
+	*ListMeta
+}
+
+type ListMeta struct {
+	Total int `json:"total"`
+	// page to ask for next, null on the last page
+	Cursor     *string `json:"cursor"`
+	TotalPages int     `json:"total_pages,omitempty"`
+}
+
+// ResponseError is an error with details for the extension members of the
+// problem written for it. The failed validations of request body fields have
+// the field and the rule.
+type ResponseError struct {
+	Message string         `json:"message"`
+	Field   string         `json:"field,omitempty"`
+	Rule    string         `json:"rule,omitempty"`
+	Param   string         `json:"param,omitempty"`
+	Meta    map[string]any `json:"meta,omitempty"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// Problem is an RFC 7807 problem details document, the body of the error
+// responses.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// the errors of the problems with several of them, e.g. the failed
+	// validations of the request body fields
+	Errors []*ResponseError `json:"errors,omitempty"`
+	// the id of the request, also sent as the X-Request-Id header
+	RequestId string `json:"request_id,omitempty"`
+	// other extension members, written next to the standard ones
+	Extensions map[string]any `json:"-"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if len(p.Errors) > 0 {
+		members["errors"] = p.Errors
+	}
+	if p.RequestId != "" {
+		members["request_id"] = p.RequestId
+	}
+	return json.Marshal(members)
+}
+
+type IIIFInfoResponse struct {
+	Context        string   `json:"@context"`
+	Id             string   `json:"id"`
+	Type           string   `json:"type"`
+	Protocol       string   `json:"protocol"`
+	Profile        string   `json:"profile"`
+	Width          int32    `json:"width"`
+	Height         int32    `json:"height"`
+	ExtraQualities []string `json:"extraQualities,omitempty"`
+	ExtraFormats   []string `json:"extraFormats,omitempty"`
+	ExtraFeatures  []string `json:"extraFeatures,omitempty"`
+	MaxWidth       int      `json:"maxWidth,omitempty"`
+	MaxHeight      int      `json:"maxHeight,omitempty"`
+	MaxArea        int      `json:"maxArea,omitempty"`
+}
+
+type LifecycleRule struct {
+	Id              string `json:"id" validate:"omitempty,max=255"`
+	Prefix          string `json:"prefix" validate:"max=1024"`
+	TransitionDays  int32  `json:"transition_days,omitempty" validate:"min=0"`
+	TransitionClass string `json:"transition_class,omitempty"`
+	ExpireDays      int32  `json:"expire_days,omitempty" validate:"min=0"`
+	Disabled        bool   `json:"disabled"`
+}
+
+type PictureVersion struct {
+	VersionId    string    `json:"version_id"`
+	IsLatest     bool      `json:"is_latest"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type ProcessingStepResult struct {
+	Name       string `json:"name"`
+	Succeeded  bool   `json:"succeeded"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type ProcessingResult struct {
+	Picture              *PictureResponse        `json:"picture"`
+	ProcessingDurationMs int64                   `json:"processing_duration_ms"`
+	Steps                []*ProcessingStepResult `json:"steps"`
+}
+
+type ProbeResponse struct {
+	Status string `json:"status"`
+	// outcome of each readiness check, "ok" or the error
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type ConfigReloadResponse struct {
+	// changed settings that only take effect after a restart
+	RestartRequired []string `json:"restart_required"`
+}
+
+type StorageTierRequest struct {
+	Tier string `json:"tier" validate:"required,oneof=hot warm cold"`
+}
+
+// RestoreStatus tells whether the file of a cold picture can be served, or
+// when to ask again while it's restored.
+type RestoreStatus struct {
+	// available or restoring
+	Status            string `json:"status"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+type ProcessingJob struct {
+	Id         string     `json:"job_id"`
+	Status     string     `json:"status"`
+	BatchSize  int        `json:"batch_size"`
+	Total      int        `json:"total"`
+	Enqueued   int        `json:"enqueued"`
+	Error      string     `json:"error,omitempty"`
+	StartedOn  time.Time  `json:"started_on"`
+	FinishedOn *time.Time `json:"finished_on,omitempty"`
+}
+
+// AuditLogEntry is a change of a picture, with the user who made it, empty
+// for the anonymous requests. The values are the picture before and after
+// the change, null for the created and deleted ones.
+type AuditLogEntry struct {
+	Id         uint            `json:"id"`
+	EntityType string          `json:"entity_type"`
+	EntityId   uint            `json:"entity_id"`
+	Action     string          `json:"action"`
+	ActorId    string          `json:"actor_id"`
+	OldValue   json.RawMessage `json:"old_value" swaggertype:"object"`
+	NewValue   json.RawMessage `json:"new_value" swaggertype:"object"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// ReconcileReport compares the pictures of the database with the files of
+// the storage.
+type ReconcileReport struct {
+	Pictures int `json:"pictures"`
+	Files    int `json:"files"`
+	// the files of the pictures that aren't stored
+	Missing []*MissingFile `json:"missing"`
+	// the stored files no picture refers to, including the soft deleted ones
+	Orphaned []string `json:"orphaned"`
+}
+
+type MissingFile struct {
+	PictureId   uint   `json:"picture_id"`
+	Destination string `json:"destination"`
+}
+
+// PurgeReport lists the soft deleted pictures removed for good, or that
+// would be on a dry run, along with their files.
+type PurgeReport struct {
+	DryRun   bool     `json:"dry_run"`
+	Pictures []uint   `json:"pictures"`
+	Files    []string `json:"files"`
+	Videos   []string `json:"videos,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// ReprocessReport counts the pictures run through the processing pipeline.
+type ReprocessReport struct {
+	DryRun    bool     `json:"dry_run"`
+	Total     int      `json:"total"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// MigrationReport counts the files copied from a storage backend to another.
+type MigrationReport struct {
+	DryRun bool `json:"dry_run"`
+	Copied int  `json:"copied"`
+	// the files already in the target storage with the same size
+	Skipped int      `json:"skipped"`
+	Bytes   int64    `json:"bytes"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// IdempotentResponse is the response replayed to the retries of a request
+// sent with an X-Idempotency-Key header, along with the method, the route
+// and the hex SHA-256 of the body of the request, which the retries have to
+// match.
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	Method      string
+	Route       string
+	BodyHash    string
+}
+
+// PrefixMigrationReport counts the files moved under their hashed prefixes.
+type PrefixMigrationReport struct {
+	DryRun bool `json:"dry_run"`
+	// the pictures, soft deleted or not, with files moved
+	Pictures int `json:"pictures"`
+	Files    int `json:"files"`
+	// the files removed from their former destinations
+	Removed int      `json:"removed"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// StorageStats summarizes the pictures of the database and the files of the
+// storage.
+type StorageStats struct {
+	Pictures        int `json:"pictures"`
+	DeletedPictures int `json:"deleted_pictures"`
+	// the uploaded files of the pictures, by content type
+	ContentTypes []*ContentTypeUsage `json:"content_types"`
+	// every stored file, along with the thumbnails and the tile sets
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// StorageCostEstimate is the monthly cost of the stored files at the prices
+// of storage.costPerGBMonth, in their currency.
+type StorageCostEstimate struct {
+	Backend     string      `json:"backend"`
+	Bytes       int64       `json:"bytes"`
+	MonthlyCost float64     `json:"monthly_cost"`
+	Tiers       []*TierCost `json:"tiers"`
+	// storage.monthlyCostAlertThreshold, 0 for no alert
+	AlertThreshold float64 `json:"alert_threshold"`
+}
+
+// TierCost is the part of a storage tier in a StorageCostEstimate. The
+// thumbnails and the other files computed from the pictures are hot.
+type TierCost struct {
+	Tier           string  `json:"tier"`
+	Bytes          int64   `json:"bytes"`
+	CostPerGBMonth float64 `json:"cost_per_gb_month"`
+	MonthlyCost    float64 `json:"monthly_cost"`
+}
+
+type ContentTypeUsage struct {
+	ContentType string `json:"content_type"`
+	Pictures    int    `json:"pictures"`
+	Bytes       int64  `json:"bytes"`
+}
+
+type PictureFrame struct {
+	Frame   int `json:"frame"`
+	DelayMs int `json:"delay_ms"`
+}
+
+// PictureQuality is a referenceless quality estimate of a picture, see
+// imaging.AssessQuality.
+type PictureQuality struct {
+	Sharpness     float64 `json:"sharpness"`
+	NoiseEstimate float64 `json:"noise_estimate"`
+	QualityScore  float64 `json:"quality_score"`
+}
+
+// Srcset lists the sizes of a picture for responsive images, Srcset being
+// the value of the srcset attribute of an <img>.
+type Srcset struct {
+	Images []*SrcsetImage `json:"images"`
+	Srcset string         `json:"srcset"`
+}
+
+type SrcsetImage struct {
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Placeholder is a tiny JPEG of a picture to show while it loads.
+type Placeholder struct {
+	DataURI string `json:"data_uri"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// StegCheck is the result of the chi-square attack on the least significant
+// bits of a picture.
+type StegCheck struct {
+	Suspicious           bool    `json:"suspicious"`
+	ChiSquare            float64 `json:"chi_square"`
+	EmbeddingProbability float64 `json:"embedding_probability"`
+}
+
+// Tileset describes the Deep Zoom tiles of a picture, e.g. for the
+// tileSources of OpenSeadragon.
+type Tileset struct {
+	Descriptor string `json:"descriptor"`
+	Tiles      string `json:"tiles"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	TileSize   int    `json:"tile_size"`
+	Overlap    int    `json:"overlap"`
+	Format     string `json:"format"`
+	MaxLevel   int    `json:"max_level"`
+	TileCount  int    `json:"tile_count"`
+}
+
+type CollectionRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type CollectionResponse struct {
+	Id       uint               `json:"id"`
+	Name     string             `json:"name"`
+	Pictures []*PictureResponse `json:"pictures"`
+	// the ids of the pinned pictures, which come first, in their pin order
+	Pinned []uint `json:"pinned"`
+
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+type SpritePosition struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type SpriteResponse struct {
+	Id uint `json:"id"`
+	// positions of each picture in the sprite sheet by picture id
+	Positions map[string]*SpritePosition `json:"positions"`
+	Data      *PictureResponse           `json:"data"`
+}
+
+type VideoMetadata struct {
+	DurationSeconds float64 `json:"duration_seconds"`
+	FrameRate       float64 `json:"frame_rate"`
+	VideoCodec      string  `json:"video_codec"`
+}
+
+type IPTCData struct {
+	Keywords  []string `json:"keywords"`
+	Copyright string   `json:"copyright,omitempty"`
+	Credit    string   `json:"credit,omitempty"`
+	Caption   string   `json:"caption,omitempty"`
+}
+
+// Adjustments are the changes of the brightness, contrast and saturation of
+// a picture, between -1 and 1 where 0 changes nothing.
+type Adjustments struct {
+	Brightness float64
+	Contrast   float64
+	Saturation float64
+}
+
+// UnsharpMask sharpens a picture by amount times its difference with its
+// blur of the radius, where the difference is at least threshold levels.
+type UnsharpMask struct {
+	Amount    float64
+	Radius    int
+	Threshold int
+}
+
+// Border is a solid border added around a picture, with its widths in
+// pixels.
+type Border struct {
+	Top    int
+	Right  int
+	Bottom int
+	Left   int
+	Color  color.RGBA
+}
+
+// PictureFilter narrows down a picture search, the nil filters are left out.
+type PictureFilter struct {
+	Box        *BoundingBox
+	Grayscale  *bool
+	MinQuality *float64
+}
+
+type BoundingBox struct {
+	LatMin float64
+	LatMax float64
+	// LonMin is greater than LonMax for boxes crossing the antimeridian
+	LonMin float64
+	LonMax float64
+}
+
+type PictureLocation struct {
+	Lat      float64  `json:"lat"`
+	Lon      float64  `json:"lon"`
+	Altitude *float64 `json:"altitude"`
 }
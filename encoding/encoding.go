@@ -0,0 +1,218 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"imagenexus/dto"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoder writes the responses of the API in a format of the Accept header.
+type Encoder interface {
+	// ContentType of the responses, and of the problems, see RFC 7807
+	ContentType() string
+	ProblemContentType() string
+	Encode(io.Writer, any) error
+}
+
+var (
+	JSON        Encoder = jsonEncoder{}
+	XML         Encoder = xmlEncoder{}
+	MessagePack Encoder = msgpackEncoder{}
+)
+
+// ENCODERS are the encoders by media type of the Accept header.
+var ENCODERS = map[string]Encoder{
+	"application/json":        JSON,
+	"application/xml":         XML,
+	"text/xml":                XML,
+	"application/msgpack":     MessagePack,
+	"application/x-msgpack":   MessagePack,
+	"application/vnd.msgpack": MessagePack,
+}
+
+// Negotiate returns the encoder of the first media type of the Accept header
+// with one, JSON when there's none. The vendor media types of the API
+// versions, e.g. application/vnd.imagenexus.v2+json, are JSON.
+func Negotiate(accept string) Encoder {
+	for _, eachType := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(eachType, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+		if encoder, ok := ENCODERS[mediaType]; ok {
+			return encoder
+		}
+	}
+	return JSON
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+func (jsonEncoder) ProblemContentType() string {
+	return "application/problem+json"
+}
+
+func (jsonEncoder) Encode(w io.Writer, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// toTree returns the JSON shape of the value, so every format carries the
+// same members, named after the json tags and the MarshalJSON methods, e.g.
+// the extension members of the problems.
+func toTree(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree any
+	err = decoder.Decode(&tree)
+	return tree, err
+}
+
+// msgpackEncoder writes the integers as such rather than as the floats of
+// JSON.
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string {
+	return "application/msgpack"
+}
+
+func (msgpackEncoder) ProblemContentType() string {
+	return "application/msgpack"
+}
+
+func (msgpackEncoder) Encode(w io.Writer, value any) error {
+	tree, err := toTree(value)
+	if err != nil {
+		return err
+	}
+	return msgpack.NewEncoder(w).Encode(withNumbers(tree))
+}
+
+// withNumbers replaces the json.Numbers of the tree with int64s, or float64s
+// when they aren't integers.
+func withNumbers(tree any) any {
+	switch node := tree.(type) {
+	case json.Number:
+		if integer, err := node.Int64(); err == nil {
+			return integer
+		}
+		float, _ := node.Float64()
+		return float
+	case map[string]any:
+		for key, value := range node {
+			node[key] = withNumbers(value)
+		}
+	case []any:
+		for i, value := range node {
+			node[i] = withNumbers(value)
+		}
+	}
+	return tree
+}
+
+const problemNamespace = "urn:ietf:rfc:7807"
+
+// xmlEncoder writes the members of the objects as elements, sorted by name,
+// and the items of the arrays as item elements. The root is a response
+// element, or the problem element of RFC 7807. The members whose names
+// aren't XML names, e.g. the keys of some maps, are entry elements with a
+// key attribute instead.
+type xmlEncoder struct{}
+
+func (xmlEncoder) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xmlEncoder) ProblemContentType() string {
+	return "application/problem+xml"
+}
+
+func (xmlEncoder) Encode(w io.Writer, value any) error {
+	tree, err := toTree(value)
+	if err != nil {
+		return err
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+	switch value.(type) {
+	case dto.Problem, *dto.Problem:
+		root = xml.StartElement{Name: xml.Name{Space: problemNamespace, Local: "problem"}}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := writeElement(encoder, root, tree); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+func writeElement(encoder *xml.Encoder, start xml.StartElement, tree any) error {
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch node := tree.(type) {
+	case nil:
+	case map[string]any:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !xmlName.MatchString(key) || strings.HasPrefix(strings.ToLower(key), "xml") {
+				child = xml.StartElement{Name: xml.Name{Local: "entry"}, Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}}}
+			}
+			if err := writeElement(encoder, child, node[key]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range node {
+			if err := writeElement(encoder, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	case string:
+		if err := encoder.EncodeToken(xml.CharData(node)); err != nil {
+			return err
+		}
+	case json.Number:
+		if err := encoder.EncodeToken(xml.CharData(node.String())); err != nil {
+			return err
+		}
+	case bool:
+		if err := encoder.EncodeToken(xml.CharData(strconv.FormatBool(node))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
@@ -0,0 +1,46 @@
+package encoding
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"imagenexus/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert.Equal(t, JSON, Negotiate(""))
+	assert.Equal(t, JSON, Negotiate("*/*"))
+	assert.Equal(t, JSON, Negotiate("application/vnd.imagenexus.v2+json"))
+	assert.Equal(t, XML, Negotiate("text/html, application/xml;q=0.9"))
+	assert.Equal(t, MessagePack, Negotiate("Application/MsgPack"))
+	assert.Equal(t, JSON, Negotiate("application/json, application/xml"))
+}
+
+func TestXMLEncoder(t *testing.T) {
+	var buffer bytes.Buffer
+	value := map[string]any{"id": 1, "tags": []string{"a", "b"}, "sizes": map[string]int{"640x480": 2}}
+	assert.Nil(t, XML.Encode(&buffer, value))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<response><id>1</id><sizes><entry key="640x480">2</entry></sizes><tags><item>a</item><item>b</item></tags></response>`,
+		buffer.String())
+
+	buffer.Reset()
+	assert.Nil(t, XML.Encode(&buffer, dto.Problem{Title: "Not Found", Status: http.StatusNotFound}))
+	assert.Contains(t, buffer.String(), `<problem xmlns="urn:ietf:rfc:7807">`)
+	assert.Contains(t, buffer.String(), `<status>404</status>`)
+}
+
+func TestMessagePackEncoder(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.Nil(t, MessagePack.Encode(&buffer, map[string]any{"id": 1, "ratio": 1.5, "name": "cat"}))
+
+	var decoded map[string]any
+	assert.Nil(t, msgpack.Unmarshal(buffer.Bytes(), &decoded))
+	assert.Equal(t, int64(1), decoded["id"])
+	assert.Equal(t, 1.5, decoded["ratio"])
+	assert.Equal(t, "cat", decoded["name"])
+}
@@ -0,0 +1,481 @@
+package iiif
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/image/tiff"
+)
+
+const (
+	Context  = "http://iiif.io/api/image/3/context.json"
+	Protocol = "http://iiif.io/api/image"
+	Profile  = "level2"
+	Type     = "ImageService3"
+
+	InfoContentType = `application/ld+json;profile="http://iiif.io/api/image/3/context.json"`
+)
+
+var ExtraFormats = []string{"gif", "tif"}
+var ExtraQualities = []string{"color", "gray", "bitonal"}
+var ExtraFeatures = []string{"mirroring", "rotationArbitrary", "regionByPct", "sizeByPct", "sizeUpscaling"}
+
+var FORMAT_CONTENT_TYPES = map[string]string{
+	"jpg": "image/jpeg",
+	"png": "image/png",
+	"gif": "image/gif",
+	"tif": "image/tiff",
+}
+
+var ErrInvalidRequest = errors.New("invalid iiif request")
+
+type Region struct {
+	Full    bool
+	Square  bool
+	Percent bool
+	X       float64
+	Y       float64
+	W       float64
+	H       float64
+}
+
+type Size struct {
+	Upscale  bool
+	Max      bool
+	Confined bool
+	Percent  float64
+	Width    int
+	Height   int
+}
+
+// Limits bounds the size of the returned images, as advertised by the maxWidth,
+// maxHeight and maxArea properties of info.json. A zero limit doesn't bound.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxArea   int
+}
+
+type Rotation struct {
+	Mirror  bool
+	Degrees float64
+}
+
+type Request struct {
+	Region   Region
+	Size     Size
+	Rotation Rotation
+	Quality  string
+	Format   string
+}
+
+func invalid(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRequest, fmt.Sprintf(format, args...))
+}
+
+// ParseRequest parses the path segments of an image request:
+// {region}/{size}/{rotation}/{quality}.{format}
+func ParseRequest(region, size, rotation, qualityFormat string) (*Request, error) {
+	parsedRegion, err := ParseRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedSize, err := ParseSize(size)
+	if err != nil {
+		return nil, err
+	}
+
+	parsedRotation, err := ParseRotation(rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	dot := strings.LastIndex(qualityFormat, ".")
+	if dot <= 0 || dot == len(qualityFormat)-1 {
+		return nil, invalid("quality and format must be given as {quality}.{format}")
+	}
+
+	quality, format := qualityFormat[:dot], qualityFormat[dot+1:]
+	switch quality {
+	case "default", "color", "gray", "bitonal":
+	default:
+		return nil, invalid("unsupported quality %q", quality)
+	}
+
+	if _, ok := FORMAT_CONTENT_TYPES[format]; !ok {
+		return nil, invalid("unsupported format %q", format)
+	}
+
+	return &Request{
+		Region:   *parsedRegion,
+		Size:     *parsedSize,
+		Rotation: *parsedRotation,
+		Quality:  quality,
+		Format:   format,
+	}, nil
+}
+
+func ParseRegion(value string) (*Region, error) {
+	switch value {
+	case "full":
+		return &Region{Full: true}, nil
+	case "square":
+		return &Region{Square: true}, nil
+	}
+
+	region := &Region{}
+	if strings.HasPrefix(value, "pct:") {
+		region.Percent = true
+		value = strings.TrimPrefix(value, "pct:")
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, invalid("region must be full, square, x,y,w,h or pct:x,y,w,h")
+	}
+
+	numbers := make([]float64, 4)
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(part, 64)
+		if err != nil || number < 0 {
+			return nil, invalid("invalid region value %q", part)
+		}
+		if !region.Percent && number != math.Trunc(number) {
+			return nil, invalid("pixel region values must be integers")
+		}
+		numbers[i] = number
+	}
+
+	region.X, region.Y, region.W, region.H = numbers[0], numbers[1], numbers[2], numbers[3]
+	if region.W == 0 || region.H == 0 {
+		return nil, invalid("region width and height must be greater than 0")
+	}
+
+	return region, nil
+}
+
+func ParseSize(value string) (*Size, error) {
+	size := &Size{}
+	if strings.HasPrefix(value, "^") {
+		size.Upscale = true
+		value = strings.TrimPrefix(value, "^")
+	}
+
+	if value == "max" {
+		size.Max = true
+		return size, nil
+	}
+
+	if strings.HasPrefix(value, "pct:") {
+		percent, err := strconv.ParseFloat(strings.TrimPrefix(value, "pct:"), 64)
+		if err != nil || percent <= 0 {
+			return nil, invalid("invalid size percentage %q", value)
+		}
+		if percent > 100 && !size.Upscale {
+			return nil, invalid("size percentage above 100 requires ^")
+		}
+		size.Percent = percent
+		return size, nil
+	}
+
+	if strings.HasPrefix(value, "!") {
+		size.Confined = true
+		value = strings.TrimPrefix(value, "!")
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return nil, invalid("size must be max, w,, ,h, pct:n, w,h or !w,h")
+	}
+
+	var err error
+	if parts[0] != "" {
+		if size.Width, err = strconv.Atoi(parts[0]); err != nil || size.Width <= 0 {
+			return nil, invalid("invalid size width %q", parts[0])
+		}
+	}
+	if parts[1] != "" {
+		if size.Height, err = strconv.Atoi(parts[1]); err != nil || size.Height <= 0 {
+			return nil, invalid("invalid size height %q", parts[1])
+		}
+	}
+
+	if size.Width == 0 && size.Height == 0 {
+		return nil, invalid("size must specify a width or a height")
+	}
+	if size.Confined && (size.Width == 0 || size.Height == 0) {
+		return nil, invalid("!w,h size requires both a width and a height")
+	}
+
+	return size, nil
+}
+
+func ParseRotation(value string) (*Rotation, error) {
+	rotation := &Rotation{}
+	if strings.HasPrefix(value, "!") {
+		rotation.Mirror = true
+		value = strings.TrimPrefix(value, "!")
+	}
+
+	degrees, err := strconv.ParseFloat(value, 64)
+	if err != nil || degrees < 0 || degrees > 360 {
+		return nil, invalid("rotation must be a number between 0 and 360")
+	}
+	rotation.Degrees = degrees
+
+	return rotation, nil
+}
+
+// Rect resolves the region against the full image bounds. Regions extending
+// beyond the image are cropped to it.
+func (r Region) Rect(bounds image.Rectangle) (image.Rectangle, error) {
+	width, height := bounds.Dx(), bounds.Dy()
+
+	var rect image.Rectangle
+	switch {
+	case r.Full:
+		return bounds, nil
+	case r.Square:
+		side := width
+		if height < side {
+			side = height
+		}
+		x := (width - side) / 2
+		y := (height - side) / 2
+		rect = image.Rect(x, y, x+side, y+side)
+	case r.Percent:
+		rect = image.Rect(
+			int(r.X*float64(width)/100),
+			int(r.Y*float64(height)/100),
+			int(math.Round((r.X+r.W)*float64(width)/100)),
+			int(math.Round((r.Y+r.H)*float64(height)/100)),
+		)
+	default:
+		rect = image.Rect(int(r.X), int(r.Y), int(r.X+r.W), int(r.Y+r.H))
+	}
+
+	rect = rect.Add(bounds.Min).Intersect(bounds)
+	if rect.Empty() {
+		return image.Rectangle{}, invalid("region is outside the image bounds")
+	}
+
+	return rect, nil
+}
+
+// Dimensions resolves the requested size against the extracted region. The
+// max size is bounded by the limits, the other sizes are refused beyond them.
+func (s Size) Dimensions(regionWidth, regionHeight int, limits Limits) (int, int, error) {
+	// sized in floats, so that the sizes too large for the limits don't
+	// overflow before they're refused
+	width, height := float64(regionWidth), float64(regionHeight)
+	aspect := width / height
+
+	switch {
+	case s.Max:
+		scale := limits.scale(width, height)
+		if !s.Upscale {
+			scale = math.Min(scale, 1)
+		}
+		width = math.Floor(width * scale)
+		height = math.Floor(height * scale)
+	case s.Percent > 0:
+		width = math.Round(width * s.Percent / 100)
+		height = math.Round(height * s.Percent / 100)
+	case s.Confined:
+		scale := math.Min(float64(s.Width)/width, float64(s.Height)/height)
+		width = math.Round(width * scale)
+		height = math.Round(height * scale)
+	case s.Width > 0 && s.Height > 0:
+		width, height = float64(s.Width), float64(s.Height)
+	case s.Width > 0:
+		width = float64(s.Width)
+		height = math.Round(float64(s.Width) / aspect)
+	default:
+		height = float64(s.Height)
+		width = math.Round(float64(s.Height) * aspect)
+	}
+
+	width = math.Max(width, 1)
+	height = math.Max(height, 1)
+
+	if !s.Upscale && (width > float64(regionWidth) || height > float64(regionHeight)) {
+		return 0, 0, invalid("requested size exceeds the region size, use ^ to upscale")
+	}
+	if err := limits.check(width, height); err != nil {
+		return 0, 0, err
+	}
+
+	return int(width), int(height), nil
+}
+
+// scale returns the largest scale of an image of the size within the limits.
+func (l Limits) scale(width, height float64) float64 {
+	scale := math.Inf(1)
+	if l.MaxWidth > 0 {
+		scale = math.Min(scale, float64(l.MaxWidth)/width)
+	}
+	if l.MaxHeight > 0 {
+		scale = math.Min(scale, float64(l.MaxHeight)/height)
+	}
+	if l.MaxArea > 0 {
+		scale = math.Min(scale, math.Sqrt(float64(l.MaxArea)/(width*height)))
+	}
+	if math.IsInf(scale, 1) {
+		return 1
+	}
+	return scale
+}
+
+func (l Limits) check(width, height float64) error {
+	if l.MaxWidth > 0 && width > float64(l.MaxWidth) {
+		return invalid("requested width exceeds the maximum width of %d", l.MaxWidth)
+	}
+	if l.MaxHeight > 0 && height > float64(l.MaxHeight) {
+		return invalid("requested height exceeds the maximum height of %d", l.MaxHeight)
+	}
+	if l.MaxArea > 0 && width*height > float64(l.MaxArea) {
+		return invalid("requested size exceeds the maximum area of %d pixels", l.MaxArea)
+	}
+	return nil
+}
+
+// Apply runs the region, size, rotation and quality steps, in that order, on
+// the given image, refusing the sizes beyond the limits.
+func (r *Request) Apply(src image.Image, limits Limits) (image.Image, error) {
+	rect, err := r.Region.Rect(src.Bounds())
+	if err != nil {
+		return nil, err
+	}
+
+	width, height, err := r.Size.Dimensions(rect.Dx(), rect.Dy(), limits)
+	if err != nil {
+		return nil, err
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(scaled, scaled.Bounds(), src, rect, draw.Src, nil)
+
+	var result image.Image = scaled
+	if r.Rotation.Mirror {
+		result = mirror(scaled)
+	}
+	result = rotate(result, r.Rotation.Degrees)
+
+	switch r.Quality {
+	case "gray":
+		result = toGray(result)
+	case "bitonal":
+		result = toBitonal(result)
+	}
+
+	return result, nil
+}
+
+func mirror(src *image.RGBA) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dst.Set(bounds.Max.X-1-(x-bounds.Min.X), y, src.At(x, y))
+		}
+	}
+	return dst
+}
+
+// rotate turns the image clockwise. Multiples of 90 degrees are done pixel
+// by pixel, other angles are interpolated onto a canvas large enough to hold
+// the rotated image.
+func rotate(src image.Image, degrees float64) image.Image {
+	degrees = math.Mod(degrees, 360)
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	switch degrees {
+	case 0:
+		return src
+	case 90, 180, 270:
+		dstWidth, dstHeight := width, height
+		if degrees != 180 {
+			dstWidth, dstHeight = height, width
+		}
+		dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				pixel := src.At(bounds.Min.X+x, bounds.Min.Y+y)
+				switch degrees {
+				case 90:
+					dst.Set(height-1-y, x, pixel)
+				case 180:
+					dst.Set(width-1-x, height-1-y, pixel)
+				case 270:
+					dst.Set(y, width-1-x, pixel)
+				}
+			}
+		}
+		return dst
+	}
+
+	radians := degrees * math.Pi / 180
+	sin, cos := math.Sin(radians), math.Cos(radians)
+	dstWidth := int(math.Ceil(math.Abs(float64(width)*cos) + math.Abs(float64(height)*sin)))
+	dstHeight := int(math.Ceil(math.Abs(float64(width)*sin) + math.Abs(float64(height)*cos)))
+
+	srcCenterX := float64(bounds.Min.X) + float64(width)/2
+	srcCenterY := float64(bounds.Min.Y) + float64(height)/2
+	dstCenterX := float64(dstWidth) / 2
+	dstCenterY := float64(dstHeight) / 2
+
+	transform := f64.Aff3{
+		cos, -sin, dstCenterX - (cos*srcCenterX - sin*srcCenterY),
+		sin, cos, dstCenterY - (sin*srcCenterX + cos*srcCenterY),
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	draw.BiLinear.Transform(dst, transform, src, bounds, draw.Src, nil)
+	return dst
+}
+
+func toGray(src image.Image) *image.Gray {
+	bounds := src.Bounds()
+	dst := image.NewGray(bounds)
+	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
+	return dst
+}
+
+func toBitonal(src image.Image) *image.Gray {
+	dst := toGray(src)
+	for i, value := range dst.Pix {
+		if value < 128 {
+			dst.Pix[i] = 0
+		} else {
+			dst.Pix[i] = 255
+		}
+	}
+	return dst
+}
+
+// Encode writes the image in the given IIIF format.
+func Encode(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 90})
+	case "png":
+		return png.Encode(w, img)
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "tif":
+		return tiff.Encode(w, img, nil)
+	}
+	return invalid("unsupported format %q", format)
+}
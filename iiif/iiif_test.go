@@ -0,0 +1,137 @@
+package iiif
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRequest(t *testing.T) {
+	request, err := ParseRequest("pct:10,10,50,50", "^!200,100", "!90", "gray.png")
+	assert.Nil(t, err)
+	assert.True(t, request.Region.Percent)
+	assert.Equal(t, 50.0, request.Region.W)
+	assert.True(t, request.Size.Upscale)
+	assert.True(t, request.Size.Confined)
+	assert.Equal(t, 200, request.Size.Width)
+	assert.True(t, request.Rotation.Mirror)
+	assert.Equal(t, 90.0, request.Rotation.Degrees)
+	assert.Equal(t, "gray", request.Quality)
+	assert.Equal(t, "png", request.Format)
+}
+
+func TestInvalidRequests(t *testing.T) {
+	invalidRequests := [][4]string{
+		{"0,0,10", "max", "0", "default.jpg"},
+		{"0,0,0,10", "max", "0", "default.jpg"},
+		{"full", "0,", "0", "default.jpg"},
+		{"full", ",", "0", "default.jpg"},
+		{"full", "!100,", "0", "default.jpg"},
+		{"full", "pct:120", "0", "default.jpg"},
+		{"full", "max", "361", "default.jpg"},
+		{"full", "max", "0", "sepia.jpg"},
+		{"full", "max", "0", "default.jp2"},
+		{"full", "max", "0", "default"},
+	}
+
+	for _, each := range invalidRequests {
+		_, err := ParseRequest(each[0], each[1], each[2], each[3])
+		assert.ErrorIs(t, err, ErrInvalidRequest, each)
+	}
+}
+
+func TestRegionRect(t *testing.T) {
+	bounds := image.Rect(0, 0, 400, 200)
+
+	square, err := Region{Square: true}.Rect(bounds)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(100, 0, 300, 200), square)
+
+	cropped, err := Region{X: 300, Y: 100, W: 500, H: 500}.Rect(bounds)
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(300, 100, 400, 200), cropped)
+
+	_, err = Region{X: 500, Y: 0, W: 10, H: 10}.Rect(bounds)
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestSizeDimensions(t *testing.T) {
+	cases := []struct {
+		size          Size
+		width, height int
+	}{
+		{Size{Max: true}, 400, 200},
+		{Size{Width: 200}, 200, 100},
+		{Size{Height: 50}, 100, 50},
+		{Size{Percent: 25}, 100, 50},
+		{Size{Width: 100, Height: 100}, 100, 100},
+		{Size{Confined: true, Width: 100, Height: 100}, 100, 50},
+		{Size{Upscale: true, Width: 800}, 800, 400},
+	}
+
+	for _, each := range cases {
+		width, height, err := each.size.Dimensions(400, 200, Limits{})
+		assert.Nil(t, err)
+		assert.Equal(t, each.width, width)
+		assert.Equal(t, each.height, height)
+	}
+
+	_, _, err := Size{Width: 800}.Dimensions(400, 200, Limits{})
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+}
+
+func TestSizeLimits(t *testing.T) {
+	limits := Limits{MaxWidth: 1000, MaxHeight: 1000, MaxArea: 200_000}
+
+	cases := []struct {
+		size          Size
+		width, height int
+	}{
+		{Size{Max: true}, 400, 200},
+		{Size{Upscale: true, Max: true}, 632, 316},
+		{Size{Upscale: true, Width: 600}, 600, 300},
+	}
+	for _, each := range cases {
+		width, height, err := each.size.Dimensions(400, 200, limits)
+		assert.Nil(t, err)
+		assert.Equal(t, each.width, width)
+		assert.Equal(t, each.height, height)
+	}
+
+	width, height, err := Size{Max: true}.Dimensions(4000, 500, limits)
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, width)
+	assert.Equal(t, 125, height)
+
+	for _, each := range []Size{
+		{Upscale: true, Width: 1200},
+		{Upscale: true, Height: 1200},
+		{Upscale: true, Width: 800},
+		{Upscale: true, Width: 100000, Height: 100000},
+		{Upscale: true, Percent: 1e300},
+	} {
+		_, _, err := each.Dimensions(400, 200, limits)
+		assert.ErrorIs(t, err, ErrInvalidRequest, each)
+	}
+}
+
+func TestApply(t *testing.T) {
+	source := image.NewRGBA(image.Rect(0, 0, 40, 20))
+
+	request, err := ParseRequest("full", "20,", "90", "bitonal.png")
+	assert.Nil(t, err)
+
+	result, err := request.Apply(source, Limits{})
+	assert.Nil(t, err)
+	assert.Equal(t, image.Rect(0, 0, 10, 20), result.Bounds())
+	assert.IsType(t, &image.Gray{}, result)
+
+	request, err = ParseRequest("full", "max", "45", "default.png")
+	assert.Nil(t, err)
+
+	result, err = request.Apply(source, Limits{})
+	assert.Nil(t, err)
+	assert.Equal(t, 43, result.Bounds().Dx())
+	assert.Equal(t, 43, result.Bounds().Dy())
+}
@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"slices"
+)
+
+// GaussianKernel returns the 2*radius+1 weights of a Gaussian of standard
+// deviation radius/3, summing to 1.
+func GaussianKernel(radius int) []float64 {
+	sigma := float64(radius) / 3
+	kernel := make([]float64, 2*radius+1)
+	sum := 0.0
+	for i := range kernel {
+		x := float64(i - radius)
+		kernel[i] = math.Exp(-x * x / (2 * sigma * sigma))
+		sum += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= sum
+	}
+	return kernel
+}
+
+// GaussianBlur blurs the image with a Gaussian of the given radius in
+// pixels. The 2D kernel is separable, so the image is blurred horizontally
+// then vertically, in O(radius) per pixel instead of O(radius²). The
+// premultiplied channels are blurred to keep transparent pixels from
+// darkening their neighbours, and the edges are extended.
+func GaussianBlur(src image.Image, radius int) *image.RGBA64 {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]float64, 4*width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, a := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			copy(pixels[4*(y*width+x):], []float64{float64(r), float64(g), float64(b), float64(a)})
+		}
+	}
+
+	kernel := GaussianKernel(radius)
+	pixels = blurPass(pixels, width, height, kernel, 4, 4*width)
+	pixels = blurPass(pixels, height, width, kernel, 4*width, 4)
+
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+	for i, value := range pixels {
+		channel := uint16(math.Round(max(0, min(value, 0xffff))))
+		dst.Pix[2*i] = uint8(channel >> 8)
+		dst.Pix[2*i+1] = uint8(channel)
+	}
+	return dst
+}
+
+// blurPass convolves the lines of pixels with the kernel. Each of the lines
+// has length pixels, step floats apart, and the lines are stride floats
+// apart.
+func blurPass(pixels []float64, length, lines int, kernel []float64, step, stride int) []float64 {
+	radius := len(kernel) / 2
+	blurred := make([]float64, len(pixels))
+	for line := 0; line < lines; line++ {
+		for i := 0; i < length; i++ {
+			var sums [4]float64
+			for k, weight := range kernel {
+				j := max(0, min(i+k-radius, length-1))
+				offset := line*stride + j*step
+				for channel := range sums {
+					sums[channel] += weight * pixels[offset+channel]
+				}
+			}
+			copy(blurred[line*stride+i*step:], sums[:])
+		}
+	}
+	return blurred
+}
+
+// UnsharpMask sharpens the image by adding amount times its difference with
+// its Gaussian blur of the radius, leaving the channels that differ from the
+// blur by less than threshold levels out of 255 as they are. The alpha
+// channel is kept.
+func UnsharpMask(src image.Image, radius int, amount float64, threshold uint8) *image.RGBA64 {
+	blurred := GaussianBlur(src, radius)
+	bounds := src.Bounds()
+	minDifference := float64(threshold) * 0x101
+
+	dst := image.NewRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, a := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			blur := blurred.RGBA64At(x, y)
+
+			original := [3]float64{float64(r), float64(g), float64(b)}
+			for i, blurValue := range [3]float64{float64(blur.R), float64(blur.G), float64(blur.B)} {
+				difference := original[i] - blurValue
+				if math.Abs(difference) >= minDifference {
+					// premultiplied channels can't exceed the alpha
+					original[i] = max(0, min(original[i]+amount*difference, float64(a)))
+				}
+			}
+
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(math.Round(original[0])),
+				G: uint16(math.Round(original[1])),
+				B: uint16(math.Round(original[2])),
+				A: uint16(a),
+			})
+		}
+	}
+	return dst
+}
+
+// Median replaces each channel of the pixels with its median over the
+// (2*radius+1)² pixels around them, removing noise while keeping the edges.
+// The edges of the image are extended and the alpha channel is kept.
+func Median(src image.Image, radius int) *image.NRGBA64 {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]color.NRGBA64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixels[y*width+x] = color.NRGBA64Model.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA64)
+		}
+	}
+
+	size := (2*radius + 1) * (2*radius + 1)
+	neighbourhood := [3][]uint16{make([]uint16, size), make([]uint16, size), make([]uint16, size)}
+	dst := image.NewNRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			n := 0
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					pixel := pixels[max(0, min(y+dy, height-1))*width+max(0, min(x+dx, width-1))]
+					neighbourhood[0][n], neighbourhood[1][n], neighbourhood[2][n] = pixel.R, pixel.G, pixel.B
+					n++
+				}
+			}
+			for _, each := range neighbourhood {
+				slices.Sort(each)
+			}
+
+			dst.SetNRGBA64(x, y, color.NRGBA64{
+				R: neighbourhood[0][size/2],
+				G: neighbourhood[1][size/2],
+				B: neighbourhood[2][size/2],
+				A: pixels[y*width+x].A,
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,159 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"slices"
+)
+
+// Dither reduces the image to a palette of at most colors colors chosen by
+// median cut, diffusing the quantization error of every pixel to its
+// neighbours with Floyd-Steinberg dithering. Mostly transparent pixels take
+// a transparent palette entry, the others become opaque.
+func Dither(src image.Image, colors int) *image.Paletted {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	pixels := make([]color.NRGBA, width*height)
+	var opaque []color.NRGBA
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixel := color.NRGBAModel.Convert(src.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			pixels[y*width+x] = pixel
+			if pixel.A >= 0x80 {
+				opaque = append(opaque, pixel)
+			}
+		}
+	}
+
+	transparent := len(opaque) < len(pixels)
+	if transparent {
+		colors--
+	}
+	opaquePalette := MedianCut(opaque, colors)
+	palette := opaquePalette
+	transparentIndex := uint8(len(palette))
+	if transparent {
+		palette = append(slices.Clip(opaquePalette), color.NRGBA{})
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	// the errors diffused to the current and the next rows
+	current := make([][3]float64, width+2)
+	next := make([][3]float64, width+2)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pixel := pixels[y*width+x]
+			if pixel.A < 0x80 {
+				dst.SetColorIndex(x, y, transparentIndex)
+				continue
+			}
+
+			wanted := [3]float64{
+				clamp255(float64(pixel.R) + current[x+1][0]),
+				clamp255(float64(pixel.G) + current[x+1][1]),
+				clamp255(float64(pixel.B) + current[x+1][2]),
+			}
+			index := nearestColor(opaquePalette, wanted)
+			dst.SetColorIndex(x, y, uint8(index))
+
+			chosen := palette[index].(color.NRGBA)
+			for i, value := range [3]float64{float64(chosen.R), float64(chosen.G), float64(chosen.B)} {
+				quantizationError := wanted[i] - value
+				current[x+2][i] += quantizationError * 7 / 16
+				next[x][i] += quantizationError * 3 / 16
+				next[x+1][i] += quantizationError * 5 / 16
+				next[x+2][i] += quantizationError * 1 / 16
+			}
+		}
+		current, next = next, current
+		clear(next)
+	}
+	return dst
+}
+
+// MedianCut picks a palette of at most colors colors for the pixels by
+// splitting the box of colors with the widest channel range at its median
+// until there are as many boxes as colors, each box giving its mean color.
+func MedianCut(pixels []color.NRGBA, colors int) color.Palette {
+	if len(pixels) == 0 {
+		return color.Palette{color.NRGBA{A: 0xff}}
+	}
+
+	boxes := [][]color.NRGBA{slices.Clone(pixels)}
+	for len(boxes) < colors {
+		widest, widestChannel, widestRange := -1, 0, 0
+		for i, box := range boxes {
+			channel, channelRange := widestRangeChannel(box)
+			if channelRange > widestRange {
+				widest, widestChannel, widestRange = i, channel, channelRange
+			}
+		}
+		// every box has a single color
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		slices.SortFunc(box, func(a, b color.NRGBA) int {
+			return int(channelValue(a, widestChannel)) - int(channelValue(b, widestChannel))
+		})
+		median := len(box) / 2
+		boxes[widest] = box[:median]
+		boxes = append(boxes, box[median:])
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var sums [3]int
+		for _, pixel := range box {
+			sums[0] += int(pixel.R)
+			sums[1] += int(pixel.G)
+			sums[2] += int(pixel.B)
+		}
+		n := len(box)
+		palette = append(palette, color.NRGBA{uint8((sums[0] + n/2) / n), uint8((sums[1] + n/2) / n), uint8((sums[2] + n/2) / n), 0xff})
+	}
+	return palette
+}
+
+func widestRangeChannel(box []color.NRGBA) (int, int) {
+	lows := [3]uint8{0xff, 0xff, 0xff}
+	var highs [3]uint8
+	for _, pixel := range box {
+		for channel := range lows {
+			value := channelValue(pixel, channel)
+			lows[channel] = min(lows[channel], value)
+			highs[channel] = max(highs[channel], value)
+		}
+	}
+
+	widest := 0
+	for channel := range lows {
+		if highs[channel]-lows[channel] > highs[widest]-lows[widest] {
+			widest = channel
+		}
+	}
+	return widest, int(highs[widest] - lows[widest])
+}
+
+func channelValue(pixel color.NRGBA, channel int) uint8 {
+	return [3]uint8{pixel.R, pixel.G, pixel.B}[channel]
+}
+
+func nearestColor(palette color.Palette, wanted [3]float64) int {
+	nearest, nearestDistance := 0, -1.0
+	for i, each := range palette {
+		candidate := each.(color.NRGBA)
+		dr, dg, db := wanted[0]-float64(candidate.R), wanted[1]-float64(candidate.G), wanted[2]-float64(candidate.B)
+		distance := dr*dr + dg*dg + db*db
+		if nearestDistance < 0 || distance < nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
+	}
+	return nearest
+}
+
+func clamp255(value float64) float64 {
+	return max(0, min(value, 255))
+}
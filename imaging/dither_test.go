@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedianCut(t *testing.T) {
+	pixels := []color.NRGBA{
+		{0, 0, 0, 255}, {10, 0, 0, 255},
+		{200, 0, 0, 255}, {210, 0, 0, 255},
+	}
+
+	// the red range is split at the median, each half giving its mean
+	assert.Equal(t, color.Palette{color.NRGBA{5, 0, 0, 255}, color.NRGBA{205, 0, 0, 255}}, MedianCut(pixels, 2))
+	assert.Len(t, MedianCut(pixels, 4), 4)
+	// there are no more colors than distinct ones
+	assert.Len(t, MedianCut([]color.NRGBA{{1, 2, 3, 255}, {1, 2, 3, 255}}, 16), 1)
+}
+
+func TestDither(t *testing.T) {
+	// a horizontal gradient reduced to a dark and a light gray
+	gradient := newGradient(64, 4)
+	dithered := Dither(gradient, 2)
+	assert.Len(t, dithered.Palette, 2)
+
+	// the share of white pixels follows the gradient
+	whites := func(x0, x1 int) int {
+		count := 0
+		for y := 0; y < 4; y++ {
+			for x := x0; x < x1; x++ {
+				r, _, _, _ := dithered.At(x, y).RGBA()
+				if r > 0x8000 {
+					count++
+				}
+			}
+		}
+		return count
+	}
+	assert.Less(t, whites(0, 16), whites(16, 32))
+	assert.Less(t, whites(16, 32), whites(32, 48))
+	assert.Less(t, whites(32, 48), whites(48, 64))
+	assert.InDelta(t, 128, whites(0, 64), 16)
+
+	// transparent pixels take a palette entry of their own
+	gradient.Set(0, 0, color.RGBA{})
+	dithered = Dither(gradient, 4)
+	assert.Len(t, dithered.Palette, 4)
+	assert.Equal(t, uint8(3), dithered.ColorIndexAt(0, 0))
+	_, _, _, a := dithered.At(1, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), a)
+	assert.Equal(t, image.Rect(0, 0, 64, 4), dithered.Bounds())
+}
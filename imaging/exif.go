@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+)
+
+var ErrNoGPS = errors.New("the image has no GPS coordinates")
+
+var exifJPEGMarker = []byte("Exif\x00\x00")
+
+// The EXIF tags pointing to the GPS IFD and the GPS tags in it.
+const (
+	exifGPSInfoTag = 0x8825
+
+	gpsLatitudeRef  = 1
+	gpsLatitude     = 2
+	gpsLongitudeRef = 3
+	gpsLongitude    = 4
+	gpsAltitudeRef  = 5
+	gpsAltitude     = 6
+)
+
+type GPS struct {
+	Latitude  float64
+	Longitude float64
+	// in meters above sea level, nil when the image doesn't have it
+	Altitude *float64
+}
+
+// ExtractGPS reads the GPS coordinates from the EXIF metadata of a JPEG, or
+// from a TIFF file which stores them the same way.
+func ExtractGPS(data []byte, contentType string) (*GPS, error) {
+	var exif []byte
+	switch contentType {
+	case "image/jpeg":
+		err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+			if marker == 0xe1 && exif == nil && bytes.HasPrefix(segment, exifJPEGMarker) {
+				exif = segment[len(exifJPEGMarker):]
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	case "image/tiff":
+		exif = data
+	}
+	if exif == nil {
+		return nil, ErrNoGPS
+	}
+
+	reader, err := newTIFFReader(exif)
+	if err != nil {
+		return nil, err
+	}
+
+	ifd0, err := reader.ifd(reader.firstIFD())
+	if err != nil {
+		return nil, err
+	}
+
+	gpsPointer, ok := ifd0[exifGPSInfoTag]
+	if !ok {
+		return nil, ErrNoGPS
+	}
+	gpsOffset, ok := reader.uint(gpsPointer)
+	if !ok {
+		return nil, errors.New("invalid GPS IFD pointer")
+	}
+
+	gpsIFD, err := reader.ifd(gpsOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	latitude, ok := readGPSCoordinate(reader, gpsIFD, gpsLatitude, gpsLatitudeRef, 'S')
+	if !ok {
+		return nil, ErrNoGPS
+	}
+	longitude, ok := readGPSCoordinate(reader, gpsIFD, gpsLongitude, gpsLongitudeRef, 'W')
+	if !ok {
+		return nil, ErrNoGPS
+	}
+
+	gps := &GPS{Latitude: latitude, Longitude: longitude}
+	if entry, ok := gpsIFD[gpsAltitude]; ok {
+		if values, ok := reader.rationals(entry); ok && len(values) == 1 {
+			altitude := values[0]
+			// a reference of 1 means below sea level
+			if ref, ok := gpsIFD[gpsAltitudeRef]; ok && len(ref.value) > 0 && ref.value[0] == 1 {
+				altitude = -altitude
+			}
+			gps.Altitude = &altitude
+		}
+	}
+
+	return gps, nil
+}
+
+// readGPSCoordinate converts the degrees, minutes and seconds of a GPS tag to
+// decimal degrees, negative for the given hemisphere reference.
+func readGPSCoordinate(reader *tiffReader, ifd map[uint16]*tiffEntry, tag, refTag uint16, negativeRef byte) (float64, bool) {
+	entry, ok := ifd[tag]
+	if !ok {
+		return 0, false
+	}
+
+	values, ok := reader.rationals(entry)
+	if !ok || len(values) != 3 {
+		return 0, false
+	}
+
+	coordinate := values[0] + values[1]/60 + values[2]/3600
+	if ref, ok := ifd[refTag]; ok && len(ref.value) > 0 && ref.value[0] == negativeRef {
+		coordinate = -coordinate
+	}
+	return coordinate, true
+}
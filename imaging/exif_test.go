@@ -0,0 +1,87 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testIFDEntry struct {
+	tag, fieldType uint16
+	count          uint32
+	value          []byte
+}
+
+// newTestEXIF writes a big endian TIFF structure with IFD0 only pointing to
+// a GPS IFD holding the given entries.
+func newTestEXIF(gpsEntries []testIFDEntry) []byte {
+	order := binary.BigEndian
+	data := []byte("MM\x00*")
+	data = order.AppendUint32(data, 8)
+
+	// IFD0 with the GPS pointer
+	gpsOffset := uint32(8 + 2 + 12 + 4)
+	data = order.AppendUint16(data, 1)
+	data = order.AppendUint16(data, exifGPSInfoTag)
+	data = order.AppendUint16(data, 4)
+	data = order.AppendUint32(data, 1)
+	data = order.AppendUint32(data, gpsOffset)
+	data = order.AppendUint32(data, 0)
+
+	valuesOffset := gpsOffset + 2 + 12*uint32(len(gpsEntries)) + 4
+	var values []byte
+	data = order.AppendUint16(data, uint16(len(gpsEntries)))
+	for _, eachEntry := range gpsEntries {
+		data = order.AppendUint16(data, eachEntry.tag)
+		data = order.AppendUint16(data, eachEntry.fieldType)
+		data = order.AppendUint32(data, eachEntry.count)
+		if len(eachEntry.value) <= 4 {
+			data = append(data, append(eachEntry.value, make([]byte, 4-len(eachEntry.value))...)...)
+		} else {
+			data = order.AppendUint32(data, valuesOffset+uint32(len(values)))
+			values = append(values, eachEntry.value...)
+		}
+	}
+	data = order.AppendUint32(data, 0)
+	return append(data, values...)
+}
+
+func rationals(values ...uint32) []byte {
+	var result []byte
+	for i := 0; i < len(values); i += 2 {
+		result = binary.BigEndian.AppendUint32(result, values[i])
+		result = binary.BigEndian.AppendUint32(result, values[i+1])
+	}
+	return result
+}
+
+func TestExtractGPS(t *testing.T) {
+	exif := newTestEXIF([]testIFDEntry{
+		{gpsLatitudeRef, 2, 2, []byte("N\x00")},
+		{gpsLatitude, 5, 3, rationals(48, 1, 51, 1, 2400, 100)},
+		{gpsLongitudeRef, 2, 2, []byte("W\x00")},
+		{gpsLongitude, 5, 3, rationals(2, 1, 17, 1, 4000, 100)},
+		{gpsAltitudeRef, 1, 1, []byte{0}},
+		{gpsAltitude, 5, 1, rationals(355, 10)},
+	})
+
+	var encoded bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&encoded, newGradient(20, 20), nil))
+
+	_, err := ExtractGPS(encoded.Bytes(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrNoGPS)
+
+	data := withJPEGSegment(encoded.Bytes(), append(append([]byte{}, exifJPEGMarker...), exif...))
+	gps, err := ExtractGPS(data, "image/jpeg")
+	assert.Nil(t, err)
+	assert.InDelta(t, 48.8567, gps.Latitude, 0.0001)
+	assert.InDelta(t, -2.2944, gps.Longitude, 0.0001)
+	assert.InDelta(t, 35.5, *gps.Altitude, 0.0001)
+
+	gps, err = ExtractGPS(exif, "image/tiff")
+	assert.Nil(t, err)
+	assert.InDelta(t, 48.8567, gps.Latitude, 0.0001)
+}
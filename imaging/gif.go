@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Frames renders every frame of an animated GIF as a full image. GIF frames
+// only hold the part of the canvas that changed, so each one is drawn over
+// the previous frames honouring their disposal methods.
+func Frames(g *gif.GIF) []*image.RGBA {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		for _, eachFrame := range g.Image {
+			bounds = bounds.Union(eachFrame.Bounds())
+		}
+	}
+
+	canvas := image.NewRGBA(bounds)
+	frames := make([]*image.RGBA, 0, len(g.Image))
+
+	for i, eachFrame := range g.Image {
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = copyRGBA(canvas)
+		}
+
+		draw.Draw(canvas, eachFrame.Bounds(), eachFrame, eachFrame.Bounds().Min, draw.Over)
+		frames = append(frames, copyRGBA(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, eachFrame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return frames
+}
+
+func copyRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+// Animate builds a looping GIF out of the images, scaling them all to the
+// size of the first one. delay is the time each frame is shown in
+// hundredths of a second.
+func Animate(images []image.Image, delay int) *gif.GIF {
+	bounds := image.Rectangle{Max: images[0].Bounds().Size()}
+	animation := &gif.GIF{
+		Config: image.Config{Width: bounds.Dx(), Height: bounds.Dy()},
+	}
+
+	for _, eachImage := range images {
+		scaled := image.NewRGBA(bounds)
+		xdraw.CatmullRom.Scale(scaled, bounds, eachImage, eachImage.Bounds(), xdraw.Src, nil)
+
+		frame := image.NewPaletted(bounds, palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, bounds, scaled, image.Point{})
+
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, delay)
+		animation.Disposal = append(animation.Disposal, gif.DisposalNone)
+	}
+
+	return animation
+}
+
+// AnimatedThumbnail scales the animated GIF down to fit in a maxSize x
+// maxSize box, flattened on white, and drops frames to play it at most at
+// fps frames per second. The dropped frames lengthen the frames before them,
+// so the animation lasts as long.
+func AnimatedThumbnail(g *gif.GIF, maxSize, fps int) *gif.GIF {
+	minDelay := max(1, 100/fps)
+	thumbnail := &gif.GIF{LoopCount: g.LoopCount}
+
+	for i, eachFrame := range Frames(g) {
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		// browsers play the delays under 2 hundredths of a second at 10 fps
+		if delay < 2 {
+			delay = 10
+		}
+
+		last := len(thumbnail.Delay) - 1
+		if last >= 0 && thumbnail.Delay[last] < minDelay {
+			thumbnail.Delay[last] += delay
+			continue
+		}
+
+		scaled := Flatten(Thumbnail(eachFrame, maxSize), color.White)
+		frame := image.NewPaletted(scaled.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(frame, frame.Bounds(), scaled, image.Point{})
+
+		thumbnail.Image = append(thumbnail.Image, frame)
+		thumbnail.Delay = append(thumbnail.Delay, delay)
+		thumbnail.Disposal = append(thumbnail.Disposal, gif.DisposalNone)
+	}
+
+	if len(thumbnail.Image) > 0 {
+		size := thumbnail.Image[0].Bounds().Size()
+		thumbnail.Config = image.Config{Width: size.X, Height: size.Y}
+	}
+	return thumbnail
+}
@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var ErrNoICCProfile = errors.New("the image has no embedded ICC profile")
+
+var iccJPEGMarker = []byte("ICC_PROFILE\x00")
+
+// A JPEG segment holds at most 65535 bytes including its length, the ICC
+// marker and the chunk sequence number and count.
+const iccJPEGChunkSize = 65535 - 2 - 14
+
+const tiffICCProfileTag = 34675
+
+// ExtractICCProfile returns the ICC profile embedded in a JPEG or TIFF file.
+func ExtractICCProfile(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return extractJPEGICCProfile(data)
+	case "image/tiff":
+		return extractTIFFICCProfile(data)
+	}
+	return nil, ErrNoICCProfile
+}
+
+// extractJPEGICCProfile joins the ICC chunks stored in the APP2 segments,
+// which can come in any order.
+func extractJPEGICCProfile(data []byte) ([]byte, error) {
+	chunks := map[byte][]byte{}
+	var chunkCount byte
+
+	err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+		if marker == 0xe2 && len(segment) > len(iccJPEGMarker)+2 && bytes.HasPrefix(segment, iccJPEGMarker) {
+			header := segment[len(iccJPEGMarker):]
+			chunks[header[0]] = header[2:]
+			chunkCount = header[1]
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chunks) == 0 || len(chunks) != int(chunkCount) {
+		return nil, ErrNoICCProfile
+	}
+
+	var profile []byte
+	for sequence := byte(1); sequence <= chunkCount; sequence++ {
+		chunk, ok := chunks[sequence]
+		if !ok {
+			return nil, errors.New("missing ICC profile chunk")
+		}
+		profile = append(profile, chunk...)
+	}
+	return profile, nil
+}
+
+func extractTIFFICCProfile(data []byte) ([]byte, error) {
+	profile, err := readTIFFTag(data, tiffICCProfileTag)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, ErrNoICCProfile
+	}
+	return profile, nil
+}
+
+// EmbedICCProfile stores the profile in an encoded JPEG or PNG. Other
+// formats are returned as they are.
+func EmbedICCProfile(data, profile []byte, contentType string) ([]byte, error) {
+	if len(profile) == 0 {
+		return data, nil
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		return embedJPEGICCProfile(data, profile)
+	case "image/png":
+		return embedPNGICCProfile(data, profile)
+	}
+	return data, nil
+}
+
+// embedJPEGICCProfile splits the profile in APP2 segments placed right after
+// the start of image marker and the JFIF segment when there is one.
+func embedJPEGICCProfile(data, profile []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errors.New("invalid JPEG")
+	}
+
+	insertAt := 2
+	if data[2] == 0xff && data[3] == 0xe0 && len(data) >= 6 {
+		insertAt += 2 + int(binary.BigEndian.Uint16(data[4:6]))
+	}
+
+	chunkCount := (len(profile) + iccJPEGChunkSize - 1) / iccJPEGChunkSize
+	if chunkCount > 255 {
+		return nil, errors.New("ICC profile too large")
+	}
+
+	result := append([]byte{}, data[:insertAt]...)
+	for i := 0; i < chunkCount; i++ {
+		chunk := profile[i*iccJPEGChunkSize : min((i+1)*iccJPEGChunkSize, len(profile))]
+
+		result = append(result, 0xff, 0xe2)
+		result = binary.BigEndian.AppendUint16(result, uint16(2+len(iccJPEGMarker)+2+len(chunk)))
+		result = append(result, iccJPEGMarker...)
+		result = append(result, byte(i+1), byte(chunkCount))
+		result = append(result, chunk...)
+	}
+	return append(result, data[insertAt:]...), nil
+}
+
+// embedPNGICCProfile adds a compressed iCCP chunk after the IHDR chunk.
+func embedPNGICCProfile(data, profile []byte) ([]byte, error) {
+	// 8 bytes of signature and the 25 bytes of the IHDR chunk
+	const insertAt = 8 + 25
+	if len(data) < insertAt || string(data[12:16]) != "IHDR" {
+		return nil, errors.New("invalid PNG")
+	}
+
+	// profile name, its terminator and the zlib compression method
+	chunkData := []byte("ICC profile\x00\x00")
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	writer.Write(profile)
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	chunkData = append(chunkData, compressed.Bytes()...)
+
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(chunkData)))
+	chunk = append(chunk, "iCCP"...)
+	chunk = append(chunk, chunkData...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	result := append([]byte{}, data[:insertAt]...)
+	result = append(result, chunk...)
+	return append(result, data[insertAt:]...), nil
+}
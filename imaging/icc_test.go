@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProfile(size int) []byte {
+	profile := make([]byte, size)
+	for i := range profile {
+		profile[i] = byte(i % 251)
+	}
+	return profile
+}
+
+func TestJPEGICCProfile(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&encoded, newGradient(20, 20), nil))
+
+	_, err := ExtractICCProfile(encoded.Bytes(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrNoICCProfile)
+
+	// spans several APP2 segments
+	profile := newTestProfile(150000)
+	embedded, err := EmbedICCProfile(encoded.Bytes(), profile, "image/jpeg")
+	assert.Nil(t, err)
+
+	extracted, err := ExtractICCProfile(embedded, "image/jpeg")
+	assert.Nil(t, err)
+	assert.Equal(t, profile, extracted)
+
+	_, err = jpeg.Decode(bytes.NewReader(embedded))
+	assert.Nil(t, err)
+}
+
+func TestPNGICCProfile(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Nil(t, png.Encode(&encoded, newGradient(20, 20)))
+
+	embedded, err := EmbedICCProfile(encoded.Bytes(), newTestProfile(1000), "image/png")
+	assert.Nil(t, err)
+	assert.Contains(t, string(embedded), "iCCP")
+
+	_, err = png.Decode(bytes.NewReader(embedded))
+	assert.Nil(t, err)
+}
+
+func TestTIFFICCProfile(t *testing.T) {
+	profile := newTestProfile(100)
+
+	// header, then an IFD with only the InterColorProfile entry
+	data := []byte("II*\x00")
+	data = binary.LittleEndian.AppendUint32(data, 8)
+	data = binary.LittleEndian.AppendUint16(data, 1)
+	data = binary.LittleEndian.AppendUint16(data, tiffICCProfileTag)
+	data = binary.LittleEndian.AppendUint16(data, 7)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(profile)))
+	data = binary.LittleEndian.AppendUint32(data, 26)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = append(data, profile...)
+
+	extracted, err := ExtractICCProfile(data, "image/tiff")
+	assert.Nil(t, err)
+	assert.Equal(t, profile, extracted)
+}
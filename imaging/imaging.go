@@ -0,0 +1,281 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Thumbnail scales the image down to fit in a maxSize x maxSize box, keeping
+// its aspect ratio. Images that already fit are only copied.
+func Thumbnail(src image.Image, maxSize int) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	return dst
+}
+
+// Flatten draws the image over a solid background, for encoders that don't
+// support transparency.
+func Flatten(src image.Image, background color.Color) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Over)
+	return dst
+}
+
+// Pad surrounds the image with a solid border of the given widths.
+func Pad(src image.Image, top, right, bottom, left int, border color.Color) *image.RGBA {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, left+bounds.Dx()+right, top+bounds.Dy()+bottom))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(border), image.Point{}, draw.Src)
+	draw.Draw(dst, bounds.Sub(bounds.Min).Add(image.Pt(left, top)), src, bounds.Min, draw.Src)
+	return dst
+}
+
+// Composite draws the overlay over a copy of the base with its top left
+// corner at the point, its alpha scaled by the opacity between 0 and 1. The
+// parts of the overlay outside the base are clipped.
+func Composite(base, overlay image.Image, at image.Point, opacity float64) *image.RGBA {
+	bounds := base.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), base, bounds.Min, draw.Src)
+
+	overlayBounds := overlay.Bounds()
+	mask := image.NewUniform(color.Alpha16{uint16(math.Round(opacity * 0xffff))})
+	target := overlayBounds.Sub(overlayBounds.Min).Add(at)
+	draw.DrawMask(dst, target, overlay, overlayBounds.Min, mask, image.Point{}, draw.Over)
+	return dst
+}
+
+// ParseHexColor parses an opaque #RRGGBB color, the # being optional.
+func ParseHexColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected #RRGGBB", value)
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: %w", value, err)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+// DifferenceHash computes the 64 bit dHash of the image: each bit tells
+// whether a pixel of the 9x8 grayscale version is brighter than its right
+// neighbour. Similar images have hashes with a small Hamming distance.
+func DifferenceHash(src image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), src, src.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// AlphaImage is an image the PNG encoder writes with an alpha channel even
+// when every pixel is opaque, which it otherwise drops.
+type AlphaImage struct {
+	*image.NRGBA
+}
+
+func (a *AlphaImage) Opaque() bool {
+	return false
+}
+
+// WithAlpha copies the image into an NRGBA image keeping an alpha channel.
+func WithAlpha(src image.Image) *AlphaImage {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+	return &AlphaImage{dst}
+}
+
+// Grayscale converts the image to 16 bit grays. Images with transparency
+// keep their alpha channel and are converted to gray NRGBA64 pixels instead.
+func Grayscale(src image.Image) image.Image {
+	bounds := src.Bounds()
+	if opaque, ok := src.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		dst := image.NewGray16(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				dst.Set(x-bounds.Min.X, y-bounds.Min.Y, color.Gray16Model.Convert(src.At(x, y)))
+			}
+		}
+		return dst
+	}
+
+	return mapNRGBA64(src, func(pixel color.NRGBA64) color.NRGBA64 {
+		gray := color.Gray16Model.Convert(color.NRGBA64{pixel.R, pixel.G, pixel.B, 0xffff}).(color.Gray16).Y
+		return color.NRGBA64{gray, gray, gray, pixel.A}
+	})
+}
+
+// Sepia tones the image with the usual sepia color matrix, keeping its
+// alpha channel.
+func Sepia(src image.Image) *image.NRGBA64 {
+	return mapNRGBA64(src, func(pixel color.NRGBA64) color.NRGBA64 {
+		r, g, b := float64(pixel.R), float64(pixel.G), float64(pixel.B)
+		return color.NRGBA64{
+			R: clamp16(0.393*r + 0.769*g + 0.189*b),
+			G: clamp16(0.349*r + 0.686*g + 0.168*b),
+			B: clamp16(0.272*r + 0.534*g + 0.131*b),
+			A: pixel.A,
+		}
+	})
+}
+
+// Adjust changes the brightness, contrast and saturation of the image, each
+// between -1 and 1 where 0 changes nothing. Brightness is added to the
+// channels, contrast scales them away from mid gray and saturation scales
+// them away from the luminance of the pixel, in that order.
+func Adjust(src image.Image, brightness, contrast, saturation float64) *image.NRGBA64 {
+	return mapNRGBA64(src, func(pixel color.NRGBA64) color.NRGBA64 {
+		channels := [3]float64{float64(pixel.R) / 0xffff, float64(pixel.G) / 0xffff, float64(pixel.B) / 0xffff}
+		for i, value := range channels {
+			value = clampUnit(value + brightness)
+			channels[i] = clampUnit((value-0.5)*(1+contrast) + 0.5)
+		}
+
+		luminance := 0.299*channels[0] + 0.587*channels[1] + 0.114*channels[2]
+		for i, value := range channels {
+			channels[i] = clampUnit(luminance + (value-luminance)*(1+saturation))
+		}
+
+		return color.NRGBA64{
+			R: uint16(math.Round(channels[0] * 0xffff)),
+			G: uint16(math.Round(channels[1] * 0xffff)),
+			B: uint16(math.Round(channels[2] * 0xffff)),
+			A: pixel.A,
+		}
+	})
+}
+
+// Equalize spreads the luminance of the image over the whole range by
+// histogram equalization of its Y channel, keeping the chroma and alpha of
+// the pixels. Uniform images are returned as they are.
+func Equalize(src image.Image) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+
+	var histogram [256]int
+	for i := 0; i < len(dst.Pix); i += 4 {
+		y, _, _ := color.RGBToYCbCr(dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2])
+		histogram[y]++
+	}
+
+	// the cumulative distribution, starting at the darkest luminance
+	var cdf [256]int
+	cdfMin, sum := 0, 0
+	for y, count := range histogram {
+		sum += count
+		cdf[y] = sum
+		if cdfMin == 0 {
+			cdfMin = sum
+		}
+	}
+	total := len(dst.Pix) / 4
+	if total == cdfMin {
+		return dst
+	}
+
+	var mapping [256]uint8
+	for y := range mapping {
+		mapping[y] = uint8(math.Round(float64(max(0, cdf[y]-cdfMin)) * 255 / float64(total-cdfMin)))
+	}
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		y, cb, cr := color.RGBToYCbCr(dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2])
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = color.YCbCrToRGB(mapping[y], cb, cr)
+	}
+	return dst
+}
+
+// AutoLevel stretches the channels of the image so their darkest value maps
+// to 0 and their brightest one to 65535, each channel on its own when
+// perChannel is set. Otherwise the channels share the stretch of the
+// luminance, which keeps the hues. Channels with a single value and fully
+// transparent pixels are left out.
+func AutoLevel(src image.Image, perChannel bool) *image.NRGBA64 {
+	bounds := src.Bounds()
+	lows := [3]uint16{0xffff, 0xffff, 0xffff}
+	var highs [3]uint16
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBA64Model.Convert(src.At(x, y)).(color.NRGBA64)
+			if pixel.A == 0 {
+				continue
+			}
+
+			channels := [3]uint16{pixel.R, pixel.G, pixel.B}
+			if !perChannel {
+				gray := color.Gray16Model.Convert(color.NRGBA64{pixel.R, pixel.G, pixel.B, 0xffff}).(color.Gray16).Y
+				channels = [3]uint16{gray, gray, gray}
+			}
+			for i, value := range channels {
+				lows[i] = min(lows[i], value)
+				highs[i] = max(highs[i], value)
+			}
+		}
+	}
+
+	return mapNRGBA64(src, func(pixel color.NRGBA64) color.NRGBA64 {
+		channels := [3]uint16{pixel.R, pixel.G, pixel.B}
+		for i, value := range channels {
+			if highs[i] > lows[i] {
+				stretched := (float64(value) - float64(lows[i])) * 0xffff / float64(highs[i]-lows[i])
+				channels[i] = uint16(math.Round(max(0, min(stretched, 0xffff))))
+			}
+		}
+		return color.NRGBA64{channels[0], channels[1], channels[2], pixel.A}
+	})
+}
+
+// mapNRGBA64 applies the function to the non premultiplied pixels of the
+// image.
+func mapNRGBA64(src image.Image, function func(color.NRGBA64) color.NRGBA64) *image.NRGBA64 {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA64(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pixel := color.NRGBA64Model.Convert(src.At(x, y)).(color.NRGBA64)
+			dst.SetNRGBA64(x-bounds.Min.X, y-bounds.Min.Y, function(pixel))
+		}
+	}
+	return dst
+}
+
+func clamp16(value float64) uint16 {
+	return uint16(min(value, 0xffff))
+}
+
+func clampUnit(value float64) float64 {
+	return max(0, min(value, 1))
+}
@@ -0,0 +1,439 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"math"
+	"math/bits"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newGradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestThumbnail(t *testing.T) {
+	assert.Equal(t, image.Rect(0, 0, 200, 100), Thumbnail(newGradient(400, 200), 200).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 50, 200), Thumbnail(newGradient(100, 400), 200).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 40, 30), Thumbnail(newGradient(40, 30), 200).Bounds())
+}
+
+func TestFlatten(t *testing.T) {
+	transparent := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	flattened := Flatten(transparent, color.White)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, flattened.RGBAAt(0, 0))
+}
+
+// cdfDistance is the largest difference between the cumulative distribution
+// of the luminance of the image and the one of a uniform distribution, 0 for
+// a perfectly flat histogram.
+func cdfDistance(img *image.NRGBA) float64 {
+	var histogram [256]int
+	for i := 0; i < len(img.Pix); i += 4 {
+		y, _, _ := color.RGBToYCbCr(img.Pix[i], img.Pix[i+1], img.Pix[i+2])
+		histogram[y]++
+	}
+
+	distance, sum := 0.0, 0
+	total := float64(len(img.Pix) / 4)
+	for y, count := range histogram {
+		sum += count
+		distance = math.Max(distance, math.Abs(float64(sum)/total-float64(y+1)/256))
+	}
+	return distance
+}
+
+func TestEqualize(t *testing.T) {
+	// a low contrast image, its grays range from 100 to 139
+	low := image.NewNRGBA(image.Rect(0, 0, 40, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 40; x++ {
+			v := uint8(100 + x)
+			low.SetNRGBA(x, y, color.NRGBA{v, v, v, 200})
+		}
+	}
+
+	equalized := Equalize(low)
+	assert.Less(t, cdfDistance(equalized), cdfDistance(low))
+	assert.Less(t, cdfDistance(equalized), 0.05)
+
+	// the darkest and brightest pixels reach the ends of the range
+	assert.Equal(t, color.NRGBA{0, 0, 0, 200}, equalized.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{255, 255, 255, 200}, equalized.NRGBAAt(39, 9))
+
+	uniform := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(uniform, uniform.Bounds(), image.NewUniform(color.NRGBA{50, 60, 70, 255}), image.Point{}, draw.Src)
+	assert.Equal(t, uniform.Pix, Equalize(uniform).Pix)
+}
+
+func TestAutoLevel(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{50, 100, 80, 255})
+	src.SetNRGBA(1, 0, color.NRGBA{100, 150, 80, 255})
+	src.SetNRGBA(2, 0, color.NRGBA{150, 200, 80, 255})
+
+	leveled := AutoLevel(src, true)
+	assert.Equal(t, color.NRGBA64{0, 0, 80 * 0x101, 0xffff}, leveled.NRGBA64At(0, 0))
+	assert.Equal(t, color.NRGBA64{0x8000, 0x8000, 80 * 0x101, 0xffff}, leveled.NRGBA64At(1, 0))
+	assert.Equal(t, color.NRGBA64{0xffff, 0xffff, 80 * 0x101, 0xffff}, leveled.NRGBA64At(2, 0))
+
+	// the luminance goes from about 82.8 to 171.3, the channels beyond are
+	// clamped
+	leveled = AutoLevel(src, false)
+	first, last := leveled.NRGBA64At(0, 0), leveled.NRGBA64At(2, 0)
+	assert.Equal(t, uint16(0), first.R)
+	assert.InDelta(t, (100-82.8)*0xffff/(171.3-82.8), first.G, 0x200)
+	assert.Equal(t, uint16(0), first.B)
+	assert.InDelta(t, (150-82.8)*0xffff/(171.3-82.8), last.R, 0x200)
+	assert.Equal(t, uint16(0xffff), last.G)
+
+	uniform := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(uniform, uniform.Bounds(), image.NewUniform(color.NRGBA{50, 60, 70, 255}), image.Point{}, draw.Src)
+	assert.Equal(t, color.NRGBA64{50 * 0x101, 60 * 0x101, 70 * 0x101, 0xffff}, AutoLevel(uniform, true).NRGBA64At(1, 1))
+}
+
+func TestPad(t *testing.T) {
+	padded := Pad(newGradient(4, 2), 1, 2, 3, 4, color.RGBA{255, 0, 0, 255})
+	assert.Equal(t, image.Rect(0, 0, 4+2+4, 2+1+3), padded.Bounds())
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, padded.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, padded.RGBAAt(9, 5))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, padded.RGBAAt(4, 1))
+	assert.Equal(t, color.RGBA{191, 191, 191, 255}, padded.RGBAAt(7, 2))
+}
+
+func TestComposite(t *testing.T) {
+	base := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(base, base.Bounds(), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+	overlay := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(overlay, overlay.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	composed := Composite(base, overlay, image.Pt(1, 1), 1)
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, composed.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, composed.RGBAAt(1, 1))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, composed.RGBAAt(2, 2))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, composed.RGBAAt(3, 3))
+	// the base is left as it is
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, base.RGBAAt(1, 1))
+
+	composed = Composite(base, overlay, image.Pt(3, -1), 0.5)
+	assert.Equal(t, color.RGBA{128, 0, 127, 255}, composed.RGBAAt(3, 0))
+	assert.Equal(t, color.RGBA{0, 0, 255, 255}, composed.RGBAAt(3, 1))
+}
+
+func TestParseHexColor(t *testing.T) {
+	parsed, err := ParseHexColor("#1a2B3c")
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{0x1a, 0x2b, 0x3c, 255}, parsed)
+
+	parsed, err = ParseHexColor("ffffff")
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, parsed)
+
+	for _, each := range []string{"", "#fff", "#12345g", "#-12345", "#1234567"} {
+		_, err = ParseHexColor(each)
+		assert.NotNil(t, err, each)
+	}
+}
+
+func TestWithAlpha(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Nil(t, png.Encode(&encoded, WithAlpha(newGradient(4, 2))))
+
+	decoded, err := png.Decode(&encoded)
+	if assert.Nil(t, err) {
+		// the opaque pixels keep their alpha channel
+		assert.Equal(t, color.NRGBAModel, decoded.ColorModel())
+		assert.Equal(t, color.NRGBA{127, 127, 127, 255}, decoded.At(2, 0))
+	}
+}
+
+func TestGrayscale(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+
+	gray := Grayscale(red)
+	assert.Equal(t, color.Gray16Model, gray.ColorModel())
+	assert.Equal(t, color.Gray16Model.Convert(color.RGBA{255, 0, 0, 255}), gray.At(1, 1))
+
+	// transparent pixels stay transparent
+	red.Set(0, 0, color.RGBA{})
+	gray = Grayscale(red)
+	assert.Equal(t, color.NRGBA64Model, gray.ColorModel())
+	assert.Equal(t, uint16(0), gray.At(0, 0).(color.NRGBA64).A)
+	assert.Equal(t, color.Gray16Model.Convert(color.RGBA{255, 0, 0, 255}).(color.Gray16).Y, gray.At(1, 1).(color.NRGBA64).R)
+}
+
+func TestSepia(t *testing.T) {
+	toned := Sepia(newGradient(4, 1))
+	assert.Equal(t, color.NRGBA64{0, 0, 0, 0xffff}, toned.NRGBA64At(0, 0))
+
+	// grays turn brown: more red than green, more green than blue
+	pixel := toned.NRGBA64At(2, 0)
+	assert.Greater(t, pixel.R, pixel.G)
+	assert.Greater(t, pixel.G, pixel.B)
+}
+
+func TestAdjust(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{R: 204, G: 102, B: 51, A: 128})
+
+	cases := []struct {
+		name                             string
+		brightness, contrast, saturation float64
+		pixel                            color.NRGBA
+	}{
+		{"unchanged", 0, 0, 0, color.NRGBA{204, 102, 51, 128}},
+		{"brighter", 0.2, 0, 0, color.NRGBA{255, 153, 102, 128}},
+		{"darker", -0.4, 0, 0, color.NRGBA{102, 0, 0, 128}},
+		{"more contrast", 0, 0.5, 0, color.NRGBA{242, 89, 13, 128}},
+		{"no contrast", 0, -1, 0, color.NRGBA{128, 128, 128, 128}},
+		// the luminance is 0.299*0.8 + 0.587*0.4 + 0.114*0.2
+		{"desaturated", 0, 0, -1, color.NRGBA{127, 127, 127, 128}},
+		{"half saturated", 0, 0, -0.5, color.NRGBA{165, 114, 89, 128}},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			pixel := Adjust(src, each.brightness, each.contrast, each.saturation).NRGBA64At(0, 0)
+			// rounded to 8 bits, the conversion of the color model truncates
+			rounded := color.NRGBA{to8(pixel.R), to8(pixel.G), to8(pixel.B), to8(pixel.A)}
+			assert.Equal(t, each.pixel, rounded)
+		})
+	}
+}
+
+func TestUnsharpMask(t *testing.T) {
+	// a step from 0x4000 to 0xc000 between the x 3 and 4
+	step := image.NewGray16(image.Rect(0, 0, 8, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 8; x++ {
+			value := uint16(0x4000)
+			if x >= 4 {
+				value = 0xc000
+			}
+			step.SetGray16(x, y, color.Gray16{value})
+		}
+	}
+
+	// with a radius of 3 the pixels across the step weigh k[4] to k[6] in
+	// the blur of the dark pixel next to it
+	kernel := GaussianKernel(3)
+	darkBlur := 0x4000 + 0x8000*(kernel[4]+kernel[5]+kernel[6])
+	sharpened := UnsharpMask(step, 3, 1, 0)
+
+	cases := []struct {
+		x     int
+		value uint16
+	}{
+		// far enough from the step to be uniform
+		{0, 0x4000},
+		{7, 0xc000},
+		// next to the step, twice the difference with the blur
+		{3, uint16(math.Round(0x4000 - (darkBlur - 0x4000)))},
+		{4, uint16(math.Round(0xc000 + (darkBlur - 0x4000)))},
+	}
+	for _, each := range cases {
+		assert.InDelta(t, each.value, sharpened.RGBA64At(each.x, 1).R, 1, "x=%d", each.x)
+		assert.Equal(t, uint16(0xffff), sharpened.RGBA64At(each.x, 1).A)
+	}
+
+	// a large amount clamps the channels
+	sharpened = UnsharpMask(step, 3, 5, 0)
+	assert.Equal(t, uint16(0), sharpened.RGBA64At(3, 1).R)
+	assert.Equal(t, uint16(0xffff), sharpened.RGBA64At(4, 1).R)
+
+	// a threshold above the difference leaves the step as it is
+	sharpened = UnsharpMask(step, 3, 1, 255)
+	assert.Equal(t, uint16(0x4000), sharpened.RGBA64At(3, 1).R)
+}
+
+func to8(value uint16) uint8 {
+	return uint8((uint32(value) + 128) / 257)
+}
+
+func TestGaussianKernel(t *testing.T) {
+	kernel := GaussianKernel(3)
+	assert.Len(t, kernel, 7)
+
+	sum := 0.0
+	for i, weight := range kernel {
+		sum += weight
+		assert.InDelta(t, kernel[len(kernel)-1-i], weight, 1e-12)
+	}
+	assert.InDelta(t, 1, sum, 1e-12)
+	// sigma is 1, the weights at 0 and 1 are e^0 and e^-1/2 up to the sum
+	assert.InDelta(t, math.Exp(-0.5), kernel[4]/kernel[3], 1e-12)
+}
+
+func TestGaussianBlur(t *testing.T) {
+	// a uniform image stays the same, edges included
+	gray := image.NewUniform(color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff})
+	uniform := image.NewRGBA64(image.Rect(0, 0, 6, 4))
+	draw.Draw(uniform, uniform.Bounds(), gray, image.Point{}, draw.Src)
+	blurred := GaussianBlur(uniform, 2)
+	assert.Equal(t, color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff}, blurred.RGBA64At(0, 0))
+	assert.Equal(t, color.RGBA64{0x8000, 0x8000, 0x8000, 0xffff}, blurred.RGBA64At(3, 2))
+
+	// a white dot spreads by the product of the 1D kernels
+	dot := image.NewGray16(image.Rect(0, 0, 9, 9))
+	dot.SetGray16(4, 4, color.Gray16{0xffff})
+	kernel := GaussianKernel(3)
+	blurred = GaussianBlur(dot, 3)
+	assert.Equal(t, uint16(math.Round(0xffff*kernel[3]*kernel[3])), blurred.RGBA64At(4, 4).R)
+	assert.Equal(t, uint16(math.Round(0xffff*kernel[3]*kernel[5])), blurred.RGBA64At(6, 4).R)
+	assert.Equal(t, blurred.RGBA64At(6, 4), blurred.RGBA64At(4, 2))
+	assert.Equal(t, uint16(0xffff), blurred.RGBA64At(0, 0).A)
+}
+
+func TestMedian(t *testing.T) {
+	// a gray image with salt and pepper noise
+	noisy := image.NewGray(image.Rect(0, 0, 6, 6))
+	draw.Draw(noisy, noisy.Bounds(), image.NewUniform(color.Gray{128}), image.Point{}, draw.Src)
+	noisy.SetGray(1, 1, color.Gray{255})
+	noisy.SetGray(4, 2, color.Gray{0})
+	noisy.SetGray(0, 5, color.Gray{255})
+
+	denoised := Median(noisy, 1)
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			assert.Equal(t, color.NRGBA64{128 * 0x101, 128 * 0x101, 128 * 0x101, 0xffff}, denoised.NRGBA64At(x, y), "%d,%d", x, y)
+		}
+	}
+
+	// edges are kept: the median of a pixel by a vertical edge is its side
+	edge := image.NewGray(image.Rect(0, 0, 6, 6))
+	draw.Draw(edge, image.Rect(3, 0, 6, 6), image.NewUniform(color.Gray{255}), image.Point{}, draw.Src)
+	denoised = Median(edge, 2)
+	assert.Equal(t, uint16(0), denoised.NRGBA64At(2, 3).R)
+	assert.Equal(t, uint16(0xffff), denoised.NRGBA64At(3, 3).R)
+}
+
+func TestDifferenceHash(t *testing.T) {
+	brightening := newGradient(400, 300)
+	assert.Equal(t, uint64(0), DifferenceHash(brightening))
+
+	darkening := Flatten(brightening, color.White)
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			darkening.Set(x, y, brightening.At(399-x, y))
+		}
+	}
+	assert.Equal(t, ^uint64(0), DifferenceHash(darkening))
+
+	scaled := DifferenceHash(Thumbnail(darkening, 100))
+	assert.LessOrEqual(t, bits.OnesCount64(scaled^^uint64(0)), 4)
+}
+
+func TestFrames(t *testing.T) {
+	palette := color.Palette{color.Transparent, color.Black, color.White}
+
+	background := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	draw.Draw(background, background.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	patch := image.NewPaletted(image.Rect(2, 2, 4, 4), palette)
+	draw.Draw(patch, patch.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	corner := image.NewPaletted(image.Rect(0, 0, 1, 1), palette)
+	corner.SetColorIndex(0, 0, 2)
+
+	animation := &gif.GIF{
+		Image:    []*image.Paletted{background, patch, corner},
+		Delay:    []int{10, 10, 10},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 4, Height: 4},
+	}
+
+	frames := Frames(animation)
+	assert.Len(t, frames, 3)
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, frames[1].RGBAAt(3, 3))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, frames[1].RGBAAt(0, 0))
+
+	// the patch is cleared before drawing the third frame
+	assert.Equal(t, color.RGBA{}, frames[2].RGBAAt(3, 3))
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, frames[2].RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, frames[2].RGBAAt(1, 1))
+}
+
+func TestTile(t *testing.T) {
+	images := []image.Image{newGradient(10, 20), newGradient(30, 10), newGradient(20, 20)}
+
+	sheet, positions := Tile(images, 2)
+	assert.Equal(t, image.Rect(0, 0, 50, 40), sheet.Bounds())
+	assert.Equal(t, []image.Rectangle{
+		image.Rect(0, 0, 10, 20),
+		image.Rect(20, 0, 50, 10),
+		image.Rect(0, 20, 20, 40),
+	}, positions)
+}
+
+func TestAnimate(t *testing.T) {
+	animation := Animate([]image.Image{newGradient(40, 30), newGradient(80, 20)}, 20)
+
+	assert.Len(t, animation.Image, 2)
+	assert.Equal(t, []int{20, 20}, animation.Delay)
+	assert.Equal(t, image.Rect(0, 0, 40, 30), animation.Image[1].Bounds())
+	assert.Equal(t, 40, animation.Config.Width)
+}
+
+func TestAnimatedThumbnail(t *testing.T) {
+	frames := make([]image.Image, 20)
+	for i := range frames {
+		frames[i] = newGradient(80, 40)
+	}
+	// 20 fps
+	animation := Animate(frames, 5)
+	animation.LoopCount = 3
+
+	thumbnail := AnimatedThumbnail(animation, 40, 10)
+	assert.Len(t, thumbnail.Image, 10)
+	assert.Equal(t, []int{10, 10, 10, 10, 10, 10, 10, 10, 10, 10}, thumbnail.Delay)
+	assert.Equal(t, image.Rect(0, 0, 40, 20), thumbnail.Image[0].Bounds())
+	assert.Equal(t, 40, thumbnail.Config.Width)
+	assert.Equal(t, 3, thumbnail.LoopCount)
+
+	// slower animations keep their frames
+	assert.Len(t, AnimatedThumbnail(Animate(frames[:4], 50), 40, 10).Image, 4)
+}
+
+func TestAssessQuality(t *testing.T) {
+	checkerboard := image.NewGray(image.Rect(0, 0, 128, 128))
+	for y := 0; y < 128; y++ {
+		for x := 0; x < 128; x++ {
+			if (x/16+y/16)%2 == 0 {
+				checkerboard.SetGray(x, y, color.Gray{255})
+			}
+		}
+	}
+
+	sharp := AssessQuality(checkerboard)
+	blurred := AssessQuality(GaussianBlur(checkerboard, 3))
+	assert.Greater(t, sharp.Sharpness, blurred.Sharpness)
+	assert.Greater(t, sharp.Score, blurred.Score)
+
+	random := rand.New(rand.NewSource(1))
+	noisy := image.NewGray(image.Rect(0, 0, 200, 200))
+	for i := range noisy.Pix {
+		noisy.Pix[i] = uint8(128 + random.NormFloat64()*10)
+	}
+	assert.InDelta(t, 10, AssessQuality(noisy).NoiseEstimate, 1)
+	assert.Less(t, AssessQuality(newGradient(64, 64)).NoiseEstimate, 1.0)
+
+	flat := AssessQuality(image.NewGray(image.Rect(0, 0, 16, 16)))
+	assert.Equal(t, 0.0, flat.Sharpness)
+	assert.Equal(t, 0.0, flat.NoiseEstimate)
+	assert.Equal(t, 0.0, flat.Score)
+}
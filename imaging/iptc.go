@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"imagenexus/dto"
+)
+
+var ErrNoIPTC = errors.New("the image has no embedded IPTC metadata")
+
+var photoshopJPEGMarker = []byte("Photoshop 3.0\x00")
+
+// The image resource block holding the IPTC-NAA record.
+const photoshopIPTCResource = 0x0404
+
+// The datasets of the IIM application record (2).
+const (
+	iptcKeywords  = 25
+	iptcCredit    = 110
+	iptcCopyright = 116
+	iptcCaption   = 120
+)
+
+// ExtractIPTC reads the IPTC/IIM application record stored in the Photoshop
+// APP13 segment of a JPEG.
+func ExtractIPTC(data []byte, contentType string) (*dto.IPTCData, error) {
+	if contentType != "image/jpeg" {
+		return nil, ErrNoIPTC
+	}
+
+	var resources []byte
+	err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+		if marker == 0xed && bytes.HasPrefix(segment, photoshopJPEGMarker) {
+			resources = append(resources, segment[len(photoshopJPEGMarker):]...)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := findPhotoshopResource(resources, photoshopIPTCResource)
+	if err != nil {
+		return nil, err
+	}
+	return parseIIM(record)
+}
+
+// findPhotoshopResource looks for the resource with the given id in a list
+// of 8BIM image resource blocks.
+func findPhotoshopResource(resources []byte, id uint16) ([]byte, error) {
+	for len(resources) >= 12 && string(resources[0:4]) == "8BIM" {
+		resourceId := binary.BigEndian.Uint16(resources[4:6])
+
+		// the name is a pascal string padded to an even length
+		nameLength := int(resources[6]) + 1
+		nameLength += nameLength & 1
+		if 6+nameLength+4 > len(resources) {
+			break
+		}
+
+		sizeAt := 6 + nameLength
+		size := int(binary.BigEndian.Uint32(resources[sizeAt : sizeAt+4]))
+		dataAt := sizeAt + 4
+		if dataAt+size > len(resources) {
+			return nil, errors.New("truncated image resource block")
+		}
+
+		if resourceId == id {
+			return resources[dataAt : dataAt+size], nil
+		}
+
+		size += size & 1
+		resources = resources[min(dataAt+size, len(resources)):]
+	}
+	return nil, ErrNoIPTC
+}
+
+func parseIIM(record []byte) (*dto.IPTCData, error) {
+	iptc := &dto.IPTCData{Keywords: []string{}}
+	found := false
+
+	for len(record) >= 5 && record[0] == 0x1c {
+		recordNumber, dataset := record[1], record[2]
+		size := int(binary.BigEndian.Uint16(record[3:5]))
+		record = record[5:]
+
+		// extended datasets store the length of their size first
+		if size&0x8000 != 0 {
+			sizeLength := size & 0x7fff
+			if sizeLength > 4 || sizeLength > len(record) {
+				return nil, errors.New("invalid IPTC dataset size")
+			}
+			size = 0
+			for _, eachByte := range record[:sizeLength] {
+				size = size<<8 | int(eachByte)
+			}
+			record = record[sizeLength:]
+		}
+
+		if size > len(record) {
+			return nil, errors.New("truncated IPTC dataset")
+		}
+		value := decodeIPTCString(record[:size])
+		record = record[size:]
+
+		if recordNumber != 2 {
+			continue
+		}
+
+		switch dataset {
+		case iptcKeywords:
+			iptc.Keywords = append(iptc.Keywords, value)
+		case iptcCredit:
+			iptc.Credit = value
+		case iptcCopyright:
+			iptc.Copyright = value
+		case iptcCaption:
+			iptc.Caption = value
+		default:
+			continue
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, ErrNoIPTC
+	}
+	return iptc, nil
+}
+
+// decodeIPTCString reads the value as UTF-8, falling back to Latin-1 which
+// older files use without declaring it.
+func decodeIPTCString(value []byte) string {
+	if utf8.Valid(value) {
+		return strings.TrimSpace(string(value))
+	}
+
+	runes := make([]rune, len(value))
+	for i, eachByte := range value {
+		runes[i] = rune(eachByte)
+	}
+	return strings.TrimSpace(string(runes))
+}
@@ -0,0 +1,53 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"testing"
+
+	"imagenexus/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newIIMDataset(dataset byte, value string) []byte {
+	result := []byte{0x1c, 2, dataset}
+	result = binary.BigEndian.AppendUint16(result, uint16(len(value)))
+	return append(result, value...)
+}
+
+func TestExtractIPTC(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&encoded, newGradient(20, 20), nil))
+
+	_, err := ExtractIPTC(encoded.Bytes(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrNoIPTC)
+
+	var record []byte
+	record = append(record, newIIMDataset(iptcKeywords, "cat")...)
+	record = append(record, newIIMDataset(iptcKeywords, "kitten")...)
+	record = append(record, newIIMDataset(iptcCopyright, "\xa9 Image Nexus")...)
+	record = append(record, newIIMDataset(iptcCaption, "A cat on a sofa")...)
+
+	// an unrelated resource ahead of the IPTC one, both with empty names
+	payload := append([]byte{}, photoshopJPEGMarker...)
+	payload = append(payload, "8BIM\x03\xed\x00\x00"...)
+	payload = binary.BigEndian.AppendUint32(payload, 3)
+	payload = append(payload, 1, 2, 3, 0)
+	payload = append(payload, "8BIM\x04\x04\x00\x00"...)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(record)))
+	payload = append(payload, record...)
+
+	segment := []byte{0xff, 0xed}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	data := append(append(append([]byte{}, encoded.Bytes()[:2]...), append(segment, payload...)...), encoded.Bytes()[2:]...)
+
+	iptc, err := ExtractIPTC(data, "image/jpeg")
+	assert.Nil(t, err)
+	assert.Equal(t, &dto.IPTCData{
+		Keywords:  []string{"cat", "kitten"},
+		Copyright: "© Image Nexus",
+		Caption:   "A cat on a sofa",
+	}, iptc)
+}
@@ -0,0 +1,164 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// walkJPEGSegments calls fn with the marker and payload of each segment of a
+// JPEG up to the image data, where the metadata segments end.
+func walkJPEGSegments(data []byte, fn func(marker byte, segment []byte)) error {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return errors.New("invalid JPEG marker")
+		}
+		marker := data[i+1]
+
+		switch {
+		case marker == 0xff:
+			// fill byte
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// markers without a length
+			i += 2
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if length < 2 || i+2+length > len(data) {
+			return errors.New("truncated JPEG segment")
+		}
+
+		fn(marker, data[i+4:i+2+length])
+		i += 2 + length
+	}
+	return nil
+}
+
+// tiffTypeSizes are the sizes in bytes of the TIFF field types.
+var tiffTypeSizes = map[uint16]int{
+	1:  1, // BYTE
+	2:  1, // ASCII
+	3:  2, // SHORT
+	4:  4, // LONG
+	5:  8, // RATIONAL
+	7:  1, // UNDEFINED
+	9:  4, // SLONG
+	10: 8, // SRATIONAL
+}
+
+type tiffEntry struct {
+	fieldType uint16
+	count     int
+	value     []byte
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func newTIFFReader(data []byte) (*tiffReader, error) {
+	if len(data) < 8 {
+		return nil, errors.New("truncated TIFF header")
+	}
+
+	switch string(data[0:2]) {
+	case "II":
+		return &tiffReader{data, binary.LittleEndian}, nil
+	case "MM":
+		return &tiffReader{data, binary.BigEndian}, nil
+	}
+	return nil, errors.New("invalid TIFF byte order")
+}
+
+func (r *tiffReader) firstIFD() int {
+	return int(r.order.Uint32(r.data[4:8]))
+}
+
+// ifd reads the entries of the IFD at offset by tag. Values of fields with
+// an unknown type are left empty.
+func (r *tiffReader) ifd(offset int) (map[uint16]*tiffEntry, error) {
+	if offset+2 > len(r.data) {
+		return nil, errors.New("truncated TIFF IFD")
+	}
+
+	count := int(r.order.Uint16(r.data[offset : offset+2]))
+	entries := make(map[uint16]*tiffEntry, count)
+	for i := 0; i < count; i++ {
+		at := offset + 2 + 12*i
+		if at+12 > len(r.data) {
+			return nil, errors.New("truncated TIFF IFD")
+		}
+
+		entry := &tiffEntry{
+			fieldType: r.order.Uint16(r.data[at+2 : at+4]),
+			count:     int(r.order.Uint32(r.data[at+4 : at+8])),
+		}
+
+		// values of up to 4 bytes are stored in the entry itself
+		size := tiffTypeSizes[entry.fieldType] * entry.count
+		valueAt := at + 8
+		if size > 4 {
+			valueAt = int(r.order.Uint32(r.data[at+8 : at+12]))
+		}
+		if size < 0 || valueAt+size > len(r.data) {
+			return nil, errors.New("invalid TIFF field value")
+		}
+
+		entry.value = r.data[valueAt : valueAt+size]
+		entries[r.order.Uint16(r.data[at:at+2])] = entry
+	}
+	return entries, nil
+}
+
+func (r *tiffReader) uint(entry *tiffEntry) (int, bool) {
+	switch {
+	case entry.count < 1:
+		return 0, false
+	case entry.fieldType == 3:
+		return int(r.order.Uint16(entry.value)), true
+	case entry.fieldType == 4:
+		return int(r.order.Uint32(entry.value)), true
+	}
+	return 0, false
+}
+
+func (r *tiffReader) rationals(entry *tiffEntry) ([]float64, bool) {
+	if entry.fieldType != 5 {
+		return nil, false
+	}
+
+	values := make([]float64, entry.count)
+	for i := range values {
+		numerator := r.order.Uint32(entry.value[8*i:])
+		denominator := r.order.Uint32(entry.value[8*i+4:])
+		if denominator == 0 {
+			return nil, false
+		}
+		values[i] = float64(numerator) / float64(denominator)
+	}
+	return values, true
+}
+
+// readTIFFTag returns the value bytes of a tag of the first IFD of a TIFF,
+// or nil when the tag isn't there.
+func readTIFFTag(data []byte, tag uint16) ([]byte, error) {
+	reader, err := newTIFFReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := reader.ifd(reader.firstIFD())
+	if err != nil {
+		return nil, err
+	}
+
+	if entry, ok := entries[tag]; ok {
+		return entry.value, nil
+	}
+	return nil, nil
+}
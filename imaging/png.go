@@ -0,0 +1,160 @@
+package imaging
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"io"
+
+	"golang.org/x/image/draw"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// adam7Passes are the first column and row and the column and row steps of
+// the pixels of the seven passes of Adam7 interlacing.
+var adam7Passes = [7][4]int{
+	{0, 0, 8, 8}, {4, 0, 8, 8}, {0, 4, 4, 8}, {2, 0, 4, 4},
+	{0, 2, 2, 4}, {1, 0, 2, 2}, {0, 1, 1, 2},
+}
+
+// IsInterlacedPNG tells whether the PNG file starting with header is Adam7
+// interlaced.
+func IsInterlacedPNG(header []byte) bool {
+	// the interlace method is the last byte of IHDR, the first chunk
+	return len(header) > 28 && bytes.HasPrefix(header, pngSignature) && header[28] == 1
+}
+
+// EncodeInterlacedPNG writes the image as an Adam7 interlaced PNG, which
+// browsers show at growing resolutions while it loads and image/png can't
+// write. The pixels are written as 8 bit RGB, or RGBA when some are
+// transparent.
+func EncodeInterlacedPNG(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	pixels := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(pixels, pixels.Bounds(), img, bounds.Min, draw.Src)
+
+	colorType, bytesPerPixel := byte(6), 4
+	if pixels.Opaque() {
+		colorType, bytesPerPixel = 2, 3
+	}
+
+	var compressed bytes.Buffer
+	compressor := zlib.NewWriter(&compressed)
+	for _, pass := range adam7Passes {
+		passWidth := (width - pass[0] + pass[2] - 1) / pass[2]
+		passHeight := (height - pass[1] + pass[3] - 1) / pass[3]
+		if passWidth <= 0 || passHeight <= 0 {
+			continue
+		}
+
+		previous := make([]byte, passWidth*bytesPerPixel)
+		current := make([]byte, passWidth*bytesPerPixel)
+		for row := 0; row < passHeight; row++ {
+			y := pass[1] + row*pass[3]
+			for column := 0; column < passWidth; column++ {
+				pixel := pixels.NRGBAAt(pass[0]+column*pass[2], y)
+				copy(current[column*bytesPerPixel:], []byte{pixel.R, pixel.G, pixel.B, pixel.A}[:bytesPerPixel])
+			}
+
+			if _, err := compressor.Write(filterScanline(current, previous, bytesPerPixel)); err != nil {
+				return err
+			}
+			previous, current = current, previous
+		}
+	}
+	if err := compressor.Close(); err != nil {
+		return err
+	}
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	// 8 bits per channel, deflate, adaptive filtering and Adam7
+	header[8], header[9], header[10], header[11], header[12] = 8, colorType, 0, 0, 1
+
+	if _, err := w.Write(pngSignature); err != nil {
+		return err
+	}
+	for _, chunk := range []struct {
+		kind string
+		data []byte
+	}{{"IHDR", header}, {"IDAT", compressed.Bytes()}, {"IEND", nil}} {
+		if err := writePNGChunk(w, chunk.kind, chunk.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterScanline returns the filter type byte followed by the scanline
+// filtered with the filter giving the smallest sum of absolute differences,
+// the heuristic suggested by the PNG specification.
+func filterScanline(current, previous []byte, bytesPerPixel int) []byte {
+	var best []byte
+	bestSum := -1
+	for filter := byte(0); filter < 5; filter++ {
+		filtered := make([]byte, len(current)+1)
+		filtered[0] = filter
+		sum := 0
+		for i, value := range current {
+			var left, up, upLeft byte
+			if i >= bytesPerPixel {
+				left, upLeft = current[i-bytesPerPixel], previous[i-bytesPerPixel]
+			}
+			up = previous[i]
+
+			switch filter {
+			case 1:
+				value -= left
+			case 2:
+				value -= up
+			case 3:
+				value -= byte((int(left) + int(up)) / 2)
+			case 4:
+				value -= paeth(left, up, upLeft)
+			}
+			filtered[i+1] = value
+			sum += min(int(value), 256-int(value))
+		}
+
+		if bestSum < 0 || sum < bestSum {
+			best, bestSum = filtered, sum
+		}
+	}
+	return best
+}
+
+func paeth(left, up, upLeft byte) byte {
+	estimate := int(left) + int(up) - int(upLeft)
+	distanceLeft := abs(estimate - int(left))
+	distanceUp := abs(estimate - int(up))
+	distanceUpLeft := abs(estimate - int(upLeft))
+	if distanceLeft <= distanceUp && distanceLeft <= distanceUpLeft {
+		return left
+	}
+	if distanceUp <= distanceUpLeft {
+		return up
+	}
+	return upLeft
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
@@ -0,0 +1,46 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeInterlacedPNG(t *testing.T) {
+	noisy := func(w, h int, alpha bool) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for i := range img.Pix {
+			img.Pix[i] = uint8(i * 37 % 251)
+			if i%4 == 3 && !alpha {
+				img.Pix[i] = 255
+			}
+		}
+		return img
+	}
+
+	for _, each := range []*image.NRGBA{noisy(13, 7, false), noisy(13, 7, true), noisy(1, 1, false), noisy(3, 17, true), noisy(40, 40, false)} {
+		var encoded bytes.Buffer
+		if !assert.Nil(t, EncodeInterlacedPNG(&encoded, each)) {
+			continue
+		}
+		assert.True(t, IsInterlacedPNG(encoded.Bytes()))
+
+		decoded, err := png.Decode(&encoded)
+		if assert.Nil(t, err, each.Bounds()) {
+			assert.Equal(t, each.Bounds(), decoded.Bounds())
+			for y := 0; y < each.Bounds().Dy(); y++ {
+				for x := 0; x < each.Bounds().Dx(); x++ {
+					assert.Equal(t, each.NRGBAAt(x, y), color.NRGBAModel.Convert(decoded.At(x, y)), "%v at %d,%d", each.Bounds(), x, y)
+				}
+			}
+		}
+	}
+
+	var plain bytes.Buffer
+	png.Encode(&plain, noisy(4, 4, false))
+	assert.False(t, IsInterlacedPNG(plain.Bytes()))
+}
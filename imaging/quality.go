@@ -0,0 +1,107 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// qualitySize is the size the images are scaled down to before assessing
+// their quality, so the scores of pictures of different sizes compare.
+const qualitySize = 1024
+
+// blurThreshold is the Laplacian variance under which images are commonly
+// considered blurry, the sharpness scoring half.
+const blurThreshold = 100
+
+// noiseThreshold is the noise standard deviation halving the score, about
+// what a high ISO photo shows.
+const noiseThreshold = 10
+
+// Quality is a referenceless quality estimate of an image.
+type Quality struct {
+	// the variance of the Laplacian of the luminance
+	Sharpness float64
+	// the standard deviation of the noise of the luminance
+	NoiseEstimate float64
+	// from 0 to 100, higher for sharp images without noise
+	Score float64
+}
+
+// AssessQuality estimates the quality of the image from its luminance,
+// scaled down to fit in 1024 x 1024. Sharp images have a large Laplacian
+// variance. The noise is estimated with the mask of Immerkær, "Fast Noise
+// Variance Estimation", 1996, taking the median of its response instead of
+// the mean so that the edges aren't mistaken for noise.
+func AssessQuality(src image.Image) *Quality {
+	luminance := scaledLuminance(src)
+	bounds := luminance.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 3 || height < 3 {
+		return &Quality{}
+	}
+
+	pixel := func(x, y int) int {
+		return int(luminance.Pix[y*luminance.Stride+x])
+	}
+
+	var sum, sumOfSquares float64
+	// the response of the noise mask is at most 16 * 255
+	var noiseHistogram [16*255 + 1]int
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			center := pixel(x, y)
+			edges := pixel(x-1, y) + pixel(x+1, y) + pixel(x, y-1) + pixel(x, y+1)
+			corners := pixel(x-1, y-1) + pixel(x+1, y-1) + pixel(x-1, y+1) + pixel(x+1, y+1)
+
+			laplacian := float64(edges - 4*center)
+			sum += laplacian
+			sumOfSquares += laplacian * laplacian
+
+			// the difference of two Laplacians cancels out the structure
+			response := 4*center - 2*edges + corners
+			noiseHistogram[max(response, -response)]++
+		}
+	}
+
+	count := (width - 2) * (height - 2)
+	mean := sum / float64(count)
+	sharpness := sumOfSquares/float64(count) - mean*mean
+
+	median, seen := 0, 0
+	for response, responseCount := range noiseHistogram {
+		seen += responseCount
+		if 2*seen >= count {
+			median = response
+			break
+		}
+	}
+	// the mask has a norm of 6, and the median absolute value of normal
+	// noise is 0.6745 of its deviation
+	noise := float64(median) / (6 * 0.6745)
+
+	sharpnessScore := sharpness / (sharpness + blurThreshold)
+	noiseScore := 1 / (1 + noise*noise/(noiseThreshold*noiseThreshold))
+	return &Quality{
+		Sharpness:     sharpness,
+		NoiseEstimate: noise,
+		Score:         100 * sharpnessScore * noiseScore,
+	}
+}
+
+func scaledLuminance(src image.Image) *image.Gray {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > qualitySize || height > qualitySize {
+		scale := float64(qualitySize) / float64(max(width, height))
+		width = max(1, int(float64(width)*scale))
+		height = max(1, int(float64(height)*scale))
+	}
+
+	// the transparent pixels are flattened on white, like in the thumbnails
+	opaque := Flatten(src, color.White)
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(gray, gray.Bounds(), opaque, opaque.Bounds(), draw.Src, nil)
+	return gray
+}
@@ -0,0 +1,133 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// minPairCount is the smallest expected count of the pairs of values taken
+// into account, below which the chi-square approximation doesn't hold.
+const minPairCount = 5
+
+// ChiSquareLSB runs the chi-square attack of Westfeld and Pfitzmann on the
+// red, green and blue samples of the image. Embedding a message in the least
+// significant bits evens out the counts of each pair of values 2k and 2k+1,
+// so a low chi-square statistic is a sign of hidden data. It returns the
+// statistic and the probability that the least significant bits were
+// overwritten, 0 when there are too few samples to tell.
+func ChiSquareLSB(src image.Image) (float64, float64) {
+	var histogram [256]int
+	countSamples(src, &histogram)
+
+	chiSquare := 0.0
+	pairs := 0
+	for value := 0; value < 256; value += 2 {
+		expected := float64(histogram[value]+histogram[value+1]) / 2
+		if expected < minPairCount {
+			continue
+		}
+		difference := float64(histogram[value]) - expected
+		chiSquare += difference * difference / expected
+		pairs++
+	}
+
+	if pairs < 2 {
+		return chiSquare, 0
+	}
+	return chiSquare, upperGammaRegularized(float64(pairs-1)/2, chiSquare/2)
+}
+
+func countSamples(src image.Image, histogram *[256]int) {
+	bounds := src.Bounds()
+
+	// the common decoded formats are read without a conversion per pixel
+	switch typed := src.(type) {
+	case *image.NRGBA:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := typed.Pix[typed.PixOffset(bounds.Min.X, y):typed.PixOffset(bounds.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				histogram[row[i]]++
+				histogram[row[i+1]]++
+				histogram[row[i+2]]++
+			}
+		}
+		return
+	case *image.RGBA:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			row := typed.Pix[typed.PixOffset(bounds.Min.X, y):typed.PixOffset(bounds.Max.X, y)]
+			for i := 0; i < len(row); i += 4 {
+				histogram[row[i]]++
+				histogram[row[i+1]]++
+				histogram[row[i+2]]++
+			}
+		}
+		return
+	case *image.Gray:
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for _, value := range typed.Pix[typed.PixOffset(bounds.Min.X, y):typed.PixOffset(bounds.Max.X, y)] {
+				histogram[value]++
+			}
+		}
+		return
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := src.At(x, y).RGBA()
+			histogram[r>>8]++
+			histogram[g>>8]++
+			histogram[b>>8]++
+		}
+	}
+}
+
+// upperGammaRegularized returns Q(a, x), the probability that a chi-square
+// distributed variable with 2a degrees of freedom is above 2x, see
+// Numerical Recipes 6.2.
+func upperGammaRegularized(a, x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	logGamma, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - logGamma)
+
+	if x < a+1 {
+		// series of the lower function P(a, x)
+		term := 1 / a
+		sum := term
+		for n := 1; n < 1000; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return max(0, 1-sum*prefix)
+	}
+
+	// continued fraction of Q(a, x) by the modified Lentz method
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < 1000; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return prefix * h
+}
@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChiSquareLSB(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	cover := image.NewNRGBA(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			// a smooth gradient whose values are mostly even
+			value := uint8((x + y) / 2 * 2)
+			if random.Intn(10) == 0 {
+				value++
+			}
+			cover.SetNRGBA(x, y, color.NRGBA{value, value, 255 - value, 255})
+		}
+	}
+
+	chiSquare, probability := ChiSquareLSB(cover)
+	assert.Greater(t, chiSquare, 1000.0)
+	assert.Less(t, probability, 0.01)
+
+	// overwrite the least significant bits with a random message
+	stego := image.NewNRGBA(cover.Bounds())
+	copy(stego.Pix, cover.Pix)
+	for i := range stego.Pix {
+		if i%4 != 3 {
+			stego.Pix[i] = stego.Pix[i]&^1 | uint8(random.Intn(2))
+		}
+	}
+
+	chiSquare, probability = ChiSquareLSB(stego)
+	assert.Less(t, chiSquare, 200.0)
+	assert.Greater(t, probability, 0.95)
+
+	// flat images have too few pairs to tell
+	_, probability = ChiSquareLSB(image.NewGray(image.Rect(0, 0, 50, 50)))
+	assert.Equal(t, 0.0, probability)
+}
+
+func TestUpperGammaRegularized(t *testing.T) {
+	// the chi-square survival function with 2 degrees of freedom is exp(-x/2)
+	for _, x := range []float64{0.5, 2, 10} {
+		assert.InDelta(t, math.Exp(-x/2), upperGammaRegularized(1, x/2), 1e-9, x)
+	}
+	// the median of the chi-square distribution with 10 degrees of freedom
+	assert.InDelta(t, 0.5, upperGammaRegularized(5, 9.341818/2), 1e-5)
+	assert.InDelta(t, 0.05, upperGammaRegularized(50, 124.342/2), 1e-4)
+}
@@ -0,0 +1,42 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Tile lays the images out in a grid with the given number of columns,
+// keeping their sizes. Each column is as wide as its widest image and each
+// row as high as its highest one. It returns the sheet and where each image
+// was drawn.
+func Tile(images []image.Image, columns int) (*image.RGBA, []image.Rectangle) {
+	columns = max(1, min(columns, len(images)))
+	rows := (len(images) + columns - 1) / columns
+
+	columnX := make([]int, columns+1)
+	rowY := make([]int, rows+1)
+	for i, eachImage := range images {
+		size := eachImage.Bounds().Size()
+		columnX[i%columns+1] = max(columnX[i%columns+1], size.X)
+		rowY[i/columns+1] = max(rowY[i/columns+1], size.Y)
+	}
+	for i := 1; i <= columns; i++ {
+		columnX[i] += columnX[i-1]
+	}
+	for i := 1; i <= rows; i++ {
+		rowY[i] += rowY[i-1]
+	}
+
+	sheet := image.NewRGBA(image.Rect(0, 0, columnX[columns], rowY[rows]))
+	positions := make([]image.Rectangle, 0, len(images))
+	for i, eachImage := range images {
+		bounds := eachImage.Bounds()
+		position := image.Rectangle{Max: bounds.Size()}.Add(image.Pt(columnX[i%columns], rowY[i/columns]))
+
+		draw.Draw(sheet, position, eachImage, bounds.Min, draw.Src)
+		positions = append(positions, position)
+	}
+
+	return sheet, positions
+}
@@ -0,0 +1,253 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+
+	"golang.org/x/image/webp"
+)
+
+// The flags of the VP8X chunk of the WebP extended format.
+// See https://developers.google.com/speed/webp/docs/riff_container
+const (
+	webpAnimationFlag = 0x02
+	webpAlphaFlag     = 0x10
+)
+
+// The flags of an ANMF chunk.
+const (
+	webpDisposeToBackground = 0x01
+	webpDoNotBlend          = 0x02
+)
+
+var ErrInvalidWebP = errors.New("invalid animated WebP")
+
+// ErrWebPTooLarge is returned for the animated WebPs with more pixels to
+// decode than their limit, which is checked before the canvas, whose size
+// comes from the file, is allocated.
+var ErrWebPTooLarge = errors.New("the animated WebP is too large to decode")
+
+// IsAnimatedWebP tells whether the file starting with header is a WebP with
+// the animation bit of its VP8X chunk set. golang.org/x/image/webp only
+// decodes the first frame of those.
+func IsAnimatedWebP(header []byte) bool {
+	return len(header) > 20 &&
+		string(header[0:4]) == "RIFF" &&
+		string(header[8:12]) == "WEBP" &&
+		string(header[12:16]) == "VP8X" &&
+		header[20]&webpAnimationFlag != 0
+}
+
+type WebPAnimation struct {
+	Frames []*image.RGBA
+	// Durations of each frame in milliseconds
+	Durations []int
+	LoopCount int
+}
+
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+	chunks := []webpChunk{}
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: truncated chunk header", ErrInvalidWebP)
+		}
+
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size > len(data)-8 {
+			return nil, fmt.Errorf("%w: truncated %q chunk", ErrInvalidWebP, data[0:4])
+		}
+		chunks = append(chunks, webpChunk{fourCC: string(data[0:4]), payload: data[8 : 8+size]})
+
+		// chunks are padded to an even size
+		size += size & 1
+		data = data[min(8+size, len(data)):]
+	}
+	return chunks, nil
+}
+
+func appendWebPChunk(dst []byte, fourCC string, payload []byte) []byte {
+	dst = append(dst, fourCC...)
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(payload)))
+	dst = append(dst, payload...)
+	if len(payload)&1 == 1 {
+		dst = append(dst, 0)
+	}
+	return dst
+}
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// DecodeWebPAnimation renders every frame of an animated WebP as a full
+// image, blending and disposing the frames like a browser would. maxPixels
+// bounds the pixels of the canvas and of the rendered frames together.
+func DecodeWebPAnimation(data []byte, maxPixels int) (*WebPAnimation, error) {
+	animation := &WebPAnimation{}
+	loopCount, err := renderWebPAnimation(data, maxPixels, func(canvas *image.RGBA, duration int) (bool, error) {
+		if (len(animation.Frames)+2)*canvas.Bounds().Dx()*canvas.Bounds().Dy() > maxPixels {
+			return false, fmt.Errorf("%w: more than %d pixels of frames", ErrWebPTooLarge, maxPixels)
+		}
+		animation.Frames = append(animation.Frames, copyRGBA(canvas))
+		animation.Durations = append(animation.Durations, duration)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	animation.LoopCount = loopCount
+	return animation, nil
+}
+
+// DecodeWebPFirstFrame renders the first frame of an animated WebP only, on
+// a canvas of at most maxPixels.
+func DecodeWebPFirstFrame(data []byte, maxPixels int) (*image.RGBA, error) {
+	var first *image.RGBA
+	_, err := renderWebPAnimation(data, maxPixels, func(canvas *image.RGBA, _ int) (bool, error) {
+		first = canvas
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return first, nil
+}
+
+// renderWebPAnimation draws the frames on the canvas one after the other,
+// calling fn with the canvas and the duration of each frame until it
+// returns false, and returns the loop count. The canvas is reused for the
+// next frames.
+func renderWebPAnimation(data []byte, maxPixels int, fn func(*image.RGBA, int) (bool, error)) (int, error) {
+	if !IsAnimatedWebP(data) {
+		return 0, fmt.Errorf("%w: not an animated WebP", ErrInvalidWebP)
+	}
+
+	chunks, err := readWebPChunks(data[12:])
+	if err != nil {
+		return 0, err
+	}
+
+	vp8x := chunks[0].payload
+	if len(vp8x) < 10 {
+		return 0, fmt.Errorf("%w: short VP8X chunk", ErrInvalidWebP)
+	}
+	width, height := 1+uint24(vp8x[4:7]), 1+uint24(vp8x[7:10])
+	if width*height > maxPixels {
+		return 0, fmt.Errorf("%w: %dx%d canvas", ErrWebPTooLarge, width, height)
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	loopCount, frames := 0, 0
+	for _, eachChunk := range chunks[1:] {
+		switch eachChunk.fourCC {
+		case "ANIM":
+			if len(eachChunk.payload) < 6 {
+				return 0, fmt.Errorf("%w: short ANIM chunk", ErrInvalidWebP)
+			}
+			loopCount = int(binary.LittleEndian.Uint16(eachChunk.payload[4:6]))
+
+		case "ANMF":
+			header := eachChunk.payload
+			if len(header) < 16 {
+				return 0, fmt.Errorf("%w: short ANMF chunk", ErrInvalidWebP)
+			}
+
+			frame, err := decodeWebPFrame(header[16:], maxPixels)
+			if err != nil {
+				return 0, err
+			}
+
+			// frame offsets are stored halved
+			offset := image.Pt(2*uint24(header[0:3]), 2*uint24(header[3:6]))
+			bounds := image.Rect(0, 0, 1+uint24(header[6:9]), 1+uint24(header[9:12])).Add(offset).Intersect(canvas.Bounds())
+			flags := header[15]
+
+			op := draw.Over
+			if flags&webpDoNotBlend != 0 {
+				op = draw.Src
+			}
+			draw.Draw(canvas, bounds, frame, frame.Bounds().Min, op)
+
+			frames++
+			next, err := fn(canvas, uint24(header[12:15]))
+			if err != nil || !next {
+				return loopCount, err
+			}
+
+			if flags&webpDisposeToBackground != 0 {
+				draw.Draw(canvas, bounds, image.Transparent, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if frames == 0 {
+		return 0, fmt.Errorf("%w: no frames", ErrInvalidWebP)
+	}
+	return loopCount, nil
+}
+
+// decodeWebPFrame wraps the bitstream chunks of an ANMF frame in a
+// standalone WebP file for golang.org/x/image/webp to decode, unless its
+// bitstream has more than maxPixels.
+func decodeWebPFrame(frameData []byte, maxPixels int) (image.Image, error) {
+	chunks, err := readWebPChunks(frameData)
+	if err != nil {
+		return nil, err
+	}
+
+	var alpha, bitstream *webpChunk
+	for i := range chunks {
+		switch chunks[i].fourCC {
+		case "ALPH":
+			alpha = &chunks[i]
+		case "VP8 ", "VP8L":
+			bitstream = &chunks[i]
+		}
+	}
+	if bitstream == nil {
+		return nil, fmt.Errorf("%w: frame without bitstream", ErrInvalidWebP)
+	}
+
+	body := []byte("WEBP")
+	if alpha != nil && bitstream.fourCC == "VP8 " {
+		// the VP8X header needs the frame size, read from the VP8 key frame
+		// header following the 3 byte frame tag and 3 byte start code
+		if len(bitstream.payload) < 10 {
+			return nil, fmt.Errorf("%w: short VP8 chunk", ErrInvalidWebP)
+		}
+		width := int(binary.LittleEndian.Uint16(bitstream.payload[6:8]) & 0x3fff)
+		height := int(binary.LittleEndian.Uint16(bitstream.payload[8:10]) & 0x3fff)
+
+		vp8x := make([]byte, 10)
+		vp8x[0] = webpAlphaFlag
+		putUint24(vp8x[4:7], width-1)
+		putUint24(vp8x[7:10], height-1)
+		body = appendWebPChunk(body, "VP8X", vp8x)
+		body = appendWebPChunk(body, alpha.fourCC, alpha.payload)
+	}
+	body = appendWebPChunk(body, bitstream.fourCC, bitstream.payload)
+
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	file = append(file, body...)
+	config, err := webp.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d frame", ErrWebPTooLarge, config.Width, config.Height)
+	}
+	return webp.Decode(bytes.NewReader(file))
+}
@@ -0,0 +1,143 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/webp"
+)
+
+const testMaxPixels = 1_000_000
+
+// newAnimatedWebP builds an animated WebP out of the bitstream chunks of
+// static WebP files, placing frame i at offsets[i].
+func newAnimatedWebP(t *testing.T, canvas image.Rectangle, files []string, offsets []image.Point) []byte {
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpAnimationFlag | webpAlphaFlag
+	putUint24(vp8x[4:7], canvas.Dx()-1)
+	putUint24(vp8x[7:10], canvas.Dy()-1)
+
+	body := appendWebPChunk([]byte("WEBP"), "VP8X", vp8x)
+	body = appendWebPChunk(body, "ANIM", []byte{0, 0, 0, 0, 0, 0})
+
+	for i, eachFile := range files {
+		data, err := os.ReadFile(eachFile)
+		assert.Nil(t, err)
+		config, err := webp.DecodeConfig(bytes.NewReader(data))
+		assert.Nil(t, err)
+
+		chunks, err := readWebPChunks(data[12:])
+		assert.Nil(t, err)
+
+		frame := make([]byte, 16)
+		putUint24(frame[0:3], offsets[i].X/2)
+		putUint24(frame[3:6], offsets[i].Y/2)
+		putUint24(frame[6:9], config.Width-1)
+		putUint24(frame[9:12], config.Height-1)
+		putUint24(frame[12:15], 100*(i+1))
+		for _, eachChunk := range chunks {
+			if eachChunk.fourCC != "VP8X" {
+				frame = appendWebPChunk(frame, eachChunk.fourCC, eachChunk.payload)
+			}
+		}
+		body = appendWebPChunk(body, "ANMF", frame)
+	}
+
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestIsAnimatedWebP(t *testing.T) {
+	static, err := os.ReadFile("testdata/gopher.lossless.webp")
+	assert.Nil(t, err)
+	assert.False(t, IsAnimatedWebP(static))
+
+	animated := newAnimatedWebP(t, image.Rect(0, 0, 75, 100), []string{"testdata/gopher.lossless.webp"}, []image.Point{{}})
+	assert.True(t, IsAnimatedWebP(animated))
+
+	_, err = DecodeWebPAnimation(static, testMaxPixels)
+	assert.ErrorIs(t, err, ErrInvalidWebP)
+}
+
+func TestDecodeWebPAnimation(t *testing.T) {
+	rose, err := os.ReadFile("testdata/rose.lossy-with-alpha.webp")
+	assert.Nil(t, err)
+	roseImage, err := webp.Decode(bytes.NewReader(rose))
+	assert.Nil(t, err)
+	roseSize := roseImage.Bounds().Size()
+
+	canvas := image.Rect(0, 0, 76+roseSize.X, max(100, roseSize.Y))
+	data := newAnimatedWebP(t, canvas,
+		[]string{"testdata/gopher.lossless.webp", "testdata/rose.lossy-with-alpha.webp"},
+		[]image.Point{{}, {X: 76}})
+
+	animation, err := DecodeWebPAnimation(data, testMaxPixels)
+	assert.Nil(t, err)
+	assert.Len(t, animation.Frames, 2)
+	assert.Equal(t, []int{100, 200}, animation.Durations)
+	assert.Equal(t, canvas, animation.Frames[1].Bounds())
+
+	// the first frame is kept under the second one
+	assert.Equal(t, animation.Frames[0].At(10, 10), animation.Frames[1].At(10, 10))
+	r, g, b, a := roseImage.At(roseSize.X/2, roseSize.Y/2).RGBA()
+	r2, g2, b2, a2 := animation.Frames[1].At(76+roseSize.X/2, roseSize.Y/2).RGBA()
+	assert.Equal(t, []uint32{r >> 8, g >> 8, b >> 8, a >> 8}, []uint32{r2 >> 8, g2 >> 8, b2 >> 8, a2 >> 8})
+}
+
+func TestDecodeWebPFirstFrame(t *testing.T) {
+	data := newAnimatedWebP(t, image.Rect(0, 0, 150, 100),
+		[]string{"testdata/gopher.lossless.webp", "testdata/gopher.lossless.webp"},
+		[]image.Point{{}, {X: 76}})
+
+	animation, err := DecodeWebPAnimation(data, testMaxPixels)
+	assert.Nil(t, err)
+	first, err := DecodeWebPFirstFrame(data, testMaxPixels)
+	assert.Nil(t, err)
+	assert.Equal(t, animation.Frames[0], first)
+}
+
+func TestDecodeWebPAnimationLimits(t *testing.T) {
+	// a canvas of 16M x 16M pixels would take a petabyte
+	huge := newAnimatedWebP(t, image.Rect(0, 0, 1<<24, 1<<24), []string{"testdata/gopher.lossless.webp"}, []image.Point{{}})
+	_, err := DecodeWebPAnimation(huge, testMaxPixels)
+	assert.ErrorIs(t, err, ErrWebPTooLarge)
+	_, err = DecodeWebPFirstFrame(huge, testMaxPixels)
+	assert.ErrorIs(t, err, ErrWebPTooLarge)
+
+	// the frames are copies of the whole canvas
+	files := make([]string, 50)
+	offsets := make([]image.Point, 50)
+	for i := range files {
+		files[i] = "testdata/gopher.lossless.webp"
+	}
+	many := newAnimatedWebP(t, image.Rect(0, 0, 200, 200), files, offsets)
+	_, err = DecodeWebPAnimation(many, testMaxPixels)
+	assert.ErrorIs(t, err, ErrWebPTooLarge)
+	_, err = DecodeWebPFirstFrame(many, testMaxPixels)
+	assert.Nil(t, err)
+
+	// the frames are decoded within the limit as well
+	_, err = DecodeWebPFirstFrame(newAnimatedWebP(t, image.Rect(0, 0, 75, 100), []string{"testdata/gopher.lossless.webp"}, []image.Point{{}}), 75*100-1)
+	assert.ErrorIs(t, err, ErrWebPTooLarge)
+}
+
+func FuzzDecodeWebPAnimation(f *testing.F) {
+	t := &testing.T{}
+	f.Add(newAnimatedWebP(t, image.Rect(0, 0, 150, 100), []string{"testdata/gopher.lossless.webp", "testdata/rose.lossy-with-alpha.webp"}, []image.Point{{}, {X: 76}}))
+	f.Add(newAnimatedWebP(t, image.Rect(0, 0, 1<<24, 1<<24), []string{"testdata/gopher.lossless.webp"}, []image.Point{{}}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		animation, err := DecodeWebPAnimation(data, testMaxPixels)
+		if err != nil {
+			return
+		}
+		pixels := 0
+		for _, eachFrame := range animation.Frames {
+			pixels += eachFrame.Bounds().Dx() * eachFrame.Bounds().Dy()
+		}
+		assert.LessOrEqual(t, pixels, testMaxPixels)
+	})
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrNoXMP = errors.New("the image has no embedded XMP metadata")
+
+var xmpJPEGMarker = []byte("http://ns.adobe.com/xap/1.0/\x00")
+
+const (
+	tiffXMPTag = 700
+
+	rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// ExtractXMP returns the XMP packet embedded in a JPEG or TIFF file, after
+// checking it's well formed XML holding an RDF description.
+func ExtractXMP(data []byte, contentType string) (string, error) {
+	var packet []byte
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		err = walkJPEGSegments(data, func(marker byte, segment []byte) {
+			if marker == 0xe1 && packet == nil && bytes.HasPrefix(segment, xmpJPEGMarker) {
+				packet = segment[len(xmpJPEGMarker):]
+			}
+		})
+	case "image/tiff":
+		packet, err = readTIFFTag(data, tiffXMPTag)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	packet = bytes.TrimRight(packet, "\x00")
+	if len(packet) == 0 {
+		return "", ErrNoXMP
+	}
+
+	if err := validateXMP(packet); err != nil {
+		return "", err
+	}
+	return string(packet), nil
+}
+
+func validateXMP(packet []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	hasRDF := false
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid XMP packet: %w", err)
+		}
+
+		if element, ok := token.(xml.StartElement); ok && element.Name.Space == rdfNamespace && element.Name.Local == "RDF" {
+			hasRDF = true
+		}
+	}
+
+	if !hasRDF {
+		return errors.New("invalid XMP packet: no rdf:RDF element")
+	}
+	return nil
+}
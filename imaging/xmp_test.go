@@ -0,0 +1,49 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testXMP = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description xmlns:dc="http://purl.org/dc/elements/1.1/">
+   <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">Copyright Image Nexus</rdf:li></rdf:Alt></dc:rights>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// withJPEGSegment inserts an APP1 segment right after the start of image marker.
+func withJPEGSegment(data, payload []byte) []byte {
+	segment := []byte{0xff, 0xe1}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	result := append([]byte{}, data[:2]...)
+	result = append(result, segment...)
+	return append(result, data[2:]...)
+}
+
+func TestExtractXMP(t *testing.T) {
+	var encoded bytes.Buffer
+	assert.Nil(t, jpeg.Encode(&encoded, newGradient(20, 20), nil))
+
+	_, err := ExtractXMP(encoded.Bytes(), "image/jpeg")
+	assert.ErrorIs(t, err, ErrNoXMP)
+
+	data := withJPEGSegment(encoded.Bytes(), append(append([]byte{}, xmpJPEGMarker...), testXMP...))
+	packet, err := ExtractXMP(data, "image/jpeg")
+	assert.Nil(t, err)
+	assert.Equal(t, testXMP, packet)
+
+	invalid := withJPEGSegment(encoded.Bytes(), append(append([]byte{}, xmpJPEGMarker...), "<x:xmpmeta>"...))
+	_, err = ExtractXMP(invalid, "image/jpeg")
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrNoXMP)
+}
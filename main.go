@@ -6,36 +6,26 @@ import (
 	"net/http"
 	"strconv"
 
-	"imagenexus/api/resthandlers"
-	"imagenexus/api/routes"
+	"imagenexus/app"
 	"imagenexus/config"
 	"imagenexus/db"
-	"imagenexus/docs"
-	"imagenexus/service"
-	"imagenexus/storage"
-
-	"github.com/gin-gonic/gin"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// The Swagger docs are regenerated before the SDKs, so they're generated
+// from the current handler annotations.
+//go:generate go run github.com/swaggo/swag/cmd/swag@v1.16.1 init
+//go:generate make gen-sdk-python
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description "Bearer" followed by a space and the JWT
 func main() {
-	err := config.Init("config", "./")
+	err := config.Init(config.File())
 	if err != nil {
 		log.Fatalln("Unable to read the config file: %w", err)
 	}
-
-	router := gin.Default()
-	// Logger middleware will write the logs to gin.DefaultWriter = os.Stdout
-	router.Use(gin.Logger())
-	// Recovery middleware recovers from any panics and writes a 500 if there was one.
-	router.Use(gin.Recovery())
-	router.MaxMultipartMemory = 8 << 20 // 8 MiB
-
-	// Set swagger data
-	docs.SwaggerInfo.Title = "Cat Pictures"
-	docs.SwaggerInfo.Description = "RESTful API for uploading and managing cat pictures."
-	docs.SwaggerInfo.Schemes = []string{"http"}
+	config.Watch()
 
 	// Initialize the db
 	dbConfig := db.NewConfiguration()
@@ -44,24 +34,27 @@ func main() {
 		log.Panicln(err)
 	}
 
-	repository := db.NewPicturesRepository(dbHandler)
-	localStorage := storage.NewStorage(config.GetConfigValue("server.imagePath"))
-	service := service.NewPicturesService(repository, localStorage)
-	handler := resthandlers.NewPicturesHandler(service)
-	routesList := routes.NewPicturesRoutes(handler)
+	imageStorage, err := app.NewImageStorage()
+	if err != nil {
+		log.Panicln(err)
+	}
 
-	serverHandler := resthandlers.NewServerHandler()
-	serverRoutesList := routes.NewServerRouteList(serverHandler)
+	videoStorage, err := app.NewVideoStorage()
+	if err != nil {
+		log.Panicln(err)
+	}
 
-	routes.Install(router, routesList)
-	routes.Install(router, serverRoutesList)
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router := app.NewRouter(dbHandler, dbConfig, imageStorage, videoStorage)
 
 	apiPort, err := strconv.Atoi(config.GetConfigValue("server.port"))
 	if err != nil {
 		log.Fatalln("Unable to parse api port")
 	}
 
+	if config.GetConfigBool("server.tls.enabled") {
+		log.Fatal(listenAndServeTLS(router, apiPort))
+	}
+
 	log.Printf("API service running on port: %d", apiPort)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", apiPort), router))
 }
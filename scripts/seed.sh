@@ -0,0 +1,29 @@
+#!/bin/sh
+# Uploads a few sample pictures to populate a development environment.
+#
+# usage: scripts/seed.sh [api url], http://localhost:8000 by default
+set -eu
+
+API_URL="${1:-http://localhost:8000}"
+SCRIPT_DIR="$(cd "$(dirname "$0")" && pwd)"
+
+upload_file() {
+	echo "uploading $1"
+	curl --fail --silent --show-error -F "image=@$1" -F "description=$2" "$API_URL/v1/" >/dev/null
+}
+
+upload_base64() {
+	echo "uploading $1"
+	curl --fail --silent --show-error -H "Content-Type: application/json" \
+		-d "{\"filename\": \"$1\", \"data\": \"$2\"}" "$API_URL/v1/picture/base64" >/dev/null
+}
+
+for picture in "$SCRIPT_DIR"/../imaging/testdata/*.webp; do
+	upload_file "$picture" "sample $(basename "$picture")"
+done
+
+# 16x16 gradient and checkerboard PNGs
+upload_base64 gradient.png "iVBORw0KGgoAAAANSUhEUgAAABAAAAAQCAIAAACQkWg2AAABlklEQVR42hXRURVEIQhFUSMYgQhGMAIRiGCEE8EIRiACEYhABCLMG7/ZrMt1jMEcyGAN9kAHNjgDBnfwBj6IQQ5q0IMxJnMikzXZE53Y5EyY3Mmb+CQmOalJzw8IUxBhCVtQwYQjIFzhCS6EkEIJLR9YzIUs1mIvdGGLs2BxF2/hi1jkoha9PrCZG9mszd7oxjZnw+Zu3sY3sclNbXp/QJmKKEvZiiqmHAXlKk9xJZRUSmn9gDENMZaxDTXMOAbGNZ7hRhhplNH2gcM8yGEd9kEPdjgHDvfwDn6IQx7q0OcD/wK/Sr4jv9hfkG/1N/x/Fx44BCQU9Pc94zIvclmXfdGLXc79j9/Lu/glLnmpS98PPOZDHuuxH/qwx3n/5ffxHv6IRz7q0e8DznTEWc521DHn+D/KdZ7jTjjplNP+gWAGEqxgBxpYcOIf/AYv8CCCDCro+EAyE0lWshNNLDn5P/MmL/Ekkkwq6fxAMQspVrELLaw49S/lFq/wIoosquj6QDMbaVazG22sOf2v8Dav8SaabKrp5geIAnAQC3NfwAAAAABJRU5ErkJggg=="
+upload_base64 checkerboard.png "iVBORw0KGgoAAAANSUhEUgAAABAAAAAQCAIAAACQkWg2AAAAIklEQVR42mPQQAL/GxjgCJc4wyDUQIwiZPHBqGE0HgaFBgC1H/uBoAWZZgAAAABJRU5ErkJggg=="
+
+echo "seeded $API_URL"
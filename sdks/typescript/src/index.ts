@@ -0,0 +1,120 @@
+// The fetch-based client of the Image Nexus REST API. The types of the API
+// are generated from docs/swagger.yaml to schema.ts by make gen-sdk-ts.
+//
+//	const client = new ImageNexusClient("https://images.example.com", { token });
+//	const { data: picture } = await client.upload(file, "cat.png");
+import type { definitions, paths } from "./schema";
+
+export type { definitions, paths };
+
+export type Picture = definitions["dto.PictureResponse"];
+export type ResponseMeta = definitions["dto.ResponseMeta"];
+export type ResponseError = definitions["dto.ResponseError"];
+export type Problem = definitions["dto.Problem"];
+
+// Envelope is the body of the successful responses, the data of the
+// response along with its meta, e.g. the totals of the listings. dto.Response
+// is generated with an untyped data.
+export interface Envelope<T> {
+  data: T;
+  meta?: ResponseMeta;
+}
+
+// ApiError is the problem document of a failed request, see RFC 7807. errors
+// holds the failed validations of the fields of the request body.
+export class ApiError extends Error {
+  readonly status: number;
+  readonly problem: Problem;
+
+  constructor(status: number, problem: Problem) {
+    super(problem.detail ? `${status} ${problem.title}: ${problem.detail}` : `${status} ${problem.title}`);
+    this.name = "ApiError";
+    this.status = status;
+    this.problem = problem;
+  }
+
+  get errors(): ResponseError[] {
+    return this.problem.errors ?? [];
+  }
+}
+
+export interface ClientOptions {
+  // the JWT sent as a bearer token, the requests are anonymous without one
+  token?: string;
+  // sends the requests, the global fetch when unset
+  fetch?: typeof fetch;
+}
+
+export class ImageNexusClient {
+  private readonly baseUrl: string;
+  private readonly token?: string;
+  private readonly fetch: typeof fetch;
+
+  // baseUrl is the address of the server, e.g. https://images.example.com
+  constructor(baseUrl: string, options: ClientOptions = {}) {
+    this.baseUrl = baseUrl.replace(/\/$/, "") + "/v1";
+    this.token = options.token;
+    this.fetch = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  upload(file: Blob, filename: string): Promise<Envelope<Picture>> {
+    const form = new FormData();
+    form.append("image", file, filename);
+    return this.request("POST", "/", form);
+  }
+
+  get(id: number): Promise<Envelope<Picture>> {
+    return this.request("GET", `/picture/${id}`);
+  }
+
+  // list lists a page of the pictures, starting from 1, the most recently
+  // updated first.
+  list(page = 1): Promise<Envelope<Picture[]>> {
+    return this.request("GET", `/?page=${page}`);
+  }
+
+  delete(id: number): Promise<Envelope<definitions["dto.StringResponse"]>> {
+    return this.request("DELETE", `/picture/${id}`);
+  }
+
+  addTag(id: number, tag: string): Promise<Envelope<Picture>> {
+    const body: definitions["dto.TagRequest"] = { tag };
+    return this.request("POST", `/picture/${id}/tags`, JSON.stringify(body), "application/json");
+  }
+
+  // image downloads the image file of the picture.
+  async image(id: number): Promise<Blob> {
+    const response = await this.send("GET", `/picture/${id}/image`);
+    return response.blob();
+  }
+
+  private async request<T>(method: string, path: string, body?: BodyInit, contentType?: string): Promise<Envelope<T>> {
+    const response = await this.send(method, path, body, contentType);
+    return (await response.json()) as Envelope<T>;
+  }
+
+  private async send(method: string, path: string, body?: BodyInit, contentType?: string): Promise<Response> {
+    const headers: Record<string, string> = {};
+    if (contentType) {
+      headers["Content-Type"] = contentType;
+    }
+    if (this.token) {
+      headers["Authorization"] = `Bearer ${this.token}`;
+    }
+
+    const response = await this.fetch(this.baseUrl + path, { method, headers, body });
+    if (!response.ok) {
+      let problem: Problem;
+      try {
+        problem = (await response.json()) as Problem;
+      } catch {
+        problem = {};
+      }
+      if (!problem.title) {
+        problem.title = response.statusText;
+      }
+      throw new ApiError(response.status, problem);
+    }
+    return response;
+  }
+}
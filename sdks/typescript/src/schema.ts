@@ -0,0 +1,2954 @@
+/**
+ * This file was auto-generated by openapi-typescript.
+ * Do not make direct changes to the file.
+ */
+
+export interface paths {
+  "/healthcheck": {
+    /** Get the start time and uptime of the server along with the IP address of the client */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: { [key: string]: string };
+          };
+        };
+      };
+    };
+  };
+  "/healthz": {
+    /** Succeeds as long as the server is able to answer requests */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProbeResponse"];
+          };
+        };
+      };
+    };
+  };
+  "/healthz/storage": {
+    /** Get the state of the circuit breaker of the image storage: closed, half-open while a call is tried after a timeout, or open while the storage calls fail right away with a 503 */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProbeResponse"];
+          };
+        };
+        /** Service Unavailable */
+        503: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProbeResponse"];
+          };
+        };
+      };
+    };
+  };
+  "/readyz": {
+    /** Succeeds when the dependencies of the server, such as the database, are reachable */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProbeResponse"];
+          };
+        };
+        /** Service Unavailable */
+        503: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProbeResponse"];
+          };
+        };
+      };
+    };
+  };
+  "/v1/": {
+    /** List of pictures along with its metadata */
+    get: {
+      parameters: {
+        query: {
+          /** page number starting from 1 */
+          page?: number;
+        };
+        header: {
+          /** ETag of a previous response, answered with a 304 when the page didn't change */
+          "If-None-Match"?: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"][];
+          };
+        };
+        /** the page didn't change */
+        304: unknown;
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Given a image file, save it & get its computed metadata */
+    post: {
+      parameters: {
+        formData: {
+          /** upload image file */
+          image: unknown;
+          /** description of the image, taken from the IPTC caption when empty */
+          description?: string;
+        };
+        header: {
+          /** replays the response of the first upload with the same key, instead of saving the image again */
+          "X-Idempotency-Key"?: string;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** an upload with the same idempotency key is in progress */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Request Entity Too Large */
+        413: {
+          schema: definitions["dto.Problem"];
+        };
+        /** the idempotency key was used by another request */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/audit-log": {
+    /** List the creations, updates and deletions of a picture, or the ones made by a user, the latest first */
+    get: {
+      parameters: {
+        query: {
+          /** Image Id */
+          entity_id?: number;
+          /** subject of the token of the user */
+          actor_id?: string;
+          /** page number starting from 1 */
+          page?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.AuditLogEntry"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/config/reload": {
+    /** Re-read the config file, listing the changed settings that need a restart to take effect */
+    post: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ConfigReloadResponse"];
+          };
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/jobs/{job_id}": {
+    /** Get the progress of a reprocessing job by its ID */
+    get: {
+      parameters: {
+        path: {
+          /** Job Id */
+          job_id: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProcessingJob"];
+          };
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/moderation/queue": {
+    /** List the pictures pending moderation, the longest waiting first */
+    get: {
+      parameters: {
+        query: {
+          /** page number starting from 1 */
+          page?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/pictures/{id}/approve": {
+    /** Take a picture out of the moderation queue */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/pictures/{id}/reject": {
+    /** Take a picture out of the moderation queue, its files are then unavailable for legal reasons */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/pictures/{id}/reprocess": {
+    /** Re-run the full processing pipeline on an existing picture and return the result of each step */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProcessingResult"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/pictures/{id}/tier": {
+    /** Move the file of an image to the S3 storage class of the hot, warm or cold tier. Cold images have to be restored first. */
+    put: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        body: {
+          /** storage tier */
+          request: definitions["dto.StorageTierRequest"];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/pictures/reprocess-all": {
+    /** Start a background job queueing every picture for processing */
+    post: {
+      parameters: {
+        query: {
+          /** number of pictures queued at a time, 50 by default */
+          batch?: number;
+        };
+      };
+      responses: {
+        /** Accepted */
+        202: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ProcessingJob"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/storage/cost-estimate": {
+    /** Estimate the monthly cost of the stored files by storage tier, at the prices of storage.costPerGBMonth */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StorageCostEstimate"];
+          };
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/storage/lifecycle": {
+    /** List the lifecycle rules of the configured S3 bucket */
+    get: {
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.LifecycleRule"][];
+          };
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Create a lifecycle rule on the configured S3 bucket, or replace the rule with the same id */
+    post: {
+      parameters: {
+        body: {
+          /** lifecycle rule */
+          rule: definitions["dto.LifecycleRule"];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.LifecycleRule"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/admin/storage/lifecycle/{id}": {
+    /** Delete a lifecycle rule of the configured S3 bucket by its ID */
+    delete: {
+      parameters: {
+        path: {
+          /** Rule Id */
+          id: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StringResponse"];
+          };
+        };
+        /** Unauthorized */
+        401: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Forbidden */
+        403: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections": {
+    /** Create an empty collection of pictures */
+    post: {
+      parameters: {
+        body: {
+          /** collection */
+          collection: definitions["dto.CollectionRequest"];
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}": {
+    /** Get a collection along with its pictures, the pinned ones first by pin order, then the others in the order they were added */
+    get: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Delete a collection by its ID, keeping its pictures */
+    delete: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StringResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}/animate": {
+    /** Encode the pictures of a collection as the frames of a looping GIF, scaled to the size of the first one, and save it as a new picture */
+    post: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+        };
+        query: {
+          /** delay between frames in hundredths of a second, 20 by default */
+          delay_cs?: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}/pictures/{pic_id}": {
+    /** Add a picture at the end of a collection */
+    post: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+          /** Image Id */
+          pic_id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Remove a picture from a collection, keeping the picture itself */
+    delete: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+          /** Image Id */
+          pic_id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StringResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}/pictures/{pic_id}/pin": {
+    /** Move a picture of a collection ahead of the others, after the pictures pinned already */
+    post: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+          /** Image Id */
+          pic_id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Move a pinned picture of a collection back to the position it was added at */
+    delete: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+          /** Image Id */
+          pic_id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}/pin-order": {
+    /** Order the pinned pictures of a collection as the given ids, which have to list each of them once */
+    patch: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+        };
+        body: {
+          /** ids of the pinned pictures, in their new order */
+          order: number[];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.CollectionResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/collections/{id}/sprite": {
+    /** Tile the pictures of a collection into a PNG sprite sheet, save it as a new picture and get the position of each picture in it */
+    post: {
+      parameters: {
+        path: {
+          /** Collection Id */
+          id: number;
+        };
+        query: {
+          /** number of columns, a square grid by default */
+          columns?: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.SpriteResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/iiif/{identifier}/{region}/{size}/{rotation}/{quality_format}": {
+    /** Get an image transformed per the IIIF Image API 3.0 region, size, rotation, quality and format parameters. The max size is scaled down to iiif.maxWidth, iiif.maxHeight and iiif.maxArea, the larger sizes are refused with a 400 */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          identifier: number;
+          /** full, square, x,y,w,h or pct:x,y,w,h */
+          region: string;
+          /** max, w,, ,h, pct:n, w,h or !w,h with an optional ^ prefix */
+          size: string;
+          /** degrees between 0 and 360 with an optional ! prefix to mirror */
+          rotation: string;
+          /** {quality}.{format}, e.g. default.jpg */
+          quality_format: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/iiif/{identifier}/info.json": {
+    /** Get the IIIF Image API 3.0 info.json document of an image, advertising the size limits of the returned images */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          identifier: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.IIIFInfoResponse"];
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}": {
+    /** Get a specified image with its metadata by its ID */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Given a image file and an id, update the record & get its computed metadata */
+    put: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        formData: {
+          /** upload image file */
+          image: unknown;
+          /** version of the picture the update is based on, refused with a 409 when the picture changed in between */
+          version?: number;
+        };
+      };
+      responses: {
+        /** Accepted */
+        202: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** the picture changed in between, current_version gives its version */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Request Entity Too Large */
+        413: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Delete a specified image along with its metadata by its ID */
+    delete: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** version of the picture the deletion is based on, refused with a 409 when the picture changed in between */
+          version?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StringResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** the picture changed in between, current_version gives its version */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/adjust": {
+    /** Save a copy of an image as a new picture in the same format, with its brightness, contrast and saturation changed. Each adjustment is between -1 and 1, 0 changes nothing, and at least one must be given. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** added to the channels */
+          brightness?: number;
+          /** scales the channels away from mid gray */
+          contrast?: number;
+          /** scales the channels away from the luminance, -1 for grays */
+          saturation?: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/alpha": {
+    /** Save a copy of an image as a new picture, with its alpha channel stripped into a JPEG flattened on white, or added to a PNG */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** add or strip */
+          action: "add" | "strip";
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/autolevel": {
+    /** Save a copy of an image as a new picture in the same format, with its channels stretched so their darkest value becomes black and their brightest one white. Unlike equalization the values keep their spacing. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** stretch each channel on its own, true by default, false stretches them all by the luminance */
+          per_channel?: boolean;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/blur": {
+    /** Save a copy of an image as a new picture in the same format, blurred with a Gaussian whose standard deviation is a third of the radius. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** blur radius in pixels, at most half the smallest side */
+          radius: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/border": {
+    /** Save a copy of an image as a new picture in the same format, padded with a solid border. Each side may be as wide as edits.maxBorderPercent of the height or width of the image. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** top border in pixels */
+          top?: number;
+          /** right border in pixels */
+          right?: number;
+          /** bottom border in pixels */
+          bottom?: number;
+          /** left border in pixels */
+          left?: number;
+          /** #RRGGBB, white by default */
+          color?: string;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/denoise": {
+    /** Save a copy of an image as a new picture in the same format, with its noise reduced by a median filter, e.g. for low light photographs. Strengths up to 0.5 filter over 3x3 pixels, the stronger ones over 5x5 pixels. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** above 0 and at most 1 */
+          strength: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/dither": {
+    /** Save a copy of an image as a new indexed PNG picture, reduced to a palette of colors chosen by median cut with Floyd-Steinberg dithering, e.g. for the web. Mostly transparent pixels take a transparent palette entry. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** palette size, from 2 to 256 */
+          colors: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/equalize": {
+    /** Save a copy of an image as a new picture in the same format, with the histogram of its luminance equalized to improve its contrast. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/file": {
+    /** Get the file as it was uploaded, e.g. the PDF whose preview is served as its image */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        header: {
+          /** byte range, e.g. bytes=0-1023 */
+          Range?: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Partial Content */
+        206: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/flag": {
+    /** Report a picture to the moderators, putting it back in the moderation queue */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        body: {
+          /** reason of the report */
+          request: definitions["dto.ModerationFlagRequest"];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/frames": {
+    /** List the frames of a GIF or animated WebP picture along with their delays */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureFrame"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/frames/{n}": {
+    /** Get a single frame of a GIF or animated WebP picture as a PNG */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+          /** Frame number starting from 0 */
+          n: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/frames/{n}/save": {
+    /** Save a single frame of a GIF or animated WebP picture as a new PNG picture */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+          /** Frame number starting from 0 */
+          n: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/grayscale": {
+    /** Save a copy of an image as a new picture in the same format, converted to grayscale or toned sepia. The grayscale copies are flagged is_grayscale. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** grayscale by default */
+          tone?: "grayscale" | "sepia";
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/icc": {
+    /** Get the raw ICC colour profile embedded in a JPEG or TIFF image */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/image": {
+    /** Get a specified image file by its ID. PDFs are served as the PNG preview of their first page. Cold pictures are a conflict until restored. Files not matching their checksum are a data corruption problem. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** serve PNGs Adam7 interlaced, from a copy written on the first request */
+          interlace?: boolean;
+        };
+        header: {
+          /** byte range, e.g. bytes=0-1023 */
+          Range?: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** served by nginx through X-Accel-Redirect */
+        204: unknown;
+        /** Partial Content */
+        206: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/location": {
+    /** Get the GPS coordinates where an image was taken, or null when it has none */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureLocation"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/placeholder": {
+    /** Get the data URI of a tiny JPEG of an image, fitting in size x size pixels, to use as the src of an <img> while the image loads. Browsers blur it while scaling it up. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** largest side in pixels, from 1 to 50, 10 by default */
+          size?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.Placeholder"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/quality": {
+    /** Estimate the quality of an image without a reference: the sharpness is the variance of the Laplacian of its luminance and the noise estimate the deviation of its noise. The quality score from 0 to 100 is higher for sharp images without noise, and is stored by the processing pipeline for the min_quality searches. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureQuality"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/restore": {
+    /** Request the restore of the archived file of a cold image, answering 202 with a Retry-After header until it can be served */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.RestoreStatus"];
+          };
+        };
+        /** Accepted */
+        202: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.RestoreStatus"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/retention": {
+    /** Keep an image past server.retentionDays, after which the images never viewed are removed, until retain_until. A null retain_until removes the override. */
+    put: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        body: {
+          /** time until which the image is kept */
+          request: definitions["dto.RetentionRequest"];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/sharpen": {
+    /** Save a copy of an image as a new picture in the same format, sharpened with an unsharp mask: amount times the difference with its Gaussian blur is added, where it is at least threshold levels. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        query: {
+          /** multiple of the difference with the blur, above 0 and at most 5, 1 by default */
+          amount?: number;
+          /** blur radius in pixels, at most half the smallest side */
+          radius: number;
+          /** smallest difference sharpened, from 0 to 255, 0 by default */
+          threshold?: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/srcset": {
+    /** List the IIIF URLs of an image scaled down to the breakpoints narrower than it (320, 640, 960, 1280 and 1920 px wide) and of the image at its own width, along with the srcset attribute of an <img> tag. Nothing is resized until the URLs are requested. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.Srcset"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/steg-check": {
+    /** Run a chi-square attack on the least significant bits of the pixels of an image. It flags the obvious cases of hidden data, e.g. a message spread over a lossless image, and isn't definitive. Suspicious images have an embedding probability above 0.95. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.StegCheck"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/tags": {
+    /** Add a tag to an image, along with the IPTC keywords found on upload. Adding a tag it already has changes nothing. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+        body: {
+          /** tag to add */
+          request: definitions["dto.TagRequest"];
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/thumbnail": {
+    /** Get the JPEG thumbnail generated after the image was uploaded, or the PNG preview of a PDF */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/thumbnail/animated": {
+    /** Get the animated GIF thumbnail of a GIF with several frames, generated after it was uploaded, fitting in 200x200 and playing at most at 10 fps */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/tileset": {
+    /** Get the .dzi XML descriptor of the tile set of an image */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+    /** Cut an image into the JPEG tiles of a Deep Zoom Image tile set, e.g. for OpenSeadragon, replacing its previous tile set. Transparent pixels are flattened on white. */
+    post: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.Tileset"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/tileset/{level}/{col_row}": {
+    /** Get a JPEG tile of the tile set of an image */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+          /** zoom level, 0 is a single pixel */
+          level: number;
+          /** column and row of the tile, e.g. 2_1.jpg */
+          col_row: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/versions": {
+    /** List the versions of an image kept by its updates, the current one first. With storage.s3.versioning, the S3 object versions the bucket kept of the files of the image, listed by ListObjectVersions, whose version ids are the S3 ones. */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureVersion"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/versions/{version_id}": {
+    /** Download a specific historical version of an image file */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+          /** Version Id */
+          version_id: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/{id}/xmp": {
+    /** Get the raw XMP packet embedded in a JPEG or TIFF image, with its copyright and licensing metadata */
+    get: {
+      parameters: {
+        path: {
+          /** Image Id */
+          id: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: string;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/base64": {
+    /** Given a base64 string or a data URL, save the image & get its computed metadata */
+    post: {
+      parameters: {
+        body: {
+          /** base64 data & file name */
+          request: definitions["dto.Base64PictureRequest"];
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Request Entity Too Large */
+        413: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/picture/composite": {
+    /** Save a copy of the base image as a new picture in its format, with the overlay image drawn over it at x, y with the opacity. Overlays extending outside the base are refused unless clip is true. WebPs and PDFs can't be encoded. */
+    post: {
+      parameters: {
+        body: {
+          /** base & overlay pictures and the position of the overlay */
+          request: definitions["dto.CompositeRequest"];
+        };
+        query: {
+          /** clip the overlay to the base */
+          clip?: boolean;
+        };
+      };
+      responses: {
+        /** Created */
+        201: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unprocessable Entity */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Implemented */
+        501: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/pictures": {
+    /** List the pictures whose GPS coordinates fall within a bounding box, given all four coordinates, and whether they are grayscale copies. A lon_min greater than lon_max selects a box crossing the antimeridian. */
+    get: {
+      parameters: {
+        query: {
+          /** southern latitude */
+          lat_min?: number;
+          /** northern latitude */
+          lat_max?: number;
+          /** western longitude */
+          lon_min?: number;
+          /** eastern longitude */
+          lon_max?: number;
+          /** grayscale copies only, or none of them */
+          grayscale?: boolean;
+          /** lowest quality score from 0 to 100, leaving out the unprocessed pictures */
+          min_quality?: number;
+          /** page number starting from 1 */
+          page?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/pictures/batch": {
+    /** Get the image files of several pictures as the parts of a single multipart/mixed response. Every file is opened before the response is written, the first one that can't be served fails the whole batch with its problem, which gives the id of the picture. */
+    get: {
+      parameters: {
+        query: {
+          /** comma separated image ids */
+          ids: string;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: unknown;
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Not Found */
+        404: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Conflict */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Unavailable For Legal Reasons */
+        451: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/pictures/import/datauri": {
+    /** Save every base64 data URI image independently, reporting the outcome of each one in the order of the request. The retries with the same idempotency key get the results of the first import, even a partial one, without saving any image again */
+    post: {
+      parameters: {
+        body: {
+          /** data URIs & file names */
+          request: definitions["dto.DataURIImportRequest"];
+        };
+        header: {
+          /** replays the results of the first import with the same key, instead of saving the images again */
+          "X-Idempotency-Key"?: string;
+        };
+      };
+      responses: {
+        /** Multi-Status */
+        207: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.ImportResult"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** an import with the same idempotency key is in progress */
+        409: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Request Entity Too Large */
+        413: {
+          schema: definitions["dto.Problem"];
+        };
+        /** invalid data URIs, or the idempotency key was used by another request */
+        422: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+  "/v1/pictures/nearby": {
+    /** List the pictures taken within a radius of a point, nearest first, with their distance to it */
+    get: {
+      parameters: {
+        query: {
+          /** latitude of the point */
+          lat: number;
+          /** longitude of the point */
+          lon: number;
+          /** search radius in kilometers */
+          radius_km: number;
+          /** page number starting from 1 */
+          page?: number;
+        };
+      };
+      responses: {
+        /** OK */
+        200: {
+          schema: definitions["dto.Response"] & {
+            data?: definitions["dto.PictureResponse"][];
+          };
+        };
+        /** Bad Request */
+        400: {
+          schema: definitions["dto.Problem"];
+        };
+        /** Internal Server Error */
+        500: {
+          schema: definitions["dto.Problem"];
+        };
+      };
+    };
+  };
+}
+
+export interface definitions {
+  "dto.AuditLogEntry": {
+    action?: string;
+    actor_id?: string;
+    created_at?: string;
+    entity_id?: number;
+    entity_type?: string;
+    id?: number;
+    new_value?: { [key: string]: unknown };
+    old_value?: { [key: string]: unknown };
+  };
+  "dto.Base64PictureRequest": {
+    /** @description plain base64 or a data URL */
+    data: string;
+    filename?: string;
+  };
+  "dto.CollectionRequest": {
+    name: string;
+  };
+  "dto.CollectionResponse": {
+    created_on?: string;
+    id?: number;
+    name?: string;
+    pictures?: definitions["dto.PictureResponse"][];
+    /** @description the ids of the pinned pictures, which come first, in their pin order */
+    pinned?: number[];
+    updated_on?: string;
+  };
+  "dto.CompositeRequest": {
+    base_id: number;
+    /** @description between 0 and 1, 1 when left out */
+    opacity?: number;
+    overlay_id: number;
+    x?: number;
+    y?: number;
+  };
+  "dto.ConfigReloadResponse": {
+    /** @description changed settings that only take effect after a restart */
+    restart_required?: string[];
+  };
+  "dto.DataURIImage": {
+    name?: string;
+    uri: string;
+  };
+  "dto.DataURIImportRequest": {
+    images: definitions["dto.DataURIImage"][];
+  };
+  "dto.IIIFInfoResponse": {
+    "@context"?: string;
+    extraFeatures?: string[];
+    extraFormats?: string[];
+    extraQualities?: string[];
+    height?: number;
+    id?: string;
+    maxArea?: number;
+    maxHeight?: number;
+    maxWidth?: number;
+    profile?: string;
+    protocol?: string;
+    type?: string;
+    width?: number;
+  };
+  "dto.IPTCData": {
+    caption?: string;
+    copyright?: string;
+    credit?: string;
+    keywords?: string[];
+  };
+  "dto.ImportResult": {
+    data?: definitions["dto.PictureResponse"];
+    error?: string;
+    index?: number;
+    meta?: { [key: string]: unknown };
+    status?: number;
+  };
+  "dto.LifecycleRule": {
+    disabled?: boolean;
+    expire_days?: number;
+    id?: string;
+    prefix?: string;
+    transition_class?: string;
+    transition_days?: number;
+  };
+  "dto.ModerationFlagRequest": {
+    reason: string;
+  };
+  "dto.PictureFrame": {
+    delay_ms?: number;
+    frame?: number;
+  };
+  "dto.PictureLocation": {
+    altitude?: number;
+    lat?: number;
+    lon?: number;
+  };
+  "dto.PictureQuality": {
+    noise_estimate?: number;
+    quality_score?: number;
+    sharpness?: number;
+  };
+  "dto.PictureResponse": {
+    checksum?: string;
+    content_type?: string;
+    created_on?: string;
+    description?: string;
+    /** @description set by nearby searches only */
+    distance_km?: number;
+    has_icc_profile?: boolean;
+    height?: number;
+    id?: number;
+    iptc?: definitions["dto.IPTCData"];
+    is_animated?: boolean;
+    is_grayscale?: boolean;
+    moderation_reason?: string;
+    /** @description pending, approved or rejected, along with the reason it was flagged for */
+    moderation_status?: string;
+    name?: string;
+    perceptual_hash?: string;
+    processed?: boolean;
+    /** @description from 0 to 100, computed by the processing pipeline */
+    quality_score?: number;
+    /** @description kept until then past server.retentionDays, see RetentionRequest */
+    retain_until?: string;
+    size?: string;
+    /** @description hot, warm or cold */
+    storage_tier?: string;
+    tags?: string[];
+    /** @description set for the GIFs with several frames */
+    thumbnail_animated_url?: string;
+    thumbnail_url?: string;
+    updated_on?: string;
+    url?: string;
+    /**
+     * @description incremented by every update, sent back with the updates and deletions
+     * to make sure they don't overwrite another change
+     */
+    version?: number;
+    /** @description set for the first frames of uploaded videos only */
+    video?: definitions["dto.VideoMetadata"];
+    width?: number;
+    xmp_present?: boolean;
+  };
+  "dto.PictureVersion": {
+    is_latest?: boolean;
+    last_modified?: string;
+    size?: number;
+    version_id?: string;
+  };
+  "dto.Placeholder": {
+    data_uri?: string;
+    height?: number;
+    width?: number;
+  };
+  "dto.ProbeResponse": {
+    /** @description outcome of each readiness check, "ok" or the error */
+    checks?: { [key: string]: string };
+    status?: string;
+  };
+  "dto.Problem": {
+    detail?: string;
+    /**
+     * @description the errors of the problems with several of them, e.g. the failed
+     * validations of the request body fields
+     */
+    errors?: definitions["dto.ResponseError"][];
+    instance?: string;
+    /** @description the id of the request, also sent as the X-Request-Id header */
+    request_id?: string;
+    status?: number;
+    title?: string;
+    type?: string;
+  };
+  "dto.ProcessingJob": {
+    batch_size?: number;
+    enqueued?: number;
+    error?: string;
+    finished_on?: string;
+    job_id?: string;
+    started_on?: string;
+    status?: string;
+    total?: number;
+  };
+  "dto.ProcessingResult": {
+    picture?: definitions["dto.PictureResponse"];
+    processing_duration_ms?: number;
+    steps?: definitions["dto.ProcessingStepResult"][];
+  };
+  "dto.ProcessingStepResult": {
+    duration_ms?: number;
+    error?: string;
+    name?: string;
+    succeeded?: boolean;
+  };
+  "dto.Response": {
+    data?: unknown;
+    meta?: definitions["dto.ResponseMeta"];
+  };
+  "dto.ResponseError": {
+    field?: string;
+    message?: string;
+    meta?: { [key: string]: unknown };
+    param?: string;
+    rule?: string;
+  };
+  "dto.ResponseMeta": {
+    /** @description page to ask for next, null on the last page */
+    cursor?: string;
+    request_id?: string;
+    total?: number;
+    total_pages?: number;
+    version?: string;
+  };
+  "dto.RestoreStatus": {
+    retry_after_seconds?: number;
+    /** @description available or restoring */
+    status?: string;
+  };
+  "dto.RetentionRequest": {
+    retain_until?: string;
+  };
+  "dto.SpritePosition": {
+    height?: number;
+    width?: number;
+    x?: number;
+    y?: number;
+  };
+  "dto.SpriteResponse": {
+    data?: definitions["dto.PictureResponse"];
+    id?: number;
+    /** @description positions of each picture in the sprite sheet by picture id */
+    positions?: { [key: string]: definitions["dto.SpritePosition"] };
+  };
+  "dto.Srcset": {
+    images?: definitions["dto.SrcsetImage"][];
+    srcset?: string;
+  };
+  "dto.SrcsetImage": {
+    height?: number;
+    url?: string;
+    width?: number;
+  };
+  "dto.StegCheck": {
+    chi_square?: number;
+    embedding_probability?: number;
+    suspicious?: boolean;
+  };
+  "dto.StorageCostEstimate": {
+    /** @description storage.monthlyCostAlertThreshold, 0 for no alert */
+    alert_threshold?: number;
+    backend?: string;
+    bytes?: number;
+    monthly_cost?: number;
+    tiers?: definitions["dto.TierCost"][];
+  };
+  "dto.StorageTierRequest": {
+    /** @enum {string} */
+    tier: "hot" | "warm" | "cold";
+  };
+  "dto.StringResponse": {
+    message?: string;
+  };
+  "dto.TagRequest": {
+    tag: string;
+  };
+  "dto.TierCost": {
+    bytes?: number;
+    cost_per_gb_month?: number;
+    monthly_cost?: number;
+    tier?: string;
+  };
+  "dto.Tileset": {
+    descriptor?: string;
+    format?: string;
+    height?: number;
+    max_level?: number;
+    overlap?: number;
+    tile_count?: number;
+    tile_size?: number;
+    tiles?: string;
+    width?: number;
+  };
+  "dto.VideoMetadata": {
+    duration_seconds?: number;
+    frame_rate?: number;
+    video_codec?: string;
+  };
+}
+
+export interface external {}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+)
+
+var ErrNoAdjustment = errors.New("at least one of brightness, contrast and saturation must be non zero")
+
+var ErrAdjustmentRange = errors.New("the adjustments must be between -1 and 1")
+
+// Adjust saves a copy of the picture with its brightness, contrast and
+// saturation changed as a new picture, in the same format.
+func (s *picturesService) Adjust(id int, adjustments *dto.Adjustments) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	values := []float64{adjustments.Brightness, adjustments.Contrast, adjustments.Saturation}
+	for _, each := range values {
+		// NaN is out of range too
+		if !(each >= -1 && each <= 1) {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusBadRequest,
+				Error:      ErrAdjustmentRange,
+			}
+		}
+	}
+	if values[0] == 0 && values[1] == 0 && values[2] == 0 {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrNoAdjustment,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	requestData, saveError := s.saveConverted(picture, "adjusted", func(src image.Image) image.Image {
+		return imaging.Adjust(src, adjustments.Brightness, adjustments.Contrast, adjustments.Saturation)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
+
+// Equalize saves a copy of the picture with its luminance histogram
+// equalized as a new picture, in the same format.
+func (s *picturesService) Equalize(id int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	requestData, saveError := s.saveConverted(picture, "equalized", func(src image.Image) image.Image {
+		return imaging.Equalize(src)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
+
+// AutoLevel saves a copy of the picture with its channels stretched over
+// the whole range as a new picture, in the same format. The channels are
+// stretched on their own when perChannel is set, otherwise by the
+// luminance.
+func (s *picturesService) AutoLevel(id int, perChannel bool) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	requestData, saveError := s.saveConverted(picture, "leveled", func(src image.Image) image.Image {
+		return imaging.AutoLevel(src, perChannel)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
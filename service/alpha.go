@@ -0,0 +1,109 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AlphaAdd   = "add"
+	AlphaStrip = "strip"
+)
+
+var ErrUnknownAlphaAction = errors.New("the alpha action must be add or strip")
+
+var ErrNoAlphaChannel = errors.New("the format has no alpha channel")
+
+var ErrNoWebPEncoder = errors.New("lossy pictures with an alpha channel would be WebPs, which can't be encoded")
+
+// alphaFormats are the formats that may have an alpha channel to strip.
+var alphaFormats = []string{"image/png", "image/gif", "image/tiff", "image/webp", "image/bmp"}
+
+// ChangeAlpha saves a copy of the picture as a new picture, with its alpha
+// channel stripped into a JPEG flattened on white, or added to a PNG. Only
+// PNGs get an alpha channel: the lossy format to add one to would be WebP,
+// which there is no encoder for.
+func (s *picturesService) ChangeAlpha(id int, action string) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	var extension string
+	switch {
+	case action == AlphaStrip && slices.Contains(alphaFormats, picture.ContentType):
+		extension = ".jpg"
+	case action == AlphaAdd && picture.ContentType == "image/png":
+		extension = ".png"
+	case action == AlphaAdd && picture.ContentType == "image/webp":
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotImplemented,
+			Error:      ErrNoWebPEncoder,
+		}
+	case action == AlphaAdd || action == AlphaStrip:
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("can't %s the alpha channel of %s: %w", action, picture.ContentType, ErrNoAlphaChannel),
+			Data:       gin.H{"format": picture.ContentType},
+		}
+	default:
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrUnknownAlphaAction,
+		}
+	}
+
+	if err := checkRestored(s.storage, picture); err != nil {
+		return nil, restoreError(err)
+	}
+
+	data, err := s.storage.Get(picture.Destination)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	decoded, err := storage.DecodeImage(data, picture.ContentType)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	var buffer bytes.Buffer
+	if action == AlphaStrip {
+		err = jpeg.Encode(&buffer, imaging.Flatten(decoded, color.White), &jpeg.Options{Quality: 90})
+	} else {
+		err = png.Encode(&buffer, imaging.WithAlpha(decoded))
+	}
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	suffix := "-opaque"
+	if action == AlphaAdd {
+		suffix = "-alpha"
+	}
+	name := strings.TrimSuffix(picture.Name, path.Ext(picture.Name)) + suffix + extension
+	return s.CreateFromReader(name, &buffer)
+}
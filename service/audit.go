@@ -0,0 +1,53 @@
+package service
+
+import (
+	"time"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+)
+
+// AuditService lists the changes of the pictures recorded in the audit log.
+type AuditService interface {
+	ListByPicture(id uint, limit, page int) ([]*dto.AuditLogEntry, int64, error)
+	ListByActor(actorId string, limit, page int) ([]*dto.AuditLogEntry, int64, error)
+}
+
+type auditService struct {
+	repository db.AuditRepository
+}
+
+func NewAuditService(repository db.AuditRepository) AuditService {
+	return &auditService{repository}
+}
+
+// ListByPicture lists the changes of the picture, the latest first.
+func (s *auditService) ListByPicture(id uint, limit, page int) ([]*dto.AuditLogEntry, int64, error) {
+	return toAuditLogEntries(s.repository.List(db.AuditEntityPicture, id, "", limit, page))
+}
+
+// ListByActor lists the changes made by the user, the latest first.
+func (s *auditService) ListByActor(actorId string, limit, page int) ([]*dto.AuditLogEntry, int64, error) {
+	return toAuditLogEntries(s.repository.List("", 0, actorId, limit, page))
+}
+
+func toAuditLogEntries(entries []*db.AuditLog, totalCount int64, err error) ([]*dto.AuditLogEntry, int64, error) {
+	if err != nil {
+		return nil, 0, err
+	}
+
+	responses := make([]*dto.AuditLogEntry, 0, len(entries))
+	for _, eachEntry := range entries {
+		responses = append(responses, &dto.AuditLogEntry{
+			Id:         eachEntry.ID,
+			EntityType: eachEntry.EntityType,
+			EntityId:   eachEntry.EntityID,
+			Action:     eachEntry.Action,
+			ActorId:    eachEntry.ActorId,
+			OldValue:   eachEntry.OldValue,
+			NewValue:   eachEntry.NewValue,
+			CreatedAt:  time.UnixMilli(eachEntry.CreatedAt),
+		})
+	}
+	return responses, totalCount, nil
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrDenoiseStrength = errors.New("the denoise strength must be above 0 and at most 1")
+
+var ErrBlurRadius = errors.New("the blur radius must be between 1 and half the smallest side of the picture")
+
+// Blur saves a copy of the picture blurred with a Gaussian of the radius as
+// a new picture, in the same format.
+func (s *picturesService) Blur(id int, radius int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	maxRadius := int(min(picture.Width, picture.Height) / 2)
+	if radius < 1 || radius > maxRadius {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrBlurRadius,
+			Data:       gin.H{"max_radius": maxRadius},
+		}
+	}
+
+	requestData, saveError := s.saveConverted(picture, "blurred", func(src image.Image) image.Image {
+		return imaging.GaussianBlur(src, radius)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
+
+// Denoise saves a copy of the picture with its noise reduced by a median
+// filter as a new picture, in the same format. Strengths up to 0.5 filter
+// over 3x3 pixels, the stronger ones over 5x5 pixels.
+func (s *picturesService) Denoise(id int, strength float64) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if !(strength > 0 && strength <= 1) {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrDenoiseStrength,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	radius := 1
+	if strength > 0.5 {
+		radius = 2
+	}
+	requestData, saveError := s.saveConverted(picture, "denoised", func(src image.Image) image.Image {
+		return imaging.Median(src, radius)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+
+	"imagenexus/config"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrNoBorder = errors.New("top, right, bottom and left can't be negative and at least one must be above 0")
+
+var ErrBorderTooWide = errors.New("the border is too wide")
+
+// Border saves a copy of the picture surrounded with a solid border as a new
+// picture, in the same format. Each side may be as wide as
+// edits.maxBorderPercent of the height or width of the picture.
+func (s *picturesService) Border(id int, border *dto.Border) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	sides := []int{border.Top, border.Right, border.Bottom, border.Left}
+	if min(sides[0], sides[1], sides[2], sides[3]) < 0 || max(sides[0], sides[1], sides[2], sides[3]) == 0 {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrNoBorder,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	if percent := config.GetConfigInt("edits.maxBorderPercent"); percent > 0 {
+		maxVertical := int(picture.Height) * percent / 100
+		maxHorizontal := int(picture.Width) * percent / 100
+		if max(border.Top, border.Bottom) > maxVertical || max(border.Left, border.Right) > maxHorizontal {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("%w, each side may be %d%% of the picture", ErrBorderTooWide, percent),
+				Data:       gin.H{"max_top_bottom": maxVertical, "max_left_right": maxHorizontal},
+			}
+		}
+	}
+
+	requestData, saveError := s.saveConverted(picture, "border", func(src image.Image) image.Image {
+		return imaging.Pad(src, border.Top, border.Right, border.Bottom, border.Left, border.Color)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
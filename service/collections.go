@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrEmptyCollection = errors.New("the collection has no pictures")
+
+var ErrTooFewPictures = errors.New("an animation needs at least 2 pictures")
+
+var ErrPinOrderMismatch = errors.New("the pin order has to list every pinned picture of the collection once")
+
+type CollectionsService interface {
+	Create(*dto.CollectionRequest) (*dto.CollectionResponse, error)
+	Get(int) (*dto.CollectionResponse, error)
+	Delete(int) error
+	AddPicture(int, int) (*dto.CollectionResponse, error)
+	RemovePicture(int, int) error
+	Pin(int, int) (*dto.CollectionResponse, error)
+	Unpin(int, int) (*dto.CollectionResponse, error)
+	ReorderPins(int, []uint) (*dto.CollectionResponse, error)
+	Sprite(int, int) (*dto.SpriteResponse, *dto.InvalidPictureFileError)
+	Animate(int, int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+}
+
+type collectionsService struct {
+	repository         db.CollectionsRepository
+	picturesRepository db.PicturesRepository
+	storage            storage.ImageStorage
+	pictures           PicturesService
+}
+
+func NewCollectionsService(repository db.CollectionsRepository, picturesRepository db.PicturesRepository, storage storage.ImageStorage, pictures PicturesService) CollectionsService {
+	return &collectionsService{repository, picturesRepository, storage, pictures}
+}
+
+func (s *collectionsService) Create(request *dto.CollectionRequest) (*dto.CollectionResponse, error) {
+	collection, err := s.repository.Create(request.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return collection.ToCollectionResponse(nil), nil
+}
+
+func (s *collectionsService) Get(id int) (*dto.CollectionResponse, error) {
+	collection, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pictures, err := s.repository.GetPictures(id)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned, err := s.repository.GetPinned(id)
+	if err != nil {
+		return nil, err
+	}
+
+	response := collection.ToCollectionResponse(pictures)
+	response.Pinned = pinned
+	return response, nil
+}
+
+func (s *collectionsService) Delete(id int) error {
+	return s.repository.Delete(id)
+}
+
+func (s *collectionsService) AddPicture(id, pictureId int) (*dto.CollectionResponse, error) {
+	if _, err := s.repository.GetById(id); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.picturesRepository.GetById(pictureId); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.AddPicture(id, pictureId); err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+func (s *collectionsService) RemovePicture(id, pictureId int) error {
+	return s.repository.RemovePicture(id, pictureId)
+}
+
+// Pin moves a picture of the collection ahead of the others, after the
+// pictures pinned already.
+func (s *collectionsService) Pin(id, pictureId int) (*dto.CollectionResponse, error) {
+	if _, err := s.repository.GetById(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Pin(id, pictureId); err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Unpin moves a pinned picture back to the position it was added at.
+func (s *collectionsService) Unpin(id, pictureId int) (*dto.CollectionResponse, error) {
+	if _, err := s.repository.GetById(id); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.Unpin(id, pictureId); err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// ReorderPins orders the pinned pictures of the collection as pictureIds,
+// which has to list each of them once.
+func (s *collectionsService) ReorderPins(id int, pictureIds []uint) (*dto.CollectionResponse, error) {
+	if _, err := s.repository.GetById(id); err != nil {
+		return nil, err
+	}
+
+	pinned, err := s.repository.GetPinned(id)
+	if err != nil {
+		return nil, err
+	}
+
+	listed := make(map[uint]bool, len(pictureIds))
+	for _, eachId := range pictureIds {
+		listed[eachId] = true
+	}
+	if len(pictureIds) != len(pinned) || len(listed) != len(pinned) {
+		return nil, ErrPinOrderMismatch
+	}
+	for _, eachId := range pinned {
+		if !listed[eachId] {
+			return nil, ErrPinOrderMismatch
+		}
+	}
+
+	if err := s.repository.ReorderPins(id, pictureIds); err != nil {
+		return nil, err
+	}
+
+	return s.Get(id)
+}
+
+// Sprite tiles the pictures of the collection into a PNG sprite sheet with
+// the given number of columns and saves it as a new picture.
+func (s *collectionsService) Sprite(id, columns int) (*dto.SpriteResponse, *dto.InvalidPictureFileError) {
+	collection, pictures, images, decodeError := s.decodePictures(id)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	if columns == 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(images)))))
+	}
+	sheet, rectangles := imaging.Tile(images, columns)
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, sheet); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	created, createError := s.pictures.CreateFromReader(collection.Name+"-sprite.png", &buffer)
+	if createError != nil {
+		return nil, createError
+	}
+
+	positions := make(map[string]*dto.SpritePosition, len(rectangles))
+	for i, eachPicture := range pictures {
+		positions[strconv.Itoa(int(eachPicture.ID))] = &dto.SpritePosition{
+			X:      rectangles[i].Min.X,
+			Y:      rectangles[i].Min.Y,
+			Width:  rectangles[i].Dx(),
+			Height: rectangles[i].Dy(),
+		}
+	}
+
+	return &dto.SpriteResponse{Id: created.Id, Positions: positions, Data: created}, nil
+}
+
+// Animate encodes the pictures of the collection as the frames of a looping
+// GIF, each shown for delay hundredths of a second, and saves it as a new
+// picture.
+func (s *collectionsService) Animate(id, delay int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	collection, _, images, decodeError := s.decodePictures(id)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	if len(images) < 2 {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrTooFewPictures,
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, imaging.Animate(images, delay)); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	return s.pictures.CreateFromReader(collection.Name+"-animation.gif", &buffer)
+}
+
+// decodePictures loads and decodes the pictures of the collection in their
+// order. Empty collections are rejected.
+func (s *collectionsService) decodePictures(id int) (*db.Collection, []*db.Picture, []image.Image, *dto.InvalidPictureFileError) {
+	collection, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotFound,
+			Error:      err,
+		}
+	}
+
+	pictures, err := s.repository.GetPictures(id)
+	if err != nil {
+		return nil, nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	if len(pictures) == 0 {
+		return nil, nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrEmptyCollection,
+		}
+	}
+
+	images := make([]image.Image, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		if err := checkServed(eachPicture); err != nil {
+			return nil, nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusUnavailableForLegalReasons,
+				Error:      err,
+				Data:       gin.H{"id": eachPicture.ID},
+			}
+		}
+
+		if err := checkRestored(s.storage, eachPicture); err != nil {
+			archivedError := restoreError(err)
+			archivedError.Data = gin.H{"id": eachPicture.ID}
+			return nil, nil, nil, archivedError
+		}
+
+		data, err := s.storage.Get(eachPicture.Destination)
+		if err != nil {
+			return nil, nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+
+		decoded, err := storage.DecodeImage(data, eachPicture.ContentType)
+		if err != nil {
+			return nil, nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+				Data:       gin.H{"id": eachPicture.ID},
+			}
+		}
+		images = append(images, decoded)
+	}
+
+	return collection, pictures, images, nil
+}
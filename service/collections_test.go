@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPNG(width, height int) *bytes.Buffer {
+	var content bytes.Buffer
+	png.Encode(&content, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return &content
+}
+
+func TestCollections(t *testing.T) {
+	repo := NewFakeRepository()
+	imageStorage := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, imageStorage, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewCollectionsService(NewFakeCollectionsRepository(repo), repo, imageStorage, pictures)
+
+	collection, err := svc.Create(&dto.CollectionRequest{Name: "icons"})
+	assert.Nil(t, err)
+	collectionId := int(collection.Id)
+
+	t.Run("sprite of an empty collection", func(t *testing.T) {
+		_, spriteError := svc.Sprite(collectionId, 0)
+		assert.Equal(t, http.StatusBadRequest, spriteError.StatusCode)
+		assert.ErrorIs(t, spriteError.Error, ErrEmptyCollection)
+	})
+
+	t.Run("add pictures", func(t *testing.T) {
+		for _, eachSize := range []int{10, 20, 30} {
+			picture, createError := pictures.CreateFromReader("icon.png", newTestPNG(eachSize, eachSize))
+			assert.Nil(t, createError)
+
+			_, err := svc.AddPicture(collectionId, int(picture.Id))
+			assert.Nil(t, err)
+		}
+
+		_, err := svc.AddPicture(collectionId, 100)
+		assert.NotNil(t, err)
+
+		collection, err := svc.Get(collectionId)
+		assert.Nil(t, err)
+		assert.Len(t, collection.Pictures, 3)
+	})
+
+	t.Run("sprite", func(t *testing.T) {
+		sprite, spriteError := svc.Sprite(collectionId, 2)
+		assert.Nil(t, spriteError)
+
+		assert.Equal(t, int32(50), sprite.Data.Width)
+		assert.Equal(t, int32(50), sprite.Data.Height)
+		assert.Equal(t, &dto.SpritePosition{X: 30, Y: 0, Width: 20, Height: 20}, sprite.Positions[strconv.Itoa(2)])
+		assert.Equal(t, &dto.SpritePosition{X: 0, Y: 20, Width: 30, Height: 30}, sprite.Positions[strconv.Itoa(3)])
+	})
+
+	t.Run("animate a single picture", func(t *testing.T) {
+		single, _ := svc.Create(&dto.CollectionRequest{Name: "single"})
+		svc.AddPicture(int(single.Id), 1)
+
+		_, animateError := svc.Animate(int(single.Id), 20)
+		assert.Equal(t, http.StatusBadRequest, animateError.StatusCode)
+		assert.ErrorIs(t, animateError.Error, ErrTooFewPictures)
+	})
+
+	t.Run("animate", func(t *testing.T) {
+		animation, animateError := svc.Animate(collectionId, 20)
+		assert.Nil(t, animateError)
+
+		assert.Equal(t, "image/gif", animation.ContentType)
+		assert.Equal(t, int32(10), animation.Width)
+		assert.Equal(t, int32(10), animation.Height)
+	})
+
+	t.Run("remove picture", func(t *testing.T) {
+		assert.Nil(t, svc.RemovePicture(collectionId, 1))
+		assert.NotNil(t, svc.RemovePicture(collectionId, 1))
+
+		collection, err := svc.Get(collectionId)
+		assert.Nil(t, err)
+		assert.Len(t, collection.Pictures, 2)
+	})
+}
+
+func TestCollectionPins(t *testing.T) {
+	repo := NewFakeRepository()
+	imageStorage := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, imageStorage, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewCollectionsService(NewFakeCollectionsRepository(repo), repo, imageStorage, pictures)
+
+	collection, err := svc.Create(&dto.CollectionRequest{Name: "icons"})
+	assert.Nil(t, err)
+	collectionId := int(collection.Id)
+	assert.Equal(t, []uint{}, collection.Pinned)
+
+	ids := []uint{}
+	for _, eachSize := range []int{10, 20, 30, 40} {
+		picture, createError := pictures.CreateFromReader("icon.png", newTestPNG(eachSize, eachSize))
+		assert.Nil(t, createError)
+		_, err := svc.AddPicture(collectionId, int(picture.Id))
+		assert.Nil(t, err)
+		ids = append(ids, picture.Id)
+	}
+
+	order := func(collection *dto.CollectionResponse) []uint {
+		pictureIds := []uint{}
+		for _, eachPicture := range collection.Pictures {
+			pictureIds = append(pictureIds, eachPicture.Id)
+		}
+		return pictureIds
+	}
+
+	_, err = svc.Pin(collectionId, int(ids[2]))
+	assert.Nil(t, err)
+	collection, err = svc.Pin(collectionId, int(ids[3]))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{ids[2], ids[3], ids[0], ids[1]}, order(collection))
+	assert.Equal(t, []uint{ids[2], ids[3]}, collection.Pinned)
+
+	// pinning twice keeps the pin order
+	collection, err = svc.Pin(collectionId, int(ids[2]))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{ids[2], ids[3]}, collection.Pinned)
+
+	collection, err = svc.ReorderPins(collectionId, []uint{ids[3], ids[2]})
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{ids[3], ids[2], ids[0], ids[1]}, order(collection))
+
+	for _, mismatch := range [][]uint{{ids[3]}, {ids[3], ids[3]}, {ids[3], ids[0]}, {ids[3], ids[2], ids[0]}} {
+		_, err = svc.ReorderPins(collectionId, mismatch)
+		assert.ErrorIs(t, err, ErrPinOrderMismatch, mismatch)
+	}
+
+	collection, err = svc.Unpin(collectionId, int(ids[3]))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint{ids[2], ids[0], ids[1], ids[3]}, order(collection))
+	assert.Equal(t, []uint{ids[2]}, collection.Pinned)
+
+	_, err = svc.Pin(collectionId, 100)
+	assert.NotNil(t, err)
+	_, err = svc.Pin(100, int(ids[0]))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrOverlayOutside = errors.New("the overlay extends outside the base picture, pass clip=true to clip it")
+
+// Composite saves a copy of the base picture with the overlay picture drawn
+// over it as a new picture, in the format of the base. Overlays extending
+// outside the base are refused unless clipped.
+func (s *picturesService) Composite(request *dto.CompositeRequest, clip bool) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	base, findError := s.getPictureToConvert(int(request.BaseId))
+	if findError != nil {
+		return nil, findError
+	}
+	overlayPicture, findError := s.getPictureToConvert(int(request.OverlayId))
+	if findError != nil {
+		return nil, findError
+	}
+
+	overlay, decodeError := s.decodePicture(overlayPicture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	at := image.Pt(request.X, request.Y)
+	target := overlay.Bounds().Sub(overlay.Bounds().Min).Add(at)
+	if !clip && !target.In(image.Rect(0, 0, int(base.Width), int(base.Height))) {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrOverlayOutside,
+			Data:       gin.H{"base_width": base.Width, "base_height": base.Height},
+		}
+	}
+
+	opacity := 1.0
+	if request.Opacity != nil {
+		opacity = *request.Opacity
+	}
+
+	requestData, saveError := s.saveConverted(base, "composite", func(src image.Image) image.Image {
+		return imaging.Composite(src, overlay, at, opacity)
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
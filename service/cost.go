@@ -0,0 +1,105 @@
+package service
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/webhook"
+)
+
+// bytesPerGB is the GB of the storage prices, a gibibyte.
+const bytesPerGB = 1 << 30
+
+var storageTiers = []string{db.TierHot, db.TierWarm, db.TierCold}
+
+// StorageCostService estimates the monthly cost of the stored files from the
+// prices of storage.costPerGBMonth, sending a storage.cost_alert event when
+// it's past storage.monthlyCostAlertThreshold.
+type StorageCostService interface {
+	Estimate() (*dto.StorageCostEstimate, error)
+	Start(time.Duration)
+}
+
+type storageCostService struct {
+	repository db.PicturesRepository
+	storage    storage.ImageStorage
+	events     webhook.Dispatcher
+}
+
+func NewStorageCostService(repository db.PicturesRepository, imageStorage storage.ImageStorage, events webhook.Dispatcher) StorageCostService {
+	return &storageCostService{repository, imageStorage, events}
+}
+
+// Estimate sums the sizes of the uploaded files by storage tier. When the
+// storage can list its files, the bytes of the other files, e.g. the
+// thumbnails and the tile sets, are added to the hot tier.
+func (s *storageCostService) Estimate() (*dto.StorageCostEstimate, error) {
+	sizes, err := s.repository.GetSizeByTier()
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, err := listFiles(s.storage); err == nil {
+		var listed, uploaded int64
+		for _, size := range stored {
+			listed += size
+		}
+		for _, size := range sizes {
+			uploaded += size
+		}
+		sizes[db.TierHot] += max(listed-uploaded, 0)
+	}
+
+	estimate := &dto.StorageCostEstimate{
+		Backend:        config.GetConfigValue("storage.backend"),
+		Tiers:          []*dto.TierCost{},
+		AlertThreshold: config.GetConfigFloat("storage.monthlyCostAlertThreshold"),
+	}
+	for _, tier := range storageTiers {
+		bytes := sizes[tier]
+		if bytes == 0 {
+			continue
+		}
+
+		price := config.GetConfigFloat("storage.costPerGBMonth." + tier)
+		cost := roundCents(float64(bytes) / bytesPerGB * price)
+		estimate.Tiers = append(estimate.Tiers, &dto.TierCost{Tier: tier, Bytes: bytes, CostPerGBMonth: price, MonthlyCost: cost})
+		estimate.Bytes += bytes
+		estimate.MonthlyCost += cost
+	}
+	estimate.MonthlyCost = roundCents(estimate.MonthlyCost)
+	return estimate, nil
+}
+
+func roundCents(cost float64) float64 {
+	return math.Round(cost*100) / 100
+}
+
+// Start checks the estimate every interval, sending a storage.cost_alert
+// event with it while it's past storage.monthlyCostAlertThreshold, unless
+// the threshold is 0.
+func (s *storageCostService) Start(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			threshold := config.GetConfigFloat("storage.monthlyCostAlertThreshold")
+			if threshold <= 0 {
+				continue
+			}
+
+			estimate, err := s.Estimate()
+			if err != nil {
+				log.Printf("Unable to estimate the storage cost: %v", err)
+				continue
+			}
+			if estimate.MonthlyCost > threshold {
+				log.Printf("The estimated storage cost %.2f is past the alert threshold %.2f", estimate.MonthlyCost, threshold)
+				s.events.Send(webhook.EventStorageCostAlert, estimate)
+			}
+		}
+	}()
+}
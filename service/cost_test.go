@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"imagenexus/db"
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageCostEstimate(t *testing.T) {
+	// priced per byte, to count the bytes of the small test files
+	viper.Set("storage.costPerGBMonth.hot", float64(bytesPerGB))
+	viper.Set("storage.costPerGBMonth.warm", float64(2*bytesPerGB))
+	defer viper.Set("storage.costPerGBMonth.hot", nil)
+	defer viper.Set("storage.costPerGBMonth.warm", nil)
+
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewStorageCostService(repo, images, webhook.NewDispatcher(nil, ""))
+
+	for _, eachSize := range []int{4, 5, 4} {
+		_, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(eachSize, eachSize).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+	}
+	hot, warm := repo.data[1], repo.data[2]
+	warm.StorageTier = db.TierWarm
+
+	estimate, err := svc.Estimate()
+	if assert.Nil(t, err) && assert.Len(t, estimate.Tiers, 2) {
+		// the third picture shares the file of the first one
+		assert.Equal(t, db.TierHot, estimate.Tiers[0].Tier)
+		assert.Equal(t, int64(hot.Size), estimate.Tiers[0].Bytes)
+		assert.Equal(t, float64(hot.Size), estimate.Tiers[0].MonthlyCost)
+		assert.Equal(t, db.TierWarm, estimate.Tiers[1].Tier)
+		assert.Equal(t, float64(2*warm.Size), estimate.Tiers[1].MonthlyCost)
+		assert.Equal(t, float64(hot.Size+2*warm.Size), estimate.MonthlyCost)
+		assert.Equal(t, int64(hot.Size+warm.Size), estimate.Bytes)
+	}
+
+	// the files the pictures don't account for are hot
+	writer := images.(storage.FileWriter)
+	assert.Nil(t, writer.Put("thumbnail.jpg", "image/jpeg", strings.NewReader("thumbnail")))
+	estimate, err = svc.Estimate()
+	if assert.Nil(t, err) && assert.NotEmpty(t, estimate.Tiers) {
+		assert.Equal(t, int64(hot.Size)+9, estimate.Tiers[0].Bytes)
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/http"
+	"path"
+	"strings"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+)
+
+var ErrDitherColors = errors.New("the number of colors must be between 2 and 256")
+
+// Dither saves a copy of the picture reduced to a palette of colors colors
+// with Floyd-Steinberg dithering as a new indexed PNG picture, whatever the
+// format of the picture.
+func (s *picturesService) Dither(id int, colors int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if colors < 2 || colors > 256 {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrDitherColors,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, imaging.Dither(decoded, colors)); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	name := strings.TrimSuffix(picture.Name, path.Ext(picture.Name)) + "-dithered.png"
+	return s.CreateFromReader(name, &buffer)
+}
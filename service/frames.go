@@ -0,0 +1,150 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"net/http"
+	"path"
+	"strings"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrNotAnimated = errors.New("only GIF and animated WebP pictures have frames")
+
+type animation struct {
+	frames []*image.RGBA
+	// delays of each frame in milliseconds
+	delays []int
+}
+
+func (s *picturesService) decodeAnimation(id int) (*db.Picture, *animation, *dto.InvalidPictureFileError) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, nil, servedPictureError(err)
+	}
+
+	isGIF := picture.ContentType == "image/gif"
+	if !isGIF && !picture.IsAnimated {
+		return nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrNotAnimated,
+		}
+	}
+
+	if err := checkRestored(s.storage, picture); err != nil {
+		return nil, nil, restoreError(err)
+	}
+
+	data, err := s.storage.Get(picture.Destination)
+	if err != nil {
+		return nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	result := &animation{}
+	if isGIF {
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+
+		result.frames = imaging.Frames(decoded)
+		for _, eachDelay := range decoded.Delay {
+			// GIF delays are in hundredths of a second
+			result.delays = append(result.delays, eachDelay*10)
+		}
+	} else {
+		decoded, err := imaging.DecodeWebPAnimation(data, storage.MaxAnimationPixels())
+		if errors.Is(err, imaging.ErrWebPTooLarge) {
+			return nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusUnprocessableEntity,
+				Error:      err,
+				Data:       gin.H{"max_pixels": storage.MaxAnimationPixels()},
+			}
+		}
+		if err != nil {
+			return nil, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+
+		result.frames = decoded.Frames
+		result.delays = decoded.Durations
+	}
+
+	return picture, result, nil
+}
+
+func (s *picturesService) ListFrames(id int) ([]*dto.PictureFrame, *dto.InvalidPictureFileError) {
+	_, animation, decodeError := s.decodeAnimation(id)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	frames := make([]*dto.PictureFrame, 0, len(animation.frames))
+	for i := range animation.frames {
+		frames = append(frames, &dto.PictureFrame{
+			Frame:   i,
+			DelayMs: animation.delays[i],
+		})
+	}
+	return frames, nil
+}
+
+// GetFrame renders the nth frame of an animated picture as a PNG.
+func (s *picturesService) GetFrame(id, n int) ([]byte, *dto.InvalidPictureFileError) {
+	_, data, frameError := s.encodeFrame(id, n)
+	return data, frameError
+}
+
+// SaveFrame saves the nth frame of an animated picture as a new PNG picture.
+func (s *picturesService) SaveFrame(id, n int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, data, frameError := s.encodeFrame(id, n)
+	if frameError != nil {
+		return nil, frameError
+	}
+
+	name := fmt.Sprintf("%s-frame-%d.png", strings.TrimSuffix(picture.Name, path.Ext(picture.Name)), n)
+	return s.CreateFromReader(name, bytes.NewReader(data))
+}
+
+func (s *picturesService) encodeFrame(id, n int) (*db.Picture, []byte, *dto.InvalidPictureFileError) {
+	picture, animation, decodeError := s.decodeAnimation(id)
+	if decodeError != nil {
+		return nil, nil, decodeError
+	}
+
+	if n < 0 || n >= len(animation.frames) {
+		return nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotFound,
+			Error:      fmt.Errorf("picture %d has no frame %d", id, n),
+			Data:       gin.H{"frames": len(animation.frames)},
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, animation.frames[n]); err != nil {
+		return nil, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	return picture, buffer.Bytes(), nil
+}
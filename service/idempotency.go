@@ -0,0 +1,100 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+)
+
+// defaultIdempotencyKeyHours is how long the responses are replayed when
+// server.idempotencyKeyHours is unset.
+const defaultIdempotencyKeyHours = 24
+
+// idempotencyReservation is how long a key stays reserved by a request in
+// progress, so the retries of a request interrupted by a crash aren't
+// refused until the key expires.
+const idempotencyReservation = 10 * time.Minute
+
+// IdempotencyService keeps the responses of the requests sent with an
+// X-Idempotency-Key header, per key and user, to replay them to the retries
+// of the requests for server.idempotencyKeyHours.
+type IdempotencyService interface {
+	Begin(key, userId string) (*dto.IdempotentResponse, bool, error)
+	Complete(key, userId string, response *dto.IdempotentResponse) error
+	Release(key, userId string) error
+	Start(time.Duration)
+}
+
+type idempotencyService struct {
+	repository db.IdempotencyRepository
+	now        func() time.Time
+}
+
+func NewIdempotencyService(repository db.IdempotencyRepository) IdempotencyService {
+	return &idempotencyService{repository, time.Now}
+}
+
+// Begin reserves the key for the request, returning no response, or returns
+// the response to replay when the key was used before. It tells whether the
+// first request with the key is still being handled instead.
+func (s *idempotencyService) Begin(key, userId string) (*dto.IdempotentResponse, bool, error) {
+	now := s.now()
+	existing, reserved, err := s.repository.Reserve(key, userId, now.UnixMilli(), now.Add(idempotencyReservation).UnixMilli())
+	if err != nil || reserved {
+		return nil, false, err
+	}
+	if existing.StatusCode == 0 {
+		return nil, true, nil
+	}
+	return &dto.IdempotentResponse{
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        existing.ResponseBody,
+		Method:      existing.Method,
+		Route:       existing.Route,
+		BodyHash:    existing.BodyHash,
+	}, false, nil
+}
+
+// Complete saves the response of the request that reserved the key.
+func (s *idempotencyService) Complete(key, userId string, response *dto.IdempotentResponse) error {
+	hours := config.GetConfigInt("server.idempotencyKeyHours")
+	if hours <= 0 {
+		hours = defaultIdempotencyKeyHours
+	}
+
+	return s.repository.Complete(&db.IdempotencyKey{
+		Key:          key,
+		UserId:       userId,
+		StatusCode:   response.StatusCode,
+		ContentType:  response.ContentType,
+		ResponseBody: response.Body,
+		Method:       response.Method,
+		Route:        response.Route,
+		BodyHash:     response.BodyHash,
+		ExpiresOn:    s.now().Add(time.Duration(hours) * time.Hour).UnixMilli(),
+	})
+}
+
+// Release frees the key of a request that failed, so it can be retried.
+func (s *idempotencyService) Release(key, userId string) error {
+	return s.repository.Release(key, userId)
+}
+
+// Start deletes the expired keys every interval.
+func (s *idempotencyService) Start(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			deleted, err := s.repository.DeleteExpired(s.now().UnixMilli())
+			if err != nil {
+				log.Printf("Unable to delete the expired idempotency keys: %v", err)
+			}
+			if deleted > 0 {
+				log.Printf("Deleted %d expired idempotency keys", deleted)
+			}
+		}
+	}()
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/iiif"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+)
+
+type IIIFService interface {
+	Info(int) (*dto.IIIFInfoResponse, error)
+	Render(int, *iiif.Request) ([]byte, string, *dto.InvalidPictureFileError)
+}
+
+type iiifService struct {
+	repository db.PicturesRepository
+	storage    storage.ImageStorage
+}
+
+func NewIIIFService(repository db.PicturesRepository, storage storage.ImageStorage) IIIFService {
+	return &iiifService{repository, storage}
+}
+
+// iiifLimits returns the limits of the IIIF images from iiif.maxWidth,
+// iiif.maxHeight and iiif.maxArea. IIIF clients take a width limit for the
+// height limit too, so a side without a limit takes the one of the other.
+func iiifLimits() iiif.Limits {
+	limits := iiif.Limits{
+		MaxWidth:  config.GetConfigInt("iiif.maxWidth"),
+		MaxHeight: config.GetConfigInt("iiif.maxHeight"),
+		MaxArea:   config.GetConfigInt("iiif.maxArea"),
+	}
+	if limits.MaxWidth <= 0 {
+		limits.MaxWidth = max(limits.MaxHeight, 0)
+	}
+	if limits.MaxHeight <= 0 {
+		limits.MaxHeight = limits.MaxWidth
+	}
+	limits.MaxArea = max(limits.MaxArea, 0)
+	return limits
+}
+
+func (s *iiifService) Info(id int) (*dto.IIIFInfoResponse, error) {
+	picture, err := getServedPicture(s.repository, id)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := iiifLimits()
+	return &dto.IIIFInfoResponse{
+		Context:        iiif.Context,
+		Id:             fmt.Sprintf("%s/iiif/%d", config.APIBaseURL(), picture.ID),
+		Type:           iiif.Type,
+		Protocol:       iiif.Protocol,
+		Profile:        iiif.Profile,
+		Width:          picture.Width,
+		Height:         picture.Height,
+		ExtraQualities: iiif.ExtraQualities,
+		ExtraFormats:   iiif.ExtraFormats,
+		ExtraFeatures:  iiif.ExtraFeatures,
+		MaxWidth:       limits.MaxWidth,
+		MaxHeight:      limits.MaxHeight,
+		MaxArea:        limits.MaxArea,
+	}, nil
+}
+
+func (s *iiifService) Render(id int, request *iiif.Request) ([]byte, string, *dto.InvalidPictureFileError) {
+	picture, err := getServedPicture(s.repository, id)
+	if err != nil {
+		return nil, "", servedPictureError(err)
+	}
+
+	if err := checkRestored(s.storage, picture); err != nil {
+		return nil, "", restoreError(err)
+	}
+
+	data, err := s.storage.Get(picture.Destination)
+	if err != nil {
+		return nil, "", &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	source, err := storage.DecodeImage(data, picture.ContentType)
+	if err != nil {
+		return nil, "", &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	result, err := request.Apply(source, iiifLimits())
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if errors.Is(err, iiif.ErrInvalidRequest) {
+			statusCode = http.StatusBadRequest
+		}
+		return nil, "", &dto.InvalidPictureFileError{
+			StatusCode: statusCode,
+			Error:      err,
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := iiif.Encode(&buffer, result, request.Format); err != nil {
+		return nil, "", &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	// keep the colours of the source in colour managed applications
+	contentType := iiif.FORMAT_CONTENT_TYPES[request.Format]
+	encoded, err := imaging.EmbedICCProfile(buffer.Bytes(), picture.ICCProfile, contentType)
+	if err != nil {
+		return nil, "", &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	return encoded, contentType, nil
+}
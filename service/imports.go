@@ -0,0 +1,104 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"imagenexus/dto"
+	"imagenexus/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrInvalidDataURI = errors.New("invalid data URI, expected data:<type>;base64,<data>")
+
+// ImportDataURIs saves every image independently, so one invalid image
+// doesn't fail the others.
+func (s *picturesService) ImportDataURIs(images []*dto.DataURIImage) []*dto.ImportResult {
+	results := make([]*dto.ImportResult, 0, len(images))
+	for index, eachImage := range images {
+		result := &dto.ImportResult{Index: index, Status: http.StatusCreated}
+
+		picture, importError := s.importDataURI(index, eachImage)
+		if importError != nil {
+			result.Status = importError.StatusCode
+			result.Error = importError.Error.Error()
+			result.Meta = importError.Data
+		} else {
+			result.Data = picture
+		}
+
+		results = append(results, result)
+	}
+	return results
+}
+
+func (s *picturesService) importDataURI(index int, image *dto.DataURIImage) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	mediaType, encoded, err := parseDataURI(image.URI)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+		}
+	}
+
+	data, decodeError := decodeBase64(encoded)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	// the declared type has to match the contents, storage only checks the
+	// latter
+	if detected := validation.DetectContentType(data); mediaType != detected {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      errors.New("the contents don't match the declared type"),
+			Data:       gin.H{"declared": mediaType, "format": detected},
+		}
+	}
+
+	name := path.Base(image.Name)
+	if image.Name == "" {
+		name = fmt.Sprintf("import-%d.%s", index+1, strings.TrimPrefix(mediaType, "image/"))
+	}
+
+	return s.CreateFromReader(name, bytes.NewReader(data))
+}
+
+// parseDataURI returns the media type and the base64 payload of a base64
+// data URI.
+func parseDataURI(uri string) (string, string, error) {
+	header, payload, found := strings.Cut(uri, ",")
+	header, isData := strings.CutPrefix(header, "data:")
+	header, isBase64 := strings.CutSuffix(header, ";base64")
+	if !found || !isData || !isBase64 {
+		return "", "", ErrInvalidDataURI
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrInvalidDataURI, err)
+	}
+	return mediaType, payload, nil
+}
+
+func decodeBase64(encoded string) ([]byte, *dto.InvalidPictureFileError) {
+	if sizeError := checkUploadSize(int64(base64.StdEncoding.DecodedLen(len(encoded)))); sizeError != nil {
+		return nil, sizeError
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      fmt.Errorf("invalid base64 data: %w", err),
+		}
+	}
+	return data, nil
+}
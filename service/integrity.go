@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/webhook"
+)
+
+// ChecksumMismatchError is returned for the stored files whose SHA-256
+// differs from the checksum of their picture, e.g. after a silent corruption
+// of the disk. It's the data of the storage.corruption events.
+type ChecksumMismatchError struct {
+	PictureId   uint   `json:"picture_id"`
+	Destination string `json:"destination"`
+	Expected    string `json:"expected"`
+	Actual      string `json:"actual"`
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for picture %d: expected %s, got %s", e.PictureId, e.Expected, e.Actual)
+}
+
+// verifyChecksum compares the file of the picture with its checksum, unless
+// storage.verifyChecksums is disabled or the file was verified within
+// storage.verifyChecksumsHours. The file is hashed, then rewound for the
+// response. The pictures saved without a checksum aren't verified. A mismatch is recorded
+// in the audit log and sent as a storage.corruption event.
+func (s *picturesService) verifyChecksum(picture *db.Picture, reader io.ReadSeeker) error {
+	now := time.Now()
+	if !checksumVerificationDue(picture, now) {
+		return nil
+	}
+
+	actual, err := fileChecksum(reader)
+	if err != nil {
+		return err
+	}
+	if actual == picture.Checksum {
+		if err := s.repository.RecordChecksumVerified(picture.Destination, now.UnixMilli()); err != nil {
+			log.Printf("Unable to record the verification of picture %d: %v", picture.ID, err)
+		}
+		return nil
+	}
+
+	mismatch := &ChecksumMismatchError{PictureId: picture.ID, Destination: picture.Destination, Expected: picture.Checksum, Actual: actual}
+	log.Printf("Corrupted file %s: %v", picture.Destination, mismatch)
+	if err := s.repository.RecordCorruption(context.Background(), int(picture.ID), mismatch); err != nil {
+		log.Printf("Unable to record the corruption of picture %d: %v", picture.ID, err)
+	}
+	s.events.Send(webhook.EventStorageCorruption, mismatch)
+	return mismatch
+}
+
+// checksumVerificationDue tells whether verifyChecksum hashes the file of
+// the picture.
+func checksumVerificationDue(picture *db.Picture, now time.Time) bool {
+	if picture.Checksum == "" || !config.GetConfigBool("storage.verifyChecksums") {
+		return false
+	}
+
+	interval := time.Duration(config.GetConfigInt("storage.verifyChecksumsHours")) * time.Hour
+	return now.Sub(time.UnixMilli(picture.ChecksumVerifiedOn)) >= interval
+}
+
+// fileChecksum returns the SHA-256 of the reader, rewound afterwards.
+func fileChecksum(reader io.ReadSeeker) (string, error) {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, reader); err != nil {
+		return "", err
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksumVerification(t *testing.T) {
+	viper.Set("storage.verifyChecksums", true)
+	defer viper.Set("storage.verifyChecksums", nil)
+
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	events := &recordingDispatcher{}
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), events, nil)
+
+	content := newTestPNG(4, 4).Bytes()
+	created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", content), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	id := int(created.Id)
+
+	// the file is rewound after its hashing
+	reader, _, _, err := pictures.GetFileReader(id)
+	if assert.Nil(t, err) {
+		data, _ := io.ReadAll(reader)
+		assert.Equal(t, content, data)
+		reader.Close()
+	}
+	assert.Empty(t, repo.corruptions)
+
+	corrupted := append([]byte{}, content...)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	assert.Nil(t, os.WriteFile(images.GetFullPath(repo.data[id].Destination), corrupted, 0644))
+
+	for _, getReader := range []func(int) (io.ReadSeekCloser, string, time.Time, error){pictures.GetFileReader, pictures.GetOriginalReader} {
+		_, _, _, err = getReader(id)
+		var mismatch *ChecksumMismatchError
+		if assert.ErrorAs(t, err, &mismatch) {
+			assert.Equal(t, repo.data[id].Checksum, mismatch.Expected)
+			assert.NotEqual(t, mismatch.Expected, mismatch.Actual)
+		}
+	}
+	// nginx isn't handed the corrupted file either
+	_, _, err = pictures.GetInternalRedirect(id)
+	var mismatch *ChecksumMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, []int{id, id, id}, repo.corruptions)
+	assert.Equal(t, []string{webhook.EventStorageCorruption, webhook.EventStorageCorruption, webhook.EventStorageCorruption}, events.events[len(events.events)-3:])
+
+	// the pictures without a checksum, e.g. saved before the checksums, are
+	// served as such
+	repo.data[id].Checksum = ""
+	reader, _, _, err = pictures.GetFileReader(id)
+	if assert.Nil(t, err) {
+		reader.Close()
+	}
+}
+
+func TestChecksumVerificationInterval(t *testing.T) {
+	viper.Set("storage.verifyChecksums", true)
+	defer viper.Set("storage.verifyChecksums", nil)
+	viper.Set("storage.verifyChecksumsHours", 1)
+	defer viper.Set("storage.verifyChecksumsHours", nil)
+
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), &recordingDispatcher{}, nil)
+
+	created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(4, 4).Bytes()), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	id := int(created.Id)
+
+	reader, _, _, err := pictures.GetFileReader(id)
+	if assert.Nil(t, err) {
+		reader.Close()
+	}
+	assert.NotZero(t, repo.data[id].ChecksumVerifiedOn)
+
+	// the file verified within the hour isn't hashed again
+	assert.Nil(t, os.WriteFile(images.GetFullPath(repo.data[id].Destination), []byte("corrupted"), 0644))
+	reader, _, _, err = pictures.GetFileReader(id)
+	if assert.Nil(t, err) {
+		reader.Close()
+	}
+	assert.Empty(t, repo.corruptions)
+
+	repo.data[id].ChecksumVerifiedOn = time.Now().Add(-2 * time.Hour).UnixMilli()
+	_, _, _, err = pictures.GetFileReader(id)
+	var mismatch *ChecksumMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, []int{id}, repo.corruptions)
+}
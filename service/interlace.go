@@ -0,0 +1,112 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"io"
+	"path"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/imaging"
+)
+
+// GetInterlacedFileReader opens the image of the picture like GetFileReader,
+// served from its Adam7 interlaced copy when the image is a PNG. The caller
+// is responsible for closing the reader.
+func (s *picturesService) GetInterlacedFileReader(id int) (io.ReadSeekCloser, string, time.Time, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	destination, contentType, err := s.interlacedFile(picture)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	reader, err := s.storage.GetReader(destination)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	s.recordView(picture)
+	return reader, contentType, time.UnixMilli(picture.UpdatedOn), nil
+}
+
+// GetInterlacedRedirect returns the internal nginx location of the image of
+// the picture like GetInternalRedirect, pointing at its interlaced copy when
+// the image is a PNG.
+func (s *picturesService) GetInterlacedRedirect(id int) (string, string, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	destination, contentType, err := s.interlacedFile(picture)
+	if err != nil {
+		return "", "", err
+	}
+
+	internalPath := config.GetConfigValue("server.xAccelRedirect.internalPath")
+	s.recordView(picture)
+	return path.Join("/", internalPath, destination), contentType, nil
+}
+
+// interlacedFile returns the destination and content type of the
+// interlaced copy of the PNG image of the picture, writing it on the first
+// request. The copy is kept apart from the picture file, which is served
+// as it is without ?interlace=true. The other formats are served as they
+// are. The copy drops the ancillary chunks, such as the ICC profile.
+func (s *picturesService) interlacedFile(picture *db.Picture) (string, string, error) {
+	destination, contentType := imageFile(picture)
+	// the previews of PDFs stay in the hot tier
+	if destination == picture.Destination {
+		if err := checkRestored(s.storage, picture); err != nil {
+			return "", "", err
+		}
+	}
+
+	if contentType != "image/png" {
+		return destination, contentType, nil
+	}
+	if picture.InterlacedDestination != "" {
+		return picture.InterlacedDestination, contentType, nil
+	}
+
+	data, err := s.storage.Get(destination)
+	if err != nil {
+		return "", "", err
+	}
+
+	// interlaced uploads are their own interlaced copy
+	interlacedDestination := destination
+	if !imaging.IsInterlacedPNG(data) {
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return "", "", err
+		}
+
+		var buffer bytes.Buffer
+		if err := imaging.EncodeInterlacedPNG(&buffer, decoded); err != nil {
+			return "", "", err
+		}
+
+		saved, saveError := s.storage.SaveReader(context.Background(), "interlaced.png", &buffer)
+		if saveError != nil {
+			return "", "", saveError.Error
+		}
+		interlacedDestination = saved.Destination
+	}
+
+	if err := s.repository.UpdateInterlacedDestination(int(picture.ID), interlacedDestination); err != nil {
+		return "", "", err
+	}
+	if interlacedDestination != destination {
+		rollOut(s.storage, picture.ID, interlacedDestination)
+	}
+	picture.InterlacedDestination = interlacedDestination
+	return interlacedDestination, contentType, nil
+}
@@ -0,0 +1,577 @@
+package service
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"sync"
+
+	"imagenexus/db"
+	"imagenexus/deepzoom"
+	"imagenexus/dto"
+	"imagenexus/storage"
+)
+
+var ErrListingNotSupported = errors.New("the storage backend can't list its files")
+
+var ErrWritingNotSupported = errors.New("the storage backend can't write files at a given destination")
+
+var ErrHashedPrefixesDisabled = errors.New("storage.s3.hashedPrefixes is disabled")
+
+// maintenanceBatchSize is the number of pictures read from the database at a
+// time.
+const maintenanceBatchSize = 500
+
+// MaintenanceService runs the administration tasks of cmd/admin, which
+// compare or clean up the database and the storage directly. The pictures
+// uploaded while they run may be left out.
+type MaintenanceService interface {
+	Reconcile() (*dto.ReconcileReport, error)
+	PurgeDeleted(bool) (*dto.PurgeReport, error)
+	ReprocessAll(int, bool) (*dto.ReprocessReport, error)
+	Stats() (*dto.StorageStats, error)
+	MigrateHashedPrefixes(bool) (*dto.PrefixMigrationReport, error)
+}
+
+type maintenanceService struct {
+	repository db.PicturesRepository
+	storage    storage.ImageStorage
+	// nil when video uploads are disabled
+	videos     storage.VideoStorage
+	processing ProcessingService
+}
+
+func NewMaintenanceService(repository db.PicturesRepository, imageStorage storage.ImageStorage, videos storage.VideoStorage, processing ProcessingService) MaintenanceService {
+	return &maintenanceService{repository, imageStorage, videos, processing}
+}
+
+// eachPicture calls fn with every live picture, or every soft deleted one,
+// by id.
+func (s *maintenanceService) eachPicture(deleted bool, fn func(*db.Picture) error) error {
+	var afterId uint
+	for {
+		pictures, err := s.repository.GetAfter(deleted, afterId, maintenanceBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(pictures) == 0 {
+			return nil
+		}
+
+		for _, eachPicture := range pictures {
+			if err := fn(eachPicture); err != nil {
+				return err
+			}
+		}
+		afterId = pictures[len(pictures)-1].ID
+	}
+}
+
+func listFiles(s storage.ImageStorage) (map[string]int64, error) {
+	lister, ok := storage.Capability[storage.FileLister](s)
+	if !ok {
+		return nil, ErrListingNotSupported
+	}
+
+	files := map[string]int64{}
+	err := lister.ListFiles(func(file *storage.StoredFile) error {
+		files[file.Destination] = file.Size
+		return nil
+	})
+	return files, err
+}
+
+// Reconcile finds the files of the live pictures missing from the storage
+// and the stored files of no picture. The files of the soft deleted
+// pictures, and of the previous versions, aren't orphaned until the
+// pictures are purged.
+func (s *maintenanceService) Reconcile() (*dto.ReconcileReport, error) {
+	stored, err := listFiles(s.storage)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dto.ReconcileReport{Files: len(stored), Missing: []*dto.MissingFile{}, Orphaned: []string{}}
+	referenced := map[string]bool{}
+	for _, deleted := range []bool{false, true} {
+		if err := s.keepVersionFiles(referenced, deleted); err != nil {
+			return nil, err
+		}
+	}
+	pictureIds := map[int]bool{}
+	err = s.eachPicture(false, func(picture *db.Picture) error {
+		report.Pictures++
+		pictureIds[int(picture.ID)] = true
+		for _, destination := range picture.ImageFiles() {
+			referenced[destination] = true
+			if _, ok := stored[destination]; !ok {
+				report.Missing = append(report.Missing, &dto.MissingFile{PictureId: picture.ID, Destination: destination})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.eachPicture(true, func(picture *db.Picture) error {
+		pictureIds[int(picture.ID)] = true
+		for _, destination := range picture.ImageFiles() {
+			referenced[destination] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for destination := range stored {
+		if referenced[destination] {
+			continue
+		}
+		if id, ok := tilesetPictureId(destination); ok && pictureIds[id] {
+			continue
+		}
+		report.Orphaned = append(report.Orphaned, destination)
+	}
+	slices.Sort(report.Orphaned)
+	return report, nil
+}
+
+// PurgeDeleted removes the soft deleted pictures for good, along with their
+// files, videos and tile sets. The content addressed files are shared by the
+// pictures with the same contents, they're deleted along with the last
+// picture referring to them, see db.PicturesRepository.Purge. The row of a
+// picture is kept when one of its files can't be deleted, so that the purge
+// can be run again. The dry runs list the files of no live picture, nor of a
+// deleted one purged later.
+func (s *maintenanceService) PurgeDeleted(dryRun bool) (*dto.PurgeReport, error) {
+	var deleted []*db.Picture
+	err := s.eachPicture(true, func(picture *db.Picture) error {
+		deleted = append(deleted, picture)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tilesets := listTilesets(s.storage)
+	report := &dto.PurgeReport{DryRun: dryRun, Pictures: []uint{}, Files: []string{}}
+	if dryRun {
+		return s.listPurged(report, deleted, tilesets)
+	}
+
+	for _, picture := range deleted {
+		files, videos, err := s.purge(picture, tilesets[int(picture.ID)])
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("picture %d: %v", picture.ID, err))
+			continue
+		}
+
+		report.Pictures = append(report.Pictures, picture.ID)
+		report.Files = append(report.Files, files...)
+		report.Videos = append(report.Videos, videos...)
+	}
+	return report, nil
+}
+
+// listPurged reports the pictures and files a purge would remove.
+func (s *maintenanceService) listPurged(report *dto.PurgeReport, deleted []*db.Picture, tilesets map[int][]string) (*dto.PurgeReport, error) {
+	kept := map[string]bool{}
+	keptVideos := map[string]bool{}
+	err := s.eachPicture(false, func(picture *db.Picture) error {
+		keepFiles(kept, picture)
+		keptVideos[picture.VideoDestination] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.keepVersionFiles(kept, false); err != nil {
+		return nil, err
+	}
+	versionFiles, err := s.repository.GetVersionFiles(true)
+	if err != nil {
+		return nil, err
+	}
+
+	// the files shared by several deleted pictures go with the last one
+	files := map[uint][]string{}
+	left := map[string]int{}
+	leftVideos := map[string]int{}
+	for _, picture := range deleted {
+		files[picture.ID] = picture.ImageFiles()
+		for _, destination := range versionFiles[picture.ID] {
+			if !slices.Contains(files[picture.ID], destination) {
+				files[picture.ID] = append(files[picture.ID], destination)
+			}
+		}
+		for _, destination := range files[picture.ID] {
+			left[destination]++
+		}
+		leftVideos[picture.VideoDestination]++
+	}
+
+	for _, picture := range deleted {
+		for _, destination := range files[picture.ID] {
+			left[destination]--
+			if left[destination] == 0 && !kept[destination] {
+				report.Files = append(report.Files, destination)
+			}
+		}
+		report.Files = append(report.Files, tilesets[int(picture.ID)]...)
+
+		leftVideos[picture.VideoDestination]--
+		if picture.VideoDestination != "" && leftVideos[picture.VideoDestination] == 0 && !keptVideos[picture.VideoDestination] {
+			report.Videos = append(report.Videos, picture.VideoDestination)
+		}
+		report.Pictures = append(report.Pictures, picture.ID)
+	}
+	return report, nil
+}
+
+// purge deletes the tile set of the picture, then its row along with the
+// files no other picture refers to, returning the deleted files and videos.
+func (s *maintenanceService) purge(picture *db.Picture, tileset []string) ([]string, []string, error) {
+	files := []string{}
+	for _, destination := range tileset {
+		if err := s.storage.Delete(destination); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+		files = append(files, destination)
+	}
+
+	var videos []string
+	err := s.repository.Purge(int(picture.ID), func(file db.PictureFile) error {
+		if err := s.deleteFile(file); err != nil {
+			return err
+		}
+		if file.Video {
+			videos = append(videos, file.Destination)
+		} else {
+			files = append(files, file.Destination)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, videos, nil
+}
+
+// deleteFile deletes the file from the image storage, or the video storage,
+// unless it's already gone.
+func (s *maintenanceService) deleteFile(file db.PictureFile) error {
+	var err error
+	if !file.Video {
+		err = s.storage.Delete(file.Destination)
+	} else if s.videos == nil {
+		return ErrVideosDisabled
+	} else {
+		err = s.videos.Delete(file.Destination)
+	}
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// listTilesets finds the files of the tile sets by picture, when the storage
+// can list its files.
+func listTilesets(images storage.ImageStorage) map[int][]string {
+	tilesets := map[int][]string{}
+	if stored, err := listFiles(images); err == nil {
+		for destination := range stored {
+			if id, ok := tilesetPictureId(destination); ok {
+				tilesets[id] = append(tilesets[id], destination)
+			}
+		}
+	}
+	return tilesets
+}
+
+// ReprocessAll runs every live picture through the processing pipeline,
+// with the given number of workers, and waits for them to finish.
+func (s *maintenanceService) ReprocessAll(workers int, dryRun bool) (*dto.ReprocessReport, error) {
+	var ids []int
+	err := s.eachPicture(false, func(picture *db.Picture) error {
+		ids = append(ids, int(picture.ID))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &dto.ReprocessReport{DryRun: dryRun, Total: len(ids)}
+	if dryRun {
+		return report, nil
+	}
+
+	queue := make(chan int)
+	var lock sync.Mutex
+	var wait sync.WaitGroup
+	for range max(1, workers) {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for id := range queue {
+				_, err := s.processing.Process(id)
+
+				lock.Lock()
+				if err != nil {
+					report.Failed++
+					report.Errors = append(report.Errors, fmt.Sprintf("picture %d: %v", id, err))
+				} else {
+					report.Succeeded++
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, id := range ids {
+		queue <- id
+	}
+	close(queue)
+	wait.Wait()
+
+	slices.Sort(report.Errors)
+	return report, nil
+}
+
+// Stats counts the pictures and the bytes of their uploaded files by content
+// type, and the files of the storage.
+func (s *maintenanceService) Stats() (*dto.StorageStats, error) {
+	stored, err := listFiles(s.storage)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &dto.StorageStats{ContentTypes: []*dto.ContentTypeUsage{}, Files: len(stored)}
+	for _, size := range stored {
+		stats.Bytes += size
+	}
+
+	usage := map[string]*dto.ContentTypeUsage{}
+	err = s.eachPicture(false, func(picture *db.Picture) error {
+		stats.Pictures++
+		contentType, ok := usage[picture.ContentType]
+		if !ok {
+			contentType = &dto.ContentTypeUsage{ContentType: picture.ContentType}
+			usage[picture.ContentType] = contentType
+			stats.ContentTypes = append(stats.ContentTypes, contentType)
+		}
+		contentType.Pictures++
+		contentType.Bytes += int64(picture.Size)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(stats.ContentTypes, func(a, b *dto.ContentTypeUsage) int {
+		return cmp.Or(cmp.Compare(b.Bytes, a.Bytes), cmp.Compare(a.ContentType, b.ContentType))
+	})
+
+	_, deletedCount, err := s.repository.GetDeleted(1, 1)
+	if err != nil {
+		return nil, err
+	}
+	stats.DeletedPictures = int(deletedCount)
+	return stats, nil
+}
+
+// MigrateStorage copies every file of a storage backend to another at the
+// same destination, so the pictures of the database find them once the
+// storage.backend setting is switched. The files already in the target with
+// the same size are skipped, so an interrupted migration can be resumed.
+// The videos are left in their own storage.
+func MigrateStorage(from, to storage.ImageStorage, dryRun bool) (*dto.MigrationReport, error) {
+	lister, ok := storage.Capability[storage.FileLister](from)
+	if !ok {
+		return nil, ErrListingNotSupported
+	}
+	writer, ok := storage.Capability[storage.FileWriter](to)
+	if !ok {
+		return nil, ErrWritingNotSupported
+	}
+
+	existing, err := listFiles(to)
+	if err != nil && !errors.Is(err, ErrListingNotSupported) {
+		return nil, err
+	}
+
+	report := &dto.MigrationReport{DryRun: dryRun}
+	err = lister.ListFiles(func(file *storage.StoredFile) error {
+		if size, ok := existing[file.Destination]; ok && size == file.Size {
+			report.Skipped++
+			return nil
+		}
+
+		if !dryRun {
+			if err := copyFile(from, writer, file.Destination, file.Destination); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", file.Destination, err))
+				return nil
+			}
+		}
+		report.Copied++
+		report.Bytes += file.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// copyFile copies the file at the source destination of a storage to the
+// destination of another, or of the same one.
+func copyFile(from storage.ImageStorage, to storage.FileWriter, source, destination string) error {
+	reader, contentType, err := from.GetStream(source)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// the descriptors of the tile sets are detected as plain XML
+	if strings.HasSuffix(destination, ".dzi") {
+		contentType = deepzoom.ContentType
+	}
+	return to.Put(destination, contentType, reader)
+}
+
+// MigrateHashedPrefixes moves the files of the pictures saved before
+// storage.s3.hashedPrefixes was enabled under their hashed prefixes, see
+// storage.HashedDestination, and saves their new destinations. The files
+// are copied first and removed once no picture refers to them anymore, so
+// the files shared by several pictures are moved once. The files already
+// under a hashed prefix are skipped, so an interrupted migration can be run
+// again.
+func (s *maintenanceService) MigrateHashedPrefixes(dryRun bool) (*dto.PrefixMigrationReport, error) {
+	prefixer, ok := storage.Capability[storage.HashedPrefixer](s.storage)
+	if !ok || !prefixer.HashedPrefixes() {
+		return nil, ErrHashedPrefixesDisabled
+	}
+	writer, ok := storage.Capability[storage.FileWriter](s.storage)
+	if !ok {
+		return nil, ErrWritingNotSupported
+	}
+
+	report := &dto.PrefixMigrationReport{DryRun: dryRun}
+	// the new destinations of the moved files, and the former ones still
+	// referred to by the pictures that couldn't be updated or by the previous
+	// versions, which keep their destinations
+	moved := map[string]string{}
+	kept := map[string]bool{}
+	for _, deleted := range []bool{false, true} {
+		if err := s.keepVersionFiles(kept, deleted); err != nil {
+			return nil, err
+		}
+	}
+	migrate := func(picture *db.Picture) error {
+		updated := *picture
+		changed := false
+		for _, destination := range []*string{&updated.Destination, &updated.ThumbnailDestination, &updated.AnimatedThumbnailDestination, &updated.InterlacedDestination} {
+			if *destination == "" || storage.HasHashedPrefix(*destination) {
+				continue
+			}
+
+			target, ok := moved[*destination]
+			if !ok {
+				var err error
+				if target, err = s.copyToHashedPrefix(writer, *destination, dryRun); err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("picture %d: %s: %v", picture.ID, *destination, err))
+					keepFiles(kept, picture)
+					return nil
+				}
+				moved[*destination] = target
+				report.Files++
+			}
+			*destination = target
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+
+		if !dryRun {
+			if err := s.repository.UpdateDestinations(&updated); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("picture %d: %v", picture.ID, err))
+				keepFiles(kept, picture)
+				return nil
+			}
+		}
+		report.Pictures++
+		return nil
+	}
+
+	for _, deleted := range []bool{false, true} {
+		if err := s.eachPicture(deleted, migrate); err != nil {
+			return nil, err
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for former := range moved {
+		if kept[former] {
+			continue
+		}
+		if err := s.storage.Delete(former); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", former, err))
+			continue
+		}
+		report.Removed++
+	}
+	return report, nil
+}
+
+// copyToHashedPrefix copies the file under the hashed prefix of its
+// contents, returning its new destination.
+func (s *maintenanceService) copyToHashedPrefix(writer storage.FileWriter, destination string, dryRun bool) (string, error) {
+	reader, _, err := s.storage.GetStream(destination)
+	if err != nil {
+		return "", err
+	}
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, reader)
+	reader.Close()
+	if err != nil {
+		return "", err
+	}
+
+	target := storage.HashedDestination(destination, hex.EncodeToString(hasher.Sum(nil)))
+	if dryRun {
+		return target, nil
+	}
+	return target, copyFile(s.storage, writer, destination, target)
+}
+
+func keepFiles(kept map[string]bool, picture *db.Picture) {
+	for _, destination := range picture.ImageFiles() {
+		kept[destination] = true
+	}
+}
+
+// keepVersionFiles adds the files of the previous versions of the live
+// pictures, or of the soft deleted ones, to kept.
+func (s *maintenanceService) keepVersionFiles(kept map[string]bool, deleted bool) error {
+	files, err := s.repository.GetVersionFiles(deleted)
+	if err != nil {
+		return err
+	}
+	for _, destinations := range files {
+		for _, destination := range destinations {
+			kept[destination] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	processing := NewProcessingService(repo, images, webhook.NewDispatcher(nil, ""), 200)
+	svc := NewMaintenanceService(repo, images, nil, processing)
+
+	// the third picture has the contents, and so the file, of the first one
+	ids := []int{}
+	for _, eachSize := range []int{4, 5, 4} {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(eachSize, eachSize).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		ids = append(ids, int(created.Id))
+	}
+	live, deleted, shared := repo.data[ids[0]], repo.data[ids[1]], repo.data[ids[2]]
+	assert.Equal(t, live.Destination, shared.Destination)
+	deleted.Deleted = true
+	shared.Deleted = true
+	live.ThumbnailDestination = "missing.jpg"
+
+	writer := images.(storage.FileWriter)
+	assert.Nil(t, writer.Put("orphan.png", "image/png", strings.NewReader("orphan")))
+	assert.Nil(t, writer.Put(tilesetDescriptor(ids[1]), "application/xml", strings.NewReader("<Image/>")))
+
+	reconciled, err := svc.Reconcile()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, reconciled.Pictures)
+		assert.Equal(t, 4, reconciled.Files)
+		if assert.Len(t, reconciled.Missing, 1) {
+			assert.Equal(t, "missing.jpg", reconciled.Missing[0].Destination)
+		}
+		assert.Equal(t, []string{"orphan.png"}, reconciled.Orphaned)
+	}
+
+	purged, err := svc.PurgeDeleted(true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []uint{deleted.ID, shared.ID}, purged.Pictures)
+		assert.Equal(t, []string{deleted.Destination, tilesetDescriptor(ids[1])}, purged.Files)
+	}
+	assert.Len(t, repo.data, 3)
+
+	purged, err = svc.PurgeDeleted(false)
+	if assert.Nil(t, err) {
+		assert.Empty(t, purged.Errors)
+		assert.Len(t, purged.Pictures, 2)
+	}
+	assert.Len(t, repo.data, 1)
+	_, err = images.Get(deleted.Destination)
+	assert.NotNil(t, err)
+	_, err = images.Get(live.Destination)
+	assert.Nil(t, err)
+
+	stats, err := svc.Stats()
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, stats.Pictures)
+		assert.Equal(t, 0, stats.DeletedPictures)
+		assert.Equal(t, 2, stats.Files)
+		if assert.Len(t, stats.ContentTypes, 1) {
+			assert.Equal(t, "image/png", stats.ContentTypes[0].ContentType)
+			assert.Equal(t, int64(live.Size), stats.ContentTypes[0].Bytes)
+		}
+	}
+
+	reprocessed, err := svc.ReprocessAll(2, true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, reprocessed.Total)
+		assert.Equal(t, 0, reprocessed.Succeeded)
+	}
+	reprocessed, err = svc.ReprocessAll(2, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, reprocessed.Succeeded)
+		assert.NotEqual(t, "missing.jpg", live.ThumbnailDestination)
+	}
+
+	target := storage.NewStorage(t.TempDir())
+	migrated, err := MigrateStorage(images, target, false)
+	if assert.Nil(t, err) {
+		assert.Empty(t, migrated.Errors)
+		assert.Equal(t, 3, migrated.Copied)
+	}
+	data, err := target.Get(live.Destination)
+	if assert.Nil(t, err) {
+		original, _ := images.Get(live.Destination)
+		assert.Equal(t, original, data)
+	}
+
+	migrated, err = MigrateStorage(images, target, false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, migrated.Copied)
+		assert.Equal(t, 3, migrated.Skipped)
+	}
+}
+
+// uploadingRepository uploads a picture with the contents of the deleted
+// ones once they're listed, as a client would while the purge runs.
+type uploadingRepository struct {
+	*fakeRepository
+	upload func()
+}
+
+func (r *uploadingRepository) GetAfter(deleted bool, afterId uint, limit int) ([]*db.Picture, error) {
+	pictures, err := r.fakeRepository.GetAfter(deleted, afterId, limit)
+	if deleted && len(pictures) > 0 && r.upload != nil {
+		r.upload()
+		r.upload = nil
+	}
+	return pictures, err
+}
+
+func TestPurgeDeletedUploadedMeanwhile(t *testing.T) {
+	repo := &uploadingRepository{fakeRepository: NewFakeRepository()}
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewMaintenanceService(repo, images, nil, nil)
+
+	created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(4, 4).Bytes()), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	deleted := repo.data[int(created.Id)]
+	deleted.Deleted = true
+
+	var uploaded *dto.PictureResponse
+	repo.upload = func() {
+		uploaded, createError = pictures.Create(context.Background(), utils.NewTestFileWithContent("again.png", newTestPNG(4, 4).Bytes()), "")
+	}
+
+	purged, err := svc.PurgeDeleted(false)
+	if assert.Nil(t, err) && assert.Nil(t, createError) {
+		assert.Equal(t, []uint{deleted.ID}, purged.Pictures)
+		assert.Empty(t, purged.Files)
+	}
+	if assert.NotNil(t, uploaded) {
+		assert.Equal(t, deleted.Destination, repo.data[int(uploaded.Id)].Destination)
+	}
+	_, err = images.Get(deleted.Destination)
+	assert.Nil(t, err)
+}
+
+func TestPurgeDeletedVersions(t *testing.T) {
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewMaintenanceService(repo, images, nil, nil)
+
+	// the file of the first version of the updated picture is also the one of
+	// the deleted picture
+	updated, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(4, 4).Bytes()), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	first := repo.data[int(updated.Id)].Destination
+	_, updateError := pictures.Update(context.Background(), int(updated.Id), utils.NewTestFileWithContent("picture.png", newTestPNG(5, 5).Bytes()), 0)
+	if !assert.Nil(t, updateError) {
+		return
+	}
+	second := repo.data[int(updated.Id)].Destination
+	created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("again.png", newTestPNG(4, 4).Bytes()), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	deleted := repo.data[int(created.Id)]
+	assert.Equal(t, first, deleted.Destination)
+	deleted.Deleted = true
+
+	reconciled, err := svc.Reconcile()
+	if assert.Nil(t, err) {
+		assert.Empty(t, reconciled.Orphaned)
+	}
+
+	purged, err := svc.PurgeDeleted(false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []uint{deleted.ID}, purged.Pictures)
+		assert.Empty(t, purged.Files)
+	}
+	_, err = images.Get(first)
+	assert.Nil(t, err)
+
+	repo.data[int(updated.Id)].Deleted = true
+	purged, err = svc.PurgeDeleted(true)
+	if assert.Nil(t, err) {
+		assert.ElementsMatch(t, []string{first, second}, purged.Files)
+	}
+	purged, err = svc.PurgeDeleted(false)
+	if assert.Nil(t, err) {
+		assert.ElementsMatch(t, []string{first, second}, purged.Files)
+	}
+	assert.Empty(t, repo.versions)
+}
+
+// hashedStorage is a local storage migrated as an S3 storage with
+// storage.s3.hashedPrefixes enabled.
+type hashedStorage struct {
+	storage.ImageStorage
+}
+
+func (s *hashedStorage) Unwrap() storage.ImageStorage {
+	return s.ImageStorage
+}
+
+func (s *hashedStorage) HashedPrefixes() bool {
+	return true
+}
+
+func TestMigrateHashedPrefixes(t *testing.T) {
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+
+	_, err := NewMaintenanceService(repo, images, nil, nil).MigrateHashedPrefixes(false)
+	assert.ErrorIs(t, err, ErrHashedPrefixesDisabled)
+
+	// the second picture shares the file of the first one
+	ids := []int{}
+	for _, eachSize := range []int{4, 4, 5} {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(eachSize, eachSize).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		ids = append(ids, int(created.Id))
+	}
+	first, shared, deleted := repo.data[ids[0]], repo.data[ids[1]], repo.data[ids[2]]
+	deleted.Deleted = true
+	former := first.Destination
+
+	svc := NewMaintenanceService(repo, &hashedStorage{images}, nil, nil)
+	migrated, err := svc.MigrateHashedPrefixes(true)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 3, migrated.Pictures)
+		assert.Equal(t, 2, migrated.Files)
+	}
+	assert.Equal(t, former, first.Destination)
+
+	migrated, err = svc.MigrateHashedPrefixes(false)
+	if assert.Nil(t, err) {
+		assert.Empty(t, migrated.Errors)
+		assert.Equal(t, 2, migrated.Files)
+		assert.Equal(t, 2, migrated.Removed)
+	}
+	assert.Equal(t, storage.HashedDestination(former, first.Checksum), first.Destination)
+	assert.Equal(t, first.Destination, shared.Destination)
+	assert.True(t, storage.HasHashedPrefix(deleted.Destination))
+
+	data, err := images.Get(first.Destination)
+	if assert.Nil(t, err) {
+		assert.Equal(t, newTestPNG(4, 4).Bytes(), data)
+	}
+	_, err = images.Get(former)
+	assert.NotNil(t, err)
+
+	migrated, err = svc.MigrateHashedPrefixes(false)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 0, migrated.Pictures)
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"fmt"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+)
+
+// ModerationService reviews the flagged pictures, and the new ones when
+// server.requireModeration is set.
+type ModerationService interface {
+	FlagPicture(int, string) (*dto.PictureResponse, error)
+	ApprovePicture(int) (*dto.PictureResponse, error)
+	RejectPicture(int) (*dto.PictureResponse, error)
+	ListQueue(int, int) ([]*dto.PictureResponse, int, error)
+}
+
+type moderationService struct {
+	repository db.PicturesRepository
+}
+
+func NewModerationService(repository db.PicturesRepository) ModerationService {
+	return &moderationService{repository}
+}
+
+// uploadModerationStatus is the status of the new and replaced pictures.
+func uploadModerationStatus() string {
+	if config.GetConfigBool("server.requireModeration") {
+		return db.ModerationPending
+	}
+	return db.ModerationApproved
+}
+
+// FlagPicture puts the picture back in the moderation queue for the reason.
+func (s *moderationService) FlagPicture(id int, reason string) (*dto.PictureResponse, error) {
+	if _, err := s.getPicture(id); err != nil {
+		return nil, err
+	}
+	return s.updateModeration(id, db.ModerationPending, reason)
+}
+
+// ApprovePicture takes the picture out of the queue, keeping the reason it
+// was flagged for.
+func (s *moderationService) ApprovePicture(id int) (*dto.PictureResponse, error) {
+	picture, err := s.getPicture(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateModeration(id, db.ModerationApproved, picture.ModerationReason)
+}
+
+// RejectPicture takes the picture out of the queue and stops serving its
+// files.
+func (s *moderationService) RejectPicture(id int) (*dto.PictureResponse, error) {
+	picture, err := s.getPicture(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.updateModeration(id, db.ModerationRejected, picture.ModerationReason)
+}
+
+func (s *moderationService) getPicture(id int) (*db.Picture, error) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPictureNotFound, err)
+	}
+	return picture, nil
+}
+
+func (s *moderationService) updateModeration(id int, status, reason string) (*dto.PictureResponse, error) {
+	picture, err := s.repository.UpdateModeration(id, status, reason)
+	if err != nil {
+		return nil, err
+	}
+	return picture.ToPictureResponse(), nil
+}
+
+// ListQueue lists the pending pictures, the longest waiting first.
+func (s *moderationService) ListQueue(limit, page int) ([]*dto.PictureResponse, int, error) {
+	pictures, totalCount, err := s.repository.GetByModerationStatus(db.ModerationPending, limit, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response := make([]*dto.PictureResponse, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		response = append(response, eachPicture.ToPictureResponse())
+	}
+	return response, int(totalCount), nil
+}
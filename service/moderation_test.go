@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/iiif"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeration(t *testing.T) {
+	viper.Set("server.requireModeration", true)
+	defer viper.Set("server.requireModeration", false)
+
+	repo := NewFakeRepository()
+	pictures := NewPicturesService(repo, NewFakeStorage(), NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewModerationService(repo)
+
+	ids := []int{}
+	for range 3 {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFile(utils.NewUniqueString()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		assert.Equal(t, db.ModerationPending, created.ModerationStatus)
+		ids = append(ids, int(created.Id))
+	}
+
+	queue, totalCount, err := svc.ListQueue(2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, totalCount)
+	assert.Len(t, queue, 2)
+
+	approved, err := svc.ApprovePicture(ids[0])
+	assert.Nil(t, err)
+	assert.Equal(t, db.ModerationApproved, approved.ModerationStatus)
+
+	rejected, err := svc.RejectPicture(ids[1])
+	assert.Nil(t, err)
+	assert.Equal(t, db.ModerationRejected, rejected.ModerationStatus)
+
+	_, _, _, err = pictures.GetFileReader(ids[1])
+	assert.ErrorIs(t, err, ErrPictureRejected)
+	_, _, _, err = pictures.GetThumbnailReader(ids[1])
+	assert.ErrorIs(t, err, ErrPictureRejected)
+	// pending pictures are served until they're rejected
+	_, _, err = pictures.GetFile(ids[2])
+	assert.Nil(t, err)
+
+	flagged, err := svc.FlagPicture(ids[0], "not a cat")
+	assert.Nil(t, err)
+	assert.Equal(t, db.ModerationPending, flagged.ModerationStatus)
+	assert.Equal(t, "not a cat", flagged.ModerationReason)
+
+	queue, totalCount, err = svc.ListQueue(10, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, totalCount)
+	assert.Equal(t, []uint{uint(ids[0]), uint(ids[2])}, []uint{queue[0].Id, queue[1].Id})
+
+	approved, err = svc.ApprovePicture(ids[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "not a cat", approved.ModerationReason)
+
+	_, err = svc.RejectPicture(100)
+	assert.ErrorIs(t, err, ErrPictureNotFound)
+}
+
+func TestModerationNotRequired(t *testing.T) {
+	repo := NewFakeRepository()
+	pictures := NewPicturesService(repo, NewFakeStorage(), NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+
+	created, createError := pictures.Create(context.Background(), utils.NewTestFile(utils.NewUniqueString()), "")
+	if assert.Nil(t, createError) {
+		assert.Equal(t, db.ModerationApproved, created.ModerationStatus)
+	}
+}
+
+func TestRejectedPictureNotCopied(t *testing.T) {
+	repo, imageStorage, pictures := newTestPicturesService(t)
+	rejected := createTestPicture(t, pictures, "rejected.png", newTestPNG(8, 8).Bytes())
+	served := createTestPicture(t, pictures, "served.png", newTestPNG(8, 8).Bytes())
+	repo.data[int(rejected.Id)].ModerationStatus = db.ModerationRejected
+	id := int(rejected.Id)
+
+	iiifService := NewIIIFService(repo, imageStorage)
+	collections := NewCollectionsService(NewFakeCollectionsRepository(repo), repo, imageStorage, pictures)
+	collection, err := collections.Create(&dto.CollectionRequest{Name: "rejected"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = collections.AddPicture(int(collection.Id), id)
+	assert.Nil(t, err)
+
+	request, _ := iiif.ParseRequest("full", "max", "0", "default.png")
+	cases := map[string]func() *dto.InvalidPictureFileError{
+		"iiif image": func() *dto.InvalidPictureFileError {
+			_, _, renderError := iiifService.Render(id, request)
+			return renderError
+		},
+		"list frames": func() *dto.InvalidPictureFileError {
+			_, listError := pictures.ListFrames(id)
+			return listError
+		},
+		"frame": func() *dto.InvalidPictureFileError {
+			_, frameError := pictures.GetFrame(id, 0)
+			return frameError
+		},
+		"save frame": func() *dto.InvalidPictureFileError {
+			_, saveError := pictures.SaveFrame(id, 0)
+			return saveError
+		},
+		"alpha": func() *dto.InvalidPictureFileError {
+			_, alphaError := pictures.ChangeAlpha(id, AlphaStrip)
+			return alphaError
+		},
+		"grayscale": func() *dto.InvalidPictureFileError {
+			_, toneError := pictures.ChangeTone(id, ToneGrayscale)
+			return toneError
+		},
+		"adjust": func() *dto.InvalidPictureFileError {
+			_, adjustError := pictures.Adjust(id, &dto.Adjustments{Brightness: 0.5})
+			return adjustError
+		},
+		"equalize": func() *dto.InvalidPictureFileError {
+			_, equalizeError := pictures.Equalize(id)
+			return equalizeError
+		},
+		"autolevel": func() *dto.InvalidPictureFileError {
+			_, levelError := pictures.AutoLevel(id, false)
+			return levelError
+		},
+		"blur": func() *dto.InvalidPictureFileError {
+			_, blurError := pictures.Blur(id, 2)
+			return blurError
+		},
+		"denoise": func() *dto.InvalidPictureFileError {
+			_, denoiseError := pictures.Denoise(id, 0.5)
+			return denoiseError
+		},
+		"sharpen": func() *dto.InvalidPictureFileError {
+			_, sharpenError := pictures.Sharpen(id, &dto.UnsharpMask{Amount: 1, Radius: 1})
+			return sharpenError
+		},
+		"border": func() *dto.InvalidPictureFileError {
+			_, borderError := pictures.Border(id, &dto.Border{Top: 1})
+			return borderError
+		},
+		"dither": func() *dto.InvalidPictureFileError {
+			_, ditherError := pictures.Dither(id, 2)
+			return ditherError
+		},
+		"composite base": func() *dto.InvalidPictureFileError {
+			_, compositeError := pictures.Composite(&dto.CompositeRequest{BaseId: rejected.Id, OverlayId: served.Id}, true)
+			return compositeError
+		},
+		"composite overlay": func() *dto.InvalidPictureFileError {
+			_, compositeError := pictures.Composite(&dto.CompositeRequest{BaseId: served.Id, OverlayId: rejected.Id}, true)
+			return compositeError
+		},
+		"placeholder": func() *dto.InvalidPictureFileError {
+			_, placeholderError := pictures.GetPlaceholder(id, 16)
+			return placeholderError
+		},
+		"sprite": func() *dto.InvalidPictureFileError {
+			_, spriteError := collections.Sprite(int(collection.Id), 0)
+			return spriteError
+		},
+		"animation": func() *dto.InvalidPictureFileError {
+			_, animateError := collections.Animate(int(collection.Id), 10)
+			return animateError
+		},
+	}
+
+	for name, each := range cases {
+		t.Run(name, func(t *testing.T) {
+			servedError := each()
+			if assert.NotNil(t, servedError) {
+				assert.Equal(t, http.StatusUnavailableForLegalReasons, servedError.StatusCode)
+				assert.ErrorIs(t, servedError.Error, ErrPictureRejected)
+			}
+		})
+	}
+
+	_, err = iiifService.Info(id)
+	assert.ErrorIs(t, err, ErrPictureRejected)
+	assert.Len(t, repo.data, 2)
+}
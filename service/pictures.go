@@ -1,58 +1,292 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"imagenexus/config"
 	"imagenexus/db"
 	"imagenexus/dto"
+	"imagenexus/imaging"
 	"imagenexus/storage"
+	"imagenexus/webhook"
+
+	"github.com/gin-gonic/gin"
 )
 
 type PicturesService interface {
-	Create(*multipart.FileHeader) (*dto.PictureResponse, *dto.InvalidPictureFileError)
-	Update(int, *multipart.FileHeader) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	// ctx of Create, CreateFromBase64 and Update cancels the uploads of
+	// their images, e.g. when the client of the request is gone
+	Create(context.Context, *multipart.FileHeader, string) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	CreateFromReader(string, io.Reader) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	CreateFromBase64(context.Context, *dto.Base64PictureRequest) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	ImportDataURIs([]*dto.DataURIImage) []*dto.ImportResult
+	Update(context.Context, int, *multipart.FileHeader, int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
 	List(int, int) ([]*dto.PictureResponse, int, error)
+	Search(*dto.PictureFilter, int, int) ([]*dto.PictureResponse, int, error)
+	SearchNearby(float64, float64, float64, int, int) ([]*dto.PictureResponse, int, error)
 	Get(int) (*dto.PictureResponse, error)
-	GetFile(int) (string, error)
-	Delete(int) error
+	GetFile(int) (string, string, error)
+	GetFileReader(int) (io.ReadSeekCloser, string, time.Time, error)
+	GetInterlacedFileReader(int) (io.ReadSeekCloser, string, time.Time, error)
+	GetOriginalReader(int) (io.ReadSeekCloser, string, time.Time, error)
+	GetThumbnailReader(int) (io.ReadSeekCloser, string, time.Time, error)
+	GetAnimatedThumbnailReader(int) (io.ReadSeekCloser, time.Time, error)
+	GetICCProfile(int) ([]byte, error)
+	GetXMP(int) (string, error)
+	GetLocation(int) (*dto.PictureLocation, error)
+	GetInternalRedirect(int) (string, string, error)
+	GetInterlacedRedirect(int) (string, string, error)
+	ListFrames(int) ([]*dto.PictureFrame, *dto.InvalidPictureFileError)
+	GetFrame(int, int) ([]byte, *dto.InvalidPictureFileError)
+	SaveFrame(int, int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	ChangeAlpha(int, string) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	ChangeTone(int, string) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Adjust(int, *dto.Adjustments) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Equalize(int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	AutoLevel(int, bool) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Blur(int, int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Denoise(int, float64) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Sharpen(int, *dto.UnsharpMask) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Border(int, *dto.Border) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Dither(int, int) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	Composite(*dto.CompositeRequest, bool) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	CreateTileset(int) (*dto.Tileset, *dto.InvalidPictureFileError)
+	GetTilesetDescriptor(int) ([]byte, error)
+	GetTile(int, int, int, int) (io.ReadSeekCloser, error)
+	CheckSteganography(int) (*dto.StegCheck, *dto.InvalidPictureFileError)
+	GetQuality(int) (*dto.PictureQuality, *dto.InvalidPictureFileError)
+	GetSrcset(int) (*dto.Srcset, error)
+	GetPlaceholder(int, int) (*dto.Placeholder, *dto.InvalidPictureFileError)
+	AddTag(int, string) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	SetRetention(int, *time.Time) (*dto.PictureResponse, *dto.InvalidPictureFileError)
+	ListVersions(int) ([]*dto.PictureVersion, error)
+	GetVersion(int, string) (io.ReadCloser, string, error)
+	Delete(context.Context, int, int) error
 }
 
+var ErrUploadTooLarge = errors.New("the file is too large")
+
+var ErrThumbnailNotReady = errors.New("the thumbnail hasn't been generated yet")
+
+var ErrNoAnimatedThumbnail = errors.New("only the processed GIFs with several frames have an animated thumbnail")
+
+var ErrPictureRejected = errors.New("the picture was rejected by the moderators")
+
+var ErrVideosDisabled = errors.New("video uploads are disabled")
+
+var ErrVersionNotFound = errors.New("the picture has no such version")
+
 type picturesService struct {
 	repository db.PicturesRepository
 	storage    storage.ImageStorage
+	processor  PictureProcessor
+	events     webhook.Dispatcher
+	// nil when video uploads are disabled
+	videos VideoService
+}
+
+func NewPicturesService(repository db.PicturesRepository, storage storage.ImageStorage, processor PictureProcessor, events webhook.Dispatcher, videos VideoService) PicturesService {
+	return &picturesService{repository, storage, processor, events, videos}
+}
+
+// MaxUploadSize is the largest accepted upload in bytes, 0 when unlimited.
+func MaxUploadSize() int64 {
+	return int64(config.GetConfigInt("server.maxUploadSize"))
+}
+
+func checkUploadSize(size int64) *dto.InvalidPictureFileError {
+	return checkSize(size, MaxUploadSize())
 }
 
-func NewPicturesService(repository db.PicturesRepository, storage storage.ImageStorage) PicturesService {
-	return &picturesService{repository, storage}
+func checkSize(size, maxSize int64) *dto.InvalidPictureFileError {
+	if maxSize > 0 && size > maxSize {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Error:      ErrUploadTooLarge,
+			Data:       gin.H{"max_size": maxSize},
+		}
+	}
+	return nil
 }
 
-func (s *picturesService) Create(file *multipart.FileHeader) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
-	requestData, createError := s.storage.Save(file)
+func (s *picturesService) Create(ctx context.Context, file *multipart.FileHeader, description string) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if s.videos != nil {
+		contentType, err := sniffContentType(file)
+		if err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+		if storage.IsVideo(contentType) {
+			return s.createVideo(ctx, file, contentType, description)
+		}
+	}
+
+	if sizeError := checkUploadSize(file.Size); sizeError != nil {
+		return nil, sizeError
+	}
+
+	requestData, createError := s.storage.Save(ctx, file)
 	if createError != nil {
 		return nil, createError
 	}
 
 	requestData.Size = int32(file.Size)
+	requestData.Description = description
+
+	return s.create(ctx, requestData)
+}
+
+// createVideo saves the first frame of the video as the picture, see
+// VideoService.
+func (s *picturesService) createVideo(ctx context.Context, file *multipart.FileHeader, contentType, description string) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if sizeError := checkSize(file.Size, MaxVideoUploadSize()); sizeError != nil {
+		return nil, sizeError
+	}
 
-	picture, err := s.repository.Create(requestData)
+	src, err := file.Open()
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
 		}
 	}
+	defer src.Close()
 
-	return picture.ToPictureResponse(), nil
+	requestData, ingestError := s.videos.Ingest(ctx, file.Filename, contentType, src)
+	if ingestError != nil {
+		return nil, ingestError
+	}
+
+	requestData.Size = int32(file.Size)
+	requestData.Description = description
+
+	return s.create(ctx, requestData)
+}
+
+// CreateFromReader saves a picture generated by the service itself, such as
+// a GIF frame or a sprite sheet.
+func (s *picturesService) CreateFromReader(name string, src io.Reader) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	return s.createFromReader(context.Background(), name, src)
+}
+
+func (s *picturesService) createFromReader(ctx context.Context, name string, src io.Reader) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	requestData, saveError := s.storage.SaveReader(ctx, name, src)
+	if saveError != nil {
+		return nil, saveError
+	}
+
+	return s.create(ctx, requestData)
+}
+
+// CreateFromBase64 saves a picture sent as a base64 string, such as a data
+// URL from a browser.
+func (s *picturesService) CreateFromBase64(ctx context.Context, request *dto.Base64PictureRequest) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	encoded := request.Data
+	if strings.HasPrefix(encoded, "data:") {
+		_, encoded, _ = strings.Cut(encoded, ",")
+	}
+
+	data, decodeError := decodeBase64(encoded)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	filename := request.Filename
+	if filename == "" {
+		filename = "upload"
+	}
+
+	return s.createFromReader(ctx, path.Base(filename), bytes.NewReader(data))
 }
 
-func (s *picturesService) Update(id int, file *multipart.FileHeader) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
-	requestData, createError := s.storage.Save(file)
+func (s *picturesService) create(ctx context.Context, requestData *dto.PictureRequest) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	requestData.ModerationStatus = uploadModerationStatus()
+
+	if previewError := s.savePreview(ctx, requestData); previewError != nil {
+		return nil, previewError
+	}
+	if err := resetTier(s.repository, s.storage, requestData.Destination); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	picture, err := s.repository.Create(ctx, requestData)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	rollOut(s.storage, picture.ID, requestData.Destination, requestData.ThumbnailDestination)
+
+	response := picture.ToPictureResponse()
+	s.events.Send(webhook.EventImageCreated, response)
+	s.processor.Enqueue(picture.ID)
+
+	return response, nil
+}
+
+// Update replaces the image of the picture. Unless version is 0, the
+// picture must still be at the version, the update fails with a 409 and the
+// current version otherwise.
+func (s *picturesService) Update(ctx context.Context, id int, file *multipart.FileHeader, version int) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	// check the version before the upload is stored, the repository checks it
+	// again in case of a change in between
+	if version != 0 {
+		current, findError := s.findPicture(id)
+		if findError != nil {
+			return nil, findError
+		}
+		if current.Version != version {
+			return nil, versionConflictError(&db.VersionConflictError{CurrentVersion: current.Version})
+		}
+	}
+
+	requestData, createError := s.storage.Save(ctx, file)
 	if createError != nil {
 		return nil, createError
 	}
 
-	picture, err := s.repository.Update(id, requestData)
+	if previewError := s.savePreview(ctx, requestData); previewError != nil {
+		return nil, previewError
+	}
+	if err := resetTier(s.repository, s.storage, requestData.Destination); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	// the new image needs a review as well, while the status of a rejected
+	// picture is kept when moderation isn't required
+	if uploadModerationStatus() == db.ModerationPending {
+		requestData.ModerationStatus = db.ModerationPending
+	}
+	requestData.StorageTier = db.TierHot
+	requestData.Version = version
+
+	picture, err := s.repository.Update(ctx, id, requestData)
+	if conflict := (*db.VersionConflictError)(nil); errors.As(err, &conflict) {
+		return nil, versionConflictError(conflict)
+	}
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusNotFound,
@@ -60,9 +294,71 @@ func (s *picturesService) Update(id int, file *multipart.FileHeader) (*dto.Pictu
 		}
 	}
 
+	rollOut(s.storage, picture.ID, requestData.Destination, requestData.ThumbnailDestination)
+	s.processor.Enqueue(picture.ID)
+
 	return picture.ToPictureResponse(), nil
 }
 
+// rollOut copies the saved files of the picture to the new storage backend
+// when the picture is in its rollout, see storage.NewFeatureFlagStorage.
+func rollOut(images storage.ImageStorage, pictureId uint, destinations ...string) {
+	if canary, ok := storage.Capability[storage.Canary](images); ok {
+		canary.RollOut(pictureId, destinations...)
+	}
+}
+
+// savePreview stores the first page of PDFs as a PNG, which is served as
+// their image and thumbnail. Other pictures are left as they are.
+func (s *picturesService) savePreview(ctx context.Context, requestData *dto.PictureRequest) *dto.InvalidPictureFileError {
+	if requestData.ContentType != storage.PDFContentType {
+		return nil
+	}
+
+	data, err := s.storage.Get(requestData.Destination)
+	if err != nil {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	preview, err := storage.DecodeImage(data, requestData.ContentType)
+	if err != nil {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+			Data:       gin.H{"format": requestData.ContentType},
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, preview); err != nil {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	saved, saveError := s.storage.SaveReader(ctx, "preview.png", &buffer)
+	if saveError != nil {
+		return saveError
+	}
+
+	requestData.ThumbnailDestination = saved.Destination
+	return nil
+}
+
+// imageFile returns the destination and content type of the file served as
+// the image of the picture: the preview of PDFs, the picture itself
+// otherwise.
+func imageFile(picture *db.Picture) (string, string) {
+	if picture.ContentType == storage.PDFContentType {
+		return picture.ThumbnailDestination, "image/png"
+	}
+	return picture.Destination, picture.ContentType
+}
+
 func (s *picturesService) List(limit, page int) ([]*dto.PictureResponse, int, error) {
 	pictures, totalCount, err := s.repository.GetAll(limit, page)
 	if err != nil {
@@ -76,6 +372,38 @@ func (s *picturesService) List(limit, page int) ([]*dto.PictureResponse, int, er
 	return pictureResponses, int(totalCount), err
 }
 
+// Search lists the pictures matching the filter, e.g. taken within a
+// bounding box.
+func (s *picturesService) Search(filter *dto.PictureFilter, limit, page int) ([]*dto.PictureResponse, int, error) {
+	pictures, totalCount, err := s.repository.Search(filter, limit, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pictureResponses := make([]*dto.PictureResponse, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		pictureResponses = append(pictureResponses, eachPicture.ToPictureResponse())
+	}
+	return pictureResponses, int(totalCount), nil
+}
+
+// SearchNearby lists the pictures taken within radiusKm of the point,
+// nearest first, along with their distances.
+func (s *picturesService) SearchNearby(lat, lon, radiusKm float64, limit, page int) ([]*dto.PictureResponse, int, error) {
+	pictures, totalCount, err := s.repository.GetNearby(lat, lon, radiusKm, limit, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pictureResponses := make([]*dto.PictureResponse, 0, len(pictures))
+	for _, eachPicture := range pictures {
+		response := eachPicture.ToPictureResponse()
+		response.DistanceKm = &eachPicture.DistanceKm
+		pictureResponses = append(pictureResponses, response)
+	}
+	return pictureResponses, int(totalCount), nil
+}
+
 func (s *picturesService) Get(id int) (*dto.PictureResponse, error) {
 	picture, err := s.repository.GetById(id)
 	if err != nil {
@@ -85,16 +413,478 @@ func (s *picturesService) Get(id int) (*dto.PictureResponse, error) {
 	return picture.ToPictureResponse(), nil
 }
 
-func (s *picturesService) GetFile(id int) (string, error) {
+// getServedPicture gets the picture whose files are requested, unless the
+// moderators rejected it.
+func (s *picturesService) getServedPicture(id int) (*db.Picture, error) {
+	return getServedPicture(s.repository, id)
+}
+
+// getServedPicture gets the picture whose files are requested from the
+// repository, for the services serving them besides the pictures service.
+func getServedPicture(repository db.PicturesRepository, id int) (*db.Picture, error) {
+	picture, err := repository.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkServed(picture); err != nil {
+		return nil, err
+	}
+	return picture, nil
+}
+
+// checkServed returns ErrPictureRejected when the moderators rejected the
+// picture, whose files aren't served nor copied anymore.
+func checkServed(picture *db.Picture) error {
+	if picture.ModerationStatus == db.ModerationRejected {
+		return ErrPictureRejected
+	}
+	return nil
+}
+
+// servedPictureError is the error of the picture whose files are
+// requested, unavailable for legal reasons when the moderators rejected it
+// and not found otherwise.
+func servedPictureError(err error) *dto.InvalidPictureFileError {
+	statusCode := http.StatusNotFound
+	if errors.Is(err, ErrPictureRejected) {
+		statusCode = http.StatusUnavailableForLegalReasons
+	}
+	return &dto.InvalidPictureFileError{
+		StatusCode: statusCode,
+		Error:      err,
+	}
+}
+
+// viewRecordInterval is how often the views of a picture are saved, which
+// is precise enough to tell the pictures unviewed for days.
+const viewRecordInterval = time.Hour
+
+// recordView saves when the picture was viewed, see TierService.
+func (s *picturesService) recordView(picture *db.Picture) {
+	now := time.Now().UnixMilli()
+	if now-picture.LastViewedOn < viewRecordInterval.Milliseconds() {
+		return
+	}
+
+	if err := s.repository.RecordView(int(picture.ID), now); err != nil {
+		log.Printf("Unable to record the view of picture %d: %v", picture.ID, err)
+	}
+}
+
+func (s *picturesService) GetFile(id int) (string, string, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	destination, contentType := imageFile(picture)
+	return s.storage.GetFullPath(destination), contentType, nil
+}
+
+// GetFileReader opens the image of the picture for random access along with
+// its content type and modification time, see imageFile. The caller is
+// responsible for closing the reader.
+func (s *picturesService) GetFileReader(id int) (io.ReadSeekCloser, string, time.Time, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	destination, contentType := imageFile(picture)
+	// the previews of PDFs stay in the hot tier
+	if destination == picture.Destination {
+		if err := checkRestored(s.storage, picture); err != nil {
+			return nil, "", time.Time{}, err
+		}
+	}
+
+	reader, err := s.storage.GetReader(destination)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if destination == picture.Destination {
+		if err := s.verifyChecksum(picture, reader); err != nil {
+			reader.Close()
+			return nil, "", time.Time{}, err
+		}
+	}
+
+	s.recordView(picture)
+	return reader, contentType, time.UnixMilli(picture.UpdatedOn), nil
+}
+
+// GetOriginalReader opens the uploaded file itself, e.g. the PDF rather than
+// its preview or the video rather than its first frame. The caller is
+// responsible for closing the reader.
+func (s *picturesService) GetOriginalReader(id int) (io.ReadSeekCloser, string, time.Time, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	if picture.VideoDestination != "" {
+		if s.videos == nil {
+			return nil, "", time.Time{}, ErrVideosDisabled
+		}
+
+		reader, err := s.videos.GetReader(picture.VideoDestination)
+		if err != nil {
+			return nil, "", time.Time{}, err
+		}
+		return reader, picture.VideoContentType, time.UnixMilli(picture.UpdatedOn), nil
+	}
+
+	if err := checkRestored(s.storage, picture); err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	reader, err := s.storage.GetReader(picture.Destination)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	if err := s.verifyChecksum(picture, reader); err != nil {
+		reader.Close()
+		return nil, "", time.Time{}, err
+	}
+
+	s.recordView(picture)
+	return reader, picture.ContentType, time.UnixMilli(picture.UpdatedOn), nil
+}
+
+// GetThumbnailReader opens the thumbnail generated by the processing
+// pipeline, a JPEG, or the PNG preview of PDFs, along with its content type.
+// The caller is responsible for closing the reader.
+func (s *picturesService) GetThumbnailReader(id int) (io.ReadSeekCloser, string, time.Time, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	if picture.ThumbnailDestination == "" {
+		return nil, "", time.Time{}, ErrThumbnailNotReady
+	}
+
+	reader, err := s.storage.GetReader(picture.ThumbnailDestination)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+
+	contentType := "image/jpeg"
+	if picture.ContentType == storage.PDFContentType {
+		contentType = "image/png"
+	}
+	return reader, contentType, time.UnixMilli(picture.ProcessedOn), nil
+}
+
+// GetAnimatedThumbnailReader opens the animated GIF thumbnail generated by
+// the processing pipeline. The caller is responsible for closing the reader.
+func (s *picturesService) GetAnimatedThumbnailReader(id int) (io.ReadSeekCloser, time.Time, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if picture.AnimatedThumbnailDestination == "" {
+		return nil, time.Time{}, ErrNoAnimatedThumbnail
+	}
+
+	reader, err := s.storage.GetReader(picture.AnimatedThumbnailDestination)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return reader, time.UnixMilli(picture.ProcessedOn), nil
+}
+
+// GetICCProfile returns the ICC profile extracted from the picture by the
+// processing pipeline.
+func (s *picturesService) GetICCProfile(id int) ([]byte, error) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(picture.ICCProfile) == 0 {
+		return nil, imaging.ErrNoICCProfile
+	}
+
+	return picture.ICCProfile, nil
+}
+
+// GetXMP returns the XMP packet extracted from the picture by the processing
+// pipeline.
+func (s *picturesService) GetXMP(id int) (string, error) {
 	picture, err := s.repository.GetById(id)
 	if err != nil {
 		return "", err
 	}
 
-	return s.storage.GetFullPath(picture.Destination), nil
+	if picture.XMPData == "" {
+		return "", imaging.ErrNoXMP
+	}
+
+	return picture.XMPData, nil
+}
+
+// GetLocation returns where the picture was taken, or nil when it has no GPS
+// coordinates.
+func (s *picturesService) GetLocation(id int) (*dto.PictureLocation, error) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return picture.ToPictureLocation(), nil
+}
+
+// GetInternalRedirect returns the internal nginx location of the picture file
+// to be used in the X-Accel-Redirect header. The file is refused like by
+// GetFileReader while it's archived or corrupted.
+func (s *picturesService) GetInternalRedirect(id int) (string, string, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return "", "", err
+	}
+
+	destination, contentType := imageFile(picture)
+	// the previews of PDFs stay in the hot tier
+	if destination == picture.Destination {
+		if err := s.checkRedirectedFile(picture); err != nil {
+			return "", "", err
+		}
+	}
+
+	internalPath := config.GetConfigValue("server.xAccelRedirect.internalPath")
+	s.recordView(picture)
+	return path.Join("/", internalPath, destination), contentType, nil
+}
+
+// checkRedirectedFile runs the checks of GetFileReader on the file of the
+// picture served by nginx, reading it only when its checksum is due for a
+// verification.
+func (s *picturesService) checkRedirectedFile(picture *db.Picture) error {
+	if err := checkRestored(s.storage, picture); err != nil {
+		return err
+	}
+	if !checksumVerificationDue(picture, time.Now()) {
+		return nil
+	}
+
+	reader, err := s.storage.GetReader(picture.Destination)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return s.verifyChecksum(picture, reader)
 }
 
-func (s *picturesService) Delete(id int) error {
-	err := s.repository.Delete(id)
+// ListVersions lists the versions of the picture kept by its updates, the
+// current one first. The versions are dated by the update that uploaded
+// them. With storage.s3.versioning, the versions are the ones the bucket
+// kept of the files of the picture, see listObjectVersions.
+func (s *picturesService) ListVersions(id int) ([]*dto.PictureVersion, error) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.repository.GetVersions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if versionManager, ok := s.versionManager(); ok {
+		objectVersions, err := listObjectVersions(versionManager, picture, history)
+		if err != nil {
+			return nil, err
+		}
+
+		versions := make([]*dto.PictureVersion, 0, len(objectVersions))
+		for _, eachVersion := range objectVersions {
+			versions = append(versions, eachVersion.PictureVersion)
+		}
+		return versions, nil
+	}
+
+	versions := make([]*dto.PictureVersion, 0, len(history)+1)
+	uploadedOn := picture.CreatedOn
+	for _, eachVersion := range history {
+		versions = append(versions, &dto.PictureVersion{
+			VersionId:    strconv.Itoa(eachVersion.Version),
+			Size:         int64(eachVersion.Size),
+			LastModified: time.UnixMilli(uploadedOn),
+		})
+		uploadedOn = eachVersion.ReplacedOn
+	}
+	versions = append(versions, &dto.PictureVersion{
+		VersionId:    strconv.Itoa(picture.Version),
+		IsLatest:     true,
+		Size:         int64(picture.Size),
+		LastModified: time.UnixMilli(uploadedOn),
+	})
+
+	slices.Reverse(versions)
+	return versions, nil
+}
+
+// GetVersion opens the file of a version of the picture listed by
+// ListVersions. The caller is responsible for closing the reader.
+func (s *picturesService) GetVersion(id int, versionId string) (io.ReadCloser, string, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if versionManager, ok := s.versionManager(); ok {
+		return s.getObjectVersion(versionManager, picture, versionId)
+	}
+
+	version, err := s.findVersion(picture, versionId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := checkRestored(s.storage, version); err != nil {
+		return nil, "", err
+	}
+
+	stream, _, err := s.storage.GetStream(version.Destination)
+	if err != nil {
+		return nil, "", err
+	}
+	return stream, version.ContentType, nil
+}
+
+// versionManager returns the storage keeping the previous versions of its
+// objects, when storage.s3.versioning is enabled.
+func (s *picturesService) versionManager() (storage.VersionManager, bool) {
+	versionManager, ok := storage.Capability[storage.VersionManager](s.storage)
+	if !ok || !versionManager.Versioning() {
+		return nil, false
+	}
+	return versionManager, true
+}
+
+// objectVersion is a version kept by the bucket of a file of a picture.
+type objectVersion struct {
+	*dto.PictureVersion
+	// the file of the version, with its destination, content type and tier
+	file *db.Picture
+}
+
+// listObjectVersions lists the versions kept by the bucket of the files of
+// the picture, the ones of its current file first, then the ones of the
+// files of its previous versions, the latest first. The updates usually
+// write a new object rather than replacing the previous one, e.g. under the
+// SHA-256 of their contents, so every file of the history is listed.
+func listObjectVersions(versionManager storage.VersionManager, picture *db.Picture, history []*db.PictureVersion) ([]*objectVersion, error) {
+	files := []*db.Picture{picture}
+	for i := len(history) - 1; i >= 0; i-- {
+		eachVersion := history[i]
+		files = append(files, &db.Picture{
+			ID:          picture.ID,
+			Destination: eachVersion.Destination,
+			ContentType: eachVersion.ContentType,
+			StorageTier: eachVersion.StorageTier,
+		})
+	}
+
+	versions := []*objectVersion{}
+	listed := map[string]bool{}
+	for _, eachFile := range files {
+		if listed[eachFile.Destination] {
+			continue
+		}
+		listed[eachFile.Destination] = true
+
+		fileVersions, err := versionManager.ListVersions(eachFile.Destination)
+		if err != nil {
+			return nil, err
+		}
+		for _, eachVersion := range fileVersions {
+			// the latest versions of the previous files aren't the picture's
+			eachVersion.IsLatest = eachVersion.IsLatest && eachFile == picture
+			versions = append(versions, &objectVersion{PictureVersion: eachVersion, file: eachFile})
+		}
+	}
+	return versions, nil
+}
+
+// getObjectVersion opens a version kept by the bucket of a file of the
+// picture, listed by listObjectVersions.
+func (s *picturesService) getObjectVersion(versionManager storage.VersionManager, picture *db.Picture, versionId string) (io.ReadCloser, string, error) {
+	history, err := s.repository.GetVersions(int(picture.ID))
+	if err != nil {
+		return nil, "", err
+	}
+
+	versions, err := listObjectVersions(versionManager, picture, history)
+	if err != nil {
+		return nil, "", err
+	}
+	index := slices.IndexFunc(versions, func(version *objectVersion) bool {
+		return version.VersionId == versionId
+	})
+	if index < 0 {
+		return nil, "", fmt.Errorf("%w: %q", ErrVersionNotFound, versionId)
+	}
+
+	file := versions[index].file
+	if err := checkRestored(s.storage, file); err != nil {
+		return nil, "", err
+	}
+
+	stream, contentType, err := versionManager.GetVersion(file.Destination, versionId)
+	if err != nil {
+		return nil, "", err
+	}
+	if contentType == "" {
+		contentType = file.ContentType
+	}
+	return stream, contentType, nil
+}
+
+// findVersion returns the version of the picture as a picture, the picture
+// itself for its current version.
+func (s *picturesService) findVersion(picture *db.Picture, versionId string) (*db.Picture, error) {
+	number, err := strconv.Atoi(versionId)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrVersionNotFound, versionId)
+	}
+	if number == picture.Version {
+		return picture, nil
+	}
+
+	history, err := s.repository.GetVersions(int(picture.ID))
+	if err != nil {
+		return nil, err
+	}
+	for _, eachVersion := range history {
+		if eachVersion.Version == number {
+			return &db.Picture{
+				ID:          picture.ID,
+				Destination: eachVersion.Destination,
+				ContentType: eachVersion.ContentType,
+				StorageTier: eachVersion.StorageTier,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrVersionNotFound, versionId)
+}
+
+// Delete soft deletes the picture, provided it's still at the version
+// unless version is 0, see db.VersionConflictError.
+func (s *picturesService) Delete(ctx context.Context, id int, version int) error {
+	err := s.repository.Delete(ctx, id, version)
 	return err
 }
+
+// versionConflictError is the 409 of the updates based on another version of
+// the picture, giving the current one.
+func versionConflictError(conflict *db.VersionConflictError) *dto.InvalidPictureFileError {
+	return &dto.InvalidPictureFileError{
+		StatusCode: http.StatusConflict,
+		Error:      conflict,
+		Data:       gin.H{"current_version": conflict.CurrentVersion},
+	}
+}
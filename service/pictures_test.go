@@ -1,24 +1,48 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"imagenexus/db"
 	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
 	"imagenexus/utils"
+	"imagenexus/video"
+	"imagenexus/webhook"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestServiceFunctions(t *testing.T) {
 	repo := NewFakeRepository()
 	storage := NewFakeStorage()
-	svc := NewPicturesService(repo, storage)
+	processor := NewFakeProcessor()
+	svc := NewPicturesService(repo, storage, processor, webhook.NewDispatcher(nil, ""), nil)
 
 	t.Run("create entry", func(t *testing.T) {
 		file := utils.NewTestFile(utils.NewUniqueString())
-		createResponse, errorState := svc.Create(file)
+		createResponse, errorState := svc.Create(context.Background(), file, "")
 		if errorState != nil {
 			assert.NotNil(t, errorState.Error)
 		}
@@ -28,19 +52,56 @@ func TestServiceFunctions(t *testing.T) {
 		assert.Equal(t, existsInStorage, true)
 		assert.Equal(t, createResponse, value.ToPictureResponse())
 
+		assert.Equal(t, []uint{createResponse.Id}, processor.enqueued)
+
 		entryId := int(createResponse.Id)
 		fileResponse, err := svc.Get(entryId)
 		assert.Nil(t, err)
 		assert.Equal(t, fileResponse.Name, createResponse.Name)
 	})
 
+	t.Run("create entry from base64", func(t *testing.T) {
+		data := base64.StdEncoding.EncodeToString([]byte("picture contents"))
+
+		createResponse, errorState := svc.CreateFromBase64(context.Background(), &dto.Base64PictureRequest{Data: data, Filename: "photo.jpg"})
+		assert.Nil(t, errorState)
+		assert.True(t, strings.HasSuffix(createResponse.Name, "photo.jpg"))
+		assert.Equal(t, int32(16), repo.data[int(createResponse.Id)].Size)
+
+		_, errorState = svc.CreateFromBase64(context.Background(), &dto.Base64PictureRequest{Data: "data:image/jpeg;base64," + data})
+		assert.Nil(t, errorState)
+
+		_, errorState = svc.CreateFromBase64(context.Background(), &dto.Base64PictureRequest{Data: "not base64!"})
+		assert.Equal(t, http.StatusBadRequest, errorState.StatusCode)
+	})
+
+	t.Run("import data URIs", func(t *testing.T) {
+		png := base64.StdEncoding.EncodeToString(newTestPNG(2, 2).Bytes())
+		results := svc.ImportDataURIs([]*dto.DataURIImage{
+			{URI: "data:image/png;base64," + png, Name: "inline.png"},
+			{URI: "data:image/png;base64," + png},
+			{URI: "data:image/jpeg;base64," + png},
+			{URI: "image/png;base64," + png},
+		})
+
+		assert.Len(t, results, 4)
+		assert.Equal(t, http.StatusCreated, results[0].Status)
+		assert.True(t, strings.HasSuffix(results[0].Data.Name, "inline.png"))
+		assert.True(t, strings.HasSuffix(results[1].Data.Name, "import-2.png"))
+		assert.Equal(t, http.StatusBadRequest, results[2].Status)
+		assert.Equal(t, "image/png", results[2].Meta["format"])
+		assert.Nil(t, results[2].Data)
+		assert.Equal(t, 3, results[3].Index)
+		assert.Equal(t, http.StatusBadRequest, results[3].Status)
+	})
+
 	t.Run("update entry", func(t *testing.T) {
 		file := utils.NewTestFile(utils.NewUniqueString())
 
 		allKeys := reflect.ValueOf(repo.data).MapKeys()
 		randomKey := int(allKeys[utils.NewRandomNumber(0, len(allKeys)-1)].Int())
 
-		updateResponse, errorState := svc.Update(int(repo.data[randomKey].ID), file)
+		updateResponse, errorState := svc.Update(context.Background(), int(repo.data[randomKey].ID), file, 0)
 
 		if errorState != nil {
 			assert.NotNil(t, errorState.Error)
@@ -52,6 +113,27 @@ func TestServiceFunctions(t *testing.T) {
 		assert.Equal(t, fileResponse.Name, updateResponse.Name)
 	})
 
+	t.Run("update stale version", func(t *testing.T) {
+		allKeys := reflect.ValueOf(repo.data).MapKeys()
+		picture := repo.data[int(allKeys[0].Int())]
+		version := picture.Version
+
+		updateResponse, errorState := svc.Update(context.Background(), int(picture.ID), utils.NewTestFile(utils.NewUniqueString()), version)
+		if assert.Nil(t, errorState) {
+			assert.Equal(t, version+1, updateResponse.Version)
+		}
+
+		_, errorState = svc.Update(context.Background(), int(picture.ID), utils.NewTestFile(utils.NewUniqueString()), version)
+		if assert.NotNil(t, errorState) {
+			assert.Equal(t, http.StatusConflict, errorState.StatusCode)
+			assert.Equal(t, version+1, errorState.Data["current_version"])
+		}
+
+		var conflict *db.VersionConflictError
+		assert.ErrorAs(t, svc.Delete(context.Background(), int(picture.ID), version), &conflict)
+		assert.Equal(t, version+1, conflict.CurrentVersion)
+	})
+
 	t.Run("list page", func(t *testing.T) {
 		listResponse, count, err := svc.List(10, 1)
 		totalCount := int(count)
@@ -82,25 +164,860 @@ func TestServiceFunctions(t *testing.T) {
 		assert.Equal(t, response, repo.data[int(response.Id)].ToPictureResponse())
 	})
 
+	t.Run("frames of a non GIF entry", func(t *testing.T) {
+		randomEntry := utils.NewRandomNumber(1, len(repo.data))
+		_, framesError := svc.ListFrames(randomEntry)
+
+		assert.Equal(t, http.StatusBadRequest, framesError.StatusCode)
+		assert.ErrorIs(t, framesError.Error, ErrNotAnimated)
+	})
+
+	t.Run("entry without ICC profile", func(t *testing.T) {
+		randomEntry := utils.NewRandomNumber(1, len(repo.data))
+		_, err := svc.GetICCProfile(randomEntry)
+
+		assert.ErrorIs(t, err, imaging.ErrNoICCProfile)
+	})
+
+	t.Run("search by location", func(t *testing.T) {
+		lat, lon := 48.85, -2.29
+		repo.data[1].Latitude, repo.data[1].Longitude = &lat, &lon
+
+		pictures, count, err := svc.Search(&dto.PictureFilter{Box: &dto.BoundingBox{LatMin: 48, LatMax: 49, LonMin: -3, LonMax: -2}}, 10, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, uint(1), pictures[0].Id)
+
+		_, count, _ = svc.Search(&dto.PictureFilter{Box: &dto.BoundingBox{LatMin: 48, LatMax: 49, LonMin: 170, LonMax: -170}}, 10, 1)
+		assert.Equal(t, 0, count)
+
+		nearby, count, err := svc.SearchNearby(48.86, -2.3, 5, 10, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, count)
+		assert.InDelta(t, 1.33, *nearby[0].DistanceKm, 0.01)
+
+		_, count, _ = svc.SearchNearby(48.86, -2.3, 1, 10, 1)
+		assert.Equal(t, 0, count)
+
+		location, err := svc.GetLocation(1)
+		assert.Nil(t, err)
+		assert.Equal(t, lat, location.Lat)
+		assert.Nil(t, location.Altitude)
+	})
+
 	t.Run("invalid get entry", func(t *testing.T) {
 		_, err := svc.Get(-1)
 
 		assert.NotNil(t, err)
 	})
 
+	t.Run("internal redirect entry", func(t *testing.T) {
+		randomEntry := utils.NewRandomNumber(1, len(repo.data))
+		redirectPath, contentType, err := svc.GetInternalRedirect(randomEntry)
+
+		assert.Nil(t, err)
+		assert.Equal(t, true, strings.HasSuffix(redirectPath, repo.data[randomEntry].Destination))
+		assert.Equal(t, repo.data[randomEntry].ContentType, contentType)
+	})
+
 	t.Run("delete entry", func(t *testing.T) {
 		initialLength := len(repo.data)
 		randomEntry := utils.NewRandomNumber(1, initialLength)
-		err := svc.Delete(randomEntry)
+		err := svc.Delete(context.Background(), randomEntry, 0)
 
 		assert.Nil(t, err)
 		assert.Equal(t, len(repo.data), initialLength-1)
 	})
 
 	t.Run("invalid delete entry", func(t *testing.T) {
-		err := svc.Delete(-1)
+		err := svc.Delete(context.Background(), -1, 0)
 
 		assert.NotNil(t, err)
 	})
 
 }
+
+// newTestPicturesService returns a pictures service storing the pictures in
+// a fake repository and their files in a temporary directory.
+func newTestPicturesService(t *testing.T) (*fakeRepository, storage.ImageStorage, PicturesService) {
+	repo := NewFakeRepository()
+	imageStorage := storage.NewStorage(t.TempDir())
+	return repo, imageStorage, NewPicturesService(repo, imageStorage, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+}
+
+// createTestPicture uploads a picture, stopping the test when it fails.
+func createTestPicture(t *testing.T, svc PicturesService, name string, data []byte) *dto.PictureResponse {
+	t.Helper()
+	created, createError := svc.Create(context.Background(), utils.NewTestFileWithContent(name, data), "")
+	if !assert.Nil(t, createError) {
+		t.FailNow()
+	}
+	return created
+}
+
+func TestPDFPreview(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	content := utils.NewTestPDF(1, 60, 40)
+	created := createTestPicture(t, svc, "document.pdf", content)
+	assert.NotEmpty(t, created.ThumbnailUrl)
+
+	reader, contentType, _, err := svc.GetFileReader(int(created.Id))
+	if assert.Nil(t, err) {
+		preview, err := png.Decode(reader)
+		reader.Close()
+		assert.Nil(t, err)
+		assert.Equal(t, "image/png", contentType)
+		assert.Equal(t, image.Rect(0, 0, 60, 40), preview.Bounds())
+	}
+
+	reader, contentType, _, err = svc.GetThumbnailReader(int(created.Id))
+	if assert.Nil(t, err) {
+		reader.Close()
+		assert.Equal(t, "image/png", contentType)
+	}
+
+	reader, contentType, _, err = svc.GetOriginalReader(int(created.Id))
+	if assert.Nil(t, err) {
+		original, _ := io.ReadAll(reader)
+		reader.Close()
+		assert.Equal(t, storage.PDFContentType, contentType)
+		assert.Equal(t, content, original)
+	}
+}
+
+func TestVideoUpload(t *testing.T) {
+	var frame bytes.Buffer
+	jpeg.Encode(&frame, image.NewRGBA(image.Rect(0, 0, 64, 36)), nil)
+	metadata := &dto.VideoMetadata{DurationSeconds: 12.5, FrameRate: 30, VideoCodec: "h264"}
+	extractor := NewFakeVideoExtractor(metadata, frame.Bytes())
+
+	repo := NewFakeRepository()
+	imageStorage := storage.NewStorage(t.TempDir())
+	videos := NewVideoService(imageStorage, storage.NewLocalVideoStorage(t.TempDir()), extractor)
+	svc := NewPicturesService(repo, imageStorage, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), videos)
+
+	// an MP4 ftyp box, the fake extractor doesn't read further
+	content := append([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00isommp41"), make([]byte, 64)...)
+	created := createTestPicture(t, svc, "clip.mp4", content)
+	assert.Equal(t, "clip.mp4", created.Name)
+	assert.Equal(t, metadata, created.Video)
+	assert.Equal(t, int32(64), created.Width)
+	assert.Equal(t, int32(36), created.Height)
+
+	reader, contentType, _, err := svc.GetFileReader(int(created.Id))
+	if assert.Nil(t, err) {
+		reader.Close()
+		assert.Equal(t, "image/jpeg", contentType)
+	}
+
+	reader, contentType, _, err = svc.GetOriginalReader(int(created.Id))
+	if assert.Nil(t, err) {
+		original, _ := io.ReadAll(reader)
+		reader.Close()
+		assert.Equal(t, "video/mp4", contentType)
+		assert.Equal(t, content, original)
+	}
+
+	extractor.err = video.ErrNoVideoStream
+	_, createError := svc.Create(context.Background(), utils.NewTestFileWithContent("audio.mp4", content), "")
+	if assert.NotNil(t, createError) {
+		assert.Equal(t, http.StatusBadRequest, createError.StatusCode)
+	}
+
+	viper.Set("video.maxUploadSize", 32)
+	defer viper.Set("video.maxUploadSize", 0)
+	_, createError = svc.Create(context.Background(), utils.NewTestFileWithContent("clip.mp4", content), "")
+	if assert.NotNil(t, createError) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, createError.StatusCode)
+	}
+}
+
+func TestAlphaChannel(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	// a fully transparent PNG
+	created := createTestPicture(t, svc, "icon.png", newTestPNG(4, 4).Bytes())
+
+	stripped, stripError := svc.ChangeAlpha(int(created.Id), AlphaStrip)
+	if assert.Nil(t, stripError) {
+		assert.Equal(t, "image/jpeg", stripped.ContentType)
+		assert.True(t, strings.HasSuffix(stripped.Name, "icon-opaque.jpg"))
+
+		data, _ := imageStorage.Get(repo.data[int(stripped.Id)].Destination)
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			r, g, b, _ := decoded.At(1, 1).RGBA()
+			assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+		}
+	}
+
+	added, addError := svc.ChangeAlpha(int(created.Id), AlphaAdd)
+	if assert.Nil(t, addError) {
+		assert.Equal(t, "image/png", added.ContentType)
+		data, _ := imageStorage.Get(repo.data[int(added.Id)].Destination)
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			assert.Equal(t, color.NRGBAModel, decoded.ColorModel())
+		}
+	}
+
+	_, addError = svc.ChangeAlpha(int(stripped.Id), AlphaAdd)
+	if assert.NotNil(t, addError) {
+		assert.Equal(t, http.StatusBadRequest, addError.StatusCode)
+		assert.ErrorIs(t, addError.Error, ErrNoAlphaChannel)
+	}
+
+	_, stripError = svc.ChangeAlpha(int(stripped.Id), AlphaStrip)
+	if assert.NotNil(t, stripError) {
+		assert.Equal(t, http.StatusBadRequest, stripError.StatusCode)
+	}
+
+	_, actionError := svc.ChangeAlpha(int(created.Id), "invert")
+	if assert.NotNil(t, actionError) {
+		assert.ErrorIs(t, actionError.Error, ErrUnknownAlphaAction)
+	}
+}
+
+func TestChangeTone(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	red := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for i := 0; i < len(red.Pix); i += 4 {
+		copy(red.Pix[i:], []byte{255, 0, 0, 255})
+	}
+	var encoded bytes.Buffer
+	png.Encode(&encoded, red)
+
+	created := createTestPicture(t, svc, "red.png", encoded.Bytes())
+
+	gray, toneError := svc.ChangeTone(int(created.Id), "")
+	if assert.Nil(t, toneError) {
+		assert.True(t, gray.IsGrayscale)
+		assert.Equal(t, "image/png", gray.ContentType)
+		assert.True(t, strings.HasSuffix(gray.Name, "red-grayscale.png"))
+
+		data, _ := imageStorage.Get(repo.data[int(gray.Id)].Destination)
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			assert.Equal(t, color.Gray16Model, decoded.ColorModel())
+		}
+	}
+
+	sepia, toneError := svc.ChangeTone(int(created.Id), ToneSepia)
+	if assert.Nil(t, toneError) {
+		assert.False(t, sepia.IsGrayscale)
+		assert.True(t, strings.HasSuffix(sepia.Name, "red-sepia.png"))
+	}
+
+	_, toneError = svc.ChangeTone(int(created.Id), "negative")
+	if assert.NotNil(t, toneError) {
+		assert.Equal(t, http.StatusBadRequest, toneError.StatusCode)
+	}
+
+	grayscale := true
+	pictures, count, err := svc.Search(&dto.PictureFilter{Grayscale: &grayscale}, 10, 1)
+	if assert.Nil(t, err) {
+		assert.Equal(t, 1, count)
+		assert.Equal(t, gray.Id, pictures[0].Id)
+	}
+
+	grayscale = false
+	_, count, _ = svc.Search(&dto.PictureFilter{Grayscale: &grayscale}, 10, 1)
+	assert.Equal(t, 2, count)
+}
+
+func TestAdjust(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	draw.Draw(src, src.Bounds(), image.NewUniform(color.NRGBA{204, 102, 51, 255}), image.Point{}, draw.Src)
+	var encoded bytes.Buffer
+	png.Encode(&encoded, src)
+
+	created := createTestPicture(t, svc, "orange.png", encoded.Bytes())
+
+	adjusted, adjustError := svc.Adjust(int(created.Id), &dto.Adjustments{Brightness: 0.2})
+	if assert.Nil(t, adjustError) {
+		assert.True(t, strings.HasSuffix(adjusted.Name, "orange-adjusted.png"))
+
+		data, _ := imageStorage.Get(repo.data[int(adjusted.Id)].Destination)
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			assert.Equal(t, color.NRGBA{255, 153, 102, 255}, color.NRGBAModel.Convert(decoded.At(1, 1)))
+		}
+	}
+
+	equalized, equalizeError := svc.Equalize(int(created.Id))
+	if assert.Nil(t, equalizeError) {
+		assert.True(t, strings.HasSuffix(equalized.Name, "orange-equalized.png"))
+	}
+
+	leveled, levelError := svc.AutoLevel(int(created.Id), false)
+	if assert.Nil(t, levelError) {
+		assert.True(t, strings.HasSuffix(leveled.Name, "orange-leveled.png"))
+	}
+
+	for _, each := range []*dto.Adjustments{{}, {Contrast: 1.5}, {Saturation: math.NaN()}} {
+		_, adjustError = svc.Adjust(int(created.Id), each)
+		if assert.NotNil(t, adjustError) {
+			assert.Equal(t, http.StatusBadRequest, adjustError.StatusCode)
+		}
+	}
+}
+
+func TestBlur(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "dots.png", newTestPNG(8, 6).Bytes())
+
+	blurred, blurError := svc.Blur(int(created.Id), 3)
+	if assert.Nil(t, blurError) {
+		assert.True(t, strings.HasSuffix(blurred.Name, "dots-blurred.png"))
+		assert.Equal(t, int32(8), blurred.Width)
+	}
+
+	denoised, denoiseError := svc.Denoise(int(created.Id), 0.8)
+	if assert.Nil(t, denoiseError) {
+		assert.True(t, strings.HasSuffix(denoised.Name, "dots-denoised.png"))
+	}
+	for _, strength := range []float64{0, 1.5, math.NaN()} {
+		_, denoiseError = svc.Denoise(int(created.Id), strength)
+		if assert.NotNil(t, denoiseError) {
+			assert.Equal(t, http.StatusBadRequest, denoiseError.StatusCode)
+		}
+	}
+
+	for _, radius := range []int{0, 4} {
+		_, blurError = svc.Blur(int(created.Id), radius)
+		if assert.NotNil(t, blurError) {
+			assert.Equal(t, http.StatusBadRequest, blurError.StatusCode)
+			assert.Equal(t, 3, blurError.Data["max_radius"])
+		}
+	}
+}
+
+func TestSharpen(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "dots.png", newTestPNG(8, 6).Bytes())
+
+	sharpened, sharpenError := svc.Sharpen(int(created.Id), &dto.UnsharpMask{Amount: 1, Radius: 2})
+	if assert.Nil(t, sharpenError) {
+		assert.True(t, strings.HasSuffix(sharpened.Name, "dots-sharpened.png"))
+	}
+
+	invalid := []*dto.UnsharpMask{
+		{Amount: 0, Radius: 2},
+		{Amount: 6, Radius: 2},
+		{Amount: 1, Radius: 4},
+		{Amount: 1, Radius: 2, Threshold: 256},
+	}
+	for _, each := range invalid {
+		_, sharpenError = svc.Sharpen(int(created.Id), each)
+		if assert.NotNil(t, sharpenError) {
+			assert.Equal(t, http.StatusBadRequest, sharpenError.StatusCode)
+		}
+	}
+}
+
+func TestBorder(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	viper.Set("edits.maxBorderPercent", 10)
+	defer viper.Set("edits.maxBorderPercent", 0)
+
+	created := createTestPicture(t, svc, "wide.png", newTestPNG(100, 50).Bytes())
+
+	red := color.RGBA{255, 0, 0, 255}
+	bordered, borderError := svc.Border(int(created.Id), &dto.Border{Top: 5, Right: 10, Bottom: 0, Left: 2, Color: red})
+	if assert.Nil(t, borderError) {
+		assert.Equal(t, int32(100+10+2), bordered.Width)
+		assert.Equal(t, int32(50+5), bordered.Height)
+
+		data, _ := imageStorage.Get(repo.data[int(bordered.Id)].Destination)
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			assert.Equal(t, color.NRGBA{255, 0, 0, 255}, color.NRGBAModel.Convert(decoded.At(0, 0)))
+		}
+	}
+
+	invalid := []*dto.Border{{}, {Top: -1, Left: 2}, {Top: 6}, {Left: 11}}
+	for _, each := range invalid {
+		_, borderError = svc.Border(int(created.Id), each)
+		if assert.NotNil(t, borderError) {
+			assert.Equal(t, http.StatusBadRequest, borderError.StatusCode)
+		}
+	}
+}
+
+func TestComposite(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	blue := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(blue, blue.Bounds(), image.NewUniform(color.RGBA{0, 0, 255, 255}), image.Point{}, draw.Src)
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, blue, &jpeg.Options{Quality: 100})
+
+	base := createTestPicture(t, svc, "base.jpg", encoded.Bytes())
+	red := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(red, red.Bounds(), image.NewUniform(color.RGBA{255, 0, 0, 255}), image.Point{}, draw.Src)
+	encoded.Reset()
+	png.Encode(&encoded, red)
+	overlay := createTestPicture(t, svc, "overlay.png", encoded.Bytes())
+
+	composed, composeError := svc.Composite(&dto.CompositeRequest{BaseId: base.Id, OverlayId: overlay.Id, X: 4, Y: 4}, false)
+	if assert.Nil(t, composeError) {
+		assert.Equal(t, "image/jpeg", composed.ContentType)
+		assert.True(t, strings.HasSuffix(composed.Name, "base-composite.jpg"))
+
+		data, _ := imageStorage.Get(repo.data[int(composed.Id)].Destination)
+		decoded, err := jpeg.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			r, _, b, _ := decoded.At(6, 6).RGBA()
+			assert.Greater(t, r, b)
+			r, _, b, _ = decoded.At(1, 1).RGBA()
+			assert.Greater(t, b, r)
+		}
+	}
+
+	request := &dto.CompositeRequest{BaseId: base.Id, OverlayId: overlay.Id, X: 6, Y: -1}
+	_, composeError = svc.Composite(request, false)
+	if assert.NotNil(t, composeError) {
+		assert.Equal(t, http.StatusBadRequest, composeError.StatusCode)
+		assert.ErrorIs(t, composeError.Error, ErrOverlayOutside)
+	}
+	_, composeError = svc.Composite(request, true)
+	assert.Nil(t, composeError)
+
+	_, composeError = svc.Composite(&dto.CompositeRequest{BaseId: base.Id, OverlayId: 99}, false)
+	if assert.NotNil(t, composeError) {
+		assert.Equal(t, http.StatusNotFound, composeError.StatusCode)
+	}
+}
+
+func TestDither(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil)
+	created := createTestPicture(t, svc, "photo.jpg", encoded.Bytes())
+
+	dithered, ditherError := svc.Dither(int(created.Id), 16)
+	if assert.Nil(t, ditherError) {
+		assert.Equal(t, "image/png", dithered.ContentType)
+		assert.True(t, strings.HasSuffix(dithered.Name, "photo-dithered.png"))
+
+		data, _ := imageStorage.Get(repo.data[int(dithered.Id)].Destination)
+		decoded, err := png.Decode(bytes.NewReader(data))
+		if assert.Nil(t, err) {
+			assert.IsType(t, &image.Paletted{}, decoded)
+		}
+	}
+
+	for _, colors := range []int{1, 257} {
+		_, ditherError = svc.Dither(int(created.Id), colors)
+		if assert.NotNil(t, ditherError) {
+			assert.Equal(t, http.StatusBadRequest, ditherError.StatusCode)
+		}
+	}
+}
+
+func TestInterlacedFile(t *testing.T) {
+	repo, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "icon.png", newTestPNG(9, 9).Bytes())
+
+	reader, contentType, _, err := svc.GetInterlacedFileReader(int(created.Id))
+	if !assert.Nil(t, err) {
+		return
+	}
+	interlaced, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "image/png", contentType)
+	assert.True(t, imaging.IsInterlacedPNG(interlaced))
+
+	// the copy is kept apart from the picture file, and written once
+	picture := repo.data[int(created.Id)]
+	assert.NotEqual(t, picture.Destination, picture.InterlacedDestination)
+	interlacedDestination := picture.InterlacedDestination
+	_, _, _, err = svc.GetInterlacedFileReader(int(created.Id))
+	assert.Nil(t, err)
+	assert.Equal(t, interlacedDestination, repo.data[int(created.Id)].InterlacedDestination)
+
+	reader, _, _, _ = svc.GetFileReader(int(created.Id))
+	plain, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.False(t, imaging.IsInterlacedPNG(plain))
+
+	// the other formats are served as they are
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil)
+	photo := createTestPicture(t, svc, "photo.jpg", encoded.Bytes())
+	_, contentType, _, err = svc.GetInterlacedFileReader(int(photo.Id))
+	assert.Nil(t, err)
+	assert.Equal(t, "image/jpeg", contentType)
+	assert.Equal(t, "", repo.data[int(photo.Id)].InterlacedDestination)
+}
+
+func TestTileset(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "scan.png", newTestPNG(300, 20).Bytes())
+
+	tileset, tilesetError := svc.CreateTileset(int(created.Id))
+	if !assert.Nil(t, tilesetError) {
+		return
+	}
+	assert.Equal(t, 9, tileset.MaxLevel)
+	// two tiles at the full size and one at each of the 9 smaller levels
+	assert.Equal(t, 11, tileset.TileCount)
+	assert.True(t, strings.HasSuffix(tileset.Tiles, fmt.Sprintf("/picture/%d/tileset/{level}/{column}_{row}.jpg", created.Id)))
+
+	descriptor, err := svc.GetTilesetDescriptor(int(created.Id))
+	if assert.Nil(t, err) {
+		assert.Contains(t, string(descriptor), `<Size Width="300" Height="20"></Size>`)
+	}
+
+	reader, err := svc.GetTile(int(created.Id), 9, 1, 0)
+	if assert.Nil(t, err) {
+		decoded, err := jpeg.Decode(reader)
+		reader.Close()
+		if assert.Nil(t, err) {
+			assert.Equal(t, image.Rect(0, 0, 47, 20), decoded.Bounds())
+		}
+	}
+
+	_, err = svc.GetTile(int(created.Id), 9, 2, 0)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	_, tilesetError = svc.CreateTileset(99)
+	if assert.NotNil(t, tilesetError) {
+		assert.Equal(t, http.StatusNotFound, tilesetError.StatusCode)
+	}
+}
+
+func TestCheckSteganography(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	// a gradient of even values, whose least significant bits carry a
+	// random message
+	random := rand.New(rand.NewSource(1))
+	stego := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := range stego.Pix {
+		stego.Pix[i] = uint8(i/4%128*2) | uint8(random.Intn(2))
+		if i%4 == 3 {
+			stego.Pix[i] = 255
+		}
+	}
+	var encoded bytes.Buffer
+	png.Encode(&encoded, stego)
+
+	suspicious := createTestPicture(t, svc, "stego.png", encoded.Bytes())
+	result, checkError := svc.CheckSteganography(int(suspicious.Id))
+	if assert.Nil(t, checkError) {
+		assert.True(t, result.Suspicious)
+	}
+
+	plain := createTestPicture(t, svc, "plain.png", newTestPNG(64, 64).Bytes())
+	result, checkError = svc.CheckSteganography(int(plain.Id))
+	if assert.Nil(t, checkError) {
+		assert.False(t, result.Suspicious)
+	}
+
+	_, checkError = svc.CheckSteganography(99)
+	if assert.NotNil(t, checkError) {
+		assert.Equal(t, http.StatusNotFound, checkError.StatusCode)
+	}
+}
+
+func TestQuality(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+	processing := NewProcessingService(repo, imageStorage, webhook.NewDispatcher(nil, ""), 200)
+
+	stripes := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range stripes.Pix {
+		stripes.Pix[i] = uint8(i / 8 % 2 * 255)
+	}
+	var encoded bytes.Buffer
+	png.Encode(&encoded, stripes)
+
+	sharp := createTestPicture(t, svc, "stripes.png", encoded.Bytes())
+	flat := createTestPicture(t, svc, "flat.png", newTestPNG(64, 64).Bytes())
+
+	quality, qualityError := svc.GetQuality(int(sharp.Id))
+	if assert.Nil(t, qualityError) {
+		assert.Greater(t, quality.Sharpness, 1000.0)
+		assert.Greater(t, quality.QualityScore, 50.0)
+	}
+
+	_, qualityError = svc.GetQuality(99)
+	if assert.NotNil(t, qualityError) {
+		assert.Equal(t, http.StatusNotFound, qualityError.StatusCode)
+	}
+
+	// the unprocessed pictures are left out of the searches by quality
+	minQuality := 50.0
+	found, _, _ := svc.Search(&dto.PictureFilter{MinQuality: &minQuality}, 10, 1)
+	assert.Empty(t, found)
+
+	for _, id := range []uint{sharp.Id, flat.Id} {
+		_, err := processing.Process(int(id))
+		assert.Nil(t, err)
+	}
+	assert.InDelta(t, quality.QualityScore, *repo.data[int(sharp.Id)].QualityScore, 1e-9)
+	assert.Equal(t, 0.0, *repo.data[int(flat.Id)].QualityScore)
+
+	found, _, _ = svc.Search(&dto.PictureFilter{MinQuality: &minQuality}, 10, 1)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, sharp.Id, found[0].Id)
+	}
+}
+
+func TestAnimatedThumbnail(t *testing.T) {
+	repo, imageStorage, svc := newTestPicturesService(t)
+	processing := NewProcessingService(repo, imageStorage, webhook.NewDispatcher(nil, ""), 200)
+
+	animation := &gif.GIF{}
+	for i := 0; i < 4; i++ {
+		frame := image.NewPaletted(image.Rect(0, 0, 400, 100), palette.Plan9)
+		animation.Image = append(animation.Image, frame)
+		animation.Delay = append(animation.Delay, 5)
+	}
+	var encoded bytes.Buffer
+	gif.EncodeAll(&encoded, animation)
+
+	animated := createTestPicture(t, svc, "loop.gif", encoded.Bytes())
+	still := createTestPicture(t, svc, "still.png", newTestPNG(8, 8).Bytes())
+
+	_, _, err := svc.GetAnimatedThumbnailReader(int(animated.Id))
+	assert.ErrorIs(t, err, ErrNoAnimatedThumbnail)
+
+	for _, id := range []uint{animated.Id, still.Id} {
+		_, err := processing.Process(int(id))
+		assert.Nil(t, err)
+	}
+
+	response, _ := svc.Get(int(animated.Id))
+	assert.True(t, strings.HasSuffix(response.ThumbnailAnimatedUrl, fmt.Sprintf("/picture/%d/thumbnail/animated", animated.Id)))
+	assert.NotEmpty(t, response.ThumbnailUrl)
+
+	reader, _, err := svc.GetAnimatedThumbnailReader(int(animated.Id))
+	if assert.Nil(t, err) {
+		thumbnail, err := gif.DecodeAll(reader)
+		reader.Close()
+		if assert.Nil(t, err) {
+			// 20 fps played at 10 fps
+			assert.Len(t, thumbnail.Image, 2)
+			assert.Equal(t, image.Rect(0, 0, 200, 50), thumbnail.Image[0].Bounds())
+		}
+	}
+
+	response, _ = svc.Get(int(still.Id))
+	assert.Empty(t, response.ThumbnailAnimatedUrl)
+	_, _, err = svc.GetAnimatedThumbnailReader(int(still.Id))
+	assert.ErrorIs(t, err, ErrNoAnimatedThumbnail)
+}
+
+func TestSrcset(t *testing.T) {
+	repo := NewFakeRepository()
+	svc := NewPicturesService(repo, NewFakeStorage(), NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+
+	created, _ := repo.Create(context.Background(), &dto.PictureRequest{Name: "banner.png", ContentType: "image/png", Width: 1000, Height: 300})
+	srcset, err := svc.GetSrcset(int(created.ID))
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Len(t, srcset.Images, 4)
+	assert.Equal(t, 320, srcset.Images[0].Width)
+	assert.Equal(t, 96, srcset.Images[0].Height)
+	assert.Equal(t, 1000, srcset.Images[3].Width)
+	assert.Equal(t, 300, srcset.Images[3].Height)
+	assert.True(t, strings.HasSuffix(srcset.Images[1].Url, fmt.Sprintf("/iiif/%d/full/640,/0/default.png", created.ID)))
+	assert.Equal(t, srcset.Images[0].Url+" 320w, "+srcset.Images[1].Url+" 640w, "+srcset.Images[2].Url+" 960w, "+srcset.Images[3].Url+" 1000w", srcset.Srcset)
+
+	_, err = svc.GetSrcset(99)
+	assert.NotNil(t, err)
+}
+
+func TestPlaceholder(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "wide.png", newTestPNG(400, 200).Bytes())
+
+	placeholder, placeholderError := svc.GetPlaceholder(int(created.Id), 10)
+	if assert.Nil(t, placeholderError) {
+		assert.Equal(t, 10, placeholder.Width)
+		assert.Equal(t, 5, placeholder.Height)
+
+		encoded, found := strings.CutPrefix(placeholder.DataURI, "data:image/jpeg;base64,")
+		assert.True(t, found)
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if assert.Nil(t, err) {
+			decoded, err := jpeg.Decode(bytes.NewReader(data))
+			if assert.Nil(t, err) {
+				assert.Equal(t, image.Rect(0, 0, 10, 5), decoded.Bounds())
+			}
+		}
+	}
+
+	for _, size := range []int{0, 51} {
+		_, placeholderError = svc.GetPlaceholder(int(created.Id), size)
+		if assert.NotNil(t, placeholderError) {
+			assert.Equal(t, http.StatusBadRequest, placeholderError.StatusCode)
+		}
+	}
+}
+
+func TestAddTag(t *testing.T) {
+	repo, _, svc := newTestPicturesService(t)
+
+	created := createTestPicture(t, svc, "tagged.png", newTestPNG(10, 10).Bytes())
+
+	for _, tag := range []string{"beach", " beach ", "sunset"} {
+		_, tagError := svc.AddTag(int(created.Id), tag)
+		assert.Nil(t, tagError)
+	}
+	assert.Equal(t, []string{"beach", "sunset"}, repo.data[int(created.Id)].Tags)
+
+	_, tagError := svc.AddTag(404, "beach")
+	if assert.NotNil(t, tagError) {
+		assert.Equal(t, http.StatusNotFound, tagError.StatusCode)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	_, _, svc := newTestPicturesService(t)
+
+	contents := [][]byte{newTestPNG(10, 10).Bytes(), newTestPNG(20, 20).Bytes(), newTestPNG(30, 30).Bytes()}
+	created := createTestPicture(t, svc, "versioned.png", contents[0])
+	for _, eachContent := range contents[1:] {
+		_, updateError := svc.Update(context.Background(), int(created.Id), utils.NewTestFileWithContent("versioned.png", eachContent), 0)
+		if !assert.Nil(t, updateError) {
+			return
+		}
+	}
+
+	versions, err := svc.ListVersions(int(created.Id))
+	if !assert.Nil(t, err) || !assert.Len(t, versions, 3) {
+		return
+	}
+	for i, eachVersion := range versions {
+		assert.Equal(t, fmt.Sprint(3-i), eachVersion.VersionId)
+		assert.Equal(t, i == 0, eachVersion.IsLatest)
+		assert.Equal(t, int64(len(contents[2-i])), eachVersion.Size)
+
+		stream, contentType, err := svc.GetVersion(int(created.Id), eachVersion.VersionId)
+		if assert.Nil(t, err) {
+			data, _ := io.ReadAll(stream)
+			stream.Close()
+			assert.Equal(t, contents[2-i], data)
+			assert.Equal(t, "image/png", contentType)
+		}
+	}
+
+	_, _, err = svc.GetVersion(int(created.Id), "4")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	_, _, err = svc.GetVersion(int(created.Id), "latest")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+// versioningStorage is a storage keeping the versions of its objects, as a
+// bucket with storage.s3.versioning does.
+type versioningStorage struct {
+	storage.ImageStorage
+	versions map[string][]*dto.PictureVersion
+	contents map[string][]byte
+}
+
+func (s *versioningStorage) Unwrap() storage.ImageStorage {
+	return s.ImageStorage
+}
+
+func (s *versioningStorage) Versioning() bool {
+	return true
+}
+
+func (s *versioningStorage) ListVersions(destination string) ([]*dto.PictureVersion, error) {
+	return s.versions[destination], nil
+}
+
+func (s *versioningStorage) GetVersion(destination, versionId string) (io.ReadCloser, string, error) {
+	for _, eachVersion := range s.versions[destination] {
+		if eachVersion.VersionId == versionId {
+			return io.NopCloser(bytes.NewReader(s.contents[versionId])), "", nil
+		}
+	}
+	return nil, "", &storage.S3NotFoundError{Key: destination}
+}
+
+func TestObjectVersions(t *testing.T) {
+	repo := NewFakeRepository()
+	images := &versioningStorage{
+		ImageStorage: storage.NewStorage(t.TempDir()),
+		versions:     map[string][]*dto.PictureVersion{},
+		contents:     map[string][]byte{},
+	}
+	svc := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+
+	created := createTestPicture(t, svc, "versioned.png", newTestPNG(10, 10).Bytes())
+	first := repo.data[int(created.Id)].Destination
+	_, updateError := svc.Update(context.Background(), int(created.Id), utils.NewTestFileWithContent("versioned.png", newTestPNG(20, 20).Bytes()), 0)
+	if !assert.Nil(t, updateError) {
+		return
+	}
+	second := repo.data[int(created.Id)].Destination
+
+	// the current file was overwritten once
+	images.versions[first] = []*dto.PictureVersion{{VersionId: "a", IsLatest: true}}
+	images.versions[second] = []*dto.PictureVersion{{VersionId: "c", IsLatest: true}, {VersionId: "b"}}
+	for _, eachId := range []string{"a", "b", "c"} {
+		images.contents[eachId] = []byte(eachId)
+	}
+
+	versions, err := svc.ListVersions(int(created.Id))
+	if !assert.Nil(t, err) || !assert.Len(t, versions, 3) {
+		return
+	}
+	for i, eachId := range []string{"c", "b", "a"} {
+		assert.Equal(t, eachId, versions[i].VersionId)
+		assert.Equal(t, i == 0, versions[i].IsLatest)
+
+		stream, contentType, err := svc.GetVersion(int(created.Id), eachId)
+		if assert.Nil(t, err) {
+			data, _ := io.ReadAll(stream)
+			stream.Close()
+			assert.Equal(t, []byte(eachId), data)
+			assert.Equal(t, "image/png", contentType)
+		}
+	}
+
+	_, _, err = svc.GetVersion(int(created.Id), "1")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func TestFeatureFlagRollOut(t *testing.T) {
+	repo := NewFakeRepository()
+	candidate := storage.NewStorage(t.TempDir())
+	images := storage.NewFeatureFlagStorage(storage.NewStorage(t.TempDir()), candidate, 100)
+	svc := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+
+	// the files are copied once the picture has its ID
+	created := createTestPicture(t, svc, "canary.png", newTestPNG(10, 10).Bytes())
+	first := repo.data[int(created.Id)].Destination
+	assert.Eventually(t, func() bool {
+		_, err := candidate.Get(first)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, updateError := svc.Update(context.Background(), int(created.Id), utils.NewTestFileWithContent("canary.png", newTestPNG(20, 20).Bytes()), 0)
+	if !assert.Nil(t, updateError) {
+		return
+	}
+	second := repo.data[int(created.Id)].Destination
+	assert.Eventually(t, func() bool {
+		_, err := candidate.Get(second)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
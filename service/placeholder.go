@@ -0,0 +1,59 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxPlaceholderSize is the largest side of the placeholders, which are
+// meant to stay a few hundred bytes.
+const MaxPlaceholderSize = 50
+
+var ErrPlaceholderSize = errors.New("the placeholder size must be between 1 and 50")
+
+// GetPlaceholder scales the picture down to fit in size x size pixels and
+// returns it as the data URI of a JPEG, flattened on white. Browsers blur it
+// while scaling it up, e.g. in the src of an <img> until the picture loads.
+func (s *picturesService) GetPlaceholder(id int, size int) (*dto.Placeholder, *dto.InvalidPictureFileError) {
+	if size < 1 || size > MaxPlaceholderSize {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrPlaceholderSize,
+			Data:       gin.H{"max_size": MaxPlaceholderSize},
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	placeholder := imaging.Flatten(imaging.Thumbnail(decoded, size), color.White)
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, placeholder, &jpeg.Options{Quality: 70}); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	return &dto.Placeholder{
+		DataURI: "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buffer.Bytes()),
+		Width:   placeholder.Bounds().Dx(),
+		Height:  placeholder.Bounds().Dy(),
+	}, nil
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+)
+
+// The states of a reprocessing job.
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+var ErrPictureNotFound = errors.New("picture not found")
+
+var ErrJobNotFound = errors.New("job not found")
+
+// PictureProcessor computes the derived fields of a picture (thumbnail,
+// perceptual hash, ...) after it's uploaded.
+type PictureProcessor interface {
+	Enqueue(uint)
+}
+
+type ProcessingService interface {
+	PictureProcessor
+	Start(int)
+	Process(int) (*dto.ProcessingResult, error)
+	ReprocessAll(int) *dto.ProcessingJob
+	GetJob(string) (*dto.ProcessingJob, error)
+}
+
+type processingStep struct {
+	name string
+	run  func(*db.Picture, []byte, image.Image) error
+}
+
+type processingService struct {
+	repository    db.PicturesRepository
+	storage       storage.ImageStorage
+	events        webhook.Dispatcher
+	queue         chan uint
+	thumbnailSize int
+	steps         []processingStep
+
+	jobsLock sync.Mutex
+	jobs     map[string]*dto.ProcessingJob
+}
+
+func NewProcessingService(repository db.PicturesRepository, storage storage.ImageStorage, events webhook.Dispatcher, thumbnailSize int) ProcessingService {
+	s := &processingService{
+		repository:    repository,
+		storage:       storage,
+		events:        events,
+		queue:         make(chan uint, 1024),
+		thumbnailSize: thumbnailSize,
+		jobs:          map[string]*dto.ProcessingJob{},
+	}
+
+	s.steps = []processingStep{
+		{name: "thumbnail", run: s.generateThumbnail},
+		{name: "animated_thumbnail", run: s.generateAnimatedThumbnail},
+		{name: "perceptual_hash", run: s.computePerceptualHash},
+		{name: "icc_profile", run: s.extractICCProfile},
+		{name: "xmp", run: s.extractXMP},
+		{name: "iptc", run: s.extractIPTC},
+		{name: "gps", run: s.extractGPS},
+		{name: "quality", run: s.assessQuality},
+	}
+
+	return s
+}
+
+// Start runs the given number of workers processing the queued pictures.
+func (s *processingService) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for id := range s.queue {
+				if _, err := s.Process(int(id)); err != nil {
+					log.Printf("Unable to process picture %d: %v", id, err)
+				}
+			}
+		}()
+	}
+}
+
+func (s *processingService) Enqueue(id uint) {
+	select {
+	case s.queue <- id:
+	default:
+		log.Printf("Processing queue is full, skipping picture %d", id)
+	}
+}
+
+// Process runs every pipeline step on the picture and fires the
+// image.processed webhook with the result of each step. A failing step
+// doesn't stop the following ones.
+func (s *processingService) Process(id int) (*dto.ProcessingResult, error) {
+	startedAt := time.Now()
+
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPictureNotFound, err)
+	}
+
+	data, err := s.storage.Get(picture.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := storage.DecodeImage(data, picture.ContentType)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]*dto.ProcessingStepResult, 0, len(s.steps))
+	for _, step := range s.steps {
+		stepStartedAt := time.Now()
+		stepError := step.run(picture, data, source)
+
+		result := &dto.ProcessingStepResult{
+			Name:       step.name,
+			Succeeded:  stepError == nil,
+			DurationMs: time.Since(stepStartedAt).Milliseconds(),
+		}
+		if stepError != nil {
+			result.Error = stepError.Error()
+		}
+		steps = append(steps, result)
+	}
+
+	picture.ProcessedOn = time.Now().UnixMilli()
+	if err := s.repository.UpdateComputed(picture); err != nil {
+		return nil, err
+	}
+	rollOut(s.storage, picture.ID, picture.ThumbnailDestination, picture.AnimatedThumbnailDestination)
+
+	result := &dto.ProcessingResult{
+		Picture:              picture.ToPictureResponse(),
+		ProcessingDurationMs: time.Since(startedAt).Milliseconds(),
+		Steps:                steps,
+	}
+	s.events.Send(webhook.EventImageProcessed, result)
+
+	return result, nil
+}
+
+// ReprocessAll starts a background job queueing every picture for
+// processing, batchSize pictures at a time. Unlike Enqueue, the job waits for
+// room in the queue instead of skipping pictures.
+func (s *processingService) ReprocessAll(batchSize int) *dto.ProcessingJob {
+	job := &dto.ProcessingJob{
+		Id:        utils.NewUniqueString(),
+		Status:    JobRunning,
+		BatchSize: batchSize,
+		StartedOn: time.Now(),
+	}
+
+	s.jobsLock.Lock()
+	s.jobs[job.Id] = job
+	snapshot := *job
+	s.jobsLock.Unlock()
+
+	go s.runReprocessJob(job)
+
+	return &snapshot
+}
+
+func (s *processingService) runReprocessJob(job *dto.ProcessingJob) {
+	for page := 1; ; page++ {
+		pictures, totalCount, err := s.repository.GetAll(job.BatchSize, page)
+		if err != nil {
+			s.finishJob(job, err)
+			return
+		}
+
+		if len(pictures) == 0 {
+			s.finishJob(job, nil)
+			return
+		}
+
+		for _, eachPicture := range pictures {
+			s.queue <- eachPicture.ID
+		}
+
+		s.jobsLock.Lock()
+		job.Total = int(totalCount)
+		job.Enqueued += len(pictures)
+		s.jobsLock.Unlock()
+	}
+}
+
+func (s *processingService) finishJob(job *dto.ProcessingJob, err error) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+
+	finishedOn := time.Now()
+	job.FinishedOn = &finishedOn
+	job.Status = JobCompleted
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		log.Printf("Reprocessing job %s failed: %v", job.Id, err)
+	}
+}
+
+func (s *processingService) GetJob(id string) (*dto.ProcessingJob, error) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+
+	snapshot := *job
+	return &snapshot, nil
+}
+
+// generateThumbnail encodes the thumbnail without the ICC profile of the
+// picture to keep it small.
+func (s *processingService) generateThumbnail(picture *db.Picture, _ []byte, source image.Image) error {
+	// the preview rendered on upload is the thumbnail of PDFs
+	if picture.ContentType == storage.PDFContentType && picture.ThumbnailDestination != "" {
+		return nil
+	}
+
+	thumbnail := imaging.Flatten(imaging.Thumbnail(source, s.thumbnailSize), color.White)
+
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, thumbnail, &jpeg.Options{Quality: 80}); err != nil {
+		return err
+	}
+
+	saved, saveError := s.storage.SaveReader(context.Background(), "thumbnail.jpg", &buffer)
+	if saveError != nil {
+		return saveError.Error
+	}
+
+	picture.ThumbnailDestination = saved.Destination
+	return nil
+}
+
+// The bounds of the animated thumbnails.
+const (
+	animatedThumbnailSize = 200
+	animatedThumbnailFPS  = 10
+)
+
+// generateAnimatedThumbnail stores a small copy of the GIFs with several
+// frames, next to their static thumbnail of the first frame.
+func (s *processingService) generateAnimatedThumbnail(picture *db.Picture, data []byte, _ image.Image) error {
+	picture.AnimatedThumbnailDestination = ""
+	if picture.ContentType != "image/gif" {
+		return nil
+	}
+
+	decoded, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if len(decoded.Image) < 2 {
+		return nil
+	}
+
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, imaging.AnimatedThumbnail(decoded, animatedThumbnailSize, animatedThumbnailFPS)); err != nil {
+		return err
+	}
+
+	saved, saveError := s.storage.SaveReader(context.Background(), "thumbnail.gif", &buffer)
+	if saveError != nil {
+		return saveError.Error
+	}
+
+	picture.AnimatedThumbnailDestination = saved.Destination
+	return nil
+}
+
+func (s *processingService) computePerceptualHash(picture *db.Picture, _ []byte, source image.Image) error {
+	picture.PerceptualHash = fmt.Sprintf("%016x", imaging.DifferenceHash(source))
+	return nil
+}
+
+func (s *processingService) extractICCProfile(picture *db.Picture, data []byte, _ image.Image) error {
+	profile, err := imaging.ExtractICCProfile(data, picture.ContentType)
+	if errors.Is(err, imaging.ErrNoICCProfile) {
+		picture.ICCProfile = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	picture.ICCProfile = profile
+	return nil
+}
+
+func (s *processingService) extractXMP(picture *db.Picture, data []byte, _ image.Image) error {
+	packet, err := imaging.ExtractXMP(data, picture.ContentType)
+	picture.XMPData = packet
+	if errors.Is(err, imaging.ErrNoXMP) {
+		return nil
+	}
+	return err
+}
+
+// extractIPTC stores the IPTC metadata of the picture, using its caption as
+// the description when there's none and adding its keywords to the tags.
+func (s *processingService) extractIPTC(picture *db.Picture, data []byte, _ image.Image) error {
+	iptc, err := imaging.ExtractIPTC(data, picture.ContentType)
+	if errors.Is(err, imaging.ErrNoIPTC) {
+		picture.IPTCData = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	picture.IPTCData = iptc
+	if picture.Description == "" {
+		picture.Description = iptc.Caption
+	}
+
+	for _, eachKeyword := range iptc.Keywords {
+		if !slices.Contains(picture.Tags, eachKeyword) {
+			picture.Tags = append(picture.Tags, eachKeyword)
+		}
+	}
+	return nil
+}
+
+func (s *processingService) extractGPS(picture *db.Picture, data []byte, _ image.Image) error {
+	picture.Latitude, picture.Longitude, picture.Altitude = nil, nil, nil
+
+	gps, err := imaging.ExtractGPS(data, picture.ContentType)
+	if errors.Is(err, imaging.ErrNoGPS) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	picture.Latitude, picture.Longitude, picture.Altitude = &gps.Latitude, &gps.Longitude, gps.Altitude
+	return nil
+}
+
+func (s *processingService) assessQuality(picture *db.Picture, _ []byte, source image.Image) error {
+	score := imaging.AssessQuality(source).Score
+	picture.QualityScore = &score
+	return nil
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"imagenexus/dto"
+	"imagenexus/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReprocessing(t *testing.T) {
+	repo := NewFakeRepository()
+	for i := 0; i < 5; i++ {
+		repo.Create(context.Background(), &dto.PictureRequest{Name: "picture", ContentType: "image/png"})
+	}
+	svc := NewProcessingService(repo, NewFakeStorage(), webhook.NewDispatcher(nil, ""), 200)
+
+	t.Run("missing picture", func(t *testing.T) {
+		_, err := svc.Process(100)
+		assert.ErrorIs(t, err, ErrPictureNotFound)
+	})
+
+	t.Run("reprocess all", func(t *testing.T) {
+		job := svc.ReprocessAll(2)
+		assert.Equal(t, JobRunning, job.Status)
+
+		assert.Eventually(t, func() bool {
+			job, err := svc.GetJob(job.Id)
+			return err == nil && job.Status == JobCompleted
+		}, time.Second, 10*time.Millisecond)
+
+		job, _ = svc.GetJob(job.Id)
+		assert.Equal(t, 5, job.Total)
+		assert.Equal(t, 5, job.Enqueued)
+		assert.NotNil(t, job.FinishedOn)
+	})
+
+	t.Run("missing job", func(t *testing.T) {
+		_, err := svc.GetJob("unknown")
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"imagenexus/dto"
+	"imagenexus/imaging"
+)
+
+// GetQuality assesses the quality of the picture, whose score is also
+// stored by the processing pipeline for the min_quality searches.
+func (s *picturesService) GetQuality(id int) (*dto.PictureQuality, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	quality := imaging.AssessQuality(decoded)
+	return &dto.PictureQuality{
+		Sharpness:     quality.Sharpness,
+		NoiseEstimate: quality.NoiseEstimate,
+		QualityScore:  quality.Score,
+	}, nil
+}
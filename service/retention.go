@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/webhook"
+)
+
+// retentionBatchSize is the number of expired pictures removed at a time.
+const retentionBatchSize = 100
+
+// retentionActor is the actor of the expirations in the audit log.
+const retentionActor = "retention"
+
+// RetentionService removes for good the pictures never viewed within
+// server.retentionDays of their upload, unless their retain_until override
+// keeps them longer, along with their files.
+type RetentionService interface {
+	ExpireUnviewed(int) (*dto.PurgeReport, error)
+	Start(time.Duration)
+}
+
+type retentionService struct {
+	maintenance *maintenanceService
+	events      webhook.Dispatcher
+	now         func() time.Time
+}
+
+func NewRetentionService(repository db.PicturesRepository, imageStorage storage.ImageStorage, videos storage.VideoStorage, events webhook.Dispatcher) RetentionService {
+	return &retentionService{
+		maintenance: &maintenanceService{repository: repository, storage: imageStorage, videos: videos},
+		events:      events,
+		now:         time.Now,
+	}
+}
+
+// ExpireUnviewed removes the pictures uploaded more than days ago and never
+// viewed, sending a picture.expired event for each of them first. The files
+// are removed along with the rows, unless another picture, live or soft
+// deleted, has the same contents. The files that can't be deleted are
+// reported, and left to the reconciliation.
+func (s *retentionService) ExpireUnviewed(days int) (*dto.PurgeReport, error) {
+	repository := s.maintenance.repository
+	now := s.now()
+	createdBefore := now.AddDate(0, 0, -days).UnixMilli()
+	ctx := db.WithActor(context.Background(), retentionActor)
+
+	report := &dto.PurgeReport{Pictures: []uint{}, Files: []string{}}
+	// the tile sets are found by listing the storage, once a picture expires
+	var tilesets map[int][]string
+	for {
+		pictures, err := repository.GetExpired(createdBefore, now.UnixMilli(), retentionBatchSize)
+		if err != nil {
+			return report, err
+		}
+
+		removed := 0
+		for _, eachPicture := range pictures {
+			s.events.Send(webhook.EventPictureExpired, eachPicture.ToPictureResponse())
+			var files, videos, errs []string
+			err := repository.Expire(ctx, int(eachPicture.ID), now.UnixMilli(), func(file db.PictureFile) error {
+				// the videos are left in place while video uploads are disabled
+				err := s.maintenance.deleteFile(file)
+				switch {
+				case errors.Is(err, ErrVideosDisabled):
+				case err != nil:
+					errs = append(errs, fmt.Sprintf("picture %d: %v", eachPicture.ID, err))
+				case file.Video:
+					videos = append(videos, file.Destination)
+				default:
+					files = append(files, file.Destination)
+				}
+				return nil
+			})
+			if errors.Is(err, db.ErrNotExpired) {
+				continue
+			}
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("picture %d: %v", eachPicture.ID, err))
+				continue
+			}
+			removed++
+			report.Pictures = append(report.Pictures, eachPicture.ID)
+
+			if tilesets == nil {
+				tilesets = listTilesets(s.maintenance.storage)
+			}
+			for _, destination := range tilesets[int(eachPicture.ID)] {
+				if err := s.maintenance.storage.Delete(destination); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					errs = append(errs, fmt.Sprintf("picture %d: %v", eachPicture.ID, err))
+					continue
+				}
+				files = append(files, destination)
+			}
+			report.Files = append(report.Files, files...)
+			report.Videos = append(report.Videos, videos...)
+			report.Errors = append(report.Errors, errs...)
+		}
+
+		// the pictures left over are listed again until none is removed
+		if len(pictures) < retentionBatchSize || removed == 0 {
+			break
+		}
+	}
+	return report, nil
+}
+
+// Start removes the expired pictures every interval, unless
+// server.retentionDays is 0.
+func (s *retentionService) Start(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			days := config.GetConfigInt("server.retentionDays")
+			if days <= 0 {
+				continue
+			}
+
+			report, err := s.ExpireUnviewed(days)
+			if err != nil {
+				log.Printf("Unable to remove the pictures unviewed for %d days: %v", days, err)
+			}
+			if len(report.Pictures) > 0 {
+				log.Printf("Removed %d pictures unviewed for %d days, with %d files", len(report.Pictures), days, len(report.Files))
+			}
+			for _, eachError := range report.Errors {
+				log.Printf("Unable to remove an expired picture: %s", eachError)
+			}
+		}
+	}()
+}
+
+// SetRetention keeps the picture past server.retentionDays until
+// retainUntil, or removes the override when it's nil.
+func (s *picturesService) SetRetention(id int, retainUntil *time.Time) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	var until int64
+	if retainUntil != nil {
+		until = retainUntil.UnixMilli()
+	}
+
+	picture, err := s.repository.UpdateRetainUntil(id, until)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotFound,
+			Error:      err,
+		}
+	}
+	return picture.ToPictureResponse(), nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingDispatcher struct {
+	events []string
+}
+
+func (d *recordingDispatcher) Send(event string, _ any) {
+	d.events = append(d.events, event)
+}
+
+func TestRetention(t *testing.T) {
+	repo := NewFakeRepository()
+	images := storage.NewStorage(t.TempDir())
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	events := &recordingDispatcher{}
+	svc := NewRetentionService(repo, images, nil, events)
+
+	// expired, viewed, retained, recent, and recent with the contents of the
+	// expired one
+	ids := []int{}
+	for i, eachSize := range []int{4, 5, 6, 7, 4} {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("picture.png", newTestPNG(eachSize, eachSize).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		ids = append(ids, int(created.Id))
+		repo.data[int(created.Id)].CreatedOn = time.Now().UnixMilli()
+		if i < 3 {
+			repo.data[int(created.Id)].CreatedOn = time.Now().AddDate(0, 0, -40).UnixMilli()
+		}
+	}
+	expired, shared := repo.data[ids[0]], repo.data[ids[4]]
+	assert.Equal(t, expired.Destination, shared.Destination)
+	assert.Nil(t, repo.RecordView(ids[1], time.Now().UnixMilli()))
+	retainUntil := time.Now().AddDate(0, 1, 0)
+	retained, retentionError := pictures.SetRetention(ids[2], &retainUntil)
+	if assert.Nil(t, retentionError) && assert.NotNil(t, retained.RetainUntil) {
+		assert.Equal(t, retainUntil.UnixMilli(), retained.RetainUntil.UnixMilli())
+	}
+
+	report, err := svc.ExpireUnviewed(30)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []uint{expired.ID}, report.Pictures)
+		assert.Empty(t, report.Files)
+		assert.Empty(t, report.Errors)
+	}
+	assert.Equal(t, []string{webhook.EventPictureExpired}, events.events)
+	assert.NotContains(t, repo.data, ids[0])
+	assert.Len(t, repo.data, 4)
+
+	// the file goes once no picture has it
+	delete(repo.data, ids[4])
+	repo.data[ids[3]].CreatedOn = time.Now().AddDate(0, 0, -40).UnixMilli()
+	report, err = svc.ExpireUnviewed(30)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []uint{uint(ids[3])}, report.Pictures)
+		assert.Len(t, report.Files, 1)
+	}
+
+	_, retentionError = pictures.SetRetention(ids[0], nil)
+	assert.NotNil(t, retentionError)
+}
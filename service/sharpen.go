@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net/http"
+
+	"imagenexus/dto"
+	"imagenexus/imaging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSharpenAmount is the largest multiple of the difference with the blur
+// added back by the unsharp mask.
+const maxSharpenAmount = 5.0
+
+var ErrSharpenAmount = errors.New("the sharpen amount must be above 0 and at most 5")
+
+var ErrSharpenThreshold = errors.New("the sharpen threshold must be between 0 and 255")
+
+// Sharpen saves a copy of the picture sharpened with an unsharp mask as a
+// new picture, in the same format.
+func (s *picturesService) Sharpen(id int, mask *dto.UnsharpMask) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if !(mask.Amount > 0 && mask.Amount <= maxSharpenAmount) {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrSharpenAmount,
+		}
+	}
+	if mask.Threshold < 0 || mask.Threshold > 255 {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrSharpenThreshold,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	maxRadius := int(min(picture.Width, picture.Height) / 2)
+	if mask.Radius < 1 || mask.Radius > maxRadius {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrBlurRadius,
+			Data:       gin.H{"max_radius": maxRadius},
+		}
+	}
+
+	requestData, saveError := s.saveConverted(picture, "sharpened", func(src image.Image) image.Image {
+		return imaging.UnsharpMask(src, mask.Radius, mask.Amount, uint8(mask.Threshold))
+	})
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.create(context.Background(), requestData)
+}
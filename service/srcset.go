@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"imagenexus/config"
+	"imagenexus/dto"
+)
+
+// srcsetWidths are the breakpoints of the srcset candidates.
+var srcsetWidths = []int{320, 640, 960, 1280, 1920}
+
+// srcsetFormats are the IIIF formats of the candidates, JPEG unless the
+// picture may be transparent.
+var srcsetFormats = map[string]string{
+	"image/png":  "png",
+	"image/gif":  "png",
+	"image/webp": "png",
+}
+
+// GetSrcset lists the scaled down copies of the picture at the breakpoints
+// narrower than it, along with the picture at its own width. The copies are
+// resized on request by the IIIF image endpoint, nothing is resized here.
+func (s *picturesService) GetSrcset(id int) (*dto.Srcset, error) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, err
+	}
+
+	format, ok := srcsetFormats[picture.ContentType]
+	if !ok {
+		format = "jpg"
+	}
+
+	width, height := int(picture.Width), int(picture.Height)
+	widths := []int{}
+	for _, eachWidth := range srcsetWidths {
+		if eachWidth < width {
+			widths = append(widths, eachWidth)
+		}
+	}
+	widths = append(widths, width)
+
+	srcset := &dto.Srcset{Images: make([]*dto.SrcsetImage, 0, len(widths))}
+	candidates := make([]string, 0, len(widths))
+	for _, eachWidth := range widths {
+		url := fmt.Sprintf("%s/iiif/%d/full/%d,/0/default.%s", config.APIBaseURL(), picture.ID, eachWidth, format)
+		srcset.Images = append(srcset.Images, &dto.SrcsetImage{
+			Url:    url,
+			Width:  eachWidth,
+			Height: max(1, int(math.Round(float64(eachWidth)*float64(height)/float64(width)))),
+		})
+		candidates = append(candidates, fmt.Sprintf("%s %dw", url, eachWidth))
+	}
+	srcset.Srcset = strings.Join(candidates, ", ")
+	return srcset, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"imagenexus/dto"
+	"imagenexus/imaging"
+)
+
+// suspiciousEmbeddingProbability is the probability of hidden data above
+// which the pictures are reported as suspicious.
+const suspiciousEmbeddingProbability = 0.95
+
+// CheckSteganography looks for data hidden in the least significant bits of
+// the pixels of the picture. It only tells the obvious cases, e.g. messages
+// spread over the whole picture, and the lossy formats don't keep the least
+// significant bits of their pixels anyway.
+func (s *picturesService) CheckSteganography(id int) (*dto.StegCheck, *dto.InvalidPictureFileError) {
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	chiSquare, probability := imaging.ChiSquareLSB(decoded)
+	return &dto.StegCheck{
+		Suspicious:           probability > suspiciousEmbeddingProbability,
+		ChiSquare:            chiSquare,
+		EmbeddingProbability: probability,
+	}, nil
+}
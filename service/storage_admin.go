@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/utils"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var ErrLifecycleNotSupported = errors.New("the configured storage backend doesn't support lifecycle rules")
+
+type InvalidLifecycleRuleError struct {
+	Reason string
+}
+
+func (e *InvalidLifecycleRuleError) Error() string {
+	return fmt.Sprintf("invalid lifecycle rule: %s", e.Reason)
+}
+
+type StorageAdminService interface {
+	ListLifecycleRules() ([]*dto.LifecycleRule, error)
+	PutLifecycleRule(*dto.LifecycleRule) (*dto.LifecycleRule, error)
+	DeleteLifecycleRule(string) error
+}
+
+type storageAdminService struct {
+	storage storage.ImageStorage
+}
+
+func NewStorageAdminService(storage storage.ImageStorage) StorageAdminService {
+	return &storageAdminService{storage}
+}
+
+func (s *storageAdminService) lifecycleManager() (storage.LifecycleManager, error) {
+	manager, ok := storage.Capability[storage.LifecycleManager](s.storage)
+	if !ok {
+		return nil, ErrLifecycleNotSupported
+	}
+	return manager, nil
+}
+
+func (s *storageAdminService) ListLifecycleRules() ([]*dto.LifecycleRule, error) {
+	manager, err := s.lifecycleManager()
+	if err != nil {
+		return nil, err
+	}
+	return manager.GetLifecycleRules()
+}
+
+func (s *storageAdminService) PutLifecycleRule(rule *dto.LifecycleRule) (*dto.LifecycleRule, error) {
+	manager, err := s.lifecycleManager()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateLifecycleRule(rule); err != nil {
+		return nil, err
+	}
+
+	if rule.Id == "" {
+		rule.Id = utils.NewUniqueString()
+	}
+
+	if err := manager.PutLifecycleRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *storageAdminService) DeleteLifecycleRule(id string) error {
+	manager, err := s.lifecycleManager()
+	if err != nil {
+		return err
+	}
+	return manager.DeleteLifecycleRule(id)
+}
+
+func validateLifecycleRule(rule *dto.LifecycleRule) error {
+	if rule.TransitionDays < 0 || rule.ExpireDays < 0 {
+		return &InvalidLifecycleRuleError{Reason: "days can't be negative"}
+	}
+
+	if rule.TransitionDays == 0 && rule.ExpireDays == 0 {
+		return &InvalidLifecycleRuleError{Reason: "either transition_days or expire_days is required"}
+	}
+
+	if rule.TransitionDays > 0 {
+		validClass := false
+		for _, eachClass := range s3types.TransitionStorageClass("").Values() {
+			if string(eachClass) == rule.TransitionClass {
+				validClass = true
+			}
+		}
+		if !validClass {
+			return &InvalidLifecycleRuleError{Reason: fmt.Sprintf("unknown transition_class %q", rule.TransitionClass)}
+		}
+	}
+
+	if rule.ExpireDays > 0 && rule.TransitionDays >= rule.ExpireDays {
+		return &InvalidLifecycleRuleError{Reason: "expire_days must come after transition_days"}
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"imagenexus/dto"
+)
+
+// AddTag adds the tag to the picture, unless it already has it. The tags
+// added by hand stay when the picture is reprocessed, the IPTC keywords are
+// added to them.
+func (s *picturesService) AddTag(id int, tag string) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	picture, findError := s.findPicture(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	tag = strings.TrimSpace(tag)
+	if tag == "" || slices.Contains(picture.Tags, tag) {
+		return picture.ToPictureResponse(), nil
+	}
+
+	picture.Tags = append(picture.Tags, tag)
+	if err := s.repository.UpdateTags(id, picture.Tags); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+	return picture.ToPictureResponse(), nil
+}
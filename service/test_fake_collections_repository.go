@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"imagenexus/db"
+)
+
+type fakeCollectionsRepository struct {
+	data     map[int]*db.Collection
+	pictures map[int][]int
+	// the pinned pictures of each collection, by pin order
+	pinned map[int][]uint
+	// looks up the pictures of each collection
+	picturesRepository *fakeRepository
+}
+
+func NewFakeCollectionsRepository(picturesRepository *fakeRepository) *fakeCollectionsRepository {
+	return &fakeCollectionsRepository{
+		data:               map[int]*db.Collection{},
+		pictures:           map[int][]int{},
+		pinned:             map[int][]uint{},
+		picturesRepository: picturesRepository,
+	}
+}
+
+func (f *fakeCollectionsRepository) Create(name string) (*db.Collection, error) {
+	rowId := len(f.data) + 1
+	collection := &db.Collection{
+		ID:        uint(rowId),
+		CreatedOn: time.Now().UnixMilli(),
+		UpdatedOn: time.Now().UnixMilli(),
+		Name:      name,
+	}
+	f.data[rowId] = collection
+	return collection, nil
+}
+
+func (f *fakeCollectionsRepository) GetById(id int) (*db.Collection, error) {
+	if val, ok := f.data[id]; ok {
+		return val, nil
+	}
+	return nil, errors.New("unable to find")
+}
+
+func (f *fakeCollectionsRepository) Delete(id int) error {
+	if _, ok := f.data[id]; ok {
+		delete(f.data, id)
+		delete(f.pictures, id)
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeCollectionsRepository) AddPicture(id, pictureId int) error {
+	for _, eachId := range f.pictures[id] {
+		if eachId == pictureId {
+			return nil
+		}
+	}
+	f.pictures[id] = append(f.pictures[id], pictureId)
+	return nil
+}
+
+func (f *fakeCollectionsRepository) RemovePicture(id, pictureId int) error {
+	for i, eachId := range f.pictures[id] {
+		if eachId == pictureId {
+			f.pictures[id] = append(f.pictures[id][:i], f.pictures[id][i+1:]...)
+			f.pinned[id] = slices.DeleteFunc(f.pinned[id], func(eachId uint) bool { return eachId == uint(pictureId) })
+			return nil
+		}
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeCollectionsRepository) GetPictures(id int) ([]*db.Picture, error) {
+	pictures := []*db.Picture{}
+	for _, eachId := range f.pinned[id] {
+		if picture, ok := f.picturesRepository.data[int(eachId)]; ok {
+			pictures = append(pictures, picture)
+		}
+	}
+	for _, eachId := range f.pictures[id] {
+		if picture, ok := f.picturesRepository.data[eachId]; ok && !slices.Contains(f.pinned[id], uint(eachId)) {
+			pictures = append(pictures, picture)
+		}
+	}
+	return pictures, nil
+}
+
+func (f *fakeCollectionsRepository) Pin(id, pictureId int) error {
+	if !slices.Contains(f.pictures[id], pictureId) {
+		return errors.New("unable to find")
+	}
+	if !slices.Contains(f.pinned[id], uint(pictureId)) {
+		f.pinned[id] = append(f.pinned[id], uint(pictureId))
+	}
+	return nil
+}
+
+func (f *fakeCollectionsRepository) Unpin(id, pictureId int) error {
+	if !slices.Contains(f.pictures[id], pictureId) {
+		return errors.New("unable to find")
+	}
+	f.pinned[id] = slices.DeleteFunc(f.pinned[id], func(eachId uint) bool { return eachId == uint(pictureId) })
+	return nil
+}
+
+func (f *fakeCollectionsRepository) GetPinned(id int) ([]uint, error) {
+	return append([]uint{}, f.pinned[id]...), nil
+}
+
+func (f *fakeCollectionsRepository) ReorderPins(id int, pictureIds []uint) error {
+	f.pinned[id] = append([]uint{}, pictureIds...)
+	return nil
+}
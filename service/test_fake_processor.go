@@ -0,0 +1,13 @@
+package service
+
+type fakeProcessor struct {
+	enqueued []uint
+}
+
+func NewFakeProcessor() *fakeProcessor {
+	return &fakeProcessor{enqueued: []uint{}}
+}
+
+func (p *fakeProcessor) Enqueue(id uint) {
+	p.enqueued = append(p.enqueued, id)
+}
@@ -1,7 +1,11 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"math"
+	"slices"
+	"sort"
 	"time"
 
 	"imagenexus/db"
@@ -10,6 +14,10 @@ import (
 
 type fakeRepository struct {
 	data map[int]*db.Picture
+	// the previous versions recorded by Update
+	versions []*db.PictureVersion
+	// the ids of the pictures given to RecordCorruption
+	corruptions []int
 }
 
 func NewFakeRepository() *fakeRepository {
@@ -18,33 +26,66 @@ func NewFakeRepository() *fakeRepository {
 	}
 }
 
-func (f *fakeRepository) Create(request *dto.PictureRequest) (*db.Picture, error) {
+func (f *fakeRepository) Create(_ context.Context, request *dto.PictureRequest) (*db.Picture, error) {
 	rowId := len(f.data) + 1
 	picture := &db.Picture{
 		ID:          uint(rowId),
 		CreatedOn:   time.Now().Unix(),
 		UpdatedOn:   time.Now().Unix(),
 		Deleted:     false,
+		Version:     1,
 		Name:        request.Name,
 		Destination: request.Destination,
 		Height:      request.Height,
 		Width:       request.Width,
 		Size:        request.Size,
 		ContentType: request.ContentType,
+		Checksum:    request.Checksum,
+		IsAnimated:  request.IsAnimated,
+		IsGrayscale: request.IsGrayscale,
+		Description: request.Description,
+
+		ThumbnailDestination: request.ThumbnailDestination,
+		ModerationStatus:     request.ModerationStatus,
+		StorageTier:          db.TierHot,
+	}
+	if request.Video != nil {
+		picture.VideoDestination = request.Video.Destination
+		picture.VideoContentType = request.Video.ContentType
+		if metadata := request.Video.Metadata; metadata != nil {
+			picture.DurationSeconds = metadata.DurationSeconds
+			picture.FrameRate = metadata.FrameRate
+			picture.VideoCodec = metadata.VideoCodec
+		}
 	}
 	f.data[rowId] = picture
 	return picture, nil
 }
 
-func (f *fakeRepository) Update(id int, request *dto.PictureRequest) (*db.Picture, error) {
+func (f *fakeRepository) Update(_ context.Context, id int, request *dto.PictureRequest) (*db.Picture, error) {
 	rowId := uint(id)
 	for _, eachRow := range f.data {
 		if eachRow.ID == rowId {
+			if request.Version != 0 && request.Version != eachRow.Version {
+				return nil, &db.VersionConflictError{CurrentVersion: eachRow.Version}
+			}
+			f.versions = append(f.versions, &db.PictureVersion{
+				PictureID:   eachRow.ID,
+				Version:     eachRow.Version,
+				Destination: eachRow.Destination,
+				ContentType: eachRow.ContentType,
+				Size:        eachRow.Size,
+				Checksum:    eachRow.Checksum,
+				StorageTier: eachRow.StorageTier,
+				ReplacedOn:  time.Now().Unix(),
+			})
+
 			updatedPicture := &db.Picture{
 				ID:        eachRow.ID,
 				CreatedOn: eachRow.CreatedOn,
 				UpdatedOn: time.Now().Unix(),
 				Deleted:   false,
+				Version:   eachRow.Version + 1,
 
 				Name:        request.Name,
 				Destination: request.Destination,
@@ -52,6 +93,19 @@ func (f *fakeRepository) Update(id int, request *dto.PictureRequest) (*db.Pictur
 				Width:       request.Width,
 				Size:        request.Size,
 				ContentType: request.ContentType,
+				Checksum:    request.Checksum,
+				IsAnimated:  request.IsAnimated,
+				IsGrayscale: request.IsGrayscale,
+
+				ModerationStatus: eachRow.ModerationStatus,
+				StorageTier:      eachRow.StorageTier,
+				LastViewedOn:     eachRow.LastViewedOn,
+			}
+			if request.ModerationStatus != "" {
+				updatedPicture.ModerationStatus = request.ModerationStatus
+			}
+			if request.StorageTier != "" {
+				updatedPicture.StorageTier = request.StorageTier
 			}
 			f.data[id] = updatedPicture
 			return updatedPicture, nil
@@ -61,8 +115,11 @@ func (f *fakeRepository) Update(id int, request *dto.PictureRequest) (*db.Pictur
 	return nil, errors.New("unable to find")
 }
 
-func (f *fakeRepository) Delete(id int) error {
-	if _, ok := f.data[id]; ok {
+func (f *fakeRepository) Delete(ctx context.Context, id int, version int) error {
+	if val, ok := f.data[id]; ok {
+		if version != 0 && version != val.Version {
+			return &db.VersionConflictError{CurrentVersion: val.Version}
+		}
 		delete(f.data, id)
 		return nil
 	}
@@ -70,29 +127,170 @@ func (f *fakeRepository) Delete(id int) error {
 }
 
 func (f *fakeRepository) GetAll(limit, page int) ([]*db.Picture, int64, error) {
+	return f.page(false, limit, page)
+}
+
+func (f *fakeRepository) GetDeleted(limit, page int) ([]*db.Picture, int64, error) {
+	return f.page(true, limit, page)
+}
+
+func (f *fakeRepository) page(deleted bool, limit, page int) ([]*db.Picture, int64, error) {
+	keys := []int{}
+	for eachKey, eachPicture := range f.data {
+		if eachPicture.Deleted == deleted {
+			keys = append(keys, eachKey)
+		}
+	}
+	sort.Ints(keys)
+
 	start := (page - 1) * limit
-	end := start + limit + 1
+	end := start + limit
+
+	if start >= len(keys) {
+		return []*db.Picture{}, int64(len(keys)), nil
+	}
+
+	if end > len(keys) {
+		end = len(keys)
+	}
+
+	limitedKeys := keys[start:end]
+	response := []*db.Picture{}
+	for _, eachKey := range limitedKeys {
+		response = append(response, f.data[eachKey])
+	}
+
+	return response, int64(len(keys)), nil
+}
+
+func (f *fakeRepository) GetAfter(deleted bool, afterId uint, limit int) ([]*db.Picture, error) {
+	keys := []int{}
+	for eachKey, eachPicture := range f.data {
+		if eachPicture.Deleted == deleted && eachPicture.ID > afterId {
+			keys = append(keys, eachKey)
+		}
+	}
+	sort.Ints(keys)
+
+	response := []*db.Picture{}
+	for _, eachKey := range keys[:min(limit, len(keys))] {
+		response = append(response, f.data[eachKey])
+	}
+	return response, nil
+}
+
+func (f *fakeRepository) Purge(id int, remove func(db.PictureFile) error) error {
+	val, ok := f.data[id]
+	if !ok || !val.Deleted {
+		return errors.New("unable to find")
+	}
+	if err := f.removeUnreferenced(val, remove); err != nil {
+		return err
+	}
+	f.deletePicture(val)
+	return nil
+}
+
+func (f *fakeRepository) deletePicture(picture *db.Picture) {
+	f.versions = slices.DeleteFunc(f.versions, func(version *db.PictureVersion) bool { return version.PictureID == picture.ID })
+	delete(f.data, int(picture.ID))
+}
 
-	if start >= len(f.data) {
-		return []*db.Picture{}, int64(len(f.data)), nil
+func (f *fakeRepository) removeUnreferenced(picture *db.Picture, remove func(db.PictureFile) error) error {
+	files := picture.Files()
+	for _, eachVersion := range f.versions {
+		file := db.PictureFile{Destination: eachVersion.Destination}
+		if eachVersion.PictureID == picture.ID && !slices.Contains(files, file) {
+			files = append(files, file)
+		}
 	}
 
-	if end > len(f.data) {
-		end = len(f.data)
+	for _, file := range files {
+		referenced := false
+		for _, eachPicture := range f.data {
+			if eachPicture.ID == picture.ID {
+				continue
+			}
+			if file.Video {
+				referenced = referenced || eachPicture.VideoDestination == file.Destination
+			} else {
+				referenced = referenced || slices.Contains(eachPicture.ImageFiles(), file.Destination)
+			}
+		}
+		for _, eachVersion := range f.versions {
+			referenced = referenced || (!file.Video && eachVersion.PictureID != picture.ID && eachVersion.Destination == file.Destination)
+		}
+		if referenced {
+			continue
+		}
+		if err := remove(file); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
+func (f *fakeRepository) Search(filter *dto.PictureFilter, limit, page int) ([]*db.Picture, int64, error) {
 	keys := []int{}
-	for eachKey := range f.data {
+	for eachKey, eachPicture := range f.data {
+		if filter.Grayscale != nil && eachPicture.IsGrayscale != *filter.Grayscale {
+			continue
+		}
+		if filter.MinQuality != nil && (eachPicture.QualityScore == nil || *eachPicture.QualityScore < *filter.MinQuality) {
+			continue
+		}
+		if box := filter.Box; box != nil {
+			if eachPicture.Latitude == nil || eachPicture.Longitude == nil {
+				continue
+			}
+
+			lat, lon := *eachPicture.Latitude, *eachPicture.Longitude
+			inLon := lon >= box.LonMin && lon <= box.LonMax
+			if box.LonMin > box.LonMax {
+				inLon = lon >= box.LonMin || lon <= box.LonMax
+			}
+			if lat < box.LatMin || lat > box.LatMax || !inLon {
+				continue
+			}
+		}
 		keys = append(keys, eachKey)
 	}
+	sort.Ints(keys)
 
-	limitedKeys := keys[start:end]
+	start := min((page-1)*limit, len(keys))
+	end := min(start+limit, len(keys))
 	response := []*db.Picture{}
-	for _, eachKey := range limitedKeys {
+	for _, eachKey := range keys[start:end] {
 		response = append(response, f.data[eachKey])
 	}
 
-	return response, int64(len(f.data)), nil
+	return response, int64(len(keys)), nil
+}
+
+func (f *fakeRepository) GetNearby(lat, lon, radiusKm float64, limit, page int) ([]*db.PictureDistance, int64, error) {
+	matches := []*db.PictureDistance{}
+	for _, eachPicture := range f.data {
+		if eachPicture.Latitude == nil || eachPicture.Longitude == nil {
+			continue
+		}
+
+		distance := haversineKm(lat, lon, *eachPicture.Latitude, *eachPicture.Longitude)
+		if distance <= radiusKm {
+			matches = append(matches, &db.PictureDistance{Picture: *eachPicture, DistanceKm: distance})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].DistanceKm < matches[j].DistanceKm })
+
+	start := min((page-1)*limit, len(matches))
+	end := min(start+limit, len(matches))
+	return matches[start:end], int64(len(matches)), nil
+}
+
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
+	a := math.Pow(math.Sin(toRadians(lat2-lat1)/2), 2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Pow(math.Sin(toRadians(lon2-lon1)/2), 2)
+	return 2 * 6371 * math.Asin(math.Min(1, math.Sqrt(a)))
 }
 
 func (f *fakeRepository) GetById(id int) (*db.Picture, error) {
@@ -101,3 +299,205 @@ func (f *fakeRepository) GetById(id int) (*db.Picture, error) {
 	}
 	return nil, errors.New("unable to find")
 }
+
+func (f *fakeRepository) UpdateComputed(picture *db.Picture) error {
+	if val, ok := f.data[int(picture.ID)]; ok {
+		val.ThumbnailDestination = picture.ThumbnailDestination
+		val.AnimatedThumbnailDestination = picture.AnimatedThumbnailDestination
+		val.PerceptualHash = picture.PerceptualHash
+		val.ICCProfile = picture.ICCProfile
+		val.XMPData = picture.XMPData
+		val.IPTCData = picture.IPTCData
+		val.Description = picture.Description
+		val.Tags = picture.Tags
+		val.Latitude = picture.Latitude
+		val.Longitude = picture.Longitude
+		val.Altitude = picture.Altitude
+		val.QualityScore = picture.QualityScore
+		val.ProcessedOn = picture.ProcessedOn
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeRepository) UpdateModeration(id int, status, reason string) (*db.Picture, error) {
+	if val, ok := f.data[id]; ok {
+		val.ModerationStatus = status
+		val.ModerationReason = reason
+		val.UpdatedOn = time.Now().Unix()
+		return val, nil
+	}
+	return nil, errors.New("unable to find")
+}
+
+func (f *fakeRepository) GetByModerationStatus(status string, limit, page int) ([]*db.Picture, int64, error) {
+	keys := []int{}
+	for eachKey, eachPicture := range f.data {
+		if eachPicture.ModerationStatus == status {
+			keys = append(keys, eachKey)
+		}
+	}
+	sort.Ints(keys)
+
+	start := min((page-1)*limit, len(keys))
+	end := min(start+limit, len(keys))
+	response := []*db.Picture{}
+	for _, eachKey := range keys[start:end] {
+		response = append(response, f.data[eachKey])
+	}
+
+	return response, int64(len(keys)), nil
+}
+
+func (f *fakeRepository) UpdateStorageTier(destination string, tier string) error {
+	for _, eachPicture := range f.data {
+		if eachPicture.Destination == destination {
+			eachPicture.StorageTier = tier
+		}
+	}
+	for _, eachVersion := range f.versions {
+		if eachVersion.Destination == destination {
+			eachVersion.StorageTier = tier
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepository) RecordChecksumVerified(destination string, verifiedOn int64) error {
+	for _, eachPicture := range f.data {
+		if eachPicture.Destination == destination {
+			eachPicture.ChecksumVerifiedOn = verifiedOn
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepository) GetVersions(id int) ([]*db.PictureVersion, error) {
+	versions := []*db.PictureVersion{}
+	for _, eachVersion := range f.versions {
+		if eachVersion.PictureID == uint(id) {
+			versions = append(versions, eachVersion)
+		}
+	}
+	return versions, nil
+}
+
+func (f *fakeRepository) GetVersionFiles(deleted bool) (map[uint][]string, error) {
+	files := map[uint][]string{}
+	for _, eachVersion := range f.versions {
+		if picture, ok := f.data[int(eachVersion.PictureID)]; ok && picture.Deleted == deleted {
+			files[eachVersion.PictureID] = append(files[eachVersion.PictureID], eachVersion.Destination)
+		}
+	}
+	return files, nil
+}
+
+func (f *fakeRepository) UpdateInterlacedDestination(id int, destination string) error {
+	if val, ok := f.data[id]; ok {
+		val.InterlacedDestination = destination
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeRepository) UpdateTags(id int, tags []string) error {
+	if val, ok := f.data[id]; ok {
+		val.Tags = tags
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeRepository) UpdateDestinations(picture *db.Picture) error {
+	if val, ok := f.data[int(picture.ID)]; ok {
+		val.Destination = picture.Destination
+		val.ThumbnailDestination = picture.ThumbnailDestination
+		val.AnimatedThumbnailDestination = picture.AnimatedThumbnailDestination
+		val.InterlacedDestination = picture.InterlacedDestination
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeRepository) RecordView(id int, viewedOn int64) error {
+	if val, ok := f.data[id]; ok {
+		val.LastViewedOn = viewedOn
+		val.ViewCount++
+		return nil
+	}
+	return errors.New("unable to find")
+}
+
+func (f *fakeRepository) GetUnviewedSince(tier string, viewedOn int64, limit int) ([]*db.Picture, error) {
+	viewed := map[string]bool{}
+	for _, eachPicture := range f.data {
+		if !eachPicture.Deleted && max(eachPicture.LastViewedOn, eachPicture.CreatedOn) >= viewedOn {
+			viewed[eachPicture.Destination] = true
+		}
+	}
+
+	matches := []*db.Picture{}
+	for _, eachPicture := range f.data {
+		if !eachPicture.Deleted && eachPicture.StorageTier == tier && !viewed[eachPicture.Destination] {
+			matches = append(matches, eachPicture)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return max(matches[i].LastViewedOn, matches[i].CreatedOn) < max(matches[j].LastViewedOn, matches[j].CreatedOn)
+	})
+
+	return matches[:min(limit, len(matches))], nil
+}
+
+func (f *fakeRepository) UpdateRetainUntil(id int, retainUntil int64) (*db.Picture, error) {
+	if val, ok := f.data[id]; ok && !val.Deleted {
+		val.RetainUntil = retainUntil
+		return val, nil
+	}
+	return nil, errors.New("unable to find")
+}
+
+func (f *fakeRepository) isExpired(picture *db.Picture, now int64) bool {
+	return !picture.Deleted && picture.ViewCount == 0 && (picture.RetainUntil == 0 || picture.RetainUntil < now)
+}
+
+func (f *fakeRepository) GetExpired(createdBefore, now int64, limit int) ([]*db.Picture, error) {
+	matches := []*db.Picture{}
+	for _, eachPicture := range f.data {
+		if f.isExpired(eachPicture, now) && eachPicture.CreatedOn < createdBefore {
+			matches = append(matches, eachPicture)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+
+	return matches[:min(limit, len(matches))], nil
+}
+
+func (f *fakeRepository) Expire(_ context.Context, id int, now int64, remove func(db.PictureFile) error) error {
+	val, ok := f.data[id]
+	if !ok || !f.isExpired(val, now) {
+		return db.ErrNotExpired
+	}
+	if err := f.removeUnreferenced(val, remove); err != nil {
+		return err
+	}
+	f.deletePicture(val)
+	return nil
+}
+
+func (f *fakeRepository) RecordCorruption(_ context.Context, id int, details any) error {
+	f.corruptions = append(f.corruptions, id)
+	return nil
+}
+
+func (f *fakeRepository) GetSizeByTier() (map[string]int64, error) {
+	sizes := map[string]int64{}
+	counted := map[string]bool{}
+	for _, eachPicture := range f.data {
+		if !counted[eachPicture.Destination] {
+			counted[eachPicture.Destination] = true
+			sizes[eachPicture.StorageTier] += int64(eachPicture.Size)
+		}
+	}
+	return sizes, nil
+}
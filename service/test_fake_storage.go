@@ -1,7 +1,10 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"io"
 	"mime/multipart"
 	"path/filepath"
 
@@ -26,7 +29,7 @@ func (s *fakeStorage) GetFullPath(destination string) string {
 	return s.BaseDirectory + "/" + destination
 }
 
-func (s *fakeStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+func (s *fakeStorage) Save(_ context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
 	randomFileName := utils.NewUniqueString() + "----" + file.Filename
 	destination := randomFileName + filepath.Ext(file.Filename)
 	pictureFile := &dto.PictureRequest{
@@ -41,9 +44,47 @@ func (s *fakeStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest, *dt
 	return pictureFile, nil
 }
 
+func (s *fakeStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{Error: err}
+	}
+	return s.Save(ctx, &multipart.FileHeader{Filename: filename, Size: int64(len(content))})
+}
+
 func (s *fakeStorage) Get(destination string) ([]byte, error) {
 	if val, ok := s.Contents[destination]; ok {
 		return val, nil
 	}
 	return nil, errors.New("unable to find")
 }
+
+func (s *fakeStorage) GetStream(destination string) (io.ReadCloser, string, error) {
+	if val, ok := s.Contents[destination]; ok {
+		return io.NopCloser(bytes.NewReader(val)), "image/jpeg", nil
+	}
+	return nil, "", errors.New("unable to find")
+}
+
+type fakeReader struct {
+	*bytes.Reader
+}
+
+func (r *fakeReader) Close() error {
+	return nil
+}
+
+func (s *fakeStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	if val, ok := s.Contents[destination]; ok {
+		return &fakeReader{bytes.NewReader(val)}, nil
+	}
+	return nil, errors.New("unable to find")
+}
+
+func (s *fakeStorage) Delete(destination string) error {
+	if _, ok := s.Contents[destination]; ok {
+		delete(s.Contents, destination)
+		return nil
+	}
+	return errors.New("unable to find")
+}
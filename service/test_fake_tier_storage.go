@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+
+	"imagenexus/dto"
+	"imagenexus/storage"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeTierStorage adds storage classes and restores to a storage, restoring
+// the archived files once restored is called. The saved files are written in
+// the standard class, as by S3.
+type fakeTierStorage struct {
+	storage.ImageStorage
+	classes  map[string]s3types.StorageClass
+	restores map[string]string
+}
+
+func NewFakeTierStorage(images storage.ImageStorage) *fakeTierStorage {
+	return &fakeTierStorage{
+		ImageStorage: images,
+		classes:      map[string]s3types.StorageClass{},
+		restores:     map[string]string{},
+	}
+}
+
+func (s *fakeTierStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.standard(s.ImageStorage.Save(ctx, file))
+}
+
+func (s *fakeTierStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.standard(s.ImageStorage.SaveReader(ctx, filename, src))
+}
+
+func (s *fakeTierStorage) standard(picture *dto.PictureRequest, saveError *dto.InvalidPictureFileError) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	if saveError == nil {
+		delete(s.classes, picture.Destination)
+		delete(s.restores, picture.Destination)
+	}
+	return picture, saveError
+}
+
+func (s *fakeTierStorage) SetStorageClass(destination string, class s3types.StorageClass) error {
+	s.classes[destination] = class
+	delete(s.restores, destination)
+	return nil
+}
+
+func (s *fakeTierStorage) GetRestoreState(destination string) (string, error) {
+	if s.classes[destination] != s3types.StorageClassGlacier {
+		return storage.ObjectAvailable, nil
+	}
+	if state, ok := s.restores[destination]; ok {
+		return state, nil
+	}
+	return storage.ObjectArchived, nil
+}
+
+func (s *fakeTierStorage) Restore(destination string, _ int32) error {
+	s.restores[destination] = storage.ObjectRestoring
+	return nil
+}
+
+func (s *fakeTierStorage) restored(destination string) {
+	s.restores[destination] = storage.ObjectAvailable
+}
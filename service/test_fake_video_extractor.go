@@ -0,0 +1,31 @@
+package service
+
+import (
+	"imagenexus/dto"
+)
+
+// fakeVideoExtractor stands in for ffprobe and ffmpeg, describing every
+// video with metadata and frame.
+type fakeVideoExtractor struct {
+	metadata *dto.VideoMetadata
+	frame    []byte
+	err      error
+}
+
+func NewFakeVideoExtractor(metadata *dto.VideoMetadata, frame []byte) *fakeVideoExtractor {
+	return &fakeVideoExtractor{metadata: metadata, frame: frame}
+}
+
+func (e *fakeVideoExtractor) Probe(string) (*dto.VideoMetadata, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.metadata, nil
+}
+
+func (e *fakeVideoExtractor) FirstFrame(string) ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.frame, nil
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"imagenexus/config"
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/storage"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var ErrTiersNotSupported = errors.New("the configured storage backend doesn't support storage tiers")
+
+var ErrPictureArchived = errors.New("the picture is archived, request its restore first")
+
+var ErrUnknownTier = errors.New("unknown storage tier")
+
+// restoreRetryAfter is how long clients are told to wait for a restore, the
+// shortest time standard Glacier retrievals take.
+const restoreRetryAfter = 3 * time.Hour
+
+// tierBatchSize is the number of pictures moved to the warm tier at a time.
+const tierBatchSize = 100
+
+// tierStorageClasses are the S3 storage classes of the tiers. Glacier
+// Instant Retrieval objects can't be restored, they're always readable, so
+// the cold tier uses Glacier Flexible Retrieval.
+var tierStorageClasses = map[string]s3types.StorageClass{
+	db.TierHot:  s3types.StorageClassStandard,
+	db.TierWarm: s3types.StorageClassStandardIa,
+	db.TierCold: s3types.StorageClassGlacier,
+}
+
+// TierService moves the picture files between the storage tiers, moving the
+// hot pictures to the warm tier once they haven't been viewed for
+// storage.tiers.warmAfterDays.
+type TierService interface {
+	SetTier(int, string) (*dto.PictureResponse, error)
+	Restore(int) (*dto.RestoreStatus, error)
+	MoveUnviewed(int) (int, error)
+	Start(time.Duration)
+}
+
+type tierService struct {
+	repository db.PicturesRepository
+	storage    storage.ImageStorage
+}
+
+func NewTierService(repository db.PicturesRepository, storage storage.ImageStorage) TierService {
+	return &tierService{repository, storage}
+}
+
+func (s *tierService) tierManager() (storage.TierManager, error) {
+	manager, ok := storage.Capability[storage.TierManager](s.storage)
+	if !ok {
+		return nil, ErrTiersNotSupported
+	}
+	return manager, nil
+}
+
+func (s *tierService) getPicture(id int) (*db.Picture, error) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPictureNotFound, err)
+	}
+	return picture, nil
+}
+
+// SetTier moves the file of the picture to the storage class of the tier.
+// Cold pictures have to be restored first.
+func (s *tierService) SetTier(id int, tier string) (*dto.PictureResponse, error) {
+	if _, ok := tierStorageClasses[tier]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTier, tier)
+	}
+
+	picture, err := s.getPicture(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if picture.StorageTier != tier {
+		if err := s.moveTier(picture, tier); err != nil {
+			return nil, err
+		}
+	}
+	return picture.ToPictureResponse(), nil
+}
+
+// moveTier moves the file of the picture, along with the pictures with the
+// same contents sharing it.
+func (s *tierService) moveTier(picture *db.Picture, tier string) error {
+	manager, err := s.tierManager()
+	if err != nil {
+		return err
+	}
+
+	if err := checkRestored(s.storage, picture); err != nil {
+		return err
+	}
+
+	if err := manager.SetStorageClass(picture.Destination, tierStorageClasses[tier]); err != nil {
+		return err
+	}
+	if err := s.repository.UpdateStorageTier(picture.Destination, tier); err != nil {
+		return err
+	}
+
+	picture.StorageTier = tier
+	return nil
+}
+
+// Restore requests a copy of the archived file of a cold picture, kept for
+// storage.tiers.restoreDays. The files of the other tiers are available.
+func (s *tierService) Restore(id int) (*dto.RestoreStatus, error) {
+	picture, err := s.getPicture(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if picture.StorageTier != db.TierCold {
+		return &dto.RestoreStatus{Status: storage.ObjectAvailable}, nil
+	}
+
+	manager, err := s.tierManager()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := manager.GetRestoreState(picture.Destination)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == storage.ObjectArchived {
+		if err := manager.Restore(picture.Destination, int32(config.GetConfigInt("storage.tiers.restoreDays"))); err != nil {
+			return nil, err
+		}
+		state = storage.ObjectRestoring
+	}
+
+	status := &dto.RestoreStatus{Status: state}
+	if state == storage.ObjectRestoring {
+		status.RetryAfterSeconds = int(restoreRetryAfter.Seconds())
+	}
+	return status, nil
+}
+
+// MoveUnviewed moves the hot pictures neither viewed nor uploaded for days
+// to the warm tier, returning how many files were moved. It stops at the
+// first batch with a failure, which would be listed again.
+func (s *tierService) MoveUnviewed(days int) (int, error) {
+	if _, err := s.tierManager(); err != nil {
+		return 0, err
+	}
+
+	viewedOn := time.Now().AddDate(0, 0, -days).UnixMilli()
+	moved := 0
+	for {
+		pictures, err := s.repository.GetUnviewedSince(db.TierHot, viewedOn, tierBatchSize)
+		if err != nil {
+			return moved, err
+		}
+
+		var errs []error
+		// the pictures with the same contents moved along with the first one
+		movedFiles := map[string]bool{}
+		for _, eachPicture := range pictures {
+			if movedFiles[eachPicture.Destination] {
+				continue
+			}
+			movedFiles[eachPicture.Destination] = true
+			if err := s.moveTier(eachPicture, db.TierWarm); err != nil {
+				errs = append(errs, fmt.Errorf("picture %d: %w", eachPicture.ID, err))
+				continue
+			}
+			moved++
+		}
+
+		if len(errs) > 0 {
+			return moved, errors.Join(errs...)
+		}
+		if len(pictures) < tierBatchSize {
+			return moved, nil
+		}
+	}
+}
+
+// Start moves the unviewed pictures to the warm tier every interval, unless
+// storage.tiers.warmAfterDays is 0 or the storage has no tiers.
+func (s *tierService) Start(interval time.Duration) {
+	if _, err := s.tierManager(); err != nil {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			days := config.GetConfigInt("storage.tiers.warmAfterDays")
+			if days <= 0 {
+				continue
+			}
+
+			moved, err := s.MoveUnviewed(days)
+			if err != nil {
+				log.Printf("Unable to move unviewed pictures to the warm tier: %v", err)
+			}
+			if moved > 0 {
+				log.Printf("Moved %d files unviewed for %d days to the warm tier", moved, days)
+			}
+		}
+	}()
+}
+
+// resetTier marks the pictures sharing the file just saved as hot, the
+// storages with tiers writing the saves in the standard class.
+func resetTier(repository db.PicturesRepository, images storage.ImageStorage, destination string) error {
+	if _, ok := storage.Capability[storage.TierManager](images); !ok {
+		return nil
+	}
+	return repository.UpdateStorageTier(destination, db.TierHot)
+}
+
+// restoreError is the error of checkRestored for the services answering with
+// an InvalidPictureFileError, a conflict while the picture is archived.
+func restoreError(err error) *dto.InvalidPictureFileError {
+	statusCode := http.StatusInternalServerError
+	if errors.Is(err, ErrPictureArchived) {
+		statusCode = http.StatusConflict
+	}
+	return &dto.InvalidPictureFileError{
+		StatusCode: statusCode,
+		Error:      err,
+	}
+}
+
+// checkRestored returns ErrPictureArchived when the file of a cold picture
+// can't be read until it's restored.
+func checkRestored(images storage.ImageStorage, picture *db.Picture) error {
+	if picture.StorageTier != db.TierCold {
+		return nil
+	}
+
+	manager, ok := storage.Capability[storage.TierManager](images)
+	if !ok {
+		return nil
+	}
+
+	state, err := manager.GetRestoreState(picture.Destination)
+	if err != nil {
+		return err
+	}
+	if state != storage.ObjectAvailable {
+		return ErrPictureArchived
+	}
+	return nil
+}
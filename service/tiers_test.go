@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color/palette"
+	"image/gif"
+	"net/http"
+	"testing"
+	"time"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/iiif"
+	"imagenexus/storage"
+	"imagenexus/utils"
+	"imagenexus/webhook"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageTiers(t *testing.T) {
+	repo := NewFakeRepository()
+	images := NewFakeTierStorage(storage.NewStorage(t.TempDir()))
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewTierService(repo, images)
+
+	ids := []int{}
+	for i, eachName := range []string{"old.png", "viewed.png"} {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent(eachName, newTestPNG(4, 4+i).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		assert.Equal(t, db.TierHot, created.StorageTier)
+		ids = append(ids, int(created.Id))
+		repo.data[int(created.Id)].CreatedOn = time.Now().AddDate(0, 0, -40).UnixMilli()
+	}
+	old := repo.data[ids[0]]
+
+	reader, _, _, err := pictures.GetFileReader(ids[1])
+	if assert.Nil(t, err) {
+		reader.Close()
+	}
+
+	moved, err := svc.MoveUnviewed(30)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, db.TierWarm, old.StorageTier)
+	assert.Equal(t, s3types.StorageClassStandardIa, images.classes[old.Destination])
+	assert.Equal(t, db.TierHot, repo.data[ids[1]].StorageTier)
+
+	cold, err := svc.SetTier(ids[0], db.TierCold)
+	assert.Nil(t, err)
+	assert.Equal(t, db.TierCold, cold.StorageTier)
+	assert.Equal(t, s3types.StorageClassGlacier, images.classes[old.Destination])
+
+	_, _, _, err = pictures.GetFileReader(ids[0])
+	assert.ErrorIs(t, err, ErrPictureArchived)
+	_, _, err = pictures.GetInternalRedirect(ids[0])
+	assert.ErrorIs(t, err, ErrPictureArchived)
+	_, err = svc.SetTier(ids[0], db.TierHot)
+	assert.ErrorIs(t, err, ErrPictureArchived)
+
+	for range 2 {
+		status, err := svc.Restore(ids[0])
+		assert.Nil(t, err)
+		assert.Equal(t, storage.ObjectRestoring, status.Status)
+		assert.Equal(t, int(restoreRetryAfter.Seconds()), status.RetryAfterSeconds)
+	}
+
+	images.restored(old.Destination)
+	status, err := svc.Restore(ids[0])
+	assert.Nil(t, err)
+	assert.Equal(t, storage.ObjectAvailable, status.Status)
+
+	reader, _, _, err = pictures.GetFileReader(ids[0])
+	if assert.Nil(t, err) {
+		reader.Close()
+	}
+
+	_, err = svc.SetTier(ids[0], "lukewarm")
+	assert.ErrorIs(t, err, ErrUnknownTier)
+	_, err = svc.Restore(100)
+	assert.ErrorIs(t, err, ErrPictureNotFound)
+}
+
+func TestStorageTiersNotSupported(t *testing.T) {
+	svc := NewTierService(NewFakeRepository(), NewFakeStorage())
+
+	_, err := svc.MoveUnviewed(30)
+	assert.ErrorIs(t, err, ErrTiersNotSupported)
+}
+
+func TestStorageTiersSharedFile(t *testing.T) {
+	repo := NewFakeRepository()
+	images := NewFakeTierStorage(storage.NewStorage(t.TempDir()))
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewTierService(repo, images)
+
+	ids := []int{}
+	for _, eachName := range []string{"first.png", "second.png"} {
+		created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent(eachName, newTestPNG(4, 4).Bytes()), "")
+		if !assert.Nil(t, createError) {
+			return
+		}
+		ids = append(ids, int(created.Id))
+	}
+	destination := repo.data[ids[0]].Destination
+	assert.Equal(t, destination, repo.data[ids[1]].Destination)
+
+	_, err := svc.SetTier(ids[0], db.TierCold)
+	assert.Nil(t, err)
+	assert.Equal(t, db.TierCold, repo.data[ids[1]].StorageTier)
+	_, _, _, err = pictures.GetFileReader(ids[1])
+	assert.ErrorIs(t, err, ErrPictureArchived)
+
+	// the same contents uploaded again are written in the standard class
+	created, createError := pictures.Create(context.Background(), utils.NewTestFileWithContent("third.png", newTestPNG(4, 4).Bytes()), "")
+	if !assert.Nil(t, createError) {
+		return
+	}
+	assert.Equal(t, destination, repo.data[int(created.Id)].Destination)
+	for _, id := range append(ids, int(created.Id)) {
+		assert.Equal(t, db.TierHot, repo.data[id].StorageTier)
+		reader, _, _, err := pictures.GetFileReader(id)
+		if assert.Nil(t, err) {
+			reader.Close()
+		}
+	}
+
+	// the file viewed through one of the pictures stays hot
+	for _, id := range ids {
+		repo.data[id].CreatedOn = time.Now().AddDate(0, 0, -40).UnixMilli()
+		repo.data[id].LastViewedOn = 0
+	}
+	repo.data[int(created.Id)].CreatedOn = time.Now().AddDate(0, 0, -40).UnixMilli()
+	moved, err := svc.MoveUnviewed(30)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, moved)
+
+	repo.data[int(created.Id)].LastViewedOn = 0
+	moved, err = svc.MoveUnviewed(30)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, moved)
+	for _, id := range append(ids, int(created.Id)) {
+		assert.Equal(t, db.TierWarm, repo.data[id].StorageTier)
+	}
+	assert.Equal(t, s3types.StorageClassStandardIa, images.classes[destination])
+}
+
+func TestColdPictureNotCopied(t *testing.T) {
+	repo := NewFakeRepository()
+	images := NewFakeTierStorage(storage.NewStorage(t.TempDir()))
+	pictures := NewPicturesService(repo, images, NewFakeProcessor(), webhook.NewDispatcher(nil, ""), nil)
+	svc := NewTierService(repo, images)
+
+	var content bytes.Buffer
+	assert.Nil(t, gif.Encode(&content, image.NewPaletted(image.Rect(0, 0, 4, 4), palette.Plan9), nil))
+	cold := createTestPicture(t, pictures, "cold.gif", content.Bytes())
+	id := int(cold.Id)
+	_, err := svc.SetTier(id, db.TierCold)
+	assert.Nil(t, err)
+
+	iiifService := NewIIIFService(repo, images)
+	collections := NewCollectionsService(NewFakeCollectionsRepository(repo), repo, images, pictures)
+	collection, err := collections.Create(&dto.CollectionRequest{Name: "cold"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	_, err = collections.AddPicture(int(collection.Id), id)
+	assert.Nil(t, err)
+
+	request, _ := iiif.ParseRequest("full", "max", "0", "default.png")
+	cases := map[string]func() *dto.InvalidPictureFileError{
+		"iiif image": func() *dto.InvalidPictureFileError {
+			_, _, renderError := iiifService.Render(id, request)
+			return renderError
+		},
+		"frames": func() *dto.InvalidPictureFileError {
+			_, listError := pictures.ListFrames(id)
+			return listError
+		},
+		"alpha": func() *dto.InvalidPictureFileError {
+			_, alphaError := pictures.ChangeAlpha(id, AlphaStrip)
+			return alphaError
+		},
+		"grayscale": func() *dto.InvalidPictureFileError {
+			_, toneError := pictures.ChangeTone(id, ToneGrayscale)
+			return toneError
+		},
+		"placeholder": func() *dto.InvalidPictureFileError {
+			_, placeholderError := pictures.GetPlaceholder(id, 16)
+			return placeholderError
+		},
+		"sprite": func() *dto.InvalidPictureFileError {
+			_, spriteError := collections.Sprite(int(collection.Id), 0)
+			return spriteError
+		},
+	}
+
+	for name, each := range cases {
+		t.Run(name, func(t *testing.T) {
+			archivedError := each()
+			if assert.NotNil(t, archivedError) {
+				assert.Equal(t, http.StatusConflict, archivedError.StatusCode)
+				assert.ErrorIs(t, archivedError.Error, ErrPictureArchived)
+			}
+		})
+	}
+
+	images.restored(repo.data[id].Destination)
+	_, listError := pictures.ListFrames(id)
+	assert.Nil(t, listError)
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"imagenexus/config"
+	"imagenexus/deepzoom"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+)
+
+var ErrTilesetsNotSupported = errors.New("the configured storage backend can't store tile sets")
+
+// tilesetDescriptor is the destination of the .dzi document of the tile
+// set of the picture, next to the directory of its tiles, see tilePath.
+func tilesetDescriptor(id int) string {
+	return fmt.Sprintf("tilesets/%d.dzi", id)
+}
+
+func tilePath(id, level, column, row int) string {
+	return fmt.Sprintf("tilesets/%d_files/%d/%d_%d.%s", id, level, column, row, deepzoom.Format)
+}
+
+// tilesetPictureId returns the id of the picture a file of a tile set
+// belongs to, the descriptor or one of the tiles.
+func tilesetPictureId(destination string) (int, bool) {
+	name, ok := strings.CutPrefix(destination, "tilesets/")
+	if !ok {
+		return 0, false
+	}
+	end := strings.IndexAny(name, "._")
+	if end <= 0 {
+		return 0, false
+	}
+	id, err := strconv.Atoi(name[:end])
+	return id, err == nil
+}
+
+// CreateTileset cuts the picture into the JPEG tiles of a Deep Zoom tile
+// set, replacing its previous tile set. The transparent pixels are
+// flattened on white. The descriptor is written last, once all the tiles are
+// in place.
+func (s *picturesService) CreateTileset(id int) (*dto.Tileset, *dto.InvalidPictureFileError) {
+	writer, ok := storage.Capability[storage.FileWriter](s.storage)
+	if !ok {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotImplemented,
+			Error:      ErrTilesetsNotSupported,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	tileCount := 0
+	err := deepzoom.Generate(imaging.Flatten(decoded, color.White), func(level, column, row int, tile image.Image) error {
+		var buffer bytes.Buffer
+		if err := jpeg.Encode(&buffer, tile, &jpeg.Options{Quality: 90}); err != nil {
+			return err
+		}
+		tileCount++
+		return writer.Put(tilePath(id, level, column, row), "image/jpeg", &buffer)
+	})
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	bounds := decoded.Bounds()
+	descriptor := deepzoom.NewDescriptor(bounds.Dx(), bounds.Dy())
+	body, err := descriptor.Marshal()
+	if err == nil {
+		err = writer.Put(tilesetDescriptor(id), deepzoom.ContentType, bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	tilesetURL := fmt.Sprintf("%s/picture/%d/tileset", config.APIBaseURL(), id)
+	return &dto.Tileset{
+		Descriptor: tilesetURL,
+		Tiles:      tilesetURL + "/{level}/{column}_{row}." + deepzoom.Format,
+		Width:      descriptor.Size.Width,
+		Height:     descriptor.Size.Height,
+		TileSize:   descriptor.TileSize,
+		Overlap:    descriptor.Overlap,
+		Format:     descriptor.Format,
+		MaxLevel:   descriptor.MaxLevel(),
+		TileCount:  tileCount,
+	}, nil
+}
+
+// GetTilesetDescriptor returns the .dzi document of the tile set of the
+// picture.
+func (s *picturesService) GetTilesetDescriptor(id int) ([]byte, error) {
+	if _, err := s.getServedPicture(id); err != nil {
+		return nil, err
+	}
+	return s.storage.Get(tilesetDescriptor(id))
+}
+
+// GetTile opens a tile of the tile set of the picture. The caller is
+// responsible for closing the reader.
+func (s *picturesService) GetTile(id, level, column, row int) (io.ReadSeekCloser, error) {
+	if _, err := s.getServedPicture(id); err != nil {
+		return nil, err
+	}
+	return s.storage.GetReader(tilePath(id, level, column, row))
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"net/http"
+	"path"
+	"strings"
+
+	"imagenexus/db"
+	"imagenexus/dto"
+	"imagenexus/imaging"
+	"imagenexus/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ToneGrayscale = "grayscale"
+	ToneSepia     = "sepia"
+)
+
+var ErrUnknownTone = errors.New("the tone must be grayscale or sepia")
+
+// tones are the conversions of the pixels of the pictures, by tone.
+var tones = map[string]func(image.Image) image.Image{
+	ToneGrayscale: imaging.Grayscale,
+	ToneSepia:     func(src image.Image) image.Image { return imaging.Sepia(src) },
+}
+
+// ChangeTone saves a copy of the picture converted to grayscale or toned
+// sepia as a new picture, in the same format. The grayscale copies are
+// flagged as such.
+func (s *picturesService) ChangeTone(id int, tone string) (*dto.PictureResponse, *dto.InvalidPictureFileError) {
+	if tone == "" {
+		tone = ToneGrayscale
+	}
+	convert, ok := tones[tone]
+	if !ok {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      ErrUnknownTone,
+		}
+	}
+
+	picture, findError := s.getPictureToConvert(id)
+	if findError != nil {
+		return nil, findError
+	}
+
+	requestData, saveError := s.saveConverted(picture, tone, convert)
+	if saveError != nil {
+		return nil, saveError
+	}
+
+	requestData.IsGrayscale = tone == ToneGrayscale
+	return s.create(context.Background(), requestData)
+}
+
+// getPictureToConvert finds the picture to save a converted copy of, which
+// the moderators mustn't have rejected.
+func (s *picturesService) getPictureToConvert(id int) (*db.Picture, *dto.InvalidPictureFileError) {
+	picture, err := s.getServedPicture(id)
+	if err != nil {
+		return nil, servedPictureError(err)
+	}
+	return picture, nil
+}
+
+// findPicture finds the picture whose row is changed, even if the
+// moderators rejected it.
+func (s *picturesService) findPicture(id int) (*db.Picture, *dto.InvalidPictureFileError) {
+	picture, err := s.repository.GetById(id)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotFound,
+			Error:      err,
+		}
+	}
+	return picture, nil
+}
+
+// decodePicture reads and decodes the file of the picture, once restored
+// when it's cold.
+func (s *picturesService) decodePicture(picture *db.Picture) (image.Image, *dto.InvalidPictureFileError) {
+	if err := checkRestored(s.storage, picture); err != nil {
+		return nil, restoreError(err)
+	}
+
+	data, err := s.storage.Get(picture.Destination)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	decoded, err := storage.DecodeImage(data, picture.ContentType)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+	return decoded, nil
+}
+
+// saveConverted stores a copy of the picture with converted pixels, in the
+// same format, named with the suffix. Animated pictures keep their first
+// frame only.
+func (s *picturesService) saveConverted(picture *db.Picture, suffix string, convert func(image.Image) image.Image) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	if _, ok := storage.IMAGE_ENCODERS[picture.ContentType]; !ok {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusNotImplemented,
+			Error:      storage.ErrNoEncoder,
+			Data:       gin.H{"format": picture.ContentType},
+		}
+	}
+
+	decoded, decodeError := s.decodePicture(picture)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+
+	var buffer bytes.Buffer
+	if err := storage.EncodeImage(&buffer, convert(decoded), picture.ContentType); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	extension := path.Ext(picture.Name)
+	name := strings.TrimSuffix(picture.Name, extension) + "-" + suffix + extension
+	return s.storage.SaveReader(context.Background(), name, &buffer)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"imagenexus/config"
+	"imagenexus/dto"
+	"imagenexus/storage"
+	"imagenexus/validation"
+	"imagenexus/video"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VideoService turns uploaded videos into the pictures of their first
+// frames, keeping the videos themselves in the video storage.
+type VideoService interface {
+	Ingest(context.Context, string, string, io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError)
+	GetReader(string) (io.ReadSeekCloser, error)
+}
+
+type videoService struct {
+	images    storage.ImageStorage
+	videos    storage.VideoStorage
+	extractor video.Extractor
+}
+
+func NewVideoService(images storage.ImageStorage, videos storage.VideoStorage, extractor video.Extractor) VideoService {
+	return &videoService{images, videos, extractor}
+}
+
+// MaxVideoUploadSize is the largest accepted video upload in bytes, 0 when
+// unlimited. It replaces server.maxUploadSize for videos.
+func MaxVideoUploadSize() int64 {
+	return int64(config.GetConfigInt("video.maxUploadSize"))
+}
+
+// Ingest probes the video and stores its first frame as a JPEG picture,
+// named after the video and described by its metadata. Cancelling ctx aborts
+// the uploads of the video and of the frame.
+func (s *videoService) Ingest(ctx context.Context, filename, contentType string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	// ffprobe and ffmpeg seek through the file, e.g. to the index at the end
+	// of MP4s, so they are given a copy on disk
+	spooled, err := os.CreateTemp("", "video-upload-*")
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+	defer os.Remove(spooled.Name())
+	defer spooled.Close()
+
+	if _, err := io.Copy(spooled, src); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	metadata, err := s.extractor.Probe(spooled.Name())
+	if err != nil {
+		return nil, extractionError(err, contentType)
+	}
+
+	frame, err := s.extractor.FirstFrame(spooled.Name())
+	if err != nil {
+		return nil, extractionError(err, contentType)
+	}
+
+	if _, err := spooled.Seek(0, io.SeekStart); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	destination, err := s.videos.SaveVideo(ctx, filename, contentType, spooled)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	frameName := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".jpg"
+	requestData, saveError := s.images.SaveReader(ctx, frameName, bytes.NewReader(frame))
+	if saveError != nil {
+		return nil, saveError
+	}
+
+	requestData.Name = filename
+	requestData.Video = &dto.VideoFile{Destination: destination, ContentType: contentType, Metadata: metadata}
+	return requestData, nil
+}
+
+func (s *videoService) GetReader(destination string) (io.ReadSeekCloser, error) {
+	return s.videos.GetReader(destination)
+}
+
+// extractionError blames the video for the failures of ffprobe and ffmpeg,
+// unless they aren't installed.
+func extractionError(err error, contentType string) *dto.InvalidPictureFileError {
+	if errors.Is(err, exec.ErrNotFound) {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+	return &dto.InvalidPictureFileError{
+		StatusCode: http.StatusBadRequest,
+		Error:      err,
+		Data:       gin.H{"format": contentType},
+	}
+}
+
+// sniffContentType detects the content type of the uploaded file from its
+// first bytes.
+func sniffContentType(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	header := make([]byte, 512)
+	read, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	return validation.DetectContentType(header[:read]), nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"imagenexus/dto"
+
+	"github.com/sony/gobreaker"
+)
+
+// ErrCircuitOpen is returned without calling the storage while its circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("the image storage is unavailable")
+
+// CircuitStater is implemented by the storages guarded by a circuit
+// breaker, see NewCircuitBreakerStorage.
+type CircuitStater interface {
+	// CircuitState is closed, half-open or open.
+	CircuitState() string
+}
+
+// circuitBreakerStorage fails the calls to a storage right away once it
+// failed threshold times in a row, until a call succeeds after the timeout.
+// The missing files and the rejected uploads aren't failures of the storage.
+type circuitBreakerStorage struct {
+	ImageStorage
+	breaker *gobreaker.CircuitBreaker
+}
+
+func NewCircuitBreakerStorage(storage ImageStorage, threshold uint32, timeout time.Duration) ImageStorage {
+	breaker := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:    "storage",
+		Timeout: timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= threshold
+		},
+		IsSuccessful: func(err error) bool {
+			var notFound *S3NotFoundError
+			// nor are the uploads cancelled by their clients
+			return err == nil || errors.Is(err, fs.ErrNotExist) || errors.As(err, &notFound) || errors.Is(err, context.Canceled)
+		},
+	})
+	return &circuitBreakerStorage{ImageStorage: storage, breaker: breaker}
+}
+
+// Unwrap returns the guarded storage, see Capability.
+func (s *circuitBreakerStorage) Unwrap() ImageStorage {
+	return s.ImageStorage
+}
+
+func (s *circuitBreakerStorage) CircuitState() string {
+	return s.breaker.State().String()
+}
+
+func (s *circuitBreakerStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.save(func() (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+		return s.ImageStorage.Save(ctx, file)
+	})
+}
+
+func (s *circuitBreakerStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.save(func() (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+		return s.ImageStorage.SaveReader(ctx, filename, src)
+	})
+}
+
+// save answers with a 503 while the circuit is open. Only the 5xx failures
+// count, the invalid images are rejected whatever the state of the storage.
+func (s *circuitBreakerStorage) save(save func() (*dto.PictureRequest, *dto.InvalidPictureFileError)) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	var picture *dto.PictureRequest
+	var saveError *dto.InvalidPictureFileError
+	_, err := s.breaker.Execute(func() (any, error) {
+		picture, saveError = save()
+		if saveError != nil && saveError.StatusCode >= http.StatusInternalServerError {
+			return nil, saveError.Error
+		}
+		return nil, nil
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, &dto.InvalidPictureFileError{StatusCode: http.StatusServiceUnavailable, Error: ErrCircuitOpen}
+	}
+	return picture, saveError
+}
+
+func (s *circuitBreakerStorage) Get(destination string) ([]byte, error) {
+	data, err := s.execute(func() (any, error) { return s.ImageStorage.Get(destination) })
+	if err != nil {
+		return nil, err
+	}
+	contents, _ := data.([]byte)
+	return contents, nil
+}
+
+func (s *circuitBreakerStorage) GetStream(destination string) (io.ReadCloser, string, error) {
+	var contentType string
+	stream, err := s.execute(func() (any, error) {
+		stream, streamContentType, err := s.ImageStorage.GetStream(destination)
+		contentType = streamContentType
+		return stream, err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	readCloser, _ := stream.(io.ReadCloser)
+	return readCloser, contentType, nil
+}
+
+func (s *circuitBreakerStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	reader, err := s.execute(func() (any, error) { return s.ImageStorage.GetReader(destination) })
+	if err != nil {
+		return nil, err
+	}
+	readSeekCloser, _ := reader.(io.ReadSeekCloser)
+	return readSeekCloser, nil
+}
+
+func (s *circuitBreakerStorage) Delete(destination string) error {
+	_, err := s.execute(func() (any, error) { return nil, s.ImageStorage.Delete(destination) })
+	return err
+}
+
+// execute runs the call through the breaker, failing with ErrCircuitOpen
+// while it's open.
+func (s *circuitBreakerStorage) execute(call func() (any, error)) (any, error) {
+	result, err := s.breaker.Execute(call)
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, ErrCircuitOpen
+	}
+	return result, err
+}
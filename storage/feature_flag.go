@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"path"
+	"strconv"
+
+	"imagenexus/dto"
+)
+
+// Canary is implemented by the storages copying the files of a share of the
+// pictures to a new backend, see NewFeatureFlagStorage.
+type Canary interface {
+	// RollOut copies the files at the destinations to the new backend in the
+	// background when the picture is in the rollout.
+	RollOut(pictureId uint, destinations ...string)
+}
+
+// featureFlagStorage is the write side canary of a new storage backend. The
+// files of the rolled out share of the pictures are copied to the new
+// backend in the background, the current backend serving every read, so the
+// new backend can be trialled without risk, e.g. before a migration from
+// local to S3 storage. The share is picked by a hash of the picture IDs, so
+// every file of a picture is in or out of the rollout whatever the request.
+// The files are saved before their picture has an ID, so the service rolls
+// them out once it's created, see Canary.
+type featureFlagStorage struct {
+	*replicatingStorage
+	rolloutPercent uint32
+}
+
+// NewFeatureFlagStorage copies the files of rolloutPercent percent of the
+// pictures to candidate.
+func NewFeatureFlagStorage(current, candidate ImageStorage, rolloutPercent int) ImageStorage {
+	return &featureFlagStorage{
+		replicatingStorage: &replicatingStorage{primary: current, backup: candidate},
+		rolloutPercent:     uint32(min(max(rolloutPercent, 0), 100)),
+	}
+}
+
+// InRollout tells whether the files of the picture are written to the new
+// backend.
+func (s *featureFlagStorage) InRollout(pictureId uint) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(strconv.FormatUint(uint64(pictureId), 10)))
+	return hash.Sum32()%100 < s.rolloutPercent
+}
+
+func (s *featureFlagStorage) RollOut(pictureId uint, destinations ...string) {
+	if !s.InRollout(pictureId) {
+		return
+	}
+
+	for _, destination := range destinations {
+		if destination != "" {
+			go s.replicate(destination, path.Base(destination))
+		}
+	}
+}
+
+func (s *featureFlagStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.primary.Save(ctx, file)
+}
+
+func (s *featureFlagStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	return s.primary.SaveReader(ctx, filename, src)
+}
+
+// Delete deletes the copy of the new backend too. It's tried whatever the
+// rollout, which may have been larger when the file was saved, the copy being
+// missing for the files that were never rolled out.
+func (s *featureFlagStorage) Delete(destination string) error {
+	if err := s.primary.Delete(destination); err != nil {
+		return err
+	}
+
+	if err := s.backup.Delete(destination); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Unable to delete the canary copy of %s: %v", destination, err)
+	}
+	return nil
+}
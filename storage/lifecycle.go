@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"imagenexus/dto"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// LifecycleManager is implemented by the storage backends that support
+// object lifecycle rules.
+type LifecycleManager interface {
+	GetLifecycleRules() ([]*dto.LifecycleRule, error)
+	PutLifecycleRule(*dto.LifecycleRule) error
+	DeleteLifecycleRule(string) error
+}
+
+type LifecycleRuleNotFoundError struct {
+	Id string
+}
+
+func (e *LifecycleRuleNotFoundError) Error() string {
+	return fmt.Sprintf("lifecycle rule %q not found", e.Id)
+}
+
+func (s *s3ImageStorage) GetLifecycleRules() ([]*dto.LifecycleRule, error) {
+	rules, err := s.getBucketLifecycleRules()
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*dto.LifecycleRule, 0, len(rules))
+	for _, eachRule := range rules {
+		response = append(response, toLifecycleRule(eachRule))
+	}
+	return response, nil
+}
+
+// PutLifecycleRule replaces the bucket rule with the same id, or adds it.
+func (s *s3ImageStorage) PutLifecycleRule(rule *dto.LifecycleRule) error {
+	rules, err := s.getBucketLifecycleRules()
+	if err != nil {
+		return err
+	}
+
+	updated := []s3types.LifecycleRule{fromLifecycleRule(rule)}
+	for _, eachRule := range rules {
+		if eachRule.ID == nil || *eachRule.ID != rule.Id {
+			updated = append(updated, eachRule)
+		}
+	}
+
+	return s.putBucketLifecycleRules(updated)
+}
+
+func (s *s3ImageStorage) DeleteLifecycleRule(id string) error {
+	rules, err := s.getBucketLifecycleRules()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]s3types.LifecycleRule, 0, len(rules))
+	for _, eachRule := range rules {
+		if eachRule.ID == nil || *eachRule.ID != id {
+			remaining = append(remaining, eachRule)
+		}
+	}
+
+	if len(remaining) == len(rules) {
+		return &LifecycleRuleNotFoundError{Id: id}
+	}
+
+	// a lifecycle configuration can't be saved without rules
+	if len(remaining) == 0 {
+		ctx, cancel := s.operationContext(context.Background())
+		defer cancel()
+		_, err := s.client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: &s.bucket,
+		})
+		return err
+	}
+
+	return s.putBucketLifecycleRules(remaining)
+}
+
+func (s *s3ImageStorage) getBucketLifecycleRules() ([]s3types.LifecycleRule, error) {
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	output, err := s.client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: &s.bucket,
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchLifecycleConfiguration" {
+			return []s3types.LifecycleRule{}, nil
+		}
+		return nil, err
+	}
+	return output.Rules, nil
+}
+
+func (s *s3ImageStorage) putBucketLifecycleRules(rules []s3types.LifecycleRule) error {
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 &s.bucket,
+		LifecycleConfiguration: &s3types.BucketLifecycleConfiguration{Rules: rules},
+	})
+	return err
+}
+
+func fromLifecycleRule(rule *dto.LifecycleRule) s3types.LifecycleRule {
+	id, prefix := rule.Id, rule.Prefix
+	status := s3types.ExpirationStatusEnabled
+	if rule.Disabled {
+		status = s3types.ExpirationStatusDisabled
+	}
+
+	lifecycleRule := s3types.LifecycleRule{
+		ID:     &id,
+		Status: status,
+		Filter: &s3types.LifecycleRuleFilter{Prefix: &prefix},
+	}
+
+	if rule.TransitionDays > 0 {
+		transitionDays := rule.TransitionDays
+		lifecycleRule.Transitions = []s3types.Transition{{
+			Days:         &transitionDays,
+			StorageClass: s3types.TransitionStorageClass(rule.TransitionClass),
+		}}
+	}
+
+	if rule.ExpireDays > 0 {
+		expireDays := rule.ExpireDays
+		lifecycleRule.Expiration = &s3types.LifecycleExpiration{Days: &expireDays}
+	}
+
+	return lifecycleRule
+}
+
+func toLifecycleRule(rule s3types.LifecycleRule) *dto.LifecycleRule {
+	response := &dto.LifecycleRule{Disabled: rule.Status == s3types.ExpirationStatusDisabled}
+	if rule.ID != nil {
+		response.Id = *rule.ID
+	}
+	if rule.Filter != nil && rule.Filter.Prefix != nil {
+		response.Prefix = *rule.Filter.Prefix
+	} else if rule.Prefix != nil {
+		response.Prefix = *rule.Prefix
+	}
+	if len(rule.Transitions) > 0 && rule.Transitions[0].Days != nil {
+		response.TransitionDays = *rule.Transitions[0].Days
+		response.TransitionClass = string(rule.Transitions[0].StorageClass)
+	}
+	if rule.Expiration != nil && rule.Expiration.Days != nil {
+		response.ExpireDays = *rule.Expiration.Days
+	}
+	return response
+}
@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"imagenexus/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+const (
+	cfgMaxFileSizeByType  = "server.maxFileSizeByType"
+	cfgMaxAnimationPixels = "server.maxAnimationPixels"
+)
+
+// defaultMaxAnimationPixels is the limit of server.maxAnimationPixels when
+// it's unset, 200MB of RGBA pixels.
+const defaultMaxAnimationPixels = 50_000_000
+
+// defaultMaxFileSizeKey is the limit of the content types without one of
+// their own in server.maxFileSizeByType.
+const defaultMaxFileSizeKey = "default"
+
+var ErrFileTooLarge = errors.New("the file is too large for its format")
+
+// MaxFileSize returns the largest accepted file of the content type in
+// bytes, from server.maxFileSizeByType, 0 when unlimited. The sizes are
+// numbers of bytes with an optional unit of SIZE_UNITS, e.g. 5MB.
+func MaxFileSize(contentType string) int64 {
+	limits := viper.GetStringMapString(cfgMaxFileSizeByType)
+	limit, ok := limits[strings.ToLower(contentType)]
+	if !ok {
+		limit = limits[defaultMaxFileSizeKey]
+	}
+	if limit == "" {
+		return 0
+	}
+
+	size, err := parseSize(limit)
+	if err != nil {
+		log.Printf("Ignoring the %s limit of %s: %v", contentType, cfgMaxFileSizeByType, err)
+		return 0
+	}
+	return size
+}
+
+// MaxAnimationPixels returns the most pixels decoded from an animated WebP,
+// its canvas and its rendered frames together, from
+// server.maxAnimationPixels.
+func MaxAnimationPixels() int {
+	if pixels := viper.GetInt(cfgMaxAnimationPixels); pixels > 0 {
+		return pixels
+	}
+	return defaultMaxAnimationPixels
+}
+
+// checkFileSize rejects the files larger than the limit of their content
+// type, once their format is detected.
+func checkFileSize(contentType string, size, maxSize int64) *dto.InvalidPictureFileError {
+	if maxSize > 0 && size > maxSize {
+		return &dto.InvalidPictureFileError{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Error:      ErrFileTooLarge,
+			Data:       gin.H{"format": contentType, "max_size": maxSize},
+		}
+	}
+	return nil
+}
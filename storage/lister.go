@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// StoredFile is a file found by a FileLister.
+type StoredFile struct {
+	Destination string
+	Size        int64
+}
+
+// FileLister is implemented by the storage backends that enumerate their
+// files, e.g. to compare them with the pictures of the database.
+type FileLister interface {
+	ListFiles(func(*StoredFile) error) error
+}
+
+// ListFiles walks the storage directory, giving the destinations of the files
+// with forward slashes whatever the OS.
+func (s *localImageStorage) ListFiles(fn func(*StoredFile) error) error {
+	root := filepath.Clean(s.path)
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		return fn(&StoredFile{Destination: filepath.ToSlash(relative), Size: info.Size()})
+	})
+}
+
+// ListFiles lists the objects under the prefix of the storage, a page of
+// 1000 keys at a time.
+func (s *s3ImageStorage) ListFiles(fn func(*StoredFile) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: &s.bucket,
+		Prefix: &s.prefix,
+	})
+	for paginator.HasMorePages() {
+		ctx, cancel := s.operationContext(context.Background())
+		page, err := paginator.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		for _, object := range page.Contents {
+			file := &StoredFile{Destination: (*object.Key)[len(s.prefix):]}
+			if object.Size != nil {
+				file.Size = *object.Size
+			}
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
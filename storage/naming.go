@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// cfgNamingStrategy is the viper key of the naming strategy of the saved
+// images, sha256 when unset.
+const cfgNamingStrategy = "storage.namingStrategy"
+
+// NamingStrategy names the destinations of the saved images. ext is the
+// extension of the uploaded file, along with its dot.
+type NamingStrategy interface {
+	Name(originalName, ext string, contentHash []byte) string
+}
+
+// NAMING_STRATEGIES are the strategies storage.namingStrategy can select.
+var NAMING_STRATEGIES = map[string]NamingStrategy{
+	"uuid":        uuidNaming{},
+	"sha256":      contentNaming{},
+	"original":    originalNaming{},
+	"date_prefix": datePrefixNaming{now: time.Now},
+}
+
+const defaultNamingStrategy = "sha256"
+
+// NewNamingStrategy returns the strategy of the name, the default one for an
+// empty name.
+func NewNamingStrategy(name string) (NamingStrategy, error) {
+	if name == "" {
+		name = defaultNamingStrategy
+	}
+	strategy, ok := NAMING_STRATEGIES[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown naming strategy %q", name)
+	}
+	return strategy, nil
+}
+
+// namingStrategy returns the configured strategy. The setting is validated
+// on startup, so unknown names fall back to the default one.
+func namingStrategy() NamingStrategy {
+	strategy, err := NewNamingStrategy(viper.GetString(cfgNamingStrategy))
+	if err != nil {
+		return NAMING_STRATEGIES[defaultNamingStrategy]
+	}
+	return strategy
+}
+
+// contentNaming names the files after the SHA-256 of their contents, so
+// identical uploads share a single file.
+type contentNaming struct{}
+
+func (contentNaming) Name(_, ext string, contentHash []byte) string {
+	return hex.EncodeToString(contentHash) + ext
+}
+
+type uuidNaming struct{}
+
+func (uuidNaming) Name(_, ext string, _ []byte) string {
+	return uuid.NewString() + ext
+}
+
+var unsafeNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// originalNaming keeps the uploaded filename, stripped of its directories
+// and of the characters unsafe in paths and S3 keys.
+type originalNaming struct{}
+
+func (originalNaming) Name(originalName, ext string, _ []byte) string {
+	base := filepath.Base(strings.ReplaceAll(originalName, `\`, "/"))
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	base = strings.TrimLeft(unsafeNameCharacters.ReplaceAllString(base, "_"), ".")
+	if base == "" {
+		base = "image"
+	}
+	return base + unsafeNameCharacters.ReplaceAllString(ext, "")
+}
+
+// datePrefixNaming files the images under the date of their upload,
+// YYYY/MM/DD/<uuid>.
+type datePrefixNaming struct {
+	now func() time.Time
+}
+
+func (n datePrefixNaming) Name(_, ext string, _ []byte) string {
+	return n.now().UTC().Format("2006/01/02") + "/" + uuid.NewString() + ext
+}
+
+// uniqueDestination returns the destination, suffixed with -1, -2... before
+// its extension when a file is already stored under it. The files named
+// after their contents are shared instead.
+func uniqueDestination(strategy NamingStrategy, destination string, exists func(string) (bool, error)) (string, error) {
+	if _, ok := strategy.(contentNaming); ok {
+		return destination, nil
+	}
+
+	ext := filepath.Ext(destination)
+	base := strings.TrimSuffix(destination, ext)
+	candidate := destination
+	for suffix := 1; ; suffix++ {
+		found, err := exists(candidate)
+		if err != nil || !found {
+			return candidate, err
+		}
+		candidate = base + "-" + strconv.Itoa(suffix) + ext
+	}
+}
+
+var hashedPrefix = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{2}/`)
+
+// HashedDestination files the destination under the first 4 hex characters
+// of the SHA-256 of its contents, e.g. ab/cd/abcdef...jpg, so the S3 keys
+// spread over their partitions instead of sharing a single prefix.
+func HashedDestination(destination, checksum string) string {
+	return checksum[:2] + "/" + checksum[2:4] + "/" + destination
+}
+
+// HasHashedPrefix tells whether the destination was given by
+// HashedDestination.
+func HasHashedPrefix(destination string) bool {
+	return hashedPrefix.MatchString(destination)
+}
+
+// HashedPrefixer is implemented by the storage backends that may file the
+// saved images under HashedDestination.
+type HashedPrefixer interface {
+	HashedPrefixes() bool
+}
+
+// HashedPrefixes tells whether storage.s3.hashedPrefixes is enabled.
+func (s *s3ImageStorage) HashedPrefixes() bool {
+	return s.hashedPrefixes
+}
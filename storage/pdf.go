@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+
+	"imagenexus/validation"
+
+	"github.com/gen2brain/go-fitz"
+	"github.com/spf13/viper"
+)
+
+const PDFContentType = validation.PDFContentType
+
+// cfgPDFMaxPages is the viper key of the largest accepted page count, 0 for
+// no limit.
+const cfgPDFMaxPages = "storage.pdf.maxPages"
+
+// pdfPreviewDPI renders the previews at one pixel per point, so their size
+// is the size of the page.
+const pdfPreviewDPI = 72
+
+var ErrTooManyPages = errors.New("the PDF has too many pages")
+
+var ErrNoPages = errors.New("the PDF has no pages")
+
+// openPDF parses the PDF and checks its page count, since the pages are
+// what makes a crafted PDF expensive to parse and render.
+func openPDF(r io.Reader) (*fitz.Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := fitz.NewFromMemory(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := document.NumPage()
+	if pages == 0 {
+		document.Close()
+		return nil, ErrNoPages
+	}
+	if maxPages := viper.GetInt(cfgPDFMaxPages); maxPages > 0 && pages > maxPages {
+		document.Close()
+		return nil, fmt.Errorf("%w: %d pages, at most %d are allowed", ErrTooManyPages, pages, maxPages)
+	}
+	return document, nil
+}
+
+// decodePDFConfig reads the dimensions of the preview of the PDF, i.e. of
+// its first page.
+func decodePDFConfig(r io.Reader) (image.Config, error) {
+	document, err := openPDF(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer document.Close()
+
+	bounds, err := document.Bound(0)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.RGBAModel, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+}
+
+// decodePDF renders the first page of the PDF, its preview.
+func decodePDF(r io.Reader) (image.Image, error) {
+	document, err := openPDF(r)
+	if err != nil {
+		return nil, err
+	}
+	defer document.Close()
+
+	return document.ImageDPI(0, pdfPreviewDPI)
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"imagenexus/dto"
+)
+
+// replicatingStorage writes to a primary storage synchronously and copies
+// every saved file to a backup storage in the background. Reads are only
+// served by the primary storage.
+type replicatingStorage struct {
+	primary ImageStorage
+	backup  ImageStorage
+}
+
+func NewReplicatingStorage(primary, backup ImageStorage) ImageStorage {
+	return &replicatingStorage{primary: primary, backup: backup}
+}
+
+// Unwrap returns the primary storage, see Capability.
+func (s *replicatingStorage) Unwrap() ImageStorage {
+	return s.primary
+}
+
+func (s *replicatingStorage) GetFullPath(destination string) string {
+	return s.primary.GetFullPath(destination)
+}
+
+func (s *replicatingStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	picture, saveError := s.primary.Save(ctx, file)
+	if saveError != nil {
+		return nil, saveError
+	}
+
+	go s.replicate(picture.Destination, picture.Name)
+	return picture, nil
+}
+
+func (s *replicatingStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	picture, saveError := s.primary.SaveReader(ctx, filename, src)
+	if saveError != nil {
+		return nil, saveError
+	}
+
+	go s.replicate(picture.Destination, picture.Name)
+	return picture, nil
+}
+
+// replicate copies a saved file from the primary storage, since the uploaded
+// file is gone once the request is over, which is why the replication isn't
+// cancelled along with the request either. The replica is written at the same
+// destination when the backup supports it, since the names given by the
+// naming strategies other than sha256 don't depend on the contents.
+// Failures are only logged.
+func (s *replicatingStorage) replicate(destination, filename string) {
+	stream, contentType, err := s.primary.GetStream(destination)
+	if err != nil {
+		log.Printf("Unable to read %s for replication: %v", destination, err)
+		return
+	}
+	defer stream.Close()
+
+	if writer, ok := Capability[FileWriter](s.backup); ok {
+		if err := writer.Put(destination, contentType, stream); err != nil {
+			log.Printf("Unable to replicate %s: %v", destination, err)
+		}
+		return
+	}
+
+	replica, saveError := s.backup.SaveReader(context.Background(), filename, stream)
+	if saveError != nil {
+		log.Printf("Unable to replicate %s: %v", destination, saveError.Error)
+		return
+	}
+
+	if replica.Destination != destination {
+		log.Printf("Replica of %s was stored as %s", destination, replica.Destination)
+	}
+}
+
+func (s *replicatingStorage) Get(destination string) ([]byte, error) {
+	return s.primary.Get(destination)
+}
+
+func (s *replicatingStorage) GetStream(destination string) (io.ReadCloser, string, error) {
+	return s.primary.GetStream(destination)
+}
+
+func (s *replicatingStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	return s.primary.GetReader(destination)
+}
+
+func (s *replicatingStorage) Delete(destination string) error {
+	if err := s.primary.Delete(destination); err != nil {
+		return err
+	}
+
+	if err := s.backup.Delete(destination); err != nil {
+		log.Printf("Unable to delete replica of %s: %v", destination, err)
+	}
+	return nil
+}
+
+// Capability returns the storage as T, looking through the storages that
+// wrap another one, e.g. to find out whether the primary storage of a
+// replicating storage supports lifecycle rules.
+func Capability[T any](s ImageStorage) (T, bool) {
+	for {
+		if capability, ok := s.(T); ok {
+			return capability, true
+		}
+
+		wrapper, ok := s.(interface{ Unwrap() ImageStorage })
+		if !ok {
+			var none T
+			return none, false
+		}
+		s = wrapper.Unwrap()
+	}
+}
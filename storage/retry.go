@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/spf13/viper"
+)
+
+const (
+	cfgS3MaxRetries     = "storage.s3.maxRetries"
+	cfgS3BaseRetryDelay = "storage.s3.baseRetryDelay"
+)
+
+const (
+	defaultS3BaseRetryDelay = 100 * time.Millisecond
+	maxS3RetryDelay         = 20 * time.Second
+)
+
+// RETRYABLE_S3_ERROR_CODES are the codes of the S3 errors worth retrying,
+// the throttling and the transient failures of the service. The other codes,
+// e.g. AccessDenied or NoSuchKey, fail the same way on every attempt.
+var RETRYABLE_S3_ERROR_CODES = map[string]bool{
+	"RequestLimitExceeded":    true,
+	"Throttling":              true,
+	"ThrottlingException":     true,
+	"SlowDown":                true,
+	"RequestTimeout":          true,
+	"RequestTimeoutException": true,
+	"InternalError":           true,
+	"ServiceUnavailable":      true,
+}
+
+// retryPolicy retries the S3 operations failing with a transient error, on
+// top of the retries of the SDK, which don't cover the failures while reading
+// a downloaded body or the multipart uploads given up by the uploader.
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	sleep      func(time.Duration)
+}
+
+// newRetryPolicy reads storage.s3.maxRetries, 0 for no retries, and
+// storage.s3.baseRetryDelay, in milliseconds.
+func newRetryPolicy() retryPolicy {
+	baseDelay := time.Duration(viper.GetInt(cfgS3BaseRetryDelay)) * time.Millisecond
+	if baseDelay <= 0 {
+		baseDelay = defaultS3BaseRetryDelay
+	}
+	return retryPolicy{maxRetries: viper.GetInt(cfgS3MaxRetries), baseDelay: baseDelay, sleep: time.Sleep}
+}
+
+// do runs the operation until it succeeds, fails with an error that isn't
+// retryable or runs out of retries, waiting between the attempts for an
+// exponential backoff with full jitter: a random delay up to baseDelay, then
+// up to twice baseDelay and so on, up to 20s.
+func (p retryPolicy) do(operation func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := operation()
+		if err == nil || attempt >= p.maxRetries || !isRetryable(err) {
+			return err
+		}
+		delay := maxS3RetryDelay
+		if attempt < 20 {
+			delay = min(p.baseDelay<<attempt, maxS3RetryDelay)
+		}
+		p.sleep(rand.N(delay))
+	}
+}
+
+// isRetryable tells whether the S3 operation failed with a transient error:
+// one of the RETRYABLE_S3_ERROR_CODES, a 5xx or 429 response without an error
+// code, or a network timeout or reset.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) && apiError.ErrorCode() != "" {
+		return RETRYABLE_S3_ERROR_CODES[apiError.ErrorCode()]
+	}
+	var responseError *smithyhttp.ResponseError
+	if errors.As(err, &responseError) {
+		status := responseError.HTTPStatusCode()
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) && netError.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF)
+}
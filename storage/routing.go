@@ -0,0 +1,346 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"imagenexus/dto"
+	"imagenexus/validation"
+)
+
+// SIZE_UNITS are the multipliers of the size suffixes of the config, e.g. of
+// the routing rules.
+var SIZE_UNITS = map[string]int64{
+	"B":  1,
+	"KB": 1 << 10,
+	"MB": 1 << 20,
+	"GB": 1 << 30,
+}
+
+// Rule routes the files matching all its conditions to a backend. The rule
+// without any condition is the default one.
+type Rule struct {
+	// the media type of the files, e.g. image/gif or image/*, any when empty
+	ContentType string
+	// the bounds of the size in bytes, inclusive, none when 0
+	MinSize int64
+	MaxSize int64
+	Backend string
+}
+
+// Matches tells whether the file goes to the backend of the rule.
+func (r Rule) Matches(contentType string, size int64) bool {
+	if r.ContentType != "" {
+		if family, ok := strings.CutSuffix(r.ContentType, "/*"); ok {
+			if !strings.HasPrefix(contentType, family+"/") {
+				return false
+			}
+		} else if r.ContentType != contentType {
+			return false
+		}
+	}
+	if r.MinSize > 0 && size < r.MinSize {
+		return false
+	}
+	return r.MaxSize == 0 || size <= r.MaxSize
+}
+
+// ParseRoutingRules reads the rules of storage.routing.rules, the first
+// matching one routing the file. Each rule is a list of space separated
+// conditions followed by the name of the backend, e.g.
+// "content_type:image/gif -> gifs", "size:>10MB -> s3-cold" or
+// "default -> s3-hot". The last rule has to be the default one.
+func ParseRoutingRules(lines []string) ([]Rule, error) {
+	rules := make([]Rule, 0, len(lines))
+	for _, line := range lines {
+		rule, err := parseRule(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid routing rule %q: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 || rules[len(rules)-1] != (Rule{Backend: rules[len(rules)-1].Backend}) {
+		return nil, errors.New("the last routing rule has to be the default one")
+	}
+	return rules, nil
+}
+
+func parseRule(line string) (Rule, error) {
+	conditions, backend, ok := strings.Cut(line, "->")
+	if !ok {
+		return Rule{}, errors.New("missing -> backend")
+	}
+
+	// the names of the backends are lower cased by the config
+	rule := Rule{Backend: strings.ToLower(strings.TrimSpace(backend))}
+	if rule.Backend == "" || strings.Contains(rule.Backend, "/") {
+		return Rule{}, errors.New("invalid backend name")
+	}
+
+	fields := strings.Fields(conditions)
+	if len(fields) == 0 {
+		return Rule{}, errors.New("missing condition")
+	}
+	for _, field := range fields {
+		if field == "default" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(field, ":")
+		switch key {
+		case "content_type":
+			if value == "" {
+				return Rule{}, errors.New("empty content_type")
+			}
+			rule.ContentType = strings.ToLower(value)
+		case "size":
+			if err := parseSizeCondition(&rule, value); err != nil {
+				return Rule{}, err
+			}
+		default:
+			return Rule{}, fmt.Errorf("unknown condition %s", field)
+		}
+	}
+	return rule, nil
+}
+
+// parseSizeCondition reads >N, >=N, <N or <=N, N being a number of bytes
+// with an optional unit of SIZE_UNITS, e.g. 10MB.
+func parseSizeCondition(rule *Rule, condition string) error {
+	operator := strings.TrimRight(condition, "0123456789.KMGB")
+	size, err := parseSize(condition[len(operator):])
+	if err != nil {
+		return err
+	}
+
+	switch operator {
+	case ">":
+		rule.MinSize = size + 1
+	case ">=":
+		rule.MinSize = size
+	case "<":
+		if size == 0 {
+			return errors.New("no size is below 0")
+		}
+		rule.MaxSize = size - 1
+	case "<=":
+		rule.MaxSize = size
+	default:
+		return fmt.Errorf("invalid size condition %s", condition)
+	}
+	return nil
+}
+
+func parseSize(value string) (int64, error) {
+	number := strings.TrimRight(value, "KMGB")
+	unit, ok := SIZE_UNITS[value[len(number):]]
+	if !ok && value[len(number):] != "" {
+		return 0, fmt.Errorf("unknown size unit in %s", value)
+	}
+	if !ok {
+		unit = 1
+	}
+
+	parsed, err := strconv.ParseFloat(number, 64)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid size %s", value)
+	}
+	return int64(parsed * float64(unit)), nil
+}
+
+// routingStorage saves the files to the backend of the first matching rule.
+// The destinations of the files of the backends other than the default one
+// are prefixed with the name of their backend, e.g. s3-cold/abc.jpg, which
+// routes the reads and the deletions. The destinations without the name of a
+// backend, e.g. the ones saved before the routing is enabled, are the default
+// backend's. The backends shouldn't overlap, e.g. share an S3 prefix.
+//
+// Only the capabilities of all the backends, the listing and the writing of
+// the files, are supported. The others, e.g. the storage tiers, aren't.
+type routingStorage struct {
+	rules          []Rule
+	backends       map[string]ImageStorage
+	defaultBackend string
+	// the bytes read ahead of the routing of SaveReader, one past the
+	// largest size of the rules
+	readAhead int64
+}
+
+func NewRoutingStorage(rules []Rule, backends map[string]ImageStorage) (ImageStorage, error) {
+	if len(rules) == 0 {
+		return nil, errors.New("no routing rule")
+	}
+
+	readAhead := int64(512)
+	for _, rule := range rules {
+		if _, ok := backends[rule.Backend]; !ok {
+			return nil, fmt.Errorf("unknown routing backend: %s", rule.Backend)
+		}
+		readAhead = max(readAhead, rule.MinSize, rule.MaxSize+1)
+	}
+
+	return &routingStorage{
+		rules:          rules,
+		backends:       backends,
+		defaultBackend: rules[len(rules)-1].Backend,
+		readAhead:      readAhead,
+	}, nil
+}
+
+func (s *routingStorage) route(contentType string, size int64) string {
+	for _, rule := range s.rules {
+		if rule.Matches(contentType, size) {
+			return rule.Backend
+		}
+	}
+	return s.defaultBackend
+}
+
+// resolve returns the backend of the destination, along with the
+// destination within it.
+func (s *routingStorage) resolve(destination string) (ImageStorage, string) {
+	if name, rest, ok := strings.Cut(destination, "/"); ok && name != s.defaultBackend {
+		if backend, ok := s.backends[name]; ok {
+			return backend, rest
+		}
+	}
+	return s.backends[s.defaultBackend], destination
+}
+
+// qualify prefixes the destinations of the saved picture with the name of
+// its backend.
+func (s *routingStorage) qualify(name string, picture *dto.PictureRequest) *dto.PictureRequest {
+	if name == s.defaultBackend {
+		return picture
+	}
+
+	picture.Destination = name + "/" + picture.Destination
+	if picture.ThumbnailDestination != "" {
+		picture.ThumbnailDestination = name + "/" + picture.ThumbnailDestination
+	}
+	return picture
+}
+
+func (s *routingStorage) GetFullPath(destination string) string {
+	backend, destination := s.resolve(destination)
+	return backend.GetFullPath(destination)
+}
+
+// Save routes the upload by its size and the content type of its first
+// bytes, the backend reading the file again.
+func (s *routingStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      fmt.Errorf("cannot open file: %w", err),
+		}
+	}
+	peek, err := newPeekReader(src, 512)
+	src.Close()
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	name := s.route(validation.DetectContentType(peek.Peek()), file.Size)
+	picture, saveError := s.backends[name].Save(ctx, file)
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.qualify(name, picture), nil
+}
+
+// SaveReader holds the start of the stream back, up to the largest size of
+// the rules, to route it by its size. The smaller files are read whole.
+func (s *routingStorage) SaveReader(ctx context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	header, err := io.ReadAll(io.LimitReader(src, s.readAhead))
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+
+	name := s.route(validation.DetectContentType(header), int64(len(header)))
+	picture, saveError := s.backends[name].SaveReader(ctx, filename, io.MultiReader(bytes.NewReader(header), src))
+	if saveError != nil {
+		return nil, saveError
+	}
+	return s.qualify(name, picture), nil
+}
+
+func (s *routingStorage) Get(destination string) ([]byte, error) {
+	backend, destination := s.resolve(destination)
+	return backend.Get(destination)
+}
+
+func (s *routingStorage) GetStream(destination string) (io.ReadCloser, string, error) {
+	backend, destination := s.resolve(destination)
+	return backend.GetStream(destination)
+}
+
+func (s *routingStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	backend, destination := s.resolve(destination)
+	return backend.GetReader(destination)
+}
+
+func (s *routingStorage) Delete(destination string) error {
+	backend, destination := s.resolve(destination)
+	return backend.Delete(destination)
+}
+
+// Put writes the file to the backend of its destination, the default one
+// unless it's prefixed with the name of another backend.
+func (s *routingStorage) Put(destination, contentType string, src io.Reader) error {
+	backend, destination := s.resolve(destination)
+	writer, ok := Capability[FileWriter](backend)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	return writer.Put(destination, contentType, src)
+}
+
+// ListFiles lists the files of every backend, with the destinations of
+// SaveReader. It fails with errors.ErrUnsupported when a backend can't list
+// its files.
+func (s *routingStorage) ListFiles(fn func(*StoredFile) error) error {
+	names := make([]string, 0, len(s.backends))
+	for name := range s.backends {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	listers := make([]FileLister, 0, len(names))
+	for _, name := range names {
+		lister, ok := Capability[FileLister](s.backends[name])
+		if !ok {
+			return errors.ErrUnsupported
+		}
+		listers = append(listers, lister)
+	}
+
+	for i, name := range names {
+		err := listers[i].ListFiles(func(file *StoredFile) error {
+			if name != s.defaultBackend {
+				file.Destination = name + "/" + file.Destination
+			}
+			return fn(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
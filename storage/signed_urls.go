@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+const (
+	cfgS3UseSignedURLs = "storage.s3.useSignedURLs"
+	cfgS3SignedURLTTL  = "storage.s3.signedURLTTL"
+	cfgS3RedisURL      = "storage.s3.redisURL"
+)
+
+const (
+	defaultSignedURLTTL = time.Hour
+	// the longest validity of the URLs signed with the credentials of an
+	// IAM user
+	maxSignedURLTTL = 7 * 24 * time.Hour
+	// a cached URL is signed again once it's within this share of its TTL
+	// from expiring
+	signedURLRefreshRatio = 10
+	redisTimeout          = time.Second
+	redisKeyPrefix        = "imagenexus:signed-url:"
+	// the expired URLs are swept from the memory cache past this many
+	memoryURLCacheSweepSize = 10000
+)
+
+// urlCache keeps the signed URLs until they expire.
+type urlCache interface {
+	// Get returns the URL of the key along with the time it has left
+	Get(ctx context.Context, key string) (string, time.Duration, bool)
+	Set(ctx context.Context, key, url string, ttl time.Duration)
+}
+
+// signedURLs serves the objects through pre-signed GET URLs, reused until
+// they're close to expiring so the URLs of a picture stay the same across
+// requests, e.g. for the browser caches.
+type signedURLs struct {
+	ttl     time.Duration
+	cache   urlCache
+	presign func(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// newSignedURLs reads storage.s3.signedURLTTL, in seconds, and caches the
+// URLs in the Redis of storage.s3.redisURL, shared by the instances, or in
+// memory when there's none.
+func newSignedURLs(client *s3.Client, bucket string) (*signedURLs, error) {
+	ttl := time.Duration(viper.GetInt(cfgS3SignedURLTTL)) * time.Second
+	if ttl <= 0 {
+		ttl = defaultSignedURLTTL
+	}
+	ttl = min(ttl, maxSignedURLTTL)
+
+	var cache urlCache = newMemoryURLCache()
+	if redisURL := viper.GetString(cfgS3RedisURL); redisURL != "" {
+		options, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", cfgS3RedisURL, err)
+		}
+		cache = &redisURLCache{client: redis.NewClient(options)}
+	}
+
+	presigner := s3.NewPresignClient(client)
+	return &signedURLs{
+		ttl:   ttl,
+		cache: cache,
+		presign: func(ctx context.Context, key string, ttl time.Duration) (string, error) {
+			request, err := presigner.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: &bucket, Key: &key}, s3.WithPresignExpires(ttl))
+			if err != nil {
+				return "", err
+			}
+			return request.URL, nil
+		},
+	}, nil
+}
+
+// URL returns the cached URL of the key, or signs a new one when there's
+// none or it expires within a tenth of the TTL.
+func (u *signedURLs) URL(ctx context.Context, key string) (string, error) {
+	if url, left, ok := u.cache.Get(ctx, key); ok && left > u.ttl/signedURLRefreshRatio {
+		return url, nil
+	}
+
+	url, err := u.presign(ctx, key, u.ttl)
+	if err != nil {
+		return "", err
+	}
+	u.cache.Set(ctx, key, url, u.ttl)
+	return url, nil
+}
+
+type cachedURL struct {
+	url     string
+	expires time.Time
+}
+
+type memoryURLCache struct {
+	lock    sync.Mutex
+	entries map[string]cachedURL
+	now     func() time.Time
+}
+
+func newMemoryURLCache() *memoryURLCache {
+	return &memoryURLCache{entries: map[string]cachedURL{}, now: time.Now}
+}
+
+func (c *memoryURLCache) Get(ctx context.Context, key string) (string, time.Duration, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", 0, false
+	}
+	left := entry.expires.Sub(c.now())
+	if left <= 0 {
+		delete(c.entries, key)
+		return "", 0, false
+	}
+	return entry.url, left, true
+}
+
+func (c *memoryURLCache) Set(ctx context.Context, key, url string, ttl time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= memoryURLCacheSweepSize {
+		for eachKey, entry := range c.entries {
+			if !entry.expires.After(now) {
+				delete(c.entries, eachKey)
+			}
+		}
+	}
+	c.entries[key] = cachedURL{url: url, expires: now.Add(ttl)}
+}
+
+// redisURLCache expires the URLs with the TTL of their keys. The URLs are
+// signed again while Redis is unavailable.
+type redisURLCache struct {
+	client *redis.Client
+}
+
+func (c *redisURLCache) Get(ctx context.Context, key string) (string, time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	var url *redis.StringCmd
+	var left *redis.DurationCmd
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		url = pipe.Get(ctx, redisKeyPrefix+key)
+		left = pipe.PTTL(ctx, redisKeyPrefix+key)
+		return nil
+	})
+	if err == redis.Nil {
+		return "", 0, false
+	}
+	if err != nil {
+		log.Printf("Unable to read the signed URL of %s from Redis: %v", key, err)
+		return "", 0, false
+	}
+	if left.Val() <= 0 {
+		return "", 0, false
+	}
+	return url.Val(), left.Val(), true
+}
+
+func (c *redisURLCache) Set(ctx context.Context, key, url string, ttl time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	if err := c.client.Set(ctx, redisKeyPrefix+key, url, ttl).Err(); err != nil {
+		log.Printf("Unable to cache the signed URL of %s in Redis: %v", key, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"image/png"
 	"io"
 	"io/ioutil"
+	"maps"
 	"context"
 	"log"
 	"mime/multipart"
@@ -16,9 +17,16 @@ import (
 	"os"
 	"path/filepath"
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"strings"
+	"time"
 
 	"imagenexus/dto"
-	"imagenexus/utils"
+	"imagenexus/imaging"
+	"imagenexus/validation"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/image/bmp"
@@ -32,45 +40,112 @@ import (
 	"github.com/spf13/viper"
 )
 
-var CONTENT_DECODERS = map[string](func(r io.Reader) (image.Config, error)){
-	"image/jpeg": jpeg.DecodeConfig,
-	"image/png":  png.DecodeConfig,
-	"image/gif":  gif.DecodeConfig,
-	"image/tiff": tiff.DecodeConfig,
-	"image/webp": webp.DecodeConfig,
-	"image/bmp":  bmp.DecodeConfig,
+// CONTENT_DECODERS are the decoders of the validation package along with
+// the PDFs, pictured by their first page.
+var CONTENT_DECODERS = func() map[string](func(r io.Reader) (image.Config, error)) {
+	decoders := maps.Clone(validation.CONTENT_DECODERS)
+	decoders[PDFContentType] = validation.WithDimensions(decodePDFConfig)
+	return decoders
+}()
+
+var ErrNoDimensions = validation.ErrNoDimensions
+
+var IMAGE_DECODERS = map[string](func(r io.Reader) (image.Image, error)){
+	"image/jpeg":   jpeg.Decode,
+	"image/png":    png.Decode,
+	"image/gif":    gif.Decode,
+	"image/tiff":   tiff.Decode,
+	"image/webp":   webp.Decode,
+	"image/bmp":    bmp.Decode,
+	PDFContentType: decodePDF,
+}
+
+// DecodeImage decodes the stored bytes of a picture using its content type.
+// Animated WebPs decode to their first frame, the others aren't rendered.
+func DecodeImage(data []byte, contentType string) (image.Image, error) {
+	if contentType == "image/webp" && imaging.IsAnimatedWebP(data) {
+		return imaging.DecodeWebPFirstFrame(data, MaxAnimationPixels())
+	}
+
+	decoder, ok := IMAGE_DECODERS[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format: %s", contentType)
+	}
+	return decoder(bytes.NewReader(data))
+}
+
+var ErrNoEncoder = errors.New("the format can't be encoded")
+
+var IMAGE_ENCODERS = map[string](func(w io.Writer, img image.Image) error){
+	"image/jpeg": func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, &jpeg.Options{Quality: 90}) },
+	"image/png":  png.Encode,
+	"image/gif":  func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) },
+	"image/tiff": func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) },
+	"image/bmp":  bmp.Encode,
+}
+
+// EncodeImage encodes the image in the format of the content type. WebPs and
+// PDFs can be decoded but not encoded, they return ErrNoEncoder.
+func EncodeImage(w io.Writer, img image.Image, contentType string) error {
+	encoder, ok := IMAGE_ENCODERS[contentType]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoEncoder, contentType)
+	}
+	return encoder(w, img)
 }
 
 type ImageStorage interface {
 	GetFullPath(string) string
-	Save(*multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError)
+	// ctx cancels the uploads to the remote storages, e.g. when the client of
+	// the request is gone
+	Save(context.Context, *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError)
+	SaveReader(context.Context, string, io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError)
 	Get(string) ([]byte, error)
+	GetStream(string) (io.ReadCloser, string, error)
+	GetReader(string) (io.ReadSeekCloser, error)
+	Delete(string) error
 }
 
 type localImageStorage struct {
 	path string
 }
 
+// NewStorage returns the storage of the directory, creating it along with
+// its parents. The subdirectories of the destinations, e.g. the YYYY/MM/DD
+// trees of the date_prefix naming strategy, are created on save.
 func NewStorage(path string) ImageStorage {
-	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
-		err := os.Mkdir(path, os.ModePerm)
-		if err != nil {
-			log.Fatalln("Unable to make directory: %w", path)
-		}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		log.Fatalf("Unable to make directory %s: %v", path, err)
 	}
 
 	return &localImageStorage{path}
 }
 
+// ErrPathTraversal is returned for destinations that would resolve outside
+// of the storage directory, e.g. "../../etc/passwd".
+var ErrPathTraversal = errors.New("destination escapes the storage directory")
+
+// GetFullPath returns the path of the destination under the storage
+// directory, including the subdirectories of the destination, e.g.
+// 2024/01/15/<uuid>.png. Destinations that would escape it are clamped to
+// it, the operations below refuse them with ErrPathTraversal instead.
 func (s *localImageStorage) GetFullPath(destination string) string {
-	return s.path + "/" + destination
+	return s.path + "/" + strings.TrimPrefix(filepath.Clean("/"+destination), "/")
 }
 
-func (s *localImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
-	extension := filepath.Ext(file.Filename)
-	destination := utils.NewUniqueString() + extension
-	fullPath := s.GetFullPath(destination)
+// resolvePath returns the cleaned path of the destination, making sure it is
+// still under the storage directory.
+func (s *localImageStorage) resolvePath(destination string) (string, error) {
+	root := filepath.Clean(s.path)
+	fullPath := filepath.Join(root, destination)
+	relative, err := filepath.Rel(root, fullPath)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", ErrPathTraversal
+	}
+	return fullPath, nil
+}
 
+func (s *localImageStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
 	src, err := file.Open()
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
@@ -80,74 +155,272 @@ func (s *localImageStorage) Save(file *multipart.FileHeader) (*dto.PictureReques
 	}
 	defer src.Close()
 
-	buffer := make([]byte, 512)
-	_, err = src.Read(buffer)
+	return s.SaveReader(ctx, file.Filename, src)
+}
+
+// SaveReader stores the contents of src, keeping the extension of filename.
+// The local files are written whatever ctx.
+func (s *localImageStorage) SaveReader(_ context.Context, filename string, src io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	peek, err := newPeekReader(src, 512)
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
 		}
 	}
-
-	fileType := http.DetectContentType(buffer)
-	decoder, ok := CONTENT_DECODERS[fileType]
-	if !ok {
+	if len(peek.Peek()) == 0 {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusBadRequest,
-			Error:      errors.New("unsupported format"),
-			Data:       gin.H{"format": fileType},
+			Error:      errors.New("empty file"),
 		}
 	}
 
-	_, err = src.Seek(0, io.SeekStart)
+	fileType := validation.DetectContentType(peek.Peek())
+	imageConfig, body, decodeError := decodeUpload(fileType, peek)
+	if decodeError != nil {
+		return nil, decodeError
+	}
+	// a byte past the limit tells the files too large apart
+	maxSize := MaxFileSize(fileType)
+	if maxSize > 0 {
+		body = io.LimitReader(body, maxSize+1)
+	}
+
+	// the destination depends on the checksum, so write to a temporary file
+	// while hashing and move it in place afterwards
+	out, err := os.CreateTemp(s.path, ".upload-*")
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
 		}
 	}
+	defer os.Remove(out.Name())
+	defer out.Close()
 
-	imageConfig, err := decoder(src)
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(out, hasher), body)
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
-			Data:       gin.H{"format": fileType},
 		}
 	}
+	if sizeError := checkFileSize(fileType, size, maxSize); sizeError != nil {
+		return nil, sizeError
+	}
 
-	out, err := os.Create(fullPath)
-	if err != nil {
+	if err := out.Sync(); err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
 		}
 	}
-	defer out.Close()
 
-	src.Seek(0, io.SeekStart)
-	_, err = io.Copy(out, src)
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      err,
+		}
+	}
+	if err := verifyImage(out, fileType); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+			Data:       gin.H{"format": fileType},
+		}
+	}
+
+	contentHash := hasher.Sum(nil)
+	checksum := hex.EncodeToString(contentHash)
+	naming := namingStrategy()
+	destination, err := uniqueDestination(naming, naming.Name(filename, filepath.Ext(filename), contentHash), s.exists)
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
 			Error:      err,
 		}
 	}
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+		}
+	}
+
+	// identical contents are already stored under the same content
+	// addressed destination
+	if _, err := os.Stat(fullPath); errors.Is(err, os.ErrNotExist) {
+		if err := s.moveInPlace(out.Name(), fullPath); err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+
+		if err := verifyFile(fullPath, checksum); err != nil {
+			os.Remove(fullPath)
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
+		}
+	}
 
 	pictureFile := &dto.PictureRequest{
-		Name:        file.Filename,
+		Name:        filename,
 		Destination: destination,
 		Height:      int32(imageConfig.Height),
 		Width:       int32(imageConfig.Width),
-		Size:        int32(file.Size),
+		Size:        int32(size),
 		ContentType: fileType,
+		Checksum:    checksum,
+		IsAnimated:  imaging.IsAnimatedWebP(peek.Peek()),
 	}
 
 	return pictureFile, nil
 }
 
+// exists reports whether a file is already stored at the destination. The
+// destinations escaping the storage directory are refused by resolvePath
+// afterwards.
+func (s *localImageStorage) exists(destination string) (bool, error) {
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return false, nil
+	}
+	_, err = os.Stat(fullPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// decodeUpload reads the dimensions of the upload and returns the contents to
+// store. SVGs have no decoder and are stored as rewritten by sanitizeSVG.
+func decodeUpload(fileType string, src io.Reader) (image.Config, io.Reader, *dto.InvalidPictureFileError) {
+	if fileType == svgContentType {
+		sanitized, imageConfig, err := sanitizeSVG(src)
+		if err != nil {
+			return imageConfig, nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusBadRequest,
+				Error:      err,
+				Data:       gin.H{"format": fileType},
+			}
+		}
+		return imageConfig, bytes.NewReader(sanitized), nil
+	}
+
+	decoder, ok := CONTENT_DECODERS[fileType]
+	if !ok {
+		return image.Config{}, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      errors.New("unsupported format"),
+			Data:       gin.H{"format": fileType},
+		}
+	}
+
+	// keep whatever the decoder reads so it can be replayed ahead of the rest
+	// of the upload, instead of seeking back to the start
+	var decoded bytes.Buffer
+	imageConfig, err := decoder(io.TeeReader(src, &decoded))
+	if err != nil {
+		return imageConfig, nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
+			Data:       gin.H{"format": fileType},
+		}
+	}
+	return imageConfig, io.MultiReader(&decoded, src), nil
+}
+
+// peekReader keeps the first bytes of a stream for content type detection
+// and replays them ahead of the rest of the stream.
+type peekReader struct {
+	header []byte
+	reader io.Reader
+}
+
+func newPeekReader(src io.Reader, size int) (*peekReader, error) {
+	header := make([]byte, size)
+	read, err := io.ReadFull(src, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	header = header[:read]
+	return &peekReader{header: header, reader: io.MultiReader(bytes.NewReader(header), src)}, nil
+}
+
+func (p *peekReader) Peek() []byte {
+	return p.header
+}
+
+func (p *peekReader) Read(b []byte) (int, error) {
+	return p.reader.Read(b)
+}
+
+// hashContents feeds the file contents to every given hash in a single pass,
+// rewinds the file for the next reader and returns the size of the contents.
+func hashContents(src io.ReadSeeker, hashes ...hash.Hash) (int64, error) {
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, eachHash := range hashes {
+		writers = append(writers, eachHash)
+	}
+
+	size, err := io.Copy(io.MultiWriter(writers...), src)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = src.Seek(0, io.SeekStart)
+	return size, err
+}
+
+// contentAddress names a file after the SHA-256 of its contents, keeping the
+// original extension.
+func contentAddress(checksum, filename string) string {
+	return checksum + filepath.Ext(filename)
+}
+
+// verifyFile compares the SHA-256 of the file written to disk with the
+// checksum of the uploaded contents.
+func verifyFile(fullPath, checksum string) error {
+	written, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer written.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, written); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(hasher.Sum(nil))
+	if actual != checksum {
+		return &IntegrityError{Destination: filepath.Base(fullPath), Expected: checksum, Actual: actual}
+	}
+	return nil
+}
+
+type IntegrityError struct {
+	Destination string
+	Expected    string
+	Actual      string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("integrity check failed for %q: expected checksum %s, got %s", e.Destination, e.Expected, e.Actual)
+}
+
 func (s *localImageStorage) Get(destination string) ([]byte, error) {
-	fullPath := s.GetFullPath(destination)
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return nil, err
+	}
+
 	file, err := os.Open(fullPath)
 	if err != nil {
 		return nil, err
@@ -158,6 +431,79 @@ func (s *localImageStorage) Get(destination string) ([]byte, error) {
 	return body, err
 }
 
+// GetStream opens the stored file for reading along with its detected content
+// type. The caller is responsible for closing the reader.
+func (s *localImageStorage) GetStream(destination string) (io.ReadCloser, string, error) {
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, "", err
+	}
+
+	peek, err := newPeekReader(file, 512)
+	if err != nil {
+		file.Close()
+		return nil, "", err
+	}
+
+	return &readCloser{Reader: peek, Closer: file}, validation.DetectContentType(peek.Peek()), nil
+}
+
+// GetReader opens the stored file for random access, e.g. to serve byte
+// ranges. The caller is responsible for closing the reader.
+func (s *localImageStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+// Delete removes the file along with the subdirectories it leaves empty.
+func (s *localImageStorage) Delete(destination string) error {
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(fullPath); err != nil {
+		return err
+	}
+
+	root := filepath.Clean(s.path)
+	for dir := filepath.Dir(fullPath); dir != root; dir = filepath.Dir(dir) {
+		// fails on the first directory still holding files
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// moveInPlace moves the temporary file to its destination, creating its
+// subdirectories. It tries again when a deletion removes the subdirectory
+// in between.
+func (s *localImageStorage) moveInPlace(temporary, fullPath string) error {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			return err
+		}
+		if err = os.Rename(temporary, fullPath); !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
 
 
 
@@ -166,6 +512,9 @@ const (
 	cfgS3Bucket       = "storage.s3.bucket"
 	cfgS3Prefix       = "storage.s3.prefix"
 	cfgCloudFrontURL  = "storage.s3.cloudfront_url"
+	cfgS3Versioning   = "storage.s3.versioning"
+	cfgS3Endpoint     = "storage.s3.endpoint"
+	cfgS3HashedPrefixes = "storage.s3.hashedPrefixes"
 )
 
 // s3ImageStorage implements ImageStorage, uploading into S3 + serving via CloudFront
@@ -175,46 +524,106 @@ type s3ImageStorage struct {
 	bucket       string
 	prefix       string
 	cloudFrontURL string
+	// the saved images are filed under HashedDestination
+	hashedPrefixes bool
+	// the bucket keeps the previous versions of the objects,
+	// storage.s3.versioning
+	versioning bool
+	// retries the uploads and downloads failing with a transient error
+	retry retryPolicy
+	// bounds every S3 call, storage.s3.operationTimeout
+	operationTimeout time.Duration
+	// serves the objects through pre-signed URLs rather than CloudFront,
+	// storage.s3.useSignedURLs
+	signedURLs *signedURLs
 }
 
 // NewS3Storage reads config via Viper and returns an ImageStorage
 func NewS3Storage() (ImageStorage, error) {
+	return NewS3StorageWithPrefix(viper.GetString(cfgS3Prefix))
+}
+
+// NewS3StorageWithPrefix returns the storage of the objects of the bucket
+// under the prefix rather than storage.s3.prefix.
+func NewS3StorageWithPrefix(prefix string) (ImageStorage, error) {
+	storage, err := newS3ImageStorage(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	storage.hashedPrefixes = viper.GetBool(cfgS3HashedPrefixes)
+
+	if viper.GetBool(cfgS3Versioning) {
+		if err := storage.enableVersioning(); err != nil {
+			return nil, fmt.Errorf("failed to enable bucket versioning: %w", err)
+		}
+		storage.versioning = true
+	}
+	return storage, nil
+}
+
+// newS3ImageStorage connects to the configured bucket, storing the objects
+// under the prefix.
+func newS3ImageStorage(prefix string) (*s3ImageStorage, error) {
 	// load AWS creds / region from env / ~/.aws via default chain
 	awsCfg, err := config.LoadDefaultConfig(context.TODO())
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	s3Client := s3.NewFromConfig(awsCfg)
+	s3Client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		// S3 compatible services such as LocalStack only support path style
+		// requests on custom endpoints
+		if endpoint := viper.GetString(cfgS3Endpoint); endpoint != "" {
+			o.BaseEndpoint = &endpoint
+			o.UsePathStyle = true
+		}
+	})
 	uploader := manager.NewUploader(s3Client)
 
 	bucket := viper.GetString(cfgS3Bucket)
-	prefix := viper.GetString(cfgS3Prefix)
 	if prefix != "" && prefix[len(prefix)-1] != '/' {
 		prefix = prefix + "/"
 	}
 	cfURL := viper.GetString(cfgCloudFrontURL)
 
+	var signed *signedURLs
+	if viper.GetBool(cfgS3UseSignedURLs) {
+		signed, err = newSignedURLs(s3Client, bucket)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &s3ImageStorage{
 		client:        s3Client,
 		uploader:      uploader,
 		bucket:        bucket,
 		prefix:        prefix,
 		cloudFrontURL: cfURL,
+		retry:         newRetryPolicy(),
+		operationTimeout: s3OperationTimeout(),
+		signedURLs:    signed,
 	}, nil
 }
 
-// GetFullPath returns the public URL (via CloudFront) for a given object key.
+// GetFullPath returns the public URL (via CloudFront) for a given object key,
+// or a pre-signed GET URL when storage.s3.useSignedURLs is set.
 func (s *s3ImageStorage) GetFullPath(destination string) string {
+	if s.signedURLs != nil {
+		url, err := s.signedURLs.URL(context.Background(), s.prefix+destination)
+		if err == nil {
+			return url
+		}
+		log.Printf("Unable to sign the URL of %s: %v", destination, err)
+	}
 	return fmt.Sprintf("%s/%s%s", s.cloudFrontURL, s.prefix, destination)
 }
 
-// Save uploads the file to S3 under prefix + unique name.
+// Save uploads the file to S3 under prefix + the name given by the naming
+// strategy.
 // On success it returns a dto.PictureRequest (Destination is the S3 key basename).
-func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
-	extension := filepath.Ext(file.Filename)
-	destination := utils.NewUniqueString() + extension
-
+func (s *s3ImageStorage) Save(ctx context.Context, file *multipart.FileHeader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
 	src, err := file.Open()
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
@@ -224,6 +633,41 @@ func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest,
 	}
 	defer src.Close()
 
+	return s.SaveReader(ctx, file.Filename, src)
+}
+
+// SaveReader uploads the contents of reader, keeping the extension of
+// filename. Readers that can't seek are spooled to a temporary file first,
+// since the contents are read more than once before the upload. Cancelling
+// ctx aborts the upload.
+func (s *s3ImageStorage) SaveReader(ctx context.Context, filename string, reader io.Reader) (*dto.PictureRequest, *dto.InvalidPictureFileError) {
+	src, ok := reader.(io.ReadSeeker)
+	if !ok {
+		spooled, err := os.CreateTemp("", "s3-upload-*")
+		if err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      fmt.Errorf("cannot spool file: %w", err),
+			}
+		}
+		defer os.Remove(spooled.Name())
+		defer spooled.Close()
+
+		if _, err := io.Copy(spooled, reader); err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      fmt.Errorf("cannot spool file: %w", err),
+			}
+		}
+		if _, err := spooled.Seek(0, io.SeekStart); err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      fmt.Errorf("seek error: %w", err),
+			}
+		}
+		src = spooled
+	}
+
 	buf := make([]byte, 512)
 	if _, err := src.Read(buf); err != nil {
 		return nil, &dto.InvalidPictureFileError{
@@ -232,9 +676,9 @@ func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest,
 		}
 	}
 
-	contentType := http.DetectContentType(buf)
+	contentType := validation.DetectContentType(buf)
 	decoder, ok := CONTENT_DECODERS[contentType]
-	if !ok {
+	if !ok && contentType != svgContentType {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusBadRequest,
 			Error:      errors.New("unsupported image format"),
@@ -242,6 +686,17 @@ func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest,
 		}
 	}
 
+	total, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      fmt.Errorf("seek error: %w", err),
+		}
+	}
+	if sizeError := checkFileSize(contentType, total, MaxFileSize(contentType)); sizeError != nil {
+		return nil, sizeError
+	}
+
 	if _, err := src.Seek(0, io.SeekStart); err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
@@ -249,16 +704,44 @@ func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest,
 		}
 	}
 
-	imageCfg, err := decoder(src)
-	if err != nil {
+	var imageCfg image.Config
+	if contentType == svgContentType {
+		// SVGs have no decoder, the sanitized document is uploaded instead
+		sanitized, svgCfg, err := sanitizeSVG(src)
+		if err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusBadRequest,
+				Error:      err,
+				Data:       gin.H{"format": contentType},
+			}
+		}
+		src, imageCfg = bytes.NewReader(sanitized), svgCfg
+	} else {
+		decodedCfg, err := decoder(src)
+		if err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("decode error: %w", err),
+				Data:       gin.H{"format": contentType},
+			}
+		}
+		imageCfg = decodedCfg
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
-			Error:      fmt.Errorf("decode error: %w", err),
+			Error:      fmt.Errorf("seek error: %w", err),
+		}
+	}
+	if err := verifyImage(src, contentType); err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusBadRequest,
+			Error:      err,
 			Data:       gin.H{"format": contentType},
 		}
 	}
 
-	key := s.prefix + destination
 	// reset reader
 	if _, err := src.Seek(0, io.SeekStart); err != nil {
 		return nil, &dto.InvalidPictureFileError{
@@ -267,31 +750,242 @@ func (s *s3ImageStorage) Save(file *multipart.FileHeader) (*dto.PictureRequest,
 		}
 	}
 
-	_, err = s.uploader.Upload(context.TODO(), &s3.PutObjectInput{
-		Bucket:      &s.bucket,
-		Key:         &key,
-		Body:        src,
-		ContentType: &contentType,
-		ACL:         s3types.ObjectCannedACLPrivate,
+	hasher, md5Hasher := sha256.New(), md5.New()
+	size, err := hashContents(src, hasher, md5Hasher)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      fmt.Errorf("hash error: %w", err),
+		}
+	}
+	contentHash := hasher.Sum(nil)
+	checksum := hex.EncodeToString(contentHash)
+	naming := namingStrategy()
+	name := naming.Name(filename, filepath.Ext(filename), contentHash)
+	if s.hashedPrefixes {
+		name = HashedDestination(name, checksum)
+	}
+	destination, err := uniqueDestination(naming, name, func(destination string) (bool, error) {
+		return s.exists(ctx, s.prefix+destination)
 	})
 	if err != nil {
 		return nil, &dto.InvalidPictureFileError{
 			StatusCode: http.StatusInternalServerError,
-			Error:      fmt.Errorf("s3 upload failed: %w", err),
+			Error:      fmt.Errorf("s3 head failed: %w", err),
+		}
+	}
+	key := s.prefix + destination
+
+	standard, err := s.existsInStandardClass(ctx, key)
+	if err != nil {
+		return nil, &dto.InvalidPictureFileError{
+			StatusCode: http.StatusInternalServerError,
+			Error:      fmt.Errorf("s3 head failed: %w", err),
+		}
+	}
+
+	// identical contents are already stored under the same content
+	// addressed key, they're uploaded again when moved to another tier so
+	// that the saved pictures are hot, see TierManager
+	if !standard {
+		var output *manager.UploadOutput
+		err := s.retry.do(func() error {
+			if _, err := src.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			uploadCtx, cancel := s.operationContext(ctx)
+			defer cancel()
+			var err error
+			output, err = s.uploader.Upload(uploadCtx, &s3.PutObjectInput{
+				Bucket:      &s.bucket,
+				Key:         &key,
+				Body:        src,
+				ContentType: &contentType,
+				ACL:         s3types.ObjectCannedACLPrivate,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      fmt.Errorf("s3 upload failed: %w", err),
+			}
+		}
+
+		if err := verifyETag(output.ETag, destination, hex.EncodeToString(md5Hasher.Sum(nil))); err != nil {
+			return nil, &dto.InvalidPictureFileError{
+				StatusCode: http.StatusInternalServerError,
+				Error:      err,
+			}
 		}
 	}
 
 	pic := &dto.PictureRequest{
-		Name:        file.Filename,
+		Name:        filename,
 		Destination: destination,
 		Height:      int32(imageCfg.Height),
 		Width:       int32(imageCfg.Width),
-		Size:        int32(file.Size),
+		Size:        int32(size),
 		ContentType: contentType,
+		Checksum:    checksum,
+		IsAnimated:  imaging.IsAnimatedWebP(buf),
 	}
 	return pic, nil
 }
 
+// verifyETag compares the ETag returned by S3 with the client-side MD5. The
+// ETag of a multipart upload is not an MD5 of the object, so it is skipped.
+func verifyETag(etag *string, destination, expected string) error {
+	if etag == nil {
+		return nil
+	}
+
+	actual := strings.Trim(*etag, `"`)
+	if strings.Contains(actual, "-") || actual == expected {
+		return nil
+	}
+
+	return &IntegrityError{Destination: destination, Expected: expected, Actual: actual}
+}
+
+func (s *s3ImageStorage) Delete(destination string) error {
+	key := s.prefix + destination
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	return err
+}
+
+// exists reports whether an object is already stored under the key.
+func (s *s3ImageStorage) exists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// existsInStandardClass reports whether an object is already stored under
+// the key in the STANDARD storage class, the one HEAD doesn't name.
+func (s *s3ImageStorage) existsInStandardClass(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := s.operationContext(ctx)
+	defer cancel()
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return head.StorageClass == "" || head.StorageClass == s3types.StorageClassStandard, nil
+}
+
+// GetReader returns a seekable reader over the S3 object. Reads are served by
+// ranged GET requests starting at the current offset, so seeking never
+// downloads the skipped bytes. The caller is responsible for closing the reader.
+func (s *s3ImageStorage) GetReader(destination string) (io.ReadSeekCloser, error) {
+	key := s.prefix + destination
+
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return nil, &S3NotFoundError{Key: destination}
+		}
+		return nil, &S3DownloadError{Key: destination, Err: err}
+	}
+
+	size := int64(0)
+	if head.ContentLength != nil {
+		size = *head.ContentLength
+	}
+
+	return &s3ObjectReader{storage: s, destination: destination, key: key, size: size}, nil
+}
+
+type s3ObjectReader struct {
+	storage     *s3ImageStorage
+	destination string
+	key         string
+	size        int64
+	offset      int64
+	body        io.ReadCloser
+}
+
+func (r *s3ObjectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		byteRange := fmt.Sprintf("bytes=%d-", r.offset)
+		resp, err := r.storage.getObject(context.Background(), &s3.GetObjectInput{
+			Bucket: &r.storage.bucket,
+			Key:    &r.key,
+			Range:  &byteRange,
+		})
+		if err != nil {
+			return 0, &S3DownloadError{Key: r.destination, Err: err}
+		}
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *s3ObjectReader) Seek(offset int64, whence int) (int64, error) {
+	position := offset
+	switch whence {
+	case io.SeekCurrent:
+		position += r.offset
+	case io.SeekEnd:
+		position += r.size
+	}
+
+	if position < 0 {
+		return 0, errors.New("s3 object reader: negative position")
+	}
+
+	// the open body only serves reads from the old offset
+	if position != r.offset && r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = position
+
+	return position, nil
+}
+
+func (r *s3ObjectReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	return r.body.Close()
+}
+
 type S3NotFoundError struct {
 	Key string
 }
@@ -307,25 +1001,53 @@ func (e *S3DownloadError) Error() string {
 	return fmt.Sprintf("failed to download %q: %v", e.Key, e.Err)
 }
 
+func (e *S3DownloadError) Unwrap() error {
+	return e.Err
+}
+
+// Get downloads the object, retrying the transient failures of the download
+// and of the reading of its body.
 func (s *s3ImageStorage) Get(destination string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := s.retry.do(func() error {
+		body, _, err := s.GetStream(destination)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		buf.Reset()
+		if _, err := io.Copy(&buf, body); err != nil {
+			return &S3DownloadError{Key: destination, Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetStream returns the S3 object body without buffering it. The caller is
+// responsible for closing the reader.
+func (s *s3ImageStorage) GetStream(destination string) (io.ReadCloser, string, error) {
 	key := s.prefix + destination
 
-	resp, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+	resp, err := s.getObject(context.Background(), &s3.GetObjectInput{
 		Bucket: &s.bucket,
 		Key:    &key,
 	})
 	if err != nil {
 		var apiErr interface{ ErrorCode() string }
 		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchKey" {
-			return nil, &S3NotFoundError{Key: destination}
+			return nil, "", &S3NotFoundError{Key: destination}
 		}
-		return nil, &S3DownloadError{Key: destination, Err: err}
+		return nil, "", &S3DownloadError{Key: destination, Err: err}
 	}
-	defer resp.Body.Close()
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, resp.Body); err != nil {
-		return nil, &S3DownloadError{Key: destination, Err: err}
+	contentType := ""
+	if resp.ContentType != nil {
+		contentType = *resp.ContentType
 	}
-	return buf.Bytes(), nil
+	return resp.Body, contentType, nil
 }
@@ -1,12 +1,34 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"imagenexus/utils"
 
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
 )
 
 func TestStorageCreation(t *testing.T) {
@@ -26,3 +48,914 @@ func TestStorageRetrieval(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Greater(t, len(data), 0)
 }
+
+func TestIntegrityVerification(t *testing.T) {
+	err := verifyFile("./storage_test.go", "not-the-checksum")
+	var integrityError *IntegrityError
+	assert.ErrorAs(t, err, &integrityError)
+	assert.Equal(t, "storage_test.go", integrityError.Destination)
+
+	etag := `"5d41402abc4b2a76b9719d911017c592"`
+	assert.Nil(t, verifyETag(&etag, "hello.png", "5d41402abc4b2a76b9719d911017c592"))
+	assert.NotNil(t, verifyETag(&etag, "hello.png", "00000000000000000000000000000000"))
+
+	multipartETag := `"5d41402abc4b2a76b9719d911017c592-3"`
+	assert.Nil(t, verifyETag(&multipartETag, "hello.png", "00000000000000000000000000000000"))
+}
+
+func TestStorageSave(t *testing.T) {
+	path := "./test_images_save"
+	os.RemoveAll(path)
+	defer os.RemoveAll(path)
+
+	content := bytes.NewBuffer(newTestPNG(64, 32))
+	digest := sha256.Sum256(content.Bytes())
+
+	storage := NewStorage(path)
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("picture.png", content.Bytes()))
+	assert.Nil(t, saveError)
+	assert.Equal(t, "image/png", picture.ContentType)
+	assert.Equal(t, int32(64), picture.Width)
+	assert.Equal(t, int32(32), picture.Height)
+	assert.Equal(t, hex.EncodeToString(digest[:]), picture.Checksum)
+	assert.Equal(t, picture.Checksum+".png", picture.Destination)
+
+	data, err := storage.Get(picture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, content.Bytes(), data)
+
+	stream, contentType, err := storage.GetStream(picture.Destination)
+	assert.Nil(t, err)
+	streamed, _ := io.ReadAll(stream)
+	stream.Close()
+	assert.Equal(t, "image/png", contentType)
+	assert.Equal(t, content.Bytes(), streamed)
+
+	duplicate, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("copy.png", content.Bytes()))
+	assert.Nil(t, saveError)
+	assert.Equal(t, picture.Destination, duplicate.Destination)
+
+	entries, _ := os.ReadDir(path)
+	assert.Equal(t, 1, len(entries))
+}
+
+func TestReplicatingStorage(t *testing.T) {
+	primaryPath, backupPath := "./test_images_primary", "./test_images_backup"
+	defer os.RemoveAll(primaryPath)
+	defer os.RemoveAll(backupPath)
+
+	primary, backup := NewStorage(primaryPath), NewStorage(backupPath)
+	storage := NewReplicatingStorage(primary, backup)
+
+	content := newTestPNG(16, 16)
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("picture.png", content))
+	assert.Nil(t, saveError)
+
+	assert.Eventually(t, func() bool {
+		data, err := backup.Get(picture.Destination)
+		return err == nil && bytes.Equal(content, data)
+	}, time.Second, 10*time.Millisecond)
+
+	assert.Nil(t, storage.Delete(picture.Destination))
+	_, err := primary.Get(picture.Destination)
+	assert.NotNil(t, err)
+	_, err = backup.Get(picture.Destination)
+	assert.NotNil(t, err)
+
+	_, ok := Capability[*localImageStorage](storage)
+	assert.True(t, ok)
+	_, ok = Capability[LifecycleManager](storage)
+	assert.False(t, ok)
+}
+
+func TestNamingStrategies(t *testing.T) {
+	defer viper.Set(cfgNamingStrategy, nil)
+	content := newTestPNG(16, 16)
+
+	cases := []struct {
+		strategy string
+		pattern  string
+	}{
+		{"uuid", `^[0-9a-f-]{36}\.png$`},
+		{"date_prefix", `^\d{4}/\d{2}/\d{2}/[0-9a-f-]{36}\.png$`},
+		{"original", `^my_cat\.png$`},
+	}
+
+	for _, each := range cases {
+		t.Run(each.strategy, func(t *testing.T) {
+			viper.Set(cfgNamingStrategy, each.strategy)
+			storage := NewStorage(t.TempDir())
+
+			picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("../my cat.png", content))
+			if assert.Nil(t, saveError) {
+				assert.Regexp(t, each.pattern, picture.Destination)
+				data, err := storage.Get(picture.Destination)
+				assert.Nil(t, err)
+				assert.Equal(t, content, data)
+			}
+
+			// identical contents are stored again under another name
+			duplicate, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("../my cat.png", content))
+			if assert.Nil(t, saveError) {
+				assert.NotEqual(t, picture.Destination, duplicate.Destination)
+			}
+		})
+	}
+
+	viper.Set(cfgNamingStrategy, "original")
+	storage := NewStorage(t.TempDir())
+	for _, expected := range []string{"cat.png", "cat-1.png", "cat-2.png"} {
+		picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("cat.png", content))
+		if assert.Nil(t, saveError) {
+			assert.Equal(t, expected, picture.Destination)
+		}
+	}
+
+	// the replicas keep the destination of the primary storage
+	viper.Set(cfgNamingStrategy, "uuid")
+	primary, backup := NewStorage(t.TempDir()), NewStorage(t.TempDir())
+	picture, saveError := NewReplicatingStorage(primary, backup).Save(context.Background(), utils.NewTestFileWithContent("cat.png", content))
+	assert.Nil(t, saveError)
+	assert.Eventually(t, func() bool {
+		data, err := backup.Get(picture.Destination)
+		return err == nil && bytes.Equal(content, data)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := NewNamingStrategy("random")
+	assert.NotNil(t, err)
+}
+
+func TestLocalStorageDateTree(t *testing.T) {
+	defer viper.Set(cfgNamingStrategy, nil)
+	viper.Set(cfgNamingStrategy, "date_prefix")
+	defer func(strategy NamingStrategy) { NAMING_STRATEGIES["date_prefix"] = strategy }(NAMING_STRATEGIES["date_prefix"])
+	NAMING_STRATEGIES["date_prefix"] = datePrefixNaming{now: func() time.Time {
+		return time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC)
+	}}
+
+	path := filepath.Join(t.TempDir(), "nested", "images")
+	storage := NewStorage(path)
+
+	first, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("cat.png", newTestPNG(16, 16)))
+	assert.Nil(t, saveError)
+	second, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("dog.png", newTestPNG(8, 8)))
+	assert.Nil(t, saveError)
+	assert.True(t, strings.HasPrefix(first.Destination, "2024/01/15/"))
+	assert.FileExists(t, filepath.Join(path, "2024", "01", "15", filepath.Base(second.Destination)))
+
+	// the tree is removed along with its last file only
+	assert.Nil(t, storage.Delete(first.Destination))
+	assert.DirExists(t, filepath.Join(path, "2024", "01", "15"))
+	assert.Nil(t, storage.Delete(second.Destination))
+	assert.NoDirExists(t, filepath.Join(path, "2024"))
+	assert.DirExists(t, path)
+}
+
+func newTestPNG(width, height int) []byte {
+	var content bytes.Buffer
+	png.Encode(&content, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return content.Bytes()
+}
+
+// magicHeaders are the prefixes the content types are detected by.
+var magicHeaders = map[string][]byte{
+	"image/jpeg": []byte("\xFF\xD8\xFF"),
+	"image/png":  []byte("\x89PNG\x0D\x0A\x1A\x0A"),
+	"image/gif":  []byte("GIF89a"),
+	"image/tiff": []byte("II*\x00"),
+	"image/webp": []byte("RIFF\x00\x00\x00\x00WEBPVP"),
+	"image/bmp":  []byte("BM"),
+}
+
+func FuzzDecodeConfig(f *testing.F) {
+	source := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	encoders := map[string]func(io.Writer, image.Image) error{
+		"image/jpeg": func(w io.Writer, m image.Image) error { return jpeg.Encode(w, m, nil) },
+		"image/png":  png.Encode,
+		"image/gif":  func(w io.Writer, m image.Image) error { return gif.Encode(w, m, nil) },
+		"image/tiff": func(w io.Writer, m image.Image) error { return tiff.Encode(w, m, nil) },
+		"image/bmp":  bmp.Encode,
+	}
+	for contentType, encode := range encoders {
+		var encoded bytes.Buffer
+		if err := encode(&encoded, source); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(contentType, encoded.Bytes())
+	}
+
+	webp, err := os.ReadFile("../imaging/testdata/gopher.lossless.webp")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add("image/webp", webp)
+
+	f.Fuzz(func(t *testing.T, contentType string, data []byte) {
+		decoder, ok := CONTENT_DECODERS[contentType]
+		if !ok {
+			return
+		}
+		if magic := magicHeaders[contentType]; !bytes.HasPrefix(data, magic) {
+			data = append(append([]byte{}, magic...), data...)
+		}
+
+		imageConfig, err := decoder(bytes.NewReader(data))
+		if err == nil && (imageConfig.Width <= 0 || imageConfig.Height <= 0) {
+			t.Errorf("%s decoded to %dx%d without an error", contentType, imageConfig.Width, imageConfig.Height)
+		}
+	})
+}
+
+func newTestJPEG(width, height int) []byte {
+	var content bytes.Buffer
+	jpeg.Encode(&content, image.NewRGBA(image.Rect(0, 0, width, height)), nil)
+	return content.Bytes()
+}
+
+// newPolyglotJPEG keeps the header of a JPEG up to its scan and carries an
+// SVG in place of the pixels.
+func newPolyglotJPEG() []byte {
+	content := newTestJPEG(16, 8)
+	header := content[:bytes.Index(content, []byte{0xFF, 0xDA})]
+	return append(header, []byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"/>`)...)
+}
+
+func TestLocalStorageSave(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "imagenexus-save-"+utils.NewUniqueString())
+	defer os.RemoveAll(path)
+	storage := NewStorage(path)
+
+	// larger than the peeked header and the in-memory part of multipart forms
+	oversized := newTestPNG(2048, 2048)
+	oversized = append(oversized, bytes.Repeat([]byte{0}, 9<<20)...)
+
+	cases := []struct {
+		name        string
+		filename    string
+		content     []byte
+		statusCode  int
+		contentType string
+		extension   string
+	}{
+		{"valid jpeg", "picture.jpg", newTestJPEG(16, 8), 0, "image/jpeg", ".jpg"},
+		{"valid png", "picture.png", newTestPNG(16, 8), 0, "image/png", ".png"},
+		{"oversized file", "large.png", oversized, 0, "image/png", ".png"},
+		{"invalid magic bytes", "picture.png", []byte("definitely not an image"), http.StatusBadRequest, "", ""},
+		{"corrupt image", "picture.png", newTestPNG(16, 8)[:20], http.StatusBadRequest, "", ""},
+		{"empty file", "picture.png", []byte{}, http.StatusBadRequest, "", ""},
+		{"truncated pixel data", "picture.png", newTestPNG(16, 8)[:40], http.StatusBadRequest, "", ""},
+		{"svg in jpeg polyglot", "picture.jpg", newPolyglotJPEG(), http.StatusBadRequest, "", ""},
+		{"spaces in filename", "my cat picture.png", newTestPNG(3, 3), 0, "image/png", ".png"},
+		{"unicode filename", "chat-noir-été-猫.png", newTestPNG(4, 4), 0, "image/png", ".png"},
+		{"path traversal", "../../etc/passwd.png", newTestPNG(5, 5), 0, "image/png", ".png"},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent(each.filename, each.content))
+			if each.statusCode != 0 {
+				if assert.NotNil(t, saveError) {
+					assert.Equal(t, each.statusCode, saveError.StatusCode)
+				}
+				return
+			}
+
+			if !assert.Nil(t, saveError) {
+				return
+			}
+			digest := sha256.Sum256(each.content)
+			// multipart drops the directories of the uploaded filename
+			assert.Equal(t, filepath.Base(each.filename), picture.Name)
+			assert.Equal(t, each.contentType, picture.ContentType)
+			assert.Equal(t, int32(len(each.content)), picture.Size)
+			// whatever the filename, the file is stored by its checksum
+			// directly under the storage path
+			assert.Equal(t, hex.EncodeToString(digest[:])+each.extension, picture.Destination)
+			assert.FileExists(t, filepath.Join(path, picture.Destination))
+		})
+	}
+}
+
+func TestLocalStorageGet(t *testing.T) {
+	path := t.TempDir()
+	storage := NewStorage(path)
+	content := newTestPNG(8, 8)
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("picture.png", content))
+	assert.Nil(t, saveError)
+
+	cases := []struct {
+		name        string
+		destination string
+		content     []byte
+		err         error
+	}{
+		{"stored file", picture.Destination, content, nil},
+		{"missing file", "missing.png", nil, os.ErrNotExist},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			data, err := storage.Get(each.destination)
+			if each.err != nil {
+				assert.ErrorIs(t, err, each.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, each.content, data)
+		})
+	}
+}
+
+func TestLocalStorageGetFullPath(t *testing.T) {
+	cases := []struct {
+		path        string
+		destination string
+		fullPath    string
+	}{
+		{"./images", "abc.png", "./images/abc.png"},
+		{"/var/images", "abc.png", "/var/images/abc.png"},
+		{"/var/images", "previews/abc.jpg", "/var/images/previews/abc.jpg"},
+		{"/var/images", "2024/01/15/abc.png", "/var/images/2024/01/15/abc.png"},
+		{"/var/images", "my cat.png", "/var/images/my cat.png"},
+		{"/var/images", "../../etc/passwd", "/var/images/etc/passwd"},
+		{"/var/images", "previews/../../secret.png", "/var/images/secret.png"},
+	}
+
+	for _, each := range cases {
+		storage := &localImageStorage{each.path}
+		assert.Equal(t, each.fullPath, storage.GetFullPath(each.destination), each.destination)
+	}
+}
+
+func TestLocalStoragePathTraversal(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "images")
+	storage := NewStorage(path)
+
+	// a file next to the storage directory that must stay out of reach
+	secret := filepath.Join(root, "secret.png")
+	assert.Nil(t, os.WriteFile(secret, newTestPNG(2, 2), 0o644))
+
+	for _, destination := range []string{"../secret.png", "../../etc/passwd", "previews/../../secret.png", "..", ""} {
+		_, err := storage.Get(destination)
+		assert.ErrorIs(t, err, ErrPathTraversal, destination)
+
+		_, _, err = storage.GetStream(destination)
+		assert.ErrorIs(t, err, ErrPathTraversal, destination)
+
+		_, err = storage.GetReader(destination)
+		assert.ErrorIs(t, err, ErrPathTraversal, destination)
+
+		assert.ErrorIs(t, storage.Delete(destination), ErrPathTraversal, destination)
+
+		writer, _ := Capability[FileWriter](storage)
+		assert.ErrorIs(t, writer.Put(destination, "image/png", strings.NewReader("overwritten")), ErrPathTraversal, destination)
+	}
+	assert.FileExists(t, secret)
+
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("../../secret.png", newTestPNG(3, 3)))
+	if assert.Nil(t, saveError) {
+		assert.FileExists(t, filepath.Join(path, picture.Destination))
+	}
+}
+
+func TestLocalStoragePut(t *testing.T) {
+	storage := NewStorage(t.TempDir())
+	writer, ok := Capability[FileWriter](NewReplicatingStorage(storage, NewStorage(t.TempDir())))
+	assert.True(t, ok)
+
+	assert.Nil(t, writer.Put("tilesets/1_files/0/0_0.jpg", "image/jpeg", strings.NewReader("tile")))
+	assert.Nil(t, writer.Put("tilesets/1_files/0/0_0.jpg", "image/jpeg", strings.NewReader("new tile")))
+
+	data, err := storage.Get("tilesets/1_files/0/0_0.jpg")
+	assert.Nil(t, err)
+	assert.Equal(t, "new tile", string(data))
+}
+
+func TestLocalStorageListFiles(t *testing.T) {
+	storage := NewStorage(t.TempDir())
+	lister, ok := Capability[FileLister](NewReplicatingStorage(storage, NewStorage(t.TempDir())))
+	if !assert.True(t, ok) {
+		return
+	}
+
+	writer := storage.(FileWriter)
+	assert.Nil(t, writer.Put("picture.png", "image/png", strings.NewReader("picture")))
+	assert.Nil(t, writer.Put("tilesets/1_files/0/0_0.jpg", "image/jpeg", strings.NewReader("tile")))
+
+	files := map[string]int64{}
+	err := lister.ListFiles(func(file *StoredFile) error {
+		files[file.Destination] = file.Size
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"picture.png": 7, "tilesets/1_files/0/0_0.jpg": 4}, files)
+}
+
+func TestSanitizeSVG(t *testing.T) {
+	const open = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"`
+
+	cases := []struct {
+		name      string
+		svg       string
+		sanitized string
+		width     int
+		height    int
+		err       error
+	}{
+		{
+			name:      "width and height",
+			svg:       `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg" width="20" height="10.5px"><rect width="5" height="5" fill="red"/></svg>`,
+			sanitized: open + ` width="20" height="10.5px"><rect width="5" height="5" fill="red"></rect></svg>`,
+			width:     20,
+			height:    11,
+		},
+		{
+			name:      "viewBox",
+			svg:       `<svg viewBox="0,0 64 32" width="100%"><title>a &amp; b</title></svg>`,
+			sanitized: open + ` viewBox="0,0 64 32" width="100%"><title>a &amp; b</title></svg>`,
+			width:     64,
+			height:    32,
+		},
+		{
+			name:      "scripts and foreign objects",
+			svg:       `<svg viewBox="0 0 1 1"><script>alert(1)</script><foreignObject><div>alert(2)</div></foreignObject><g><style>@import "x"</style></g></svg>`,
+			sanitized: open + ` viewBox="0 0 1 1"><g></g></svg>`,
+			width:     1,
+			height:    1,
+		},
+		{
+			name:      "event attributes",
+			svg:       `<svg viewBox="0 0 1 1" onload="alert(1)"><circle r="1" ONCLICK="alert(2)" data-x="1"/></svg>`,
+			sanitized: open + ` viewBox="0 0 1 1"><circle r="1"></circle></svg>`,
+			width:     1,
+			height:    1,
+		},
+		{
+			name: "links",
+			svg: open + ` viewBox="0 0 1 1"><use href="#a"/><use xlink:href="https://example.com/x.svg#a"/>` +
+				`<image href="data:image/png;base64,AAAA"/><image xlink:href="data:image/svg+xml;base64,AAAA"/><a href="javascript:alert(1)"><text>x</text></a></svg>`,
+			sanitized: open + ` viewBox="0 0 1 1"><use href="#a"></use><use></use><image href="data:image/png;base64,AAAA"></image><image></image></svg>`,
+			width:     1,
+			height:    1,
+		},
+		{
+			name:      "styles",
+			svg:       `<svg viewBox="0 0 1 1"><rect style="fill: url(#gradient)"/><rect style="fill: url( 'https://example.com/x')"/></svg>`,
+			sanitized: open + ` viewBox="0 0 1 1"><rect style="fill: url(#gradient)"></rect><rect></rect></svg>`,
+			width:     1,
+			height:    1,
+		},
+		{
+			name:      "charset",
+			svg:       "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><svg viewBox=\"0 0 1 1\"><desc>caf\xe9</desc></svg>",
+			sanitized: open + ` viewBox="0 0 1 1"><desc>café</desc></svg>`,
+			width:     1,
+			height:    1,
+		},
+		{name: "no dimensions", svg: `<svg width="50%"></svg>`, err: ErrNoDimensions},
+		{name: "not an svg", svg: `<html><svg viewBox="0 0 1 1"></svg></html>`, err: ErrNotSVG},
+		{name: "external entity", svg: `<!DOCTYPE svg [<!ENTITY x SYSTEM "file:///etc/passwd">]><svg viewBox="0 0 1 1"><text>&x;</text></svg>`},
+		{name: "unclosed", svg: `<svg viewBox="0 0 1 1"><g>`},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			sanitized, imageConfig, err := sanitizeSVG(strings.NewReader(each.svg))
+			if each.sanitized == "" {
+				assert.NotNil(t, err)
+				if each.err != nil {
+					assert.ErrorIs(t, err, each.err)
+				}
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, each.sanitized, string(sanitized))
+			assert.Equal(t, each.width, imageConfig.Width)
+			assert.Equal(t, each.height, imageConfig.Height)
+		})
+	}
+}
+
+func TestLocalStorageSaveSVG(t *testing.T) {
+	storage := NewStorage(t.TempDir())
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="12" height="8" onload="alert(1)"><script>alert(2)</script></svg>`)
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("drawing.svg", svg))
+	if !assert.Nil(t, saveError) {
+		return
+	}
+	assert.Equal(t, "image/svg+xml", picture.ContentType)
+	assert.Equal(t, int32(12), picture.Width)
+	assert.Equal(t, int32(8), picture.Height)
+
+	stored, err := storage.Get(picture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(len(stored)), picture.Size)
+	assert.NotContains(t, string(stored), "alert")
+
+	_, saveError = storage.Save(context.Background(), utils.NewTestFileWithContent("drawing.svg", []byte(`<svg><g></svg>`)))
+	if assert.NotNil(t, saveError) {
+		assert.Equal(t, http.StatusBadRequest, saveError.StatusCode)
+	}
+}
+
+func TestDecodePDF(t *testing.T) {
+	defer viper.Set(cfgPDFMaxPages, nil)
+	viper.Set(cfgPDFMaxPages, 3)
+
+	cases := []struct {
+		name   string
+		pdf    []byte
+		width  int
+		height int
+		err    error
+	}{
+		{"single page", utils.NewTestPDF(1, 200, 100), 200, 100, nil},
+		{"first page", utils.NewTestPDF(3, 50, 80), 50, 80, nil},
+		{"too many pages", utils.NewTestPDF(4, 50, 80), 0, 0, ErrTooManyPages},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			imageConfig, err := CONTENT_DECODERS[PDFContentType](bytes.NewReader(each.pdf))
+			preview, decodeErr := DecodeImage(each.pdf, PDFContentType)
+			if each.err != nil {
+				assert.ErrorIs(t, err, each.err)
+				assert.ErrorIs(t, decodeErr, each.err)
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, each.width, imageConfig.Width)
+			assert.Equal(t, each.height, imageConfig.Height)
+			if assert.Nil(t, decodeErr) {
+				assert.Equal(t, image.Rect(0, 0, each.width, each.height), preview.Bounds())
+			}
+		})
+	}
+}
+
+func TestLocalStorageSavePDF(t *testing.T) {
+	storage := NewStorage(t.TempDir())
+	content := utils.NewTestPDF(2, 120, 90)
+
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("document.pdf", content))
+	if !assert.Nil(t, saveError) {
+		return
+	}
+	assert.Equal(t, PDFContentType, picture.ContentType)
+	assert.Equal(t, int32(120), picture.Width)
+	assert.Equal(t, int32(90), picture.Height)
+
+	stored, err := storage.Get(picture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, content, stored)
+
+	_, saveError = storage.Save(context.Background(), utils.NewTestFileWithContent("document.pdf", []byte("%PDF-1.4\nnot really")))
+	if assert.NotNil(t, saveError) {
+		assert.Equal(t, http.StatusBadRequest, saveError.StatusCode)
+	}
+}
+
+func TestRestoreState(t *testing.T) {
+	restore := func(header string) *string { return &header }
+
+	cases := []struct {
+		class   s3types.StorageClass
+		restore *string
+		state   string
+	}{
+		{s3types.StorageClassStandardIa, nil, ObjectAvailable},
+		{s3types.StorageClassGlacierIr, nil, ObjectAvailable},
+		{s3types.StorageClassGlacier, nil, ObjectArchived},
+		{s3types.StorageClassGlacier, restore(`ongoing-request="true"`), ObjectRestoring},
+		{s3types.StorageClassDeepArchive, restore(`ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`), ObjectAvailable},
+	}
+
+	for _, each := range cases {
+		assert.Equal(t, each.state, restoreState(each.class, each.restore), string(each.class))
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "RequestLimitExceeded"}
+	unavailable := &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}}}
+
+	assert.True(t, isRetryable(throttled))
+	assert.True(t, isRetryable(&S3DownloadError{Key: "a.png", Err: io.ErrUnexpectedEOF}))
+	assert.True(t, isRetryable(unavailable))
+	assert.False(t, isRetryable(&smithy.GenericAPIError{Code: "AccessDenied"}))
+	assert.False(t, isRetryable(&S3NotFoundError{Key: "a.png"}))
+	assert.False(t, isRetryable(errors.New("invalid key")))
+
+	var delays []time.Duration
+	policy := retryPolicy{maxRetries: 3, baseDelay: 100 * time.Millisecond, sleep: func(delay time.Duration) { delays = append(delays, delay) }}
+
+	attempts := 0
+	err := policy.do(func() error {
+		attempts++
+		if attempts < 3 {
+			return throttled
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, attempts)
+	if assert.Len(t, delays, 2) {
+		assert.Less(t, delays[0], 100*time.Millisecond)
+		assert.Less(t, delays[1], 200*time.Millisecond)
+	}
+
+	attempts = 0
+	assert.Equal(t, throttled, policy.do(func() error { attempts++; return throttled }))
+	assert.Equal(t, 4, attempts)
+
+	attempts = 0
+	denied := &smithy.GenericAPIError{Code: "AccessDenied"}
+	assert.Equal(t, denied, policy.do(func() error { attempts++; return denied }))
+	assert.Equal(t, 1, attempts)
+}
+
+// failingStorage is a local storage failing its downloads while down.
+type failingStorage struct {
+	ImageStorage
+	down bool
+}
+
+func (s *failingStorage) Get(destination string) ([]byte, error) {
+	if s.down {
+		return nil, &S3DownloadError{Key: destination, Err: io.ErrUnexpectedEOF}
+	}
+	return s.ImageStorage.Get(destination)
+}
+
+func TestCircuitBreakerStorage(t *testing.T) {
+	path := t.TempDir()
+	failing := &failingStorage{ImageStorage: NewStorage(path)}
+	guarded := NewCircuitBreakerStorage(failing, 2, 50*time.Millisecond)
+
+	picture, saveError := guarded.SaveReader(context.Background(), "cat.png", bytes.NewReader(newTestPNG(4, 4)))
+	if !assert.Nil(t, saveError) {
+		return
+	}
+	state, ok := Capability[CircuitStater](guarded)
+	assert.True(t, ok)
+
+	// the missing files aren't failures of the storage
+	_, err := guarded.Get("missing.png")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Equal(t, "closed", state.CircuitState())
+
+	failing.down = true
+	for range 2 {
+		_, err = guarded.Get(picture.Destination)
+		assert.NotErrorIs(t, err, ErrCircuitOpen)
+	}
+	assert.Equal(t, "open", state.CircuitState())
+	_, err = guarded.Get(picture.Destination)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	_, saveError = guarded.SaveReader(context.Background(), "cat.png", bytes.NewReader(newTestPNG(4, 4)))
+	if assert.NotNil(t, saveError) {
+		assert.Equal(t, http.StatusServiceUnavailable, saveError.StatusCode)
+	}
+
+	failing.down = false
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, "half-open", state.CircuitState())
+	_, err = guarded.Get(picture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, "closed", state.CircuitState())
+}
+
+func TestS3SignedURLs(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cache := newMemoryURLCache()
+	cache.now = func() time.Time { return now }
+
+	signatures := 0
+	storage := &s3ImageStorage{
+		prefix:        "images/",
+		cloudFrontURL: "https://cdn.example.com",
+		signedURLs: &signedURLs{
+			ttl:   time.Hour,
+			cache: cache,
+			presign: func(ctx context.Context, key string, ttl time.Duration) (string, error) {
+				signatures++
+				if key == "images/broken.png" {
+					return "", errors.New("no credentials")
+				}
+				return fmt.Sprintf("https://bucket.s3.amazonaws.com/%s?X-Amz-Expires=%d&signature=%d", key, int(ttl.Seconds()), signatures), nil
+			},
+		},
+	}
+
+	url := storage.GetFullPath("cat.png")
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/images/cat.png?X-Amz-Expires=3600&signature=1", url)
+
+	// the URL is reused within its TTL
+	now = now.Add(50 * time.Minute)
+	assert.Equal(t, url, storage.GetFullPath("cat.png"))
+	assert.Equal(t, 1, signatures)
+
+	// and signed again within 10% of its expiry
+	now = now.Add(5 * time.Minute)
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/images/cat.png?X-Amz-Expires=3600&signature=2", storage.GetFullPath("cat.png"))
+	assert.Equal(t, "https://bucket.s3.amazonaws.com/images/dog.png?X-Amz-Expires=3600&signature=3", storage.GetFullPath("dog.png"))
+
+	now = now.Add(2 * time.Hour)
+	_, _, ok := cache.Get(context.Background(), "images/cat.png")
+	assert.False(t, ok)
+
+	// the CloudFront URL is the fallback of the failed signatures
+	assert.Equal(t, "https://cdn.example.com/images/broken.png", storage.GetFullPath("broken.png"))
+
+	storage.signedURLs = nil
+	assert.Equal(t, "https://cdn.example.com/images/cat.png", storage.GetFullPath("cat.png"))
+}
+
+func TestParseRoutingRules(t *testing.T) {
+	rules, err := ParseRoutingRules([]string{
+		"content_type:image/gif -> Gifs",
+		"content_type:image/* size:>10MB -> s3-cold",
+		"size:<=1KB -> small",
+		"default -> s3-hot",
+	})
+	if assert.Nil(t, err) {
+		assert.Equal(t, []Rule{
+			{ContentType: "image/gif", Backend: "gifs"},
+			{ContentType: "image/*", MinSize: 10<<20 + 1, Backend: "s3-cold"},
+			{MaxSize: 1 << 10, Backend: "small"},
+			{Backend: "s3-hot"},
+		}, rules)
+	}
+
+	assert.True(t, rules[1].Matches("image/png", 11<<20))
+	assert.False(t, rules[1].Matches("image/png", 10<<20))
+	assert.False(t, rules[1].Matches("video/mp4", 11<<20))
+	assert.True(t, rules[3].Matches("video/mp4", 0))
+
+	for _, invalid := range [][]string{
+		{},
+		{"size:>10MB -> s3-cold"},
+		{"default -> s3-hot", "size:>10MB -> s3-cold"},
+		{"default s3-hot"},
+		{"size:10MB -> s3-cold", "default -> s3-hot"},
+		{"size:>10TB -> s3-cold", "default -> s3-hot"},
+		{"name:cat -> cats", "default -> s3-hot"},
+		{"default -> images/hot"},
+	} {
+		_, err := ParseRoutingRules(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestRoutingStorage(t *testing.T) {
+	hotPath, coldPath, gifsPath := "./test_images_hot", "./test_images_cold", "./test_images_gifs"
+	defer os.RemoveAll(hotPath)
+	defer os.RemoveAll(coldPath)
+	defer os.RemoveAll(gifsPath)
+
+	rules, err := ParseRoutingRules([]string{"content_type:image/gif -> gifs", "size:>1KB -> cold", "default -> hot"})
+	assert.Nil(t, err)
+	hot, cold, gifs := NewStorage(hotPath), NewStorage(coldPath), NewStorage(gifsPath)
+	storage, err := NewRoutingStorage(rules, map[string]ImageStorage{"hot": hot, "cold": cold, "gifs": gifs})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	small, large := newTestPNG(1, 1), newTestPNG(512, 512)
+	assert.Less(t, len(small), 1024)
+	assert.Greater(t, len(large), 1024)
+	var animation bytes.Buffer
+	assert.Nil(t, gif.Encode(&animation, image.NewPaletted(image.Rect(0, 0, 4, 4), []color.Color{color.Black}), nil))
+
+	smallPicture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("small.png", small))
+	assert.Nil(t, saveError)
+	largePicture, saveError := storage.SaveReader(context.Background(), "large.png", bytes.NewReader(large))
+	assert.Nil(t, saveError)
+	gifPicture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("animation.gif", animation.Bytes()))
+	assert.Nil(t, saveError)
+
+	// the default backend keeps the destinations as such
+	assert.False(t, strings.Contains(smallPicture.Destination, "/"))
+	assert.Equal(t, "cold/", largePicture.Destination[:len("cold/")])
+	assert.Equal(t, "gifs/", gifPicture.Destination[:len("gifs/")])
+	assert.Equal(t, hotPath+"/"+smallPicture.Destination, storage.GetFullPath(smallPicture.Destination))
+	assert.Equal(t, coldPath+"/"+largePicture.Destination[len("cold/"):], storage.GetFullPath(largePicture.Destination))
+
+	data, err := cold.Get(largePicture.Destination[len("cold/"):])
+	assert.Nil(t, err)
+	assert.Equal(t, large, data)
+	data, err = storage.Get(largePicture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, large, data)
+	data, err = storage.Get(smallPicture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, small, data)
+
+	listed := map[string]int64{}
+	lister, ok := Capability[FileLister](storage)
+	assert.True(t, ok)
+	assert.Nil(t, lister.ListFiles(func(file *StoredFile) error {
+		listed[file.Destination] = file.Size
+		return nil
+	}))
+	assert.Equal(t, map[string]int64{
+		smallPicture.Destination: int64(len(small)),
+		largePicture.Destination: int64(len(large)),
+		gifPicture.Destination:   int64(animation.Len()),
+	}, listed)
+
+	assert.Nil(t, storage.Delete(gifPicture.Destination))
+	_, err = gifs.Get(gifPicture.Destination[len("gifs/"):])
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	_, err = NewRoutingStorage(rules, map[string]ImageStorage{"hot": hot})
+	assert.NotNil(t, err)
+}
+
+func TestFeatureFlagStorage(t *testing.T) {
+	currentPath, candidatePath := "./test_images_current", "./test_images_candidate"
+	defer os.RemoveAll(currentPath)
+	defer os.RemoveAll(candidatePath)
+
+	current, candidate := NewStorage(currentPath), NewStorage(candidatePath)
+	storage := NewFeatureFlagStorage(current, candidate, 100).(*featureFlagStorage)
+
+	// the files are copied once their picture is rolled out
+	content := newTestPNG(8, 8)
+	picture, saveError := storage.Save(context.Background(), utils.NewTestFileWithContent("picture.png", content))
+	assert.Nil(t, saveError)
+	time.Sleep(20 * time.Millisecond)
+	_, err := candidate.Get(picture.Destination)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	canary, ok := Capability[Canary](NewReplicatingStorage(storage, NewStorage(candidatePath)))
+	assert.True(t, ok)
+	canary.RollOut(1, picture.Destination, "")
+	assert.Eventually(t, func() bool {
+		data, err := candidate.Get(picture.Destination)
+		return err == nil && bytes.Equal(content, data)
+	}, time.Second, 10*time.Millisecond)
+
+	// the copies are deleted after the rollout is lowered too
+	lowered := NewFeatureFlagStorage(current, candidate, 0)
+	assert.Nil(t, lowered.Delete(picture.Destination))
+	_, err = candidate.Get(picture.Destination)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// the rollout is the same for a picture whatever the storage
+	halved := NewFeatureFlagStorage(current, candidate, 50).(*featureFlagStorage)
+	rolledOut := 0
+	for id := range uint(1000) {
+		assert.Equal(t, halved.InRollout(id), NewFeatureFlagStorage(current, candidate, 50).(*featureFlagStorage).InRollout(id))
+		if halved.InRollout(id) {
+			rolledOut++
+		}
+	}
+	assert.InDelta(t, 500, rolledOut, 60)
+
+	storage = NewFeatureFlagStorage(current, candidate, 0).(*featureFlagStorage)
+	picture, saveError = storage.SaveReader(context.Background(), "picture.png", bytes.NewReader(content))
+	assert.Nil(t, saveError)
+	data, err := storage.Get(picture.Destination)
+	assert.Nil(t, err)
+	assert.Equal(t, content, data)
+	storage.RollOut(1, picture.Destination)
+	time.Sleep(20 * time.Millisecond)
+	_, err = candidate.Get(picture.Destination)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// a missing copy doesn't fail the delete
+	assert.Nil(t, storage.Delete(picture.Destination))
+}
+
+func TestMaxFileSizeByType(t *testing.T) {
+	viper.Set(cfgMaxFileSizeByType, map[string]any{"image/gif": "5MB", "image/png": "1KB", "default": "0.5GB"})
+	defer viper.Set(cfgMaxFileSizeByType, nil)
+
+	assert.Equal(t, int64(5<<20), MaxFileSize("image/gif"))
+	assert.Equal(t, int64(1<<29), MaxFileSize("image/tiff"))
+
+	path := t.TempDir()
+	storage := NewStorage(path)
+	_, saveError := storage.SaveReader(context.Background(), "small.png", bytes.NewReader(newTestPNG(1, 1)))
+	assert.Nil(t, saveError)
+
+	large := newTestPNG(512, 512)
+	_, saveError = storage.SaveReader(context.Background(), "large.png", bytes.NewReader(large))
+	if assert.NotNil(t, saveError) {
+		assert.Equal(t, http.StatusRequestEntityTooLarge, saveError.StatusCode)
+		assert.ErrorIs(t, saveError.Error, ErrFileTooLarge)
+		assert.Equal(t, int64(1<<10), saveError.Data["max_size"])
+	}
+
+	// no file is left behind
+	entries, _ := os.ReadDir(path)
+	assert.Len(t, entries, 1)
+
+	viper.Set(cfgMaxFileSizeByType, nil)
+	assert.Equal(t, int64(0), MaxFileSize("image/png"))
+}
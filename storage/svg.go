@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"imagenexus/validation"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	svgContentType = validation.SVGContentType
+	svgNamespace   = "http://www.w3.org/2000/svg"
+	xlinkNamespace = "http://www.w3.org/1999/xlink"
+)
+
+var ErrNotSVG = errors.New("root element is not an svg")
+
+// The elements kept by sanitizeSVG. Any other element is dropped along with
+// its contents, notably script, style and foreignObject.
+var svgElements = toSet(
+	"svg", "g", "defs", "symbol", "use", "title", "desc",
+	"path", "rect", "circle", "ellipse", "line", "polyline", "polygon",
+	"text", "tspan", "textPath", "image",
+	"linearGradient", "radialGradient", "stop", "pattern",
+	"clipPath", "mask", "marker",
+	"filter", "feBlend", "feColorMatrix", "feComponentTransfer", "feComposite",
+	"feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR",
+	"feGaussianBlur", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+)
+
+// The attributes kept by sanitizeSVG, besides the namespace declarations and
+// the links checked by isSafeSVGLink.
+var svgAttributes = toSet(
+	"id", "class", "style", "version", "viewBox", "preserveAspectRatio",
+	"x", "y", "x1", "y1", "x2", "y2", "cx", "cy", "r", "rx", "ry", "fx", "fy", "fr",
+	"width", "height", "d", "points", "pathLength", "transform",
+	"fill", "fill-opacity", "fill-rule", "stroke", "stroke-width", "stroke-linecap",
+	"stroke-linejoin", "stroke-miterlimit", "stroke-dasharray", "stroke-dashoffset",
+	"stroke-opacity", "opacity", "color", "display", "visibility",
+	"clip-path", "clip-rule", "clipPathUnits", "mask", "maskUnits", "maskContentUnits",
+	"filter", "filterUnits", "primitiveUnits",
+	"gradientUnits", "gradientTransform", "spreadMethod", "offset", "stop-color", "stop-opacity",
+	"patternUnits", "patternContentUnits", "patternTransform",
+	"markerWidth", "markerHeight", "markerUnits", "refX", "refY", "orient",
+	"marker-start", "marker-mid", "marker-end",
+	"font-family", "font-size", "font-weight", "font-style", "text-anchor",
+	"dominant-baseline", "letter-spacing", "word-spacing", "text-decoration",
+	"dx", "dy", "rotate", "textLength", "lengthAdjust", "startOffset",
+	"in", "in2", "result", "stdDeviation", "mode", "type", "values", "operator",
+	"k1", "k2", "k3", "k4", "radius", "flood-color", "flood-opacity",
+	"tableValues", "slope", "intercept", "amplitude", "exponent",
+)
+
+// The data URLs allowed in links, the raster formats an image element can't
+// run scripts from.
+var svgDataURLs = []string{"data:image/png", "data:image/jpeg", "data:image/gif", "data:image/webp"}
+
+func toSet(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// sanitizeSVG rewrites the SVG document with only the allowlisted elements
+// and attributes, in UTF-8 and without comments, processing instructions or
+// doctype. The dimensions come from the width and height of the root
+// element, or from its viewBox when they are missing or relative.
+func sanitizeSVG(src io.Reader) ([]byte, image.Config, error) {
+	decoder := xml.NewDecoder(src)
+	decoder.CharsetReader = charset.NewReaderLabel
+
+	var out bytes.Buffer
+	var imageConfig image.Config
+	// the names of the open elements, empty for the dropped ones
+	var open []string
+	skipping := 0
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, imageConfig, fmt.Errorf("invalid svg: %w", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if len(open) == 0 {
+				if out.Len() > 0 || token.Name.Space != "" || token.Name.Local != "svg" {
+					return nil, imageConfig, ErrNotSVG
+				}
+				if imageConfig, err = svgDimensions(token.Attr); err != nil {
+					return nil, imageConfig, err
+				}
+			}
+
+			if skipping > 0 || token.Name.Space != "" || !svgElements[token.Name.Local] {
+				skipping++
+				open = append(open, "")
+				continue
+			}
+			open = append(open, token.Name.Local)
+			writeSVGStartElement(&out, token, len(open) == 1)
+
+		case xml.EndElement:
+			if len(open) == 0 {
+				return nil, imageConfig, errors.New("invalid svg: unexpected end element")
+			}
+			name := open[len(open)-1]
+			open = open[:len(open)-1]
+			if name == "" {
+				skipping--
+				continue
+			}
+			if token.Name.Space != "" || token.Name.Local != name {
+				return nil, imageConfig, fmt.Errorf("invalid svg: element <%s> closed by </%s>", name, token.Name.Local)
+			}
+			fmt.Fprintf(&out, "</%s>", name)
+
+		case xml.CharData:
+			if len(open) > 0 && skipping == 0 {
+				xml.EscapeText(&out, token)
+			}
+		}
+	}
+
+	if out.Len() == 0 {
+		return nil, imageConfig, ErrNotSVG
+	}
+	if len(open) > 0 {
+		return nil, imageConfig, errors.New("invalid svg: unclosed elements")
+	}
+	return out.Bytes(), imageConfig, nil
+}
+
+func writeSVGStartElement(out *bytes.Buffer, element xml.StartElement, isRoot bool) {
+	out.WriteString("<" + element.Name.Local)
+	if isRoot {
+		fmt.Fprintf(out, ` xmlns="%s" xmlns:xlink="%s"`, svgNamespace, xlinkNamespace)
+	}
+
+	for _, attr := range element.Attr {
+		name, ok := svgAttributeName(attr)
+		if !ok {
+			continue
+		}
+		out.WriteString(" " + name + `="`)
+		xml.EscapeText(out, []byte(attr.Value))
+		out.WriteString(`"`)
+	}
+	out.WriteString(">")
+}
+
+// svgAttributeName returns the name the attribute is written with, and false
+// for the attributes to drop. The namespace declarations are dropped since
+// the root element declares the two namespaces in use.
+func svgAttributeName(attr xml.Attr) (string, bool) {
+	switch {
+	case attr.Name.Space == "" && attr.Name.Local == "xmlns", attr.Name.Space == "xmlns":
+		return "", false
+	case strings.HasPrefix(strings.ToLower(attr.Name.Local), "on"):
+		return "", false
+	case attr.Name.Local == "href" && (attr.Name.Space == "" || attr.Name.Space == "xlink"):
+		if !isSafeSVGLink(attr.Value) {
+			return "", false
+		}
+		if attr.Name.Space == "xlink" {
+			return "xlink:href", true
+		}
+		return "href", true
+	case attr.Name.Space == "xml" && (attr.Name.Local == "space" || attr.Name.Local == "lang"):
+		return "xml:" + attr.Name.Local, true
+	case attr.Name.Space != "" || !svgAttributes[attr.Name.Local]:
+		return "", false
+	case attr.Name.Local == "style" && !isSafeSVGStyle(attr.Value):
+		return "", false
+	}
+	return attr.Name.Local, true
+}
+
+// isSafeSVGLink allows the links to fragments of the document and to the
+// data URLs of raster images.
+func isSafeSVGLink(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if strings.HasPrefix(value, "#") {
+		return true
+	}
+	for _, prefix := range svgDataURLs {
+		if strings.HasPrefix(value, prefix+";") || strings.HasPrefix(value, prefix+",") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSafeSVGStyle refuses the inline styles that import or load anything but
+// fragments of the document.
+func isSafeSVGStyle(value string) bool {
+	value = strings.ToLower(strings.Join(strings.Fields(value), ""))
+	if strings.Contains(value, "@import") || strings.Contains(value, "expression(") {
+		return false
+	}
+	for rest := value; ; {
+		index := strings.Index(rest, "url(")
+		if index < 0 {
+			return true
+		}
+		rest = strings.TrimLeft(rest[index+len("url("):], `"'`)
+		if !strings.HasPrefix(rest, "#") {
+			return false
+		}
+	}
+}
+
+// svgDimensions reads the dimensions of the root element.
+func svgDimensions(attrs []xml.Attr) (image.Config, error) {
+	var width, height, viewBox string
+	for _, attr := range attrs {
+		if attr.Name.Space != "" {
+			continue
+		}
+		switch attr.Name.Local {
+		case "width":
+			width = attr.Value
+		case "height":
+			height = attr.Value
+		case "viewBox":
+			viewBox = attr.Value
+		}
+	}
+
+	imageConfig := image.Config{Width: svgLength(width), Height: svgLength(height)}
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 {
+		fields := strings.FieldsFunc(viewBox, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+		if len(fields) == 4 {
+			imageConfig.Width = svgLength(fields[2])
+			imageConfig.Height = svgLength(fields[3])
+		}
+	}
+
+	if imageConfig.Width <= 0 || imageConfig.Height <= 0 {
+		return imageConfig, ErrNoDimensions
+	}
+	return imageConfig, nil
+}
+
+// svgLength rounds up an absolute length in user units or pixels, and
+// returns 0 for the others, e.g. percentages.
+func svgLength(value string) int {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	length, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(length) || math.IsInf(length, 0) || length > math.MaxInt32 {
+		return 0
+	}
+	return int(math.Ceil(length))
+}
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// The states of the archived objects, see TierManager.
+const (
+	ObjectAvailable = "available"
+	ObjectArchived  = "archived"
+	ObjectRestoring = "restoring"
+)
+
+// TierManager is implemented by the storage backends that move objects
+// between storage classes, some of which archive the objects until they are
+// restored. The saved files are in the standard class, the content
+// addressed objects of other classes being written again.
+type TierManager interface {
+	SetStorageClass(string, s3types.StorageClass) error
+	GetRestoreState(string) (string, error)
+	Restore(string, int32) error
+}
+
+// SetStorageClass copies the object onto itself in the storage class. The
+// archived objects have to be restored beforehand.
+func (s *s3ImageStorage) SetStorageClass(destination string, class s3types.StorageClass) error {
+	key := s.prefix + destination
+	source := s.bucket + "/" + key
+
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            &s.bucket,
+		Key:               &key,
+		CopySource:        &source,
+		StorageClass:      class,
+		MetadataDirective: s3types.MetadataDirectiveCopy,
+	})
+	return err
+}
+
+// GetRestoreState tells whether the object can be read, is archived, or is
+// being restored.
+func (s *s3ImageStorage) GetRestoreState(destination string) (string, error) {
+	key := s.prefix + destination
+
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return "", &S3NotFoundError{Key: destination}
+		}
+		return "", &S3DownloadError{Key: destination, Err: err}
+	}
+
+	return restoreState(head.StorageClass, head.Restore), nil
+}
+
+// restoreState reads the x-amz-restore header of an object, e.g.
+// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT" once
+// the restored copy is ready.
+func restoreState(class s3types.StorageClass, restore *string) string {
+	if class != s3types.StorageClassGlacier && class != s3types.StorageClassDeepArchive {
+		return ObjectAvailable
+	}
+	if restore == nil {
+		return ObjectArchived
+	}
+	if strings.Contains(*restore, `ongoing-request="true"`) {
+		return ObjectRestoring
+	}
+	return ObjectAvailable
+}
+
+// Restore starts a standard retrieval of the archived object, whose restored
+// copy is kept for days.
+func (s *s3ImageStorage) Restore(destination string, days int32) error {
+	key := s.prefix + destination
+
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.client.RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		RestoreRequest: &s3types.RestoreRequest{
+			Days:                 &days,
+			GlacierJobParameters: &s3types.GlacierJobParameters{Tier: s3types.TierStandard},
+		},
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		// a second request while the first one runs changes nothing
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "RestoreAlreadyInProgress" {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/spf13/viper"
+)
+
+const cfgS3OperationTimeout = "storage.s3.operationTimeout"
+
+const defaultS3OperationTimeout = 30 * time.Second
+
+// s3OperationTimeout reads storage.s3.operationTimeout, in seconds.
+func s3OperationTimeout() time.Duration {
+	timeout := time.Duration(viper.GetInt(cfgS3OperationTimeout)) * time.Second
+	if timeout <= 0 {
+		return defaultS3OperationTimeout
+	}
+	return timeout
+}
+
+// operationContext bounds an S3 call by storage.s3.operationTimeout, on top
+// of the cancellation of ctx, e.g. by the client of the request.
+func (s *s3ImageStorage) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.operationTimeout)
+}
+
+// getObject downloads the object. The timeout bounds the request up to the
+// response rather than the reading of the body, which may be streamed to a
+// slow client, and is released when the body is closed.
+func (s *s3ImageStorage) getObject(ctx context.Context, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	timer := time.AfterFunc(s.operationTimeout, cancel)
+	output, err := s.client.GetObject(ctx, input)
+	timer.Stop()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	output.Body = &cancelOnClose{ReadCloser: output.Body, cancel: cancel}
+	return output, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"fmt"
+	"image/png"
+	"io"
+
+	"imagenexus/imaging"
+)
+
+// verifyImage decodes the whole image, where the CONTENT_DECODERS only read
+// its header, and re-encodes a 1x1 thumbnail of it. It rejects polyglot
+// files that start like an image but carry something else, e.g. an SVG
+// crafted to be served back as image/jpeg.
+func verifyImage(src io.Reader, contentType string) error {
+	// SVGs have nothing to rasterize, and are stored as rewritten by
+	// sanitizeSVG anyway
+	if contentType == svgContentType {
+		return nil
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := DecodeImage(data, contentType)
+	if err != nil {
+		return fmt.Errorf("invalid image data: %w", err)
+	}
+
+	if err := png.Encode(io.Discard, imaging.Thumbnail(decoded, 1)); err != nil {
+		return fmt.Errorf("cannot re-encode image: %w", err)
+	}
+	return nil
+}
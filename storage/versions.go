@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"imagenexus/dto"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// VersionManager is implemented by the storage backends that may keep the
+// previous versions of stored objects.
+type VersionManager interface {
+	// Versioning tells whether the previous versions are kept
+	Versioning() bool
+	// ListVersions lists the versions of the object, the latest first
+	ListVersions(string) ([]*dto.PictureVersion, error)
+	// GetVersion opens a version of the object, along with its content type
+	GetVersion(string, string) (io.ReadCloser, string, error)
+}
+
+// Versioning tells whether storage.s3.versioning is enabled.
+func (s *s3ImageStorage) Versioning() bool {
+	return s.versioning
+}
+
+// enableVersioning turns on versioning of the bucket, so objects that are
+// overwritten are kept as previous versions instead of being lost.
+func (s *s3ImageStorage) enableVersioning() error {
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: &s.bucket,
+		VersioningConfiguration: &s3types.VersioningConfiguration{
+			Status: s3types.BucketVersioningStatusEnabled,
+		},
+	})
+	return err
+}
+
+func (s *s3ImageStorage) ListVersions(destination string) ([]*dto.PictureVersion, error) {
+	key := s.prefix + destination
+	versions := []*dto.PictureVersion{}
+
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: &s.bucket,
+		Prefix: &key,
+	})
+	for paginator.HasMorePages() {
+		ctx, cancel := s.operationContext(context.Background())
+		page, err := paginator.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return nil, &S3DownloadError{Key: destination, Err: err}
+		}
+
+		for _, eachVersion := range page.Versions {
+			// the prefix also matches longer keys
+			if eachVersion.Key == nil || *eachVersion.Key != key {
+				continue
+			}
+			versions = append(versions, toPictureVersion(eachVersion))
+		}
+	}
+
+	return versions, nil
+}
+
+func (s *s3ImageStorage) GetVersion(destination, versionId string) (io.ReadCloser, string, error) {
+	key := s.prefix + destination
+
+	resp, err := s.getObject(context.Background(), &s3.GetObjectInput{
+		Bucket:    &s.bucket,
+		Key:       &key,
+		VersionId: &versionId,
+	})
+	if err != nil {
+		var apiErr interface{ ErrorCode() string }
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NoSuchKey" || apiErr.ErrorCode() == "NoSuchVersion") {
+			return nil, "", &S3NotFoundError{Key: destination}
+		}
+		return nil, "", &S3DownloadError{Key: destination, Err: err}
+	}
+
+	contentType := ""
+	if resp.ContentType != nil {
+		contentType = *resp.ContentType
+	}
+	return resp.Body, contentType, nil
+}
+
+func toPictureVersion(version s3types.ObjectVersion) *dto.PictureVersion {
+	response := &dto.PictureVersion{}
+	if version.VersionId != nil {
+		response.VersionId = *version.VersionId
+	}
+	if version.IsLatest != nil {
+		response.IsLatest = *version.IsLatest
+	}
+	if version.Size != nil {
+		response.Size = *version.Size
+	}
+	if version.LastModified != nil {
+		response.LastModified = *version.LastModified
+	}
+	return response
+}
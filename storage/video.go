@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// VideoContentTypes are the accepted video formats, see validation.DetectContentType.
+var VideoContentTypes = []string{"video/mp4", "video/quicktime", "video/webm"}
+
+func IsVideo(contentType string) bool {
+	return slices.Contains(VideoContentTypes, contentType)
+}
+
+// VideoStorage keeps the uploaded videos as they are, apart from the
+// pictures of their first frames. Like the pictures, the videos are stored
+// under the SHA-256 of their contents.
+type VideoStorage interface {
+	// ctx cancels the uploads to S3
+	SaveVideo(context.Context, string, string, io.ReadSeeker) (string, error)
+	GetReader(string) (io.ReadSeekCloser, error)
+	Delete(string) error
+}
+
+type localVideoStorage struct {
+	*localImageStorage
+}
+
+func NewLocalVideoStorage(path string) VideoStorage {
+	return &localVideoStorage{NewStorage(path).(*localImageStorage)}
+}
+
+// SaveVideo stores the video, keeping the extension of filename, and returns
+// its destination.
+func (s *localVideoStorage) SaveVideo(_ context.Context, filename, _ string, src io.ReadSeeker) (string, error) {
+	checksum, _, err := checksumVideo(src)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath, err := s.resolvePath(contentAddress(checksum, filename))
+	if err != nil {
+		return "", err
+	}
+
+	// identical contents are already stored under the same destination
+	if _, err := os.Stat(fullPath); err == nil {
+		return contentAddress(checksum, filename), nil
+	}
+
+	out, err := os.CreateTemp(s.path, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return "", err
+	}
+	if err := out.Sync(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(out.Name(), fullPath); err != nil {
+		return "", err
+	}
+
+	if err := verifyFile(fullPath, checksum); err != nil {
+		os.Remove(fullPath)
+		return "", err
+	}
+	return contentAddress(checksum, filename), nil
+}
+
+type s3VideoStorage struct {
+	*s3ImageStorage
+}
+
+// NewS3VideoStorage stores the videos in the configured bucket, under the
+// prefix rather than the one of the pictures.
+func NewS3VideoStorage(prefix string) (VideoStorage, error) {
+	storage, err := newS3ImageStorage(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &s3VideoStorage{storage}, nil
+}
+
+func (s *s3VideoStorage) SaveVideo(ctx context.Context, filename, contentType string, src io.ReadSeeker) (string, error) {
+	checksum, md5Checksum, err := checksumVideo(src)
+	if err != nil {
+		return "", err
+	}
+	destination := contentAddress(checksum, filename)
+	key := s.prefix + destination
+
+	exists, err := s.exists(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("s3 head failed: %w", err)
+	}
+	// identical contents are already stored under the same key
+	if exists {
+		return destination, nil
+	}
+
+	uploadCtx, cancel := s.operationContext(ctx)
+	defer cancel()
+	output, err := s.uploader.Upload(uploadCtx, &s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        src,
+		ContentType: &contentType,
+		ACL:         s3types.ObjectCannedACLPrivate,
+	})
+	if err != nil {
+		return "", fmt.Errorf("s3 upload failed: %w", err)
+	}
+
+	if err := verifyETag(output.ETag, destination, md5Checksum); err != nil {
+		return "", err
+	}
+	return destination, nil
+}
+
+// checksumVideo returns the SHA-256 and MD5 of the video, rewound for the
+// next reader.
+func checksumVideo(src io.ReadSeeker) (string, string, error) {
+	hasher, md5Hasher := sha256.New(), md5.New()
+	if _, err := hashContents(src, hasher, md5Hasher); err != nil {
+		return "", "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), hex.EncodeToString(md5Hasher.Sum(nil)), nil
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// FileWriter is implemented by the storage backends that write files at a
+// given destination, overwriting any file already there, instead of the
+// destinations named by SaveReader. It's used for the files
+// derived from the pictures, e.g. their deep zoom tiles.
+type FileWriter interface {
+	Put(string, string, io.Reader) error
+}
+
+// Put writes the file to a temporary file moved in place afterwards, so
+// readers never see a partial file.
+func (s *localImageStorage) Put(destination, _ string, src io.Reader) error {
+	fullPath, err := s.resolvePath(destination)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(fullPath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := io.Copy(out, src); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), fullPath)
+}
+
+func (s *s3ImageStorage) Put(destination, contentType string, src io.Reader) error {
+	key := s.prefix + destination
+	ctx, cancel := s.operationContext(context.Background())
+	defer cancel()
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        src,
+		ContentType: &contentType,
+		ACL:         s3types.ObjectCannedACLPrivate,
+	})
+	return err
+}
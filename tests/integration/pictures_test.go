@@ -0,0 +1,147 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"imagenexus/api/restutil"
+	"imagenexus/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPNG(width, height int) []byte {
+	var content bytes.Buffer
+	png.Encode(&content, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return content.Bytes()
+}
+
+// decodeResponse reads the data and meta of the response envelope.
+func decodeResponse(t *testing.T, response *http.Response, data any) *dto.ResponseMeta {
+	t.Helper()
+	defer response.Body.Close()
+
+	envelope := struct {
+		Data any               `json:"data"`
+		Meta *dto.ResponseMeta `json:"meta"`
+	}{Data: data}
+	assert.Nil(t, json.NewDecoder(response.Body).Decode(&envelope))
+	return envelope.Meta
+}
+
+func TestPicturesAPI(t *testing.T) {
+	server := newTestServer(t)
+	content := newTestPNG(8, 6)
+
+	var created dto.PictureResponse
+	t.Run("upload", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, _ := writer.CreateFormFile("image", "cat.png")
+		part.Write(content)
+		writer.WriteField("description", "a cat")
+		writer.Close()
+
+		response, err := http.Post(server.URL+"/v1/", writer.FormDataContentType(), &body)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusCreated, response.StatusCode)
+
+		decodeResponse(t, response, &created)
+		assert.NotZero(t, created.Id)
+		assert.Equal(t, "image/png", created.ContentType)
+		assert.Equal(t, int32(8), created.Width)
+		assert.Equal(t, int32(6), created.Height)
+		assert.Equal(t, "a cat", created.Description)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		response, err := http.Get(fmt.Sprintf("%s/v1/picture/%d", server.URL, created.Id))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		var picture dto.PictureResponse
+		decodeResponse(t, response, &picture)
+		assert.Equal(t, created.Id, picture.Id)
+		assert.Equal(t, created.Checksum, picture.Checksum)
+		assert.Equal(t, "public, max-age=3600", response.Header.Get("Cache-Control"))
+
+		response, err = http.Get(fmt.Sprintf("%s/v1/picture/%d/image", server.URL, created.Id))
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		stored, err := io.ReadAll(response.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, content, stored)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		response, err := http.Get(server.URL + "/v1/")
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		var pictures []*dto.PictureResponse
+		meta := decodeResponse(t, response, &pictures)
+		assert.Equal(t, 1, meta.Total)
+		assert.Nil(t, meta.Cursor)
+		if assert.Len(t, pictures, 1) {
+			assert.Equal(t, created.Id, pictures[0].Id)
+		}
+		assert.Equal(t, "public, max-age=60, stale-while-revalidate=300", response.Header.Get("Cache-Control"))
+		assert.Equal(t, "max-age=60, stale-while-revalidate=300", response.Header.Get("Surrogate-Control"))
+
+		etag := response.Header.Get("ETag")
+		assert.NotEmpty(t, etag)
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/v1/", nil)
+		request.Header.Set("If-None-Match", etag)
+		response, err = http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusNotModified, response.StatusCode)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/v1/picture/%d", server.URL, created.Id), nil)
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		response.Body.Close()
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		response, err = http.Get(fmt.Sprintf("%s/v1/picture/%d", server.URL, created.Id))
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+		assert.Equal(t, restutil.ProblemContentType, response.Header.Get("Content-Type"))
+
+		var problem dto.Problem
+		assert.Nil(t, json.NewDecoder(response.Body).Decode(&problem))
+		assert.Equal(t, restutil.NotFoundProblemType, problem.Type)
+	})
+
+	t.Run("audit log", func(t *testing.T) {
+		request, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/admin/audit-log?entity_id=%d", server.URL, created.Id), nil)
+		request.Header.Set("Authorization", newTestToken(t, "admin-1", "admin"))
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		var entries []*dto.AuditLogEntry
+		meta := decodeResponse(t, response, &entries)
+		assert.Equal(t, 2, meta.Total)
+		if assert.Len(t, entries, 2) {
+			assert.Equal(t, "delete", entries[0].Action)
+			assert.NotEmpty(t, entries[0].OldValue)
+			assert.Equal(t, "create", entries[1].Action)
+			assert.Equal(t, created.Id, entries[1].EntityId)
+		}
+	})
+}
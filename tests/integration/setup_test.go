@@ -0,0 +1,108 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"imagenexus/api/middleware"
+	"imagenexus/app"
+	"imagenexus/config"
+	"imagenexus/db"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/localstack"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const testBucket = "imagenexus-test"
+
+// testJWTSecret signs the tokens of the tests, see newTestToken.
+const testJWTSecret = "imagenexus-test-secret"
+
+// newTestServer serves the whole api on a PostgreSQL container and an S3
+// bucket of a LocalStack container, which are stopped with the test.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+
+	postgresContainer, err := postgres.RunContainer(ctx,
+		testcontainers.WithImage("postgres:11.6"),
+		postgres.WithDatabase("picturesdb"),
+		postgres.WithUsername("master_user"),
+		postgres.WithPassword("master_password"),
+		testcontainers.WithWaitStrategy(wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(time.Minute)),
+	)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { postgresContainer.Terminate(ctx) })
+
+	localstackContainer, err := localstack.RunContainer(ctx, testcontainers.WithImage("localstack/localstack:3"))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { localstackContainer.Terminate(ctx) })
+
+	exitCode, _, err := localstackContainer.Exec(ctx, []string{"awslocal", "s3", "mb", "s3://" + testBucket})
+	if !assert.Nil(t, err) || !assert.Equal(t, 0, exitCode) {
+		t.FailNow()
+	}
+
+	postgresHost, _ := postgresContainer.Host(ctx)
+	postgresPort, _ := postgresContainer.MappedPort(ctx, "5432/tcp")
+	localstackHost, _ := localstackContainer.Host(ctx)
+	localstackPort, _ := localstackContainer.MappedPort(ctx, "4566/tcp")
+
+	t.Setenv("IMAGENEXUS_POSTGRES_HOST", postgresHost)
+	t.Setenv("IMAGENEXUS_POSTGRES_PORT", postgresPort.Port())
+	t.Setenv("IMAGENEXUS_STORAGE_BACKEND", "s3")
+	t.Setenv("IMAGENEXUS_STORAGE_S3_BUCKET", testBucket)
+	t.Setenv("IMAGENEXUS_STORAGE_S3_ENDPOINT", "http://"+localstackHost+":"+localstackPort.Port())
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("IMAGENEXUS_SERVER_AUTH_JWTSECRET", testJWTSecret)
+
+	if !assert.Nil(t, config.Init("config", "../../")) {
+		t.FailNow()
+	}
+
+	dbConfig := db.NewConfiguration()
+	dbHandler, err := db.NewConnection(dbConfig)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	imageStorage, err := app.NewImageStorage()
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(app.NewRouter(dbHandler, dbConfig, imageStorage, nil))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestToken returns a bearer token of the user with the role.
+func newTestToken(t *testing.T, subject, role string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, &middleware.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Role:             role,
+	}).SignedString([]byte(testJWTSecret))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	return "Bearer " + token
+}
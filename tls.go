@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"imagenexus/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listenAndServeTLS serves the api over HTTPS, either with the configured
+// certificate files or with Let's Encrypt certificates obtained through
+// autocert. A plain HTTP listener on server.tls.redirectPort redirects to the
+// HTTPS port.
+func listenAndServeTLS(handler http.Handler, port int) error {
+	redirectPort, err := strconv.Atoi(config.GetConfigValue("server.tls.redirectPort"))
+	if err != nil {
+		return fmt.Errorf("unable to parse tls redirect port: %w", err)
+	}
+
+	redirect := httpsRedirectHandler(port)
+
+	if config.GetConfigBool("server.tls.autocert.enabled") {
+		domain := config.GetConfigValue("server.tls.autocert.domain")
+		if domain == "" {
+			return fmt.Errorf("server.tls.autocert.domain is required when autocert is enabled")
+		}
+
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domain),
+			Cache:      autocert.DirCache(config.GetConfigValue("server.tls.autocert.cacheDir")),
+		}
+
+		// the redirect listener also answers the ACME http-01 challenges
+		go serveRedirect(redirectPort, manager.HTTPHandler(redirect))
+
+		server := &http.Server{
+			Addr:      fmt.Sprintf(":%d", port),
+			Handler:   handler,
+			TLSConfig: manager.TLSConfig(),
+		}
+		log.Printf("API service running with autocert for %s on port: %d", domain, port)
+		return server.ListenAndServeTLS("", "")
+	}
+
+	go serveRedirect(redirectPort, redirect)
+
+	log.Printf("API service running with TLS on port: %d", port)
+	return http.ListenAndServeTLS(
+		fmt.Sprintf(":%d", port),
+		config.GetConfigValue("server.tls.certFile"),
+		config.GetConfigValue("server.tls.keyFile"),
+		handler,
+	)
+}
+
+func serveRedirect(port int, handler http.Handler) {
+	log.Printf("HTTP to HTTPS redirect running on port: %d", port)
+	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), handler); err != nil {
+		log.Println("HTTP redirect server stopped: ", err)
+	}
+}
+
+func httpsRedirectHandler(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
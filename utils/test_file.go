@@ -1,6 +1,10 @@
 package utils
 
-import "mime/multipart"
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+)
 
 func NewTestFile(fileName string) *multipart.FileHeader {
 	return &multipart.FileHeader{
@@ -8,3 +12,46 @@ func NewTestFile(fileName string) *multipart.FileHeader {
 		Size:     1000,
 	}
 }
+
+// NewTestFileWithContent builds a file header that can be opened and read,
+// the way it would be received from a multipart form upload.
+func NewTestFileWithContent(fileName string, content []byte) *multipart.FileHeader {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("image", fileName)
+	part.Write(content)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(int64(len(content)) + 1024)
+	if err != nil {
+		panic(err)
+	}
+	return form.File["image"][0]
+}
+
+// NewTestPDF builds a PDF of blank width x height pages, in points.
+func NewTestPDF(pages, width, height int) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for i := 0; i < pages; i++ {
+		kids += fmt.Sprintf("%d 0 R ", len(objects)+1)
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] >>", width, height))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages)
+
+	var content bytes.Buffer
+	content.WriteString("%PDF-1.4\n")
+	offsets := make([]int, 0, len(objects))
+	for i, object := range objects {
+		offsets = append(offsets, content.Len())
+		fmt.Fprintf(&content, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := content.Len()
+	fmt.Fprintf(&content, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&content, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&content, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return content.Bytes()
+}
@@ -6,8 +6,10 @@ import (
 	"github.com/google/uuid"
 )
 
+// NewUniqueString returns a UUID v7, which is time-ordered so the strings
+// sort lexicographically by creation time.
 func NewUniqueString() string {
-	return uuid.New().String()
+	return uuid.Must(uuid.NewV7()).String()
 }
 
 func NewRandomNumber(min, max int) int {
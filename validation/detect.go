@@ -0,0 +1,110 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// The brands of the ftyp box of the HEIF based formats.
+var (
+	avifBrands      = [][]byte{[]byte("avif"), []byte("avis")}
+	heicBrands      = [][]byte{[]byte("heic"), []byte("heix"), []byte("hevc"), []byte("hevx"), []byte("heim"), []byte("heis")}
+	quickTimeBrands = [][]byte{[]byte("qt  ")}
+)
+
+// DetectContentType extends http.DetectContentType with the formats it
+// doesn't sniff: TIFF, SVG, QuickTime videos, and the HEIF based AVIF and
+// HEIC. Like it, it considers at most the first 512 bytes and falls back to
+// application/octet-stream.
+func DetectContentType(data []byte) string {
+	if len(data) > 512 {
+		data = data[:512]
+	}
+
+	switch {
+	case isTIFF(data):
+		return "image/tiff"
+	case isAVIF(data):
+		return "image/avif"
+	case isHEIC(data):
+		return "image/heic"
+	case hasFtypBrand(data, quickTimeBrands):
+		return "video/quicktime"
+	case isSVG(data):
+		return SVGContentType
+	}
+	return http.DetectContentType(data)
+}
+
+func isTIFF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*"))
+}
+
+func isAVIF(data []byte) bool {
+	return hasFtypBrand(data, avifBrands)
+}
+
+func isHEIC(data []byte) bool {
+	return hasFtypBrand(data, heicBrands)
+}
+
+// isSVG tells whether the root element of the XML document is an svg, past
+// the XML declaration, comments and doctype that may precede it.
+func isSVG(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n")
+		var end []byte
+		switch {
+		case bytes.HasPrefix(data, []byte("<?")):
+			end = []byte("?>")
+		case bytes.HasPrefix(data, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(data, []byte("<!")):
+			end = []byte(">")
+		default:
+			if !bytes.HasPrefix(data, []byte("<svg")) || len(data) < 5 {
+				return false
+			}
+			return strings.IndexByte(" \t\r\n/>", data[4]) >= 0
+		}
+
+		index := bytes.Index(data, end)
+		if index < 0 {
+			return false
+		}
+		data = data[index+len(end):]
+	}
+}
+
+// hasFtypBrand tells whether the data starts with an ISO base media ftyp box
+// whose major or compatible brands include one of the brands.
+func hasFtypBrand(data []byte, brands [][]byte) bool {
+	if len(data) < 12 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+
+	boxSize := binary.BigEndian.Uint32(data[:4])
+	if boxSize < 12 || boxSize%4 != 0 {
+		return false
+	}
+	end := len(data)
+	if int(boxSize) < end {
+		end = int(boxSize)
+	}
+
+	for offset := 8; offset+4 <= end; offset += 4 {
+		// the minor version follows the major brand
+		if offset == 12 {
+			continue
+		}
+		for _, brand := range brands {
+			if bytes.Equal(data[offset:offset+4], brand) {
+				return true
+			}
+		}
+	}
+	return false
+}
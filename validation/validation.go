@@ -0,0 +1,91 @@
+// Package validation checks the uploaded files the way the server does
+// before storing them, without any storage or configuration, so it also
+// builds for the browsers, see wasm/main.go.
+package validation
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+const (
+	SVGContentType = "image/svg+xml"
+	PDFContentType = "application/pdf"
+)
+
+var ErrNoDimensions = errors.New("image has no width or height")
+
+var ErrUnsupportedFormat = errors.New("unsupported format")
+
+// CONTENT_DECODERS read the dimensions of the raster formats. The storage
+// adds the PDFs, which need a native library.
+var CONTENT_DECODERS = map[string](func(r io.Reader) (image.Config, error)){
+	"image/jpeg": WithDimensions(jpeg.DecodeConfig),
+	"image/png":  WithDimensions(png.DecodeConfig),
+	"image/gif":  WithDimensions(gif.DecodeConfig),
+	"image/tiff": WithDimensions(tiff.DecodeConfig),
+	"image/webp": WithDimensions(webp.DecodeConfig),
+	"image/bmp":  WithDimensions(bmp.DecodeConfig),
+}
+
+// WithDimensions rejects the images without pixels, which some decoders
+// accept, e.g. a TIFF without its width and height tags.
+func WithDimensions(decodeConfig func(io.Reader) (image.Config, error)) func(io.Reader) (image.Config, error) {
+	return func(r io.Reader) (image.Config, error) {
+		imageConfig, err := decodeConfig(r)
+		if err == nil && (imageConfig.Width <= 0 || imageConfig.Height <= 0) {
+			return imageConfig, ErrNoDimensions
+		}
+		return imageConfig, err
+	}
+}
+
+type ValidationResult struct {
+	Valid       bool   `json:"valid"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ValidateImage detects the format of the file and reads the dimensions of
+// the raster images, like the server does on upload. SVGs and PDFs are only
+// detected, their contents are checked by the server.
+func ValidateImage(data []byte) ValidationResult {
+	contentType := DetectContentType(data)
+	result := ValidationResult{ContentType: contentType}
+	if len(data) == 0 {
+		result.Error = "empty file"
+		return result
+	}
+
+	if contentType == SVGContentType || contentType == PDFContentType {
+		result.Valid = true
+		return result
+	}
+
+	decoder, ok := CONTENT_DECODERS[contentType]
+	if !ok {
+		result.Error = ErrUnsupportedFormat.Error()
+		return result
+	}
+
+	imageConfig, err := decoder(bytes.NewReader(data))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Valid = true
+	result.Width, result.Height = imageConfig.Width, imageConfig.Height
+	return result
+}
@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ftypHeader builds the ftyp box starting the HEIF, MP4 and QuickTime files.
+func ftypHeader(majorBrand string, compatibleBrands ...string) []byte {
+	box := []byte{0, 0, 0, byte(16 + 4*len(compatibleBrands))}
+	box = append(box, "ftyp"+majorBrand+"\x00\x00\x00\x00"...)
+	for _, brand := range compatibleBrands {
+		box = append(box, brand...)
+	}
+	return box
+}
+
+var contentTypeHeaders = map[string][]byte{
+	"image/jpeg":      []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00"),
+	"image/png":       []byte("\x89PNG\x0D\x0A\x1A\x0A\x00\x00\x00\x0DIHDR"),
+	"image/gif":       []byte("GIF89a\x01\x00\x01\x00"),
+	"image/tiff":      []byte("II*\x00\x08\x00\x00\x00"),
+	"image/webp":      []byte("RIFF\x24\x00\x00\x00WEBPVP8 "),
+	"image/bmp":       []byte("BM\x36\x00\x00\x00\x00\x00"),
+	"image/avif":      ftypHeader("avif", "mif1", "miaf"),
+	"image/heic":      ftypHeader("mif1", "mif1", "heic"),
+	"video/mp4":       ftypHeader("isom", "isom", "mp41"),
+	"video/quicktime": ftypHeader("qt  ", "qt  "),
+	"video/webm":      []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01"),
+	"image/svg+xml":   []byte("<?xml version=\"1.0\"?>\n<!-- drawn by hand -->\n<!DOCTYPE svg>\n<svg xmlns=\"http://www.w3.org/2000/svg\">"),
+}
+
+func TestDetectContentType(t *testing.T) {
+	for contentType, header := range contentTypeHeaders {
+		assert.Equal(t, contentType, DetectContentType(header), contentType)
+	}
+
+	assert.Equal(t, "image/tiff", DetectContentType([]byte("MM\x00*\x00\x00\x00\x08")))
+	// the brands after the end of the box don't count
+	assert.Equal(t, "application/octet-stream", DetectContentType(append(ftypHeader("mif1"), "heic"...)))
+	assert.Equal(t, "image/svg+xml", DetectContentType([]byte("\xef\xbb\xbf  <svg/>")))
+	assert.Equal(t, "text/xml; charset=utf-8", DetectContentType([]byte(`<?xml version="1.0"?><svgfont/>`)))
+}
+
+func FuzzDetectContentType(f *testing.F) {
+	for _, header := range contentTypeHeaders {
+		f.Add(header)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		isAVIF(data)
+		isHEIC(data)
+		if DetectContentType(data) == "" {
+			t.Errorf("no content type detected for %q", data)
+		}
+	})
+}
+
+func TestValidateImage(t *testing.T) {
+	var encoded bytes.Buffer
+	png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 30, 20)))
+
+	result := ValidateImage(encoded.Bytes())
+	assert.Equal(t, ValidationResult{Valid: true, ContentType: "image/png", Width: 30, Height: 20}, result)
+
+	result = ValidateImage(encoded.Bytes()[:20])
+	assert.False(t, result.Valid)
+	assert.Equal(t, "image/png", result.ContentType)
+	assert.NotEmpty(t, result.Error)
+
+	result = ValidateImage([]byte("II*\x00\x08\x00\x00\x00\x00\x00\x00\x00"))
+	assert.False(t, result.Valid)
+	assert.Equal(t, "image/tiff", result.ContentType)
+
+	result = ValidateImage([]byte("just some text"))
+	assert.False(t, result.Valid)
+	assert.Equal(t, ErrUnsupportedFormat.Error(), result.Error)
+
+	assert.True(t, ValidateImage(contentTypeHeaders[SVGContentType]).Valid)
+	assert.False(t, ValidateImage(nil).Valid)
+}
@@ -0,0 +1,136 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"imagenexus/dto"
+)
+
+var ErrNoVideoStream = errors.New("the file has no video stream")
+
+// Extractor reads what the pictures of the uploaded videos are made of. It
+// works on files rather than streams since MP4 and QuickTime files may keep
+// their index at the end.
+type Extractor interface {
+	Probe(string) (*dto.VideoMetadata, error)
+	FirstFrame(string) ([]byte, error)
+}
+
+// ffmpegExtractor runs the ffprobe and ffmpeg commands, each bounded by
+// timeout.
+type ffmpegExtractor struct {
+	ffprobe string
+	ffmpeg  string
+	timeout time.Duration
+}
+
+func NewExtractor(ffprobe, ffmpeg string, timeout time.Duration) Extractor {
+	return &ffmpegExtractor{ffprobe: ffprobe, ffmpeg: ffmpeg, timeout: timeout}
+}
+
+// Probe reads the duration of the video and the frame rate and codec of its
+// first video stream.
+func (e *ffmpegExtractor) Probe(path string) (*dto.VideoMetadata, error) {
+	output, err := e.run(e.ffprobe,
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name,avg_frame_rate,r_frame_rate:format=duration",
+		"-of", "json",
+		path,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return parseProbe(output)
+}
+
+// FirstFrame encodes the first frame of the video as a JPEG.
+func (e *ffmpegExtractor) FirstFrame(path string) ([]byte, error) {
+	return e.run(e.ffmpeg,
+		"-v", "error",
+		"-i", path,
+		"-frames:v", "1",
+		"-f", "image2",
+		"-c:v", "mjpeg",
+		"pipe:1",
+	)
+}
+
+func (e *ffmpegExtractor) run(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, message)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecName    string `json:"codec_name"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+}
+
+func parseProbe(output []byte) (*dto.VideoMetadata, error) {
+	var probe probeOutput
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return nil, ErrNoVideoStream
+	}
+	stream := probe.Streams[0]
+
+	// WebM files often leave the average frame rate out
+	frameRate := parseFrameRate(stream.AvgFrameRate)
+	if frameRate == 0 {
+		frameRate = parseFrameRate(stream.RFrameRate)
+	}
+
+	duration, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	return &dto.VideoMetadata{
+		DurationSeconds: duration,
+		FrameRate:       frameRate,
+		VideoCodec:      stream.CodecName,
+	}, nil
+}
+
+// parseFrameRate reads the rationals ffprobe gives the frame rates as, e.g.
+// 30000/1001, and returns 0 for the unknown ones, 0/0.
+func parseFrameRate(value string) float64 {
+	numerator, denominator, found := strings.Cut(value, "/")
+	if !found {
+		denominator = "1"
+	}
+
+	n, err := strconv.ParseFloat(numerator, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(denominator, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
+}
@@ -0,0 +1,64 @@
+package video
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"imagenexus/dto"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbe(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		metadata *dto.VideoMetadata
+		err      error
+	}{
+		{
+			name:     "mp4",
+			output:   `{"streams": [{"codec_name": "h264", "avg_frame_rate": "30000/1001", "r_frame_rate": "30000/1001"}], "format": {"duration": "12.512000"}}`,
+			metadata: &dto.VideoMetadata{DurationSeconds: 12.512, FrameRate: 30000.0 / 1001, VideoCodec: "h264"},
+		},
+		{
+			name:     "webm without average frame rate",
+			output:   `{"streams": [{"codec_name": "vp9", "avg_frame_rate": "0/0", "r_frame_rate": "25/1"}], "format": {"duration": "3.0"}}`,
+			metadata: &dto.VideoMetadata{DurationSeconds: 3, FrameRate: 25, VideoCodec: "vp9"},
+		},
+		{
+			name:     "unknown duration",
+			output:   `{"streams": [{"codec_name": "hevc", "avg_frame_rate": "60"}], "format": {}}`,
+			metadata: &dto.VideoMetadata{FrameRate: 60, VideoCodec: "hevc"},
+		},
+		{name: "audio only", output: `{"streams": [], "format": {"duration": "1.0"}}`, err: ErrNoVideoStream},
+		{name: "not json", output: `Invalid data found when processing input`},
+	}
+
+	for _, each := range cases {
+		t.Run(each.name, func(t *testing.T) {
+			metadata, err := parseProbe([]byte(each.output))
+			if each.metadata == nil {
+				assert.NotNil(t, err)
+				if each.err != nil {
+					assert.ErrorIs(t, err, each.err)
+				}
+				return
+			}
+
+			assert.Nil(t, err)
+			assert.Equal(t, each.metadata, metadata)
+		})
+	}
+}
+
+func TestMissingCommands(t *testing.T) {
+	extractor := NewExtractor("imagenexus-missing-ffprobe", "imagenexus-missing-ffmpeg", time.Second)
+
+	_, err := extractor.Probe("video.mp4")
+	assert.ErrorIs(t, err, exec.ErrNotFound)
+
+	_, err = extractor.FirstFrame("video.mp4")
+	assert.ErrorIs(t, err, exec.ErrNotFound)
+}
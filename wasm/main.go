@@ -0,0 +1,47 @@
+//go:build js && wasm
+
+// Command wasm exports the upload validation of the server to JavaScript,
+// so clients can check the files before uploading them. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o imagenexus.wasm ./wasm
+//
+// or make wasm, and load it with the wasm_exec.js of the Go distribution.
+// It defines ValidateImage(bytes), taking a Uint8Array and returning an
+// object like the ValidationResult.
+package main
+
+import (
+	"syscall/js"
+
+	"imagenexus/validation"
+)
+
+func validateImage(_ js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeObject {
+		return toJS(validation.ValidationResult{Error: "ValidateImage expects a Uint8Array"})
+	}
+
+	data := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(data, args[0])
+	return toJS(validation.ValidateImage(data))
+}
+
+func toJS(result validation.ValidationResult) map[string]any {
+	value := map[string]any{
+		"valid":        result.Valid,
+		"content_type": result.ContentType,
+	}
+	if result.Valid {
+		value["width"] = result.Width
+		value["height"] = result.Height
+	} else {
+		value["error"] = result.Error
+	}
+	return value
+}
+
+func main() {
+	js.Global().Set("ValidateImage", js.FuncOf(validateImage))
+	// keep the exported function callable
+	select {}
+}
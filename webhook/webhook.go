@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	EventImageCreated   = "image.created"
+	EventImageProcessed = "image.processed"
+	// sent before the pictures past server.retentionDays are removed
+	EventPictureExpired = "picture.expired"
+	// sent once a day while the estimated storage cost is past
+	// storage.monthlyCostAlertThreshold
+	EventStorageCostAlert = "storage.cost_alert"
+	// sent when a served file doesn't match the checksum of its picture
+	EventStorageCorruption = "storage.corruption"
+)
+
+type Event struct {
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type Dispatcher interface {
+	Send(string, any)
+}
+
+type httpDispatcher struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+}
+
+// NewDispatcher returns a dispatcher posting events to every url. When a
+// secret is given, the body is signed with HMAC-SHA256 in the
+// X-Imagenexus-Signature header.
+func NewDispatcher(urls []string, secret string) Dispatcher {
+	return &httpDispatcher{
+		urls:   urls,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send delivers the event in the background. Delivery failures are logged
+// and never reach the caller.
+func (d *httpDispatcher) Send(event string, data any) {
+	if len(d.urls) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(Event{Event: event, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		log.Printf("Unable to encode %s webhook: %v", event, err)
+		return
+	}
+
+	for _, url := range d.urls {
+		go d.deliver(url, event, body)
+	}
+}
+
+func (d *httpDispatcher) deliver(url, event string, body []byte) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Unable to create %s webhook request: %v", event, err)
+		return
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Imagenexus-Event", event)
+	if len(d.secret) > 0 {
+		mac := hmac.New(sha256.New, d.secret)
+		mac.Write(body)
+		request.Header.Set("X-Imagenexus-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		log.Printf("Unable to deliver %s webhook to %s: %v", event, url, err)
+		return
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		log.Printf("Webhook %s to %s answered with status %d", event, url, response.StatusCode)
+	}
+}